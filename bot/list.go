@@ -3,16 +3,19 @@ package bot
 import (
 	"log"
 	"sort"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/dghubble/go-twitter/twitter"
+	"github.com/xarantolus/spacex-hop-bot/twitterv2"
 	"github.com/xarantolus/spacex-hop-bot/util"
 )
 
 type UserList struct {
 	listIDs []int64
-	c       *twitter.Client
+	// load returns the members of a list
+	load func(listID int64) ([]twitter.User, error)
 
 	purpose string
 
@@ -45,43 +48,6 @@ func (l *UserList) ContainsByID(id int64) bool {
 	return l.members[id]
 }
 
-// TweetAssociatedWithAny returns whether the given tweet is by, or mentions a user that is in this UserList
-func (l *UserList) TweetAssociatedWithAny(tweet *twitter.Tweet) bool {
-	if tweet == nil {
-		return false
-	}
-
-	if tweet.User != nil && l.ContainsByID(tweet.User.ID) {
-		return true
-	}
-
-	if tweet.QuotedStatus != nil && l.TweetAssociatedWithAny(tweet.QuotedStatus) {
-		return true
-	}
-
-	if tweet.RetweetedStatus != nil && l.TweetAssociatedWithAny(tweet.RetweetedStatus) {
-		return true
-	}
-
-	if tweet.Entities != nil {
-		for _, m := range tweet.Entities.UserMentions {
-			if l.ContainsByID(m.ID) {
-				return true
-			}
-		}
-	}
-
-	if tweet.ExtendedTweet != nil && tweet.ExtendedTweet.Entities != nil {
-		for _, m := range tweet.ExtendedTweet.Entities.UserMentions {
-			if l.ContainsByID(m.ID) {
-				return true
-			}
-		}
-	}
-
-	return false
-}
-
 func (l *UserList) update() {
 	l.mlock.RLock()
 	shouldUpdate := time.Since(l.lastUpdate) > 90*time.Minute
@@ -101,15 +67,12 @@ func (l *UserList) update() {
 	l.members = make(map[int64]bool)
 
 	for _, listID := range l.listIDs {
-		list, _, err := l.c.Lists.Members(&twitter.ListsMembersParams{
-			ListID: listID,
-			Count:  1000,
-		})
-		if util.LogError(err, "loading list members for list %d", listID) || list == nil {
+		users, err := l.load(listID)
+		if util.LogError(err, "loading list members for list %d", listID) {
 			continue
 		}
 
-		for _, user := range list.Users {
+		for _, user := range users {
 			l.members[user.ID] = true
 		}
 	}
@@ -121,8 +84,29 @@ func (l *UserList) update() {
 
 // ListMembers loads a list of all users from the lists with the given ID
 func ListMembers(c *twitter.Client, purpose string, listIDs ...int64) (membersMap *UserList) {
+	return loadListMembers(func(listID int64) ([]twitter.User, error) {
+		list, _, err := c.Lists.Members(&twitter.ListsMembersParams{
+			ListID: listID,
+			// Lists can have up to 5000 members
+			Count: 5000,
+		})
+		if err != nil || list == nil {
+			return nil, err
+		}
+		return list.Users, nil
+	}, purpose, listIDs...)
+}
+
+// ListMembersV2 is like ListMembers, but uses the v2 API
+func ListMembersV2(c *twitterv2.Client, purpose string, listIDs ...int64) (membersMap *UserList) {
+	return loadListMembers(func(listID int64) ([]twitter.User, error) {
+		return c.ListMembers(strconv.FormatInt(listID, 10))
+	}, purpose, listIDs...)
+}
+
+func loadListMembers(load func(listID int64) ([]twitter.User, error), purpose string, listIDs ...int64) (membersMap *UserList) {
 	membersMap = &UserList{
-		c:       c,
+		load:    load,
 		listIDs: listIDs,
 		purpose: purpose,
 	}
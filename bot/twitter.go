@@ -1,19 +1,81 @@
 package bot
 
 import (
+	"log"
+	"net/http"
+	"net/url"
+
 	"github.com/dghubble/go-twitter/twitter"
 	"github.com/dghubble/oauth1"
 	"github.com/xarantolus/spacex-hop-bot/config"
+	"github.com/xarantolus/spacex-hop-bot/ratelimit"
+	"github.com/xarantolus/spacex-hop-bot/twitterv2"
 )
 
 func Login(cfg config.Config) (client *twitter.Client, user *twitter.User, err error) {
+	target, err := cfg.APIBaseURL()
+	if err != nil {
+		return
+	}
+	client = twitter.NewClient(userHTTPClient(cfg, target))
+
+	user, _, err = client.Accounts.VerifyCredentials(&twitter.AccountVerifyParams{})
+
+	return
+}
+
+// LoginV2 returns a client for the Twitter API v2 and the user it acts as
+func LoginV2(cfg config.Config) (client *twitterv2.Client, user *twitter.User, err error) {
+	target, err := cfg.APIBaseURL()
+	if err != nil {
+		return
+	}
+	client = twitterv2.NewClient(userHTTPClient(cfg, target), cfg.Twitter.BearerToken, func(base http.RoundTripper) http.RoundTripper {
+		return apiTransport(target, base)
+	})
+
+	user, err = client.Me()
+
+	return
+}
+
+// userHTTPClient returns a client that signs requests in the context of the bot user
+func userHTTPClient(cfg config.Config, target *url.URL) *http.Client {
 	config := oauth1.NewConfig(cfg.Twitter.APIKey, cfg.Twitter.APISecretKey)
 	token := oauth1.NewToken(cfg.Twitter.AccessToken, cfg.Twitter.AccessTokenSecret)
-	httpClient := config.Client(oauth1.NoContext, token)
 
-	client = twitter.NewClient(httpClient)
+	client := config.Client(oauth1.NoContext, token)
+	client.Transport = apiTransport(target, client.Transport)
 
-	user, _, err = client.Accounts.VerifyCredentials(&twitter.AccountVerifyParams{})
+	if target != nil {
+		log.Printf("[Twitter] Sending all API requests to %s\n", target.Host)
+	}
 
-	return
+	return client
+}
+
+// apiTransport wraps base so requests are considered for rate limits. If target is not nil, all requests are sent to it
+func apiTransport(target *url.URL, base http.RoundTripper) http.RoundTripper {
+	if target != nil {
+		base = &redirectTransport{target: target, base: base}
+	}
+
+	// Polling jobs are scheduled using the rate limits of all responses
+	return ratelimit.Default.Transport(base)
+}
+
+// redirectTransport sends all requests to another server, e.g. a fake API server
+type redirectTransport struct {
+	target *url.URL
+	base   http.RoundTripper
+}
+
+func (t *redirectTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	// RoundTrippers must not modify the request
+	r = r.Clone(r.Context())
+	r.URL.Scheme = t.target.Scheme
+	r.URL.Host = t.target.Host
+	r.Host = t.target.Host
+
+	return t.base.RoundTrip(r)
 }
@@ -0,0 +1,31 @@
+// Command faketwitter runs a fake Twitter API server for a scenario. Set twitter.api_url in the bot's config to its
+// address to run the whole bot without real credentials.
+//
+//	go run ./cmd/faketwitter -scenario cmd/faketwitter/scenario.example.yaml
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/xarantolus/spacex-hop-bot/faketwitter"
+)
+
+var (
+	flagAddr     = flag.String("addr", "localhost:8081", "Address the server listens on")
+	flagScenario = flag.String("scenario", "scenario.yaml", "Scenario file")
+)
+
+func main() {
+	flag.Parse()
+
+	scenario, err := faketwitter.LoadScenario(*flagScenario)
+	if err != nil {
+		log.Fatalln("[Fake Twitter] Loading scenario:", err.Error())
+	}
+
+	log.Printf("[Fake Twitter] Serving %d tweets on http://%s, bot actions are listed at /fake/actions\n", len(scenario.Tweets), *flagAddr)
+
+	log.Fatal(http.ListenAndServe(*flagAddr, faketwitter.New(scenario).Handler()))
+}
@@ -1,7 +1,10 @@
 package config
 
 import (
+	"fmt"
+	"net/url"
 	"os"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -12,18 +15,183 @@ type Config struct {
 		AccessTokenSecret string `yaml:"access_secret"`
 		APIKey            string `yaml:"api_key"`
 		APISecretKey      string `yaml:"api_secret"`
+
+		// APIURL sends all requests to another server instead of Twitter, e.g. "http://localhost:8081"
+		// for a server started with cmd/faketwitter. Credentials can be anything in that case
+		APIURL string `yaml:"api_url"`
+
+		// API selects the Twitter API that is used, "v1.1" (default) or "v2"
+		API string `yaml:"api"`
+		// BearerToken authenticates the app, the v2 filtered stream only works with it
+		BearerToken string `yaml:"bearer_token"`
+		// StreamRules are the rules of the v2 filtered stream, which replaces the location stream.
+		// If there are none, rules for the same areas as the location stream are used
+		StreamRules []string `yaml:"stream_rules"`
+
+		// Users are accounts whose timelines are polled. If there are none, elonmusk and SpaceX are watched
+		Users []TrustedUser `yaml:"users"`
+		// LocationBoxes are the areas the location stream and the default filtered stream rules cover.
+		// If there are none, areas around Starbase and other SpaceX sites are used
+		LocationBoxes []LocationBox `yaml:"location_boxes"`
+		// Searches are keyword searches that find tweets from accounts the bot doesn't follow
+		Searches []SearchQuery `yaml:"searches"`
 	} `yaml:"twitter"`
 
 	Lists struct {
 		MainStarshipListID int64   `yaml:"main_starship_list"`
 		IgnoredListIDs     []int64 `yaml:"ignored_lists"`
+
+		// SpacePeople configures how the main starship list is maintained
+		SpacePeople struct {
+			// File is where the list membership is persisted
+			File string `yaml:"file"`
+			// BatchMinutes is how often queued list changes are applied
+			BatchMinutes int `yaml:"batch_minutes"`
+			// PruneAfterMonths removes members that haven't been retweeted in this many months, zero disables pruning
+			PruneAfterMonths int `yaml:"prune_after_months"`
+		} `yaml:"space_people"`
 	} `yaml:"lists"`
 
+	Actions struct {
+		// Reasons maps the reason a tweet was matched for (e.g. "location + pad announcement")
+		// to the action that should be taken: "retweet" (default) or "quote"
+		Reasons map[string]string `yaml:"reasons"`
+
+		// QuoteTemplate is a text/template that generates the comment for quote tweets
+		QuoteTemplate string `yaml:"quote_template"`
+
+		// Like configures which tweets that narrowly miss being retweeted are liked instead
+		Like LikeTier `yaml:"like"`
+	} `yaml:"actions"`
+
+	DecisionLog struct {
+		// Directory is where the decision log segments are stored
+		Directory string `yaml:"directory"`
+		// RetentionDays is how many days segments are kept, zero keeps them forever
+		RetentionDays int `yaml:"retention_days"`
+		// SegmentSizeMB is the size after which a new segment is started
+		SegmentSizeMB int64 `yaml:"segment_size_mb"`
+		// SearchDays is how many days of decisions are kept in memory for searching, the default is 7.
+		// The decision search API reads older decisions from the log as long as they are within RetentionDays,
+		// which is a lot slower
+		SearchDays int `yaml:"search_days"`
+	} `yaml:"decision_log"`
+
+	Retraction struct {
+		// Enabled starts a job that unretweets tweets that are no longer eligible
+		Enabled bool `yaml:"enabled"`
+		// DryRun only logs retractions without unretweeting
+		DryRun bool `yaml:"dry_run"`
+		// MaxPerRun limits how many retweets are retracted in a single run
+		MaxPerRun int `yaml:"max_per_run"`
+		// MaxAgeHours is how many hours back retweets are checked
+		MaxAgeHours int `yaml:"max_age_hours"`
+	} `yaml:"retraction"`
+
+	Links struct {
+		// PolicyFile is a YAML file with rules for links in tweets. Changes are picked up while running.
+		// If it is empty, the built-in policy is used
+		PolicyFile string `yaml:"policy_file"`
+	} `yaml:"links"`
+
+	Fetch struct {
+		// MaxConcurrent is the maximum number of requests to linked websites that run at the same time
+		MaxConcurrent int `yaml:"max_concurrent"`
+		// MaxBodyMB is the maximum size of a response that is read
+		MaxBodyMB int64 `yaml:"max_body_mb"`
+		// MaxRedirects is how many redirects are followed
+		MaxRedirects int `yaml:"max_redirects"`
+		// CacheSize is how many canonical URLs and live stream states are cached
+		CacheSize int `yaml:"cache_size"`
+	} `yaml:"fetch"`
+
+	// Publish configures other platforms everything the bot retweets or tweets is published to
+	Publish struct {
+		Mastodon []struct {
+			Server      string `yaml:"server"`
+			AccessToken string `yaml:"access_token"`
+			// Visibility of new statuses, e.g. "public" (default) or "unlisted"
+			Visibility string `yaml:"visibility"`
+		} `yaml:"mastodon"`
+
+		Bluesky []struct {
+			// Service is the URL of the PDS, e.g. https://bsky.social
+			Service    string `yaml:"service"`
+			Identifier string `yaml:"identifier"`
+			// AppPassword should be an app password, not the account password
+			AppPassword string `yaml:"app_password"`
+		} `yaml:"bluesky"`
+
+		Webhooks []struct {
+			URL string `yaml:"url"`
+			// Secret is used to sign requests, see publish.Sign
+			Secret string `yaml:"secret"`
+		} `yaml:"webhooks"`
+
+		// MaxAttempts is how often publishing a post to a backend is tried
+		MaxAttempts int `yaml:"max_attempts"`
+		// PauseAfter is the number of failed posts in a row after which a backend is paused for PauseMinutes
+		PauseAfter   int `yaml:"pause_after"`
+		PauseMinutes int `yaml:"pause_minutes"`
+	} `yaml:"publish"`
+
+	// Ingest configures sources on other platforms. Posts about Starship are republished to all publishing backends
+	Ingest struct {
+		Mastodon []struct {
+			Server   string   `yaml:"server"`
+			Hashtags []string `yaml:"hashtags"`
+			Accounts []string `yaml:"accounts"`
+		} `yaml:"mastodon"`
+
+		Bluesky struct {
+			// Service is the AppView that is used, by default the public Bluesky API
+			Service string `yaml:"service"`
+			// Actors are handles or DIDs of accounts
+			Actors []string `yaml:"actors"`
+			// Feeds are at:// URIs of custom feeds
+			Feeds []string `yaml:"feeds"`
+		} `yaml:"bluesky"`
+
+		// Feeds are URLs of RSS or Atom feeds
+		Feeds []string `yaml:"feeds"`
+
+		// IntervalMinutes is how often all sources are polled
+		IntervalMinutes int `yaml:"interval_minutes"`
+	} `yaml:"ingest"`
+
+	// Cursors configures how timeline jobs continue where they stopped after a restart
+	Cursors struct {
+		// File is where the ID of the newest tweet of every timeline is stored
+		File string `yaml:"file"`
+		// CatchUpMinutes is the maximum age of missed tweets that are processed after a restart, older ones are skipped.
+		// The default is 6 hours, a negative value disables catching up
+		CatchUpMinutes int `yaml:"catch_up_minutes"`
+	} `yaml:"cursors"`
+
+	Record struct {
+		// Cassette is a directory every call to the Twitter API, every link lookup and every incoming tweet is written to.
+		// It is split into segments and cleaned up like the decision log. It can be replayed using consumer.LoadCassette
+		// to reproduce decisions offline
+		Cassette string `yaml:"cassette"`
+	} `yaml:"record"`
+
+	Shadow struct {
+		// CandidateRules is a YAML file with additional keywords and anti keywords and built-in ones that are removed.
+		// If it is set, a matcher with these rules is evaluated for every tweet and disagreements are recorded
+		// in the decision log
+		CandidateRules string `yaml:"candidate_rules"`
+	} `yaml:"shadow"`
+
 	Server struct {
 		Port uint16 `yaml:"port"`
 	} `yaml:"server"`
 }
 
+// UseAPIv2 returns whether the Twitter API v2 should be used instead of v1.1
+func (c Config) UseAPIv2() bool {
+	return c.Twitter.API == "v2" || c.Twitter.API == "2"
+}
+
 func (c Config) IgnoredListsMapping() (mapping map[int64]bool) {
 	mapping = make(map[int64]bool)
 
@@ -34,6 +202,39 @@ func (c Config) IgnoredListsMapping() (mapping map[int64]bool) {
 	return
 }
 
+// DecisionLogDirectory returns the directory of the decision log
+func (c Config) DecisionLogDirectory() string {
+	if c.DecisionLog.Directory == "" {
+		return "decisions"
+	}
+	return c.DecisionLog.Directory
+}
+
+// CursorFile returns the file timeline cursors are stored in
+func (c Config) CursorFile() string {
+	if c.Cursors.File == "" {
+		return "cursors.json"
+	}
+	return c.Cursors.File
+}
+
+// CatchUpMaxAge returns the maximum age of missed tweets that are processed after a restart.
+// It is negative if catching up is disabled
+func (c Config) CatchUpMaxAge() time.Duration {
+	if c.Cursors.CatchUpMinutes == 0 {
+		return 6 * time.Hour
+	}
+	return time.Duration(c.Cursors.CatchUpMinutes) * time.Minute
+}
+
+// SpacePeopleListFile returns the file the space people list membership is persisted in
+func (c Config) SpacePeopleListFile() string {
+	if c.Lists.SpacePeople.File == "" {
+		return "space-people.json"
+	}
+	return c.Lists.SpacePeople.File
+}
+
 func Parse(filename string) (c Config, err error) {
 	f, err := os.Open(filename)
 	if err != nil {
@@ -46,5 +247,31 @@ func Parse(filename string) (c Config, err error) {
 		return
 	}
 
+	err = c.validateSources()
+	if err == nil {
+		_, err = c.APIBaseURL()
+	}
+	if err != nil {
+		err = fmt.Errorf("invalid config %s: %w", filename, err)
+	}
+
 	return
 }
+
+// APIBaseURL returns the server all requests are sent to instead of Twitter, it is nil if none is configured.
+// Addresses like "localhost:8081" parse as URLs, but every request to them would fail, so they are rejected
+func (c Config) APIBaseURL() (*url.URL, error) {
+	if c.Twitter.APIURL == "" {
+		return nil, nil
+	}
+
+	u, err := url.Parse(c.Twitter.APIURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid twitter API URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("invalid twitter API URL %q: must start with http:// or https:// and contain a host", c.Twitter.APIURL)
+	}
+
+	return u, nil
+}
@@ -0,0 +1,33 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse_APIURL(t *testing.T) {
+	tests := []struct {
+		apiURL  string
+		wantErr bool
+	}{
+		{"", false},
+		{"http://localhost:8081", false},
+		{"https://api.example.com/", false},
+		// This is what cmd/faketwitter prints as its address, it parses with "localhost" as scheme
+		{"localhost:8081", true},
+		{"ftp://localhost:8081", true},
+		{"http://", true},
+		{"http://[::1", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.apiURL, func(t *testing.T) {
+			_, err := parseTestConfig(t, "twitter:\n  api_url: \""+tt.apiURL+"\"\n")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse with api_url %q returned error %v, wantErr %v", tt.apiURL, err, tt.wantErr)
+			}
+			if err != nil && !strings.Contains(err.Error(), "twitter API URL") {
+				t.Errorf("unexpected error %q", err.Error())
+			}
+		})
+	}
+}
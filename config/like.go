@@ -0,0 +1,23 @@
+package config
+
+// LikeTier configures which tweets that narrowly miss being retweeted are liked instead.
+// Liked tweets show up on the likes page of the bot account
+type LikeTier struct {
+	// Questions configures liking questions that contain Starship keywords, but have no media
+	Questions LikeRule `yaml:"questions"`
+	// LocationWithoutMedia configures liking Starship tweets from the location stream that have no media
+	LocationWithoutMedia LikeRule `yaml:"location_without_media"`
+
+	// MaxPerHour is the maximum number of likes per hour over all rules, zero means no limit
+	MaxPerHour int `yaml:"max_per_hour"`
+}
+
+// LikeRule configures when one kind of borderline tweets is liked
+type LikeRule struct {
+	Enabled bool `yaml:"enabled"`
+	// MinFollowers is the number of followers the author of a tweet must at least have, which keeps us
+	// from liking tweets of new or spam accounts
+	MinFollowers int `yaml:"min_followers"`
+	// MaxPerHour is the maximum number of likes per hour for this rule, zero means no limit
+	MaxPerHour int `yaml:"max_per_hour"`
+}
@@ -0,0 +1,30 @@
+package config
+
+import "testing"
+
+func TestParse_LikeRules(t *testing.T) {
+	c, err := parseTestConfig(t, `
+actions:
+  like:
+    max_per_hour: 10
+    questions:
+      enabled: true
+    location_without_media:
+      enabled: true
+      min_followers: 50
+      max_per_hour: 4
+`)
+	if err != nil {
+		t.Fatalf("parsing config: %s", err.Error())
+	}
+
+	if c.Actions.Like.MaxPerHour != 10 {
+		t.Errorf("unexpected max likes per hour %d", c.Actions.Like.MaxPerHour)
+	}
+	if q := c.Actions.Like.Questions; q != (LikeRule{Enabled: true}) {
+		t.Errorf("unexpected questions rule %+v", q)
+	}
+	if l := c.Actions.Like.LocationWithoutMedia; l != (LikeRule{Enabled: true, MinFollowers: 50, MaxPerHour: 4}) {
+		t.Errorf("unexpected location rule %+v", l)
+	}
+}
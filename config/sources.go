@@ -0,0 +1,205 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TrustedUser is an account whose timeline is polled, so none of its tweets are missed
+type TrustedUser struct {
+	ScreenName string `yaml:"screen_name"`
+	// IntervalMinutes is how often the timeline is polled, the default is 2 minutes
+	IntervalMinutes int `yaml:"interval_minutes"`
+	// Source is how tweets of this user are treated, SourceTrustedUser (default) or SourceTimeline
+	Source string `yaml:"source"`
+}
+
+// Sources of tweets from trusted users, they are shown in logs and recorded in the decision log
+const (
+	SourceTrustedUser = "trusted_user"
+	SourceTimeline    = "timeline"
+)
+
+// Interval returns how often the timeline of the user is polled
+func (u TrustedUser) Interval() time.Duration {
+	if u.IntervalMinutes == 0 {
+		return 2 * time.Minute
+	}
+	return time.Duration(u.IntervalMinutes) * time.Minute
+}
+
+func (u TrustedUser) validate() error {
+	if u.ScreenName == "" || strings.ContainsAny(u.ScreenName, "@/ ") {
+		return fmt.Errorf("invalid screen name %q", u.ScreenName)
+	}
+	if u.IntervalMinutes < 0 {
+		return fmt.Errorf("user %q: interval must not be negative", u.ScreenName)
+	}
+	switch u.Source {
+	case "", SourceTrustedUser, SourceTimeline:
+	default:
+		return fmt.Errorf("user %q: unknown source %q, must be %q or %q", u.ScreenName, u.Source, SourceTrustedUser, SourceTimeline)
+	}
+	return nil
+}
+
+// LocationBox is a named area tweets are streamed from. Coordinates are in degrees
+type LocationBox struct {
+	Name  string  `yaml:"name"`
+	West  float64 `yaml:"west"`
+	South float64 `yaml:"south"`
+	East  float64 `yaml:"east"`
+	North float64 `yaml:"north"`
+}
+
+// String returns the box in the "west,south,east,north" format the location stream expects
+func (b LocationBox) String() string {
+	var coords = make([]string, 0, 4)
+	for _, c := range []float64{b.West, b.South, b.East, b.North} {
+		coords = append(coords, strconv.FormatFloat(c, 'f', -1, 64))
+	}
+	return strings.Join(coords, ",")
+}
+
+const (
+	// MaxLocationBoxes is the maximum number of areas the location stream accepts
+	MaxLocationBoxes = 25
+	// maxLocationBoxSize is the maximum width and height of an area in degrees. Larger areas would include
+	// so many tweets that the stream would be mostly noise
+	maxLocationBoxSize = 1.0
+)
+
+func (b LocationBox) validate() error {
+	switch {
+	case b.Name == "":
+		return fmt.Errorf("location box %s has no name", b.String())
+	case b.West < -180 || b.East > 180 || b.South < -90 || b.North > 90:
+		return fmt.Errorf("location box %q: coordinates out of range", b.Name)
+	case b.West >= b.East || b.South >= b.North:
+		return fmt.Errorf("location box %q: coordinates must be ordered west < east and south < north", b.Name)
+	case b.East-b.West > maxLocationBoxSize || b.North-b.South > maxLocationBoxSize:
+		return fmt.Errorf("location box %q: must not be larger than %g degrees in each direction", b.Name, maxLocationBoxSize)
+	}
+	return nil
+}
+
+// SearchQuery is a keyword search that is run periodically, e.g. "starship static fire" or "#Starbase filter:media"
+type SearchQuery struct {
+	Query string `yaml:"query"`
+	// IntervalMinutes is the minimum time between two searches with this query, the default is 5 minutes.
+	// All searches share the rate limit of the search endpoint, so they might run less often
+	IntervalMinutes int `yaml:"interval_minutes"`
+}
+
+// maxSearchQueryLength is the maximum length of a search query the API accepts
+const maxSearchQueryLength = 500
+
+// Interval returns the minimum time between two searches with this query
+func (q SearchQuery) Interval() time.Duration {
+	if q.IntervalMinutes == 0 {
+		return 5 * time.Minute
+	}
+	return time.Duration(q.IntervalMinutes) * time.Minute
+}
+
+func (q SearchQuery) validate() error {
+	switch {
+	case strings.TrimSpace(q.Query) == "":
+		return fmt.Errorf("empty search query")
+	case len(q.Query) > maxSearchQueryLength:
+		return fmt.Errorf("search query %q is longer than %d characters", q.Query, maxSearchQueryLength)
+	case q.IntervalMinutes < 0:
+		return fmt.Errorf("search query %q: interval must not be negative", q.Query)
+	}
+	return nil
+}
+
+// TrustedUsers returns all users whose timelines are polled
+func (c Config) TrustedUsers() []TrustedUser {
+	if len(c.Twitter.Users) == 0 {
+		return []TrustedUser{
+			{ScreenName: "elonmusk"},
+			{ScreenName: "SpaceX"},
+		}
+	}
+	return c.Twitter.Users
+}
+
+// LocationBoxes returns all areas tweets are streamed from
+func (c Config) LocationBoxes() []LocationBox {
+	if len(c.Twitter.LocationBoxes) == 0 {
+		return defaultLocationBoxes
+	}
+	return c.Twitter.LocationBoxes
+}
+
+var defaultLocationBoxes = []LocationBox{
+	// This is an area around boca chica (aka Starbase).
+	// We want to catch many tweets from there and then filter them
+	// You can see this area on a map here: https://bboxfinder.com/#25.838213,-97.321014,26.121535,-96.942673
+	{Name: "Starbase", West: -97.321014, South: 25.838213, East: -96.942673, North: 26.121535},
+
+	// McGregor test site (they also test raptor engines there, so maybe someone tweets from there)
+	// Map: https://mapper.acme.com/?ll=31.39966,-97.46246&z=12&t=M&marker0=31.39930%2C-97.46250%2C31.399308%20-97.462496&marker1=31.34836%2C-97.51740%2Cunnamed&marker2=31.48314%2C-97.36530%2C6.0%20km%20NE%20of%20McGregor%20TX
+	{Name: "McGregor", West: -97.51740, South: 31.34836, East: -97.36530, North: 31.48314},
+
+	// Port/Cape Canaveral (the oil rigs that could be used for starship landings are stationed there)
+	// Also includes SpaceX's LC-39A, where a new starship orbital launch pad is being constructed.
+	// It also includes LC-49, where the same thing should happen
+	// Map: https://mapper.acme.com/?ll=28.40952,-80.60944&z=10&t=M&marker0=28.21910%2C-80.79552%2Cunnamed&marker1=28.88617%2C-79.96262%2C79.2%20km%20ExNE%20of%20Merritt%20Island%20FL
+	{Name: "Cape Canaveral", West: -80.79552, South: 28.21910, East: -79.96262, North: 28.88617},
+
+	// Pascagoula; the oil rig Phobos is in the port
+	// https://bboxfinder.com/#30.298204,-88.678894,30.457552,-88.463974
+	{Name: "Pascagoula", West: -88.678894, South: 30.298204, East: -88.463974, North: 30.457552},
+
+	// Brownsville Airport, there will be a Starship prototype standing around and people will likely take pictures
+	// https://bboxfinder.com/#25.891967,-97.441134,25.918835,-97.406845
+	{Name: "Brownsville Airport", West: -97.441134, South: 25.891967, East: -97.406845, North: 25.918835},
+}
+
+// validateSources checks the configured users, location boxes and search queries
+func (c Config) validateSources() error {
+	var users = make(map[string]bool)
+	for _, u := range c.Twitter.Users {
+		if err := u.validate(); err != nil {
+			return err
+		}
+
+		// Screen names are case-insensitive
+		name := strings.ToLower(u.ScreenName)
+		if users[name] {
+			return fmt.Errorf("user %q is listed more than once", u.ScreenName)
+		}
+		users[name] = true
+	}
+
+	if len(c.Twitter.LocationBoxes) > MaxLocationBoxes {
+		return fmt.Errorf("%d location boxes configured, at most %d are supported", len(c.Twitter.LocationBoxes), MaxLocationBoxes)
+	}
+	var boxes = make(map[string]bool)
+	for _, b := range c.Twitter.LocationBoxes {
+		if err := b.validate(); err != nil {
+			return err
+		}
+		if boxes[b.Name] {
+			return fmt.Errorf("location box %q is listed more than once", b.Name)
+		}
+		boxes[b.Name] = true
+	}
+
+	var queries = make(map[string]bool)
+	for _, q := range c.Twitter.Searches {
+		if err := q.validate(); err != nil {
+			return err
+		}
+		if queries[q.Query] {
+			return fmt.Errorf("search query %q is listed more than once", q.Query)
+		}
+		queries[q.Query] = true
+	}
+
+	return nil
+}
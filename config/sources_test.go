@@ -0,0 +1,132 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func parseTestConfig(t *testing.T, content string) (Config, error) {
+	t.Helper()
+
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatalf("writing config: %s", err.Error())
+	}
+
+	return Parse(file)
+}
+
+func TestParse_Sources(t *testing.T) {
+	c, err := parseTestConfig(t, `
+twitter:
+  users:
+    - screen_name: SpaceX
+    - screen_name: NASASpaceflight
+      interval_minutes: 10
+      source: timeline
+  location_boxes:
+    - name: Starbase
+      west: -97.321014
+      south: 25.838213
+      east: -96.942673
+      north: 26.121535
+  searches:
+    - query: "starship static fire"
+    - query: "#Starbase filter:media"
+      interval_minutes: 15
+`)
+	if err != nil {
+		t.Fatalf("parsing config: %s", err.Error())
+	}
+
+	users := c.TrustedUsers()
+	if len(users) != 2 || users[0].Interval() != 2*time.Minute || users[1].Interval() != 10*time.Minute || users[1].Source != SourceTimeline {
+		t.Errorf("unexpected users %+v", users)
+	}
+
+	boxes := c.LocationBoxes()
+	if len(boxes) != 1 || boxes[0].String() != "-97.321014,25.838213,-96.942673,26.121535" {
+		t.Errorf("unexpected location boxes %+v", boxes)
+	}
+
+	if s := c.Twitter.Searches; len(s) != 2 || s[0].Interval() != 5*time.Minute || s[1].Interval() != 15*time.Minute {
+		t.Errorf("unexpected searches %+v", s)
+	}
+
+	// Without configured sources, the defaults are used
+	var empty Config
+	if len(empty.TrustedUsers()) != 2 || len(empty.LocationBoxes()) != 5 {
+		t.Errorf("expected default sources, got %+v and %+v", empty.TrustedUsers(), empty.LocationBoxes())
+	}
+
+	// The defaults must be valid too
+	empty.Twitter.Users, empty.Twitter.LocationBoxes = empty.TrustedUsers(), empty.LocationBoxes()
+	if err := empty.validateSources(); err != nil {
+		t.Errorf("default sources are invalid: %s", err.Error())
+	}
+}
+
+func TestParse_InvalidSources(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			"duplicate user",
+			"twitter:\n  users:\n    - screen_name: SpaceX\n    - screen_name: spacex\n",
+			"more than once",
+		},
+		{
+			"unknown source",
+			"twitter:\n  users:\n    - screen_name: SpaceX\n      source: list\n",
+			"unknown source",
+		},
+		{
+			"screen name with @",
+			"twitter:\n  users:\n    - screen_name: \"@SpaceX\"\n",
+			"invalid screen name",
+		},
+		{
+			"swapped coordinates",
+			"twitter:\n  location_boxes:\n    - {name: Starbase, west: 25.8, south: -97.3, east: 26.1, north: -96.9}\n",
+			"out of range",
+		},
+		{
+			"wrong order",
+			"twitter:\n  location_boxes:\n    - {name: Starbase, west: -96.9, south: 25.8, east: -97.3, north: 26.1}\n",
+			"must be ordered",
+		},
+		{
+			"too large",
+			"twitter:\n  location_boxes:\n    - {name: Texas, west: -106.6, south: 25.8, east: -93.5, north: 36.5}\n",
+			"must not be larger",
+		},
+		{
+			"empty search query",
+			"twitter:\n  searches:\n    - query: \" \"\n",
+			"empty search query",
+		},
+		{
+			"duplicate search query",
+			"twitter:\n  searches:\n    - query: starship\n    - query: starship\n",
+			"more than once",
+		},
+		{
+			"missing name",
+			"twitter:\n  location_boxes:\n    - {west: -97.3, south: 25.8, east: -96.9, north: 26.1}\n",
+			"has no name",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseTestConfig(t, tt.content)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
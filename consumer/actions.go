@@ -0,0 +1,163 @@
+package consumer
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/xarantolus/spacex-hop-bot/decisions"
+	"github.com/xarantolus/spacex-hop-bot/match"
+	"github.com/xarantolus/spacex-hop-bot/util"
+)
+
+// Action is what the processor does with a tweet it decided to share
+type Action string
+
+const (
+	// ActionRetweet is a normal retweet, it is used for every reason that isn't configured otherwise
+	ActionRetweet Action = decisions.ActionRetweet
+	// ActionQuote quotes the tweet with a short generated comment
+	ActionQuote Action = decisions.ActionQuote
+)
+
+func (a Action) pastTense() string {
+	switch a {
+	case ActionQuote:
+		return "Quoted"
+	default:
+		return "Retweeted"
+	}
+}
+
+// reasonDescriptions are the default comments used when quoting a tweet for the given reason
+var reasonDescriptions = map[string]string{
+	"SpaceX tweet":                "New from @SpaceX",
+	"location + pad announcement": "Announcement heard near a SpaceX site",
+	"location + live stream":      "Live stream from near a SpaceX site",
+	"live stream at spacex site":  "Live stream from a SpaceX site",
+	"thread: matched":             "More info from Elon Musk",
+	"thread: matched parent":      "Context for a reply by Elon Musk",
+	"thread: quoted":              "Elon Musk quoting",
+}
+
+const defaultQuoteTemplate = `{{.Description}}{{with .Hashtags}}
+
+{{.}}{{end}}`
+
+var defaultQuoteTmpl = template.Must(template.New("quoteTemplate").Parse(defaultQuoteTemplate))
+
+// quoteData is the data that is available in quote templates
+type quoteData struct {
+	// Reason is the reason the tweet was matched for, e.g. "location + pad announcement"
+	Reason string
+	// Description is a short human-readable text for Reason, it might be empty
+	Description string
+	// Source is the source of the tweet, e.g. "TweetSourceLocationStream"
+	Source string
+	// Vehicles are the vehicle names mentioned in the tweet, e.g. "S24" or "B7"
+	Vehicles []string
+	// Hashtags contains Vehicles as hashtags
+	Hashtags string
+	// Explanation contains the steps that led to the match, e.g. "Tweet matched keyword S24"
+	Explanation []string
+
+	Tweet *twitter.Tweet
+}
+
+// SetActions configures which action is taken for which retweet reason. Reasons that are not in
+// reasons are just retweeted. If quoteTemplate is empty, a default template is used
+func (p *Processor) SetActions(reasons map[string]string, quoteTemplate string) (err error) {
+	var actions = make(map[string]Action)
+	for reason, a := range reasons {
+		switch action := Action(strings.ToLower(a)); action {
+		case ActionRetweet, ActionQuote:
+			actions[reason] = action
+		default:
+			return fmt.Errorf("invalid action %q for reason %q", a, reason)
+		}
+	}
+
+	var tmpl = defaultQuoteTmpl
+	if quoteTemplate != "" {
+		tmpl, err = template.New("quoteTemplate").Parse(quoteTemplate)
+		if err != nil {
+			return fmt.Errorf("parsing quote template: %w", err)
+		}
+	}
+
+	p.actions = actions
+	p.quoteTmpl = tmpl
+
+	return nil
+}
+
+// quotes returns whether any reason is configured to be quoted
+func (p *Processor) quotes() bool {
+	for _, a := range p.actions {
+		if a == ActionQuote {
+			return true
+		}
+	}
+	return false
+}
+
+// actionFor returns the action that should be taken for a tweet that was matched for the given reason
+func (p *Processor) actionFor(reason string) Action {
+	a, ok := p.actions[reason]
+	if !ok {
+		return ActionRetweet
+	}
+	return a
+}
+
+// quoteText generates the text for quoting tweet. If the template generates no text, ok is false
+func (p *Processor) quoteText(tweet *twitter.Tweet, reason string, source match.TweetSource) (text string, ok bool) {
+	vehicles := match.VehicleNames(tweet.Text())
+
+	var explanation []string
+	if p.explanation != nil {
+		explanation = p.explanation.Steps
+	}
+
+	var b bytes.Buffer
+	err := p.quoteTmpl.Execute(&b, quoteData{
+		Reason:      reason,
+		Description: reasonDescriptions[reason],
+		Source:      source.String(),
+		Vehicles:    vehicles,
+		Hashtags:    util.HashTagText(vehicles),
+		Explanation: explanation,
+		Tweet:       tweet,
+	})
+	if util.LogError(err, "executing quote template for %s", util.TweetURL(tweet)) {
+		return "", false
+	}
+
+	text = strings.TrimSpace(b.String())
+	if text == "" {
+		return "", false
+	}
+
+	// Twitter turns a tweet URL at the end of the text into a quote
+	return text + "\n" + util.TweetURL(tweet), true
+}
+
+// quote quotes the given tweet with a generated comment. If no comment can be generated, it retweets instead.
+// The returned action is the one that was actually taken
+func (p *Processor) quote(tweet *twitter.Tweet, reason string, source match.TweetSource) (action Action, err error) {
+	text, ok := p.quoteText(tweet, reason, source)
+	if !ok {
+		return ActionRetweet, p.client.Retweet(tweet)
+	}
+
+	_, err = p.client.Tweet(text, nil)
+	if err != nil {
+		return ActionQuote, err
+	}
+
+	p.quotedTweets[tweet.ID] = true
+
+	return ActionQuote, nil
+}
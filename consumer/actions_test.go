@@ -0,0 +1,113 @@
+package consumer
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/xarantolus/spacex-hop-bot/match"
+)
+
+func TestProcessor_quoteAction(t *testing.T) {
+	client := &TestTwitterClient{
+		retweetedTweetIDs: make(map[int64]bool),
+		tweets:            make(map[int64]*twitter.Tweet),
+	}
+	p := NewProcessor(false, true, client, &twitter.User{ID: testBotSelfUserID}, match.NewStarshipMatcherForTests())
+
+	err := p.SetActions(map[string]string{
+		"location + pad announcement": "quote",
+	}, "")
+	if err != nil {
+		t.Fatalf("SetActions: %s", err.Error())
+	}
+
+	p.Tweet(match.TweetWrapper{
+		TweetSource: match.TweetSourceLocationStream,
+		Tweet: twitter.Tweet{
+			ID:        1234,
+			IDStr:     "1234",
+			FullText:  "Just heard over the SpaceX PA system that S24 will be lifted shortly!",
+			CreatedAt: time.Now().Add(-time.Minute).Format(time.RubyDate),
+			User:      &twitter.User{ID: 5, ScreenName: "someone"},
+			Place:     &twitter.Place{ID: match.SpaceXLaunchSiteID},
+		},
+	})
+
+	if client.HasRetweeted(1234) {
+		t.Errorf("tweet was retweeted, but should have been quoted")
+	}
+	if len(client.tweetedTexts) != 1 {
+		t.Fatalf("expected exactly one quote tweet, but got %d", len(client.tweetedTexts))
+	}
+
+	text := client.tweetedTexts[0]
+	if !strings.HasSuffix(text, "https://twitter.com/someone/status/1234") {
+		t.Errorf("quote tweet %q does not end with the quoted tweet URL", text)
+	}
+	if !strings.Contains(text, "#S24") {
+		t.Errorf("quote tweet %q does not contain vehicle hashtag", text)
+	}
+}
+
+func TestProcessor_quoteTemplate(t *testing.T) {
+	client := &TestTwitterClient{
+		retweetedTweetIDs: make(map[int64]bool),
+		tweets:            make(map[int64]*twitter.Tweet),
+	}
+	p := NewProcessor(false, true, client, &twitter.User{ID: testBotSelfUserID}, match.NewStarshipMatcherForTests())
+
+	err := p.SetActions(map[string]string{
+		"location + pad announcement": "quote",
+	}, `{{.Reason}} ({{.Source}}): {{range .Vehicles}}{{.}} {{end}}{{.Hashtags}}{{range .Explanation}}
+- {{.}}{{end}}`)
+	if err != nil {
+		t.Fatalf("SetActions: %s", err.Error())
+	}
+
+	p.Tweet(match.TweetWrapper{
+		TweetSource: match.TweetSourceLocationStream,
+		Tweet: twitter.Tweet{
+			ID:        1234,
+			IDStr:     "1234",
+			FullText:  "Just heard over the SpaceX PA system that S24 will be lifted shortly!",
+			CreatedAt: time.Now().Add(-time.Minute).Format(time.RubyDate),
+			User:      &twitter.User{ID: 5, ScreenName: "someone"},
+			Place:     &twitter.Place{ID: match.SpaceXLaunchSiteID},
+		},
+	})
+
+	if len(client.tweetedTexts) != 1 {
+		t.Fatalf("expected exactly one quote tweet, but got %d", len(client.tweetedTexts))
+	}
+
+	text := client.tweetedTexts[0]
+	if !strings.HasPrefix(text, "location + pad announcement (TweetSourceLocationStream): S24 #S24\n- ") {
+		t.Errorf("quote tweet %q does not start with the template data", text)
+	}
+	if !strings.Contains(text, "- StarshipTweet: is at SpaceX site") {
+		t.Errorf("quote tweet %q does not contain the match explanation", text)
+	}
+}
+
+func TestProcessor_SetActions(t *testing.T) {
+	p := NewProcessor(false, true, nil, &twitter.User{ID: testBotSelfUserID}, match.NewStarshipMatcherForTests())
+
+	if err := p.SetActions(map[string]string{"normal matcher": "QUOTE"}, ""); err != nil {
+		t.Errorf("SetActions returned error for valid action: %s", err.Error())
+	}
+	if a := p.actionFor("normal matcher"); a != ActionQuote {
+		t.Errorf("actionFor(\"normal matcher\") = %q, want %q", a, ActionQuote)
+	}
+	if a := p.actionFor("SpaceX tweet"); a != ActionRetweet {
+		t.Errorf("actionFor(\"SpaceX tweet\") = %q, want %q", a, ActionRetweet)
+	}
+
+	if err := p.SetActions(map[string]string{"normal matcher": "delete"}, ""); err == nil {
+		t.Errorf("SetActions did not return error for invalid action")
+	}
+	if err := p.SetActions(nil, "{{.Unclosed"); err == nil {
+		t.Errorf("SetActions did not return error for invalid template")
+	}
+}
@@ -0,0 +1,99 @@
+package consumer
+
+import (
+	"log"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/xarantolus/spacex-hop-bot/config"
+	"github.com/xarantolus/spacex-hop-bot/decisions"
+	"github.com/xarantolus/spacex-hop-bot/match"
+	"github.com/xarantolus/spacex-hop-bot/util"
+)
+
+// recentLike is a like of the last hour, which is kept for rate limiting
+type recentLike struct {
+	time   time.Time
+	reason string
+}
+
+const (
+	borderlineQuestion             = "borderline: question"
+	borderlineLocationWithoutMedia = "borderline: location without media"
+)
+
+// SetLikeTier configures which borderline tweets should be liked
+func (p *Processor) SetLikeTier(tier config.LikeTier) {
+	p.likeTier = tier
+}
+
+// isBorderlineQuestion returns whether the post would have been retweeted if it wasn't a question
+func (p *Processor) isBorderlineQuestion(post *match.Post) bool {
+	return post.InReplyTo == nil &&
+		post.Quoted == nil &&
+		post.Reposted == nil &&
+		isQuestion(post) &&
+		!post.HasMedia() &&
+		!post.IsReactionGIF() &&
+		!post.Sensitive &&
+		p.matcher.StarshipPost(post)
+}
+
+// like likes the given tweet if the like tier allows it
+func (p *Processor) like(tweet *twitter.Tweet, reason string, source match.TweetSource) {
+	if tweet.Retweeted || tweet.Favorited || p.likedTweets[tweet.ID] {
+		return
+	}
+
+	var rule config.LikeRule
+	switch reason {
+	case borderlineQuestion:
+		rule = p.likeTier.Questions
+	case borderlineLocationWithoutMedia:
+		rule = p.likeTier.LocationWithoutMedia
+	}
+	if !rule.Enabled {
+		return
+	}
+
+	if tweet.User != nil && tweet.User.FollowersCount < rule.MinFollowers {
+		return
+	}
+
+	// Only keep the likes of the last hour for rate limiting
+	var (
+		recentLikes = p.recentLikes[:0]
+		ruleLikes   int
+	)
+	for _, l := range p.recentLikes {
+		if p.now().Sub(l.time) < time.Hour {
+			recentLikes = append(recentLikes, l)
+			if l.reason == reason {
+				ruleLikes++
+			}
+		}
+	}
+	p.recentLikes = recentLikes
+
+	if p.likeTier.MaxPerHour > 0 && len(p.recentLikes) >= p.likeTier.MaxPerHour ||
+		rule.MaxPerHour > 0 && ruleLikes >= rule.MaxPerHour {
+		return
+	}
+
+	err := p.client.Like(tweet.ID)
+	if err != nil {
+		// Same as with retweets, Twitter doesn't always tell us that we already liked a tweet
+		util.LogError(err, "liking %s", util.TweetURL(tweet))
+		return
+	}
+
+	p.likedTweets[tweet.ID] = true
+	p.recentLikes = append(p.recentLikes, recentLike{time: p.now(), reason: reason})
+	tweet.Favorited = true
+
+	p.recordDecision(tweet, source, decisions.ActionLike, reason)
+
+	if !p.test {
+		log.Printf("[Twitter] Liked %s (%s - %s)", util.TweetURL(tweet), reason, source.String())
+	}
+}
@@ -0,0 +1,116 @@
+package consumer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/xarantolus/spacex-hop-bot/config"
+	"github.com/xarantolus/spacex-hop-bot/match"
+)
+
+func TestProcessor_likeTier(t *testing.T) {
+	tests := []struct {
+		text      string
+		source    match.TweetSource
+		location  string
+		tier      config.LikeTier
+		followers int
+
+		wantLike bool
+	}{
+		{
+			text:     "Is Starship going to fly this week?",
+			tier:     config.LikeTier{Questions: config.LikeRule{Enabled: true}},
+			wantLike: true,
+		},
+		{
+			text:     "Is Starship going to fly this week?",
+			tier:     config.LikeTier{},
+			wantLike: false,
+		},
+		{
+			text:     "Is the weather nice today?",
+			tier:     config.LikeTier{Questions: config.LikeRule{Enabled: true}},
+			wantLike: false,
+		},
+		{
+			text:     "Might we see Booster lift back off the OLM? CraneX is in place #Starbase",
+			source:   match.TweetSourceLocationStream,
+			location: match.SpaceXLaunchSiteID,
+			tier:     config.LikeTier{LocationWithoutMedia: config.LikeRule{Enabled: true}},
+			wantLike: true,
+		},
+		{
+			text:      "Is Starship going to fly this week?",
+			tier:      config.LikeTier{Questions: config.LikeRule{Enabled: true, MinFollowers: 100}},
+			followers: 99,
+			wantLike:  false,
+		},
+		{
+			text:      "Is Starship going to fly this week?",
+			tier:      config.LikeTier{Questions: config.LikeRule{Enabled: true, MinFollowers: 100}},
+			followers: 100,
+			wantLike:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(t.Name(), func(t *testing.T) {
+			client := &TestTwitterClient{
+				retweetedTweetIDs: make(map[int64]bool),
+				tweets:            make(map[int64]*twitter.Tweet),
+			}
+			p := NewProcessor(false, true, client, &twitter.User{ID: testBotSelfUserID}, match.NewStarshipMatcherForTests())
+			p.SetLikeTier(tt.tier)
+
+			tweet := match.TweetWrapper{
+				TweetSource: tt.source,
+				Tweet: twitter.Tweet{
+					ID:        50,
+					FullText:  tt.text,
+					CreatedAt: time.Now().Add(-time.Minute).Format(time.RubyDate),
+					User:      &twitter.User{ID: 80, ScreenName: "someone", FollowersCount: tt.followers},
+				},
+			}
+			if tt.location != "" {
+				tweet.Place = &twitter.Place{ID: tt.location}
+			}
+
+			p.Tweet(tweet)
+
+			if client.HasRetweeted(50) {
+				t.Errorf("tweet %q was retweeted, but should at most have been liked", tt.text)
+			}
+			if liked := client.likedTweetIDs[50]; liked != tt.wantLike {
+				t.Errorf("tweet %q liked=%v, but want %v", tt.text, liked, tt.wantLike)
+			}
+		})
+	}
+}
+
+func TestProcessor_likeRateLimit(t *testing.T) {
+	client := &TestTwitterClient{
+		retweetedTweetIDs: make(map[int64]bool),
+		tweets:            make(map[int64]*twitter.Tweet),
+	}
+	p := NewProcessor(false, true, client, &twitter.User{ID: testBotSelfUserID}, match.NewStarshipMatcherForTests())
+	p.SetLikeTier(config.LikeTier{
+		Questions:            config.LikeRule{Enabled: true, MaxPerHour: 2},
+		LocationWithoutMedia: config.LikeRule{Enabled: true},
+		MaxPerHour:           3,
+	})
+
+	for i := int64(0); i < 5; i++ {
+		p.like(&twitter.Tweet{ID: 100 + i}, borderlineQuestion, match.TweetSourceUnknown)
+	}
+	if len(client.likedTweetIDs) != 2 {
+		t.Errorf("expected 2 likes because of the rate limit of the rule, but got %d", len(client.likedTweetIDs))
+	}
+
+	for i := int64(0); i < 5; i++ {
+		p.like(&twitter.Tweet{ID: 200 + i}, borderlineLocationWithoutMedia, match.TweetSourceLocationStream)
+	}
+	if len(client.likedTweetIDs) != 3 {
+		t.Errorf("expected 3 likes because of the overall rate limit, but got %d", len(client.likedTweetIDs))
+	}
+}
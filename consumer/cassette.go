@@ -0,0 +1,377 @@
+package consumer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/xarantolus/spacex-hop-bot/decisions"
+	"github.com/xarantolus/spacex-hop-bot/match"
+	"github.com/xarantolus/spacex-hop-bot/scrapers"
+)
+
+// Methods of the TwitterClient and LinkResolver interfaces that are recorded, and "Incoming" for tweets the processor received
+const (
+	MethodIncoming          = "Incoming"
+	MethodLoadStatus        = "LoadStatus"
+	MethodUpdateListMembers = "UpdateListMembers"
+	MethodRetweet           = "Retweet"
+	MethodUnRetweet         = "UnRetweet"
+	MethodLike              = "Like"
+	MethodTweet             = "Tweet"
+	MethodCanonicalURL      = "CanonicalURL"
+	MethodYouTubeLive       = "YouTubeLive"
+)
+
+// Interaction is a call to a TwitterClient or LinkResolver together with its result. Cassettes are directories of
+// segments like the decision log, with one interaction per line
+type Interaction struct {
+	// Time is when the call was made. For incoming tweets, it is when the processor started handling them
+	Time   time.Time `json:"time"`
+	Method string    `json:"method"`
+
+	// TweetID is the argument of LoadStatus, UnRetweet and Like, and the ID of the tweet for Retweet
+	TweetID int64 `json:"tweet_id,omitempty"`
+	// Tweet is the result of LoadStatus and Tweet, or the incoming tweet
+	Tweet *twitter.Tweet `json:"tweet,omitempty"`
+	// Source is the source of an incoming tweet
+	Source match.TweetSource `json:"source,omitempty"`
+
+	Text        string `json:"text,omitempty"`
+	InReplyToID int64  `json:"in_reply_to_id,omitempty"`
+
+	ListID int64   `json:"list_id,omitempty"`
+	Add    []int64 `json:"add,omitempty"`
+	Remove []int64 `json:"remove,omitempty"`
+
+	// URL is the argument of CanonicalURL and YouTubeLive
+	URL       string              `json:"url,omitempty"`
+	Canonical string              `json:"canonical,omitempty"`
+	Live      *scrapers.LiveVideo `json:"live,omitempty"`
+
+	Error string `json:"error,omitempty"`
+	// ErrorKind is the message of the Err* kind of Error, so replayed errors are handled the same way
+	ErrorKind string `json:"error_kind,omitempty"`
+}
+
+func (i *Interaction) err() error {
+	if i.Error == "" {
+		return nil
+	}
+
+	if i.Error == scrapers.ErrNoVideo.Error() {
+		return scrapers.ErrNoVideo
+	}
+
+	var err = errors.New(i.Error)
+	for _, kind := range errorKinds {
+		if kind.Error() == i.ErrorKind {
+			return &TwitterError{Kind: kind, Err: err}
+		}
+	}
+	return err
+}
+
+// RecordingClient is a TwitterClient and LinkResolver that writes every call and its result to a cassette.
+// Cassettes can be loaded with LoadCassette to reproduce how the bot handled tweets
+type RecordingClient struct {
+	TwitterClient
+
+	links    LinkResolver
+	cassette *decisions.Writer
+}
+
+// NewRecordingClient wraps client and appends all interactions to cassette. Links are resolved using
+// a WebLinkResolver unless SetLinkResolver is called
+func NewRecordingClient(client TwitterClient, cassette *decisions.Writer) *RecordingClient {
+	return &RecordingClient{
+		TwitterClient: client,
+		links:         WebLinkResolver{},
+		cassette:      cassette,
+	}
+}
+
+// SetLinkResolver changes the resolver whose lookups are recorded
+func (r *RecordingClient) SetLinkResolver(links LinkResolver) {
+	r.links = links
+}
+
+func (r *RecordingClient) record(i Interaction, err error) {
+	i.Time = time.Now()
+	if err != nil {
+		i.Error = err.Error()
+
+		var te *TwitterError
+		if errors.As(err, &te) && te.Kind != nil {
+			i.ErrorKind = te.Kind.Error()
+		}
+	}
+
+	// Recording is best-effort, it should never influence what the bot does
+	_ = r.cassette.WriteValue(i)
+}
+
+// Incoming records a tweet the processor is about to handle
+func (r *RecordingClient) Incoming(tweet match.TweetWrapper) {
+	t := tweet.Tweet
+	r.record(Interaction{Method: MethodIncoming, TweetID: t.ID, Tweet: &t, Source: tweet.TweetSource}, nil)
+}
+
+func (r *RecordingClient) LoadStatus(tweetID int64) (t *twitter.Tweet, err error) {
+	t, err = r.TwitterClient.LoadStatus(tweetID)
+	r.record(Interaction{Method: MethodLoadStatus, TweetID: tweetID, Tweet: t}, err)
+	return
+}
+
+func (r *RecordingClient) UpdateListMembers(listID int64, add, remove []int64) (err error) {
+	err = r.TwitterClient.UpdateListMembers(listID, add, remove)
+	r.record(Interaction{Method: MethodUpdateListMembers, ListID: listID, Add: add, Remove: remove}, err)
+	return
+}
+
+func (r *RecordingClient) Retweet(tweet *twitter.Tweet) (err error) {
+	err = r.TwitterClient.Retweet(tweet)
+	r.record(Interaction{Method: MethodRetweet, TweetID: tweet.ID}, err)
+	return
+}
+
+func (r *RecordingClient) UnRetweet(tweetID int64) (err error) {
+	err = r.TwitterClient.UnRetweet(tweetID)
+	r.record(Interaction{Method: MethodUnRetweet, TweetID: tweetID}, err)
+	return
+}
+
+func (r *RecordingClient) Like(tweetID int64) (err error) {
+	err = r.TwitterClient.Like(tweetID)
+	r.record(Interaction{Method: MethodLike, TweetID: tweetID}, err)
+	return
+}
+
+func (r *RecordingClient) Tweet(text string, inReplyToID *int64) (t *twitter.Tweet, err error) {
+	t, err = r.TwitterClient.Tweet(text, inReplyToID)
+
+	var i = Interaction{Method: MethodTweet, Text: text, Tweet: t}
+	if inReplyToID != nil {
+		i.InReplyToID = *inReplyToID
+	}
+	r.record(i, err)
+	return
+}
+
+func (r *RecordingClient) CanonicalURL(url string) (canonical string) {
+	canonical = r.links.CanonicalURL(url)
+	r.record(Interaction{Method: MethodCanonicalURL, URL: url, Canonical: canonical}, nil)
+	return
+}
+
+func (r *RecordingClient) YouTubeLive(url string) (lv scrapers.LiveVideo, err error) {
+	lv, err = r.links.YouTubeLive(url)
+	r.record(Interaction{Method: MethodYouTubeLive, URL: url, Live: &lv}, err)
+	return
+}
+
+// ReplayClient is a TwitterClient and LinkResolver that answers calls with the results recorded in a cassette.
+// Calls made to it are recorded again, so they can be compared to the cassette
+type ReplayClient struct {
+	mu sync.Mutex
+
+	// time is when the incoming tweet that is currently replayed was handled while recording
+	time time.Time
+
+	incoming []Interaction
+	// responses contains recorded results by method and argument, they are returned in order.
+	// The last result is repeated once all of them were returned
+	responses map[string][]Interaction
+
+	calls []Interaction
+}
+
+// LoadCassette loads the cassette in dir that was written by a RecordingClient
+func LoadCassette(dir string) (r *ReplayClient, err error) {
+	var interactions []Interaction
+
+	err = decisions.ReadLines(dir, func(line []byte) error {
+		var i Interaction
+		// The last line of a segment that is still being written might be incomplete
+		if json.Unmarshal(line, &i) != nil {
+			return nil
+		}
+		interactions = append(interactions, i)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("loading cassette %s: %w", dir, err)
+	}
+
+	return NewReplayClient(interactions), nil
+}
+
+// NewReplayClient returns a client that replays the given interactions
+func NewReplayClient(interactions []Interaction) *ReplayClient {
+	var r = &ReplayClient{
+		responses: make(map[string][]Interaction),
+	}
+
+	for _, i := range interactions {
+		if i.Method == MethodIncoming {
+			r.incoming = append(r.incoming, i)
+			continue
+		}
+		key := interactionKey(i)
+		r.responses[key] = append(r.responses[key], i)
+	}
+
+	return r
+}
+
+func interactionKey(i Interaction) string {
+	switch i.Method {
+	case MethodTweet:
+		return fmt.Sprintf("%s:%d:%s", i.Method, i.InReplyToID, i.Text)
+	case MethodUpdateListMembers:
+		return fmt.Sprintf("%s:%d:%v:%v", i.Method, i.ListID, i.Add, i.Remove)
+	case MethodCanonicalURL, MethodYouTubeLive:
+		return i.Method + ":" + i.URL
+	default:
+		return fmt.Sprintf("%s:%d", i.Method, i.TweetID)
+	}
+}
+
+// Incoming returns the tweets the processor received while the cassette was recorded, in order
+func (r *ReplayClient) Incoming() (tweets []match.TweetWrapper) {
+	for _, i := range r.incoming {
+		if i.Tweet == nil {
+			continue
+		}
+		tweets = append(tweets, match.TweetWrapper{TweetSource: i.Source, Tweet: *i.Tweet})
+	}
+	return
+}
+
+// Replay lets p handle the recorded incoming tweets in order, p must use this client. While a tweet is handled,
+// the clock of p is set to the time it was handled at while recording, and links are resolved with the recorded
+// lookups. That way decisions are the same as while recording, even if the cassette is old and linked websites changed
+func (r *ReplayClient) Replay(p *Processor) {
+	p.SetLinkResolver(r)
+	p.SetClock(r.now)
+
+	for _, i := range r.incoming {
+		if i.Tweet == nil {
+			continue
+		}
+
+		r.mu.Lock()
+		r.time = i.Time
+		r.mu.Unlock()
+
+		p.Tweet(match.TweetWrapper{TweetSource: i.Source, Tweet: *i.Tweet})
+	}
+}
+
+// now returns the time of the incoming tweet that is currently replayed
+func (r *ReplayClient) now() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.time.IsZero() {
+		return time.Now()
+	}
+	return r.time
+}
+
+// Calls returns all calls that were made to this client
+func (r *ReplayClient) Calls() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]Interaction(nil), r.calls...)
+}
+
+// replay records the call and returns the recorded response, if there is one
+func (r *ReplayClient) replay(call Interaction) (recorded Interaction, ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.calls = append(r.calls, call)
+
+	key := interactionKey(call)
+	queue := r.responses[key]
+	if len(queue) == 0 {
+		return
+	}
+
+	recorded = queue[0]
+	if len(queue) > 1 {
+		r.responses[key] = queue[1:]
+	}
+
+	return recorded, true
+}
+
+func (r *ReplayClient) LoadStatus(tweetID int64) (*twitter.Tweet, error) {
+	recorded, ok := r.replay(Interaction{Method: MethodLoadStatus, TweetID: tweetID})
+	if !ok {
+		return nil, fmt.Errorf("tweet %d is not in cassette", tweetID)
+	}
+	if recorded.Tweet == nil && recorded.Error == "" {
+		return nil, fmt.Errorf("tweet %d is empty in cassette", tweetID)
+	}
+
+	return recorded.Tweet, recorded.err()
+}
+
+func (r *ReplayClient) UpdateListMembers(listID int64, add, remove []int64) error {
+	recorded, _ := r.replay(Interaction{Method: MethodUpdateListMembers, ListID: listID, Add: add, Remove: remove})
+	return recorded.err()
+}
+
+func (r *ReplayClient) Retweet(tweet *twitter.Tweet) error {
+	recorded, _ := r.replay(Interaction{Method: MethodRetweet, TweetID: tweet.ID})
+	return recorded.err()
+}
+
+func (r *ReplayClient) UnRetweet(tweetID int64) error {
+	recorded, _ := r.replay(Interaction{Method: MethodUnRetweet, TweetID: tweetID})
+	return recorded.err()
+}
+
+func (r *ReplayClient) Like(tweetID int64) error {
+	recorded, _ := r.replay(Interaction{Method: MethodLike, TweetID: tweetID})
+	return recorded.err()
+}
+
+func (r *ReplayClient) Tweet(text string, inReplyToID *int64) (*twitter.Tweet, error) {
+	var call = Interaction{Method: MethodTweet, Text: text}
+	if inReplyToID != nil {
+		call.InReplyToID = *inReplyToID
+	}
+
+	recorded, ok := r.replay(call)
+	if !ok || recorded.Tweet == nil {
+		return &twitter.Tweet{FullText: text}, recorded.err()
+	}
+
+	return recorded.Tweet, recorded.err()
+}
+
+func (r *ReplayClient) CanonicalURL(url string) string {
+	recorded, ok := r.replay(Interaction{Method: MethodCanonicalURL, URL: url})
+	if !ok || recorded.Canonical == "" {
+		return url
+	}
+	return recorded.Canonical
+}
+
+func (r *ReplayClient) YouTubeLive(url string) (scrapers.LiveVideo, error) {
+	recorded, ok := r.replay(Interaction{Method: MethodYouTubeLive, URL: url})
+	if !ok {
+		return scrapers.LiveVideo{}, scrapers.ErrNoVideo
+	}
+	if recorded.Live == nil {
+		return scrapers.LiveVideo{}, recorded.err()
+	}
+	return *recorded.Live, recorded.err()
+}
@@ -0,0 +1,238 @@
+package consumer
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/xarantolus/spacex-hop-bot/decisions"
+	"github.com/xarantolus/spacex-hop-bot/match"
+	"github.com/xarantolus/spacex-hop-bot/scrapers"
+)
+
+func TestCassette_RecordAndReplayThread(t *testing.T) {
+	var created = time.Now().Add(-time.Minute).Format(time.RubyDate)
+
+	var user = &twitter.User{ID: 80, ScreenName: "starbase_photos"}
+	var parent = &twitter.Tweet{
+		ID:        50,
+		IDStr:     "50",
+		CreatedAt: created,
+		User:      user,
+		FullText:  "Ship 25 rolled out to the launch site this morning",
+		Entities:  &twitter.Entities{Media: []twitter.MediaEntity{{ID: 1024}}},
+	}
+	var reply = twitter.Tweet{
+		ID:                  51,
+		IDStr:               "51",
+		CreatedAt:           created,
+		User:                user,
+		FullText:            "Here is another angle",
+		InReplyToStatusID:   parent.ID,
+		InReplyToUserID:     user.ID,
+		InReplyToScreenName: user.ScreenName,
+		Entities:            &twitter.Entities{Media: []twitter.MediaEntity{{ID: 1025}}},
+	}
+
+	// Record how the bot handles the reply. The parent is only known to the API, not to the processor
+	var testClient = &TestTwitterClient{
+		retweetedTweetIDs: make(map[int64]bool),
+		tweets:            map[int64]*twitter.Tweet{parent.ID: parent},
+	}
+
+	cassette := filepath.Join(t.TempDir(), "cassette")
+	w, err := decisions.NewWriter(cassette, decisions.Options{})
+	if err != nil {
+		t.Fatalf("creating cassette: %s", err.Error())
+	}
+	recorder := NewRecordingClient(testClient, w)
+
+	proc := NewProcessor(false, true, recorder, &twitter.User{ID: testBotSelfUserID}, match.NewStarshipMatcherForTests())
+
+	incoming := match.TweetWrapper{TweetSource: match.TweetSourceKnownList, Tweet: reply}
+	recorder.Incoming(incoming)
+	proc.Tweet(incoming)
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("closing cassette: %s", err.Error())
+	}
+
+	// Now replay it without the test client
+	replay, err := LoadCassette(cassette)
+	if err != nil {
+		t.Fatalf("loading cassette: %s", err.Error())
+	}
+
+	tweets := replay.Incoming()
+	if len(tweets) != 1 || tweets[0].Tweet.ID != reply.ID || tweets[0].TweetSource != match.TweetSourceKnownList {
+		t.Fatalf("unexpected incoming tweets %+v", tweets)
+	}
+
+	proc = NewProcessor(false, true, replay, &twitter.User{ID: testBotSelfUserID}, match.NewStarshipMatcherForTests())
+	replay.Replay(proc)
+
+	recorded, err := LoadCassette(cassette)
+	if err != nil {
+		t.Fatalf("loading cassette again: %s", err.Error())
+	}
+
+	var want, got []string
+	for _, rs := range recorded.responses {
+		for _, i := range rs {
+			want = append(want, interactionKey(i))
+		}
+	}
+	for _, i := range replay.Calls() {
+		got = append(got, interactionKey(i))
+	}
+
+	if len(want) == 0 {
+		t.Fatalf("nothing was recorded")
+	}
+	if !containsKey(want, "LoadStatus:50") {
+		t.Errorf("expected parent lookup to be recorded, got %v", want)
+	}
+	if !sameKeys(want, got) {
+		t.Errorf("replay made different calls than the recording:\nrecorded: %v\nreplayed: %v", want, got)
+	}
+
+	// Everything that was retweeted during recording must also be retweeted when replaying
+	if testClient.HasRetweeted(reply.ID) != containsKey(got, "Retweet:51") {
+		t.Errorf("replay decided differently about the reply")
+	}
+}
+
+func TestCassette_ReplayOldCassette(t *testing.T) {
+	// The cassette was recorded a few days ago, when the tweets were new
+	var (
+		recordedAt = time.Now().Add(-72 * time.Hour)
+		created    = recordedAt.Add(-time.Minute).Format(time.RubyDate)
+		user       = &twitter.User{ID: 80, ScreenName: "starbase_photos"}
+	)
+
+	var live = twitter.Tweet{
+		ID:        60,
+		IDStr:     "60",
+		CreatedAt: created,
+		User:      user,
+		FullText:  "Ship 25 static fire today, watch here https://t.co/live",
+		Entities:  &twitter.Entities{Urls: []twitter.URLEntity{{URL: "https://t.co/live", ExpandedURL: "https://youtu.be/abcdef"}}},
+	}
+	var article = twitter.Tweet{
+		ID:        61,
+		IDStr:     "61",
+		CreatedAt: created,
+		User:      user,
+		FullText:  "Ship 25 static fire today https://t.co/article",
+		Entities: &twitter.Entities{
+			Urls:  []twitter.URLEntity{{URL: "https://t.co/article", ExpandedURL: "https://bit.ly/starship"}},
+			Media: []twitter.MediaEntity{{ID: 1026}},
+		},
+	}
+
+	cassette := filepath.Join(t.TempDir(), "cassette")
+	w, err := decisions.NewWriter(cassette, decisions.Options{})
+	if err != nil {
+		t.Fatalf("creating cassette: %s", err.Error())
+	}
+	for _, i := range []Interaction{
+		{Time: recordedAt, Method: MethodIncoming, TweetID: live.ID, Tweet: &live, Source: match.TweetSourceLocationStream},
+		{Time: recordedAt, Method: MethodYouTubeLive, URL: "https://youtu.be/abcdef", Live: &scrapers.LiveVideo{VideoID: "abcdef", IsLive: true}},
+		{Time: recordedAt, Method: MethodRetweet, TweetID: live.ID},
+		{Time: recordedAt, Method: MethodIncoming, TweetID: article.ID, Tweet: &article, Source: match.TweetSourceKnownList},
+		// The short link used to lead to a website we ignore
+		{Time: recordedAt, Method: MethodCanonicalURL, URL: "https://bit.ly/starship", Canonical: "https://opensea.io/assets/starship"},
+	} {
+		if err := w.WriteValue(i); err != nil {
+			t.Fatalf("writing cassette: %s", err.Error())
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("closing cassette: %s", err.Error())
+	}
+
+	replay, err := LoadCassette(cassette)
+	if err != nil {
+		t.Fatalf("loading cassette: %s", err.Error())
+	}
+
+	proc := NewProcessor(false, true, replay, &twitter.User{ID: testBotSelfUserID}, match.NewStarshipMatcherForTests())
+	replay.Replay(proc)
+
+	var got []string
+	for _, i := range replay.Calls() {
+		got = append(got, interactionKey(i))
+	}
+
+	// Without the recorded clock, both tweets would be too old. Without the recorded lookups, the first one
+	// would not be a live stream and the second one would not be ignored
+	if !containsKey(got, "Retweet:60") {
+		t.Errorf("expected tweet linking a recorded live stream to be retweeted, calls: %v", got)
+	}
+	if containsKey(got, "Retweet:61") {
+		t.Errorf("expected tweet with recorded link to ignored website not to be retweeted, calls: %v", got)
+	}
+	if !containsKey(got, "YouTubeLive:https://youtu.be/abcdef") || !containsKey(got, "CanonicalURL:https://bit.ly/starship") {
+		t.Errorf("expected link lookups to be answered by the cassette, calls: %v", got)
+	}
+}
+
+func TestReplayClient_Responses(t *testing.T) {
+	replay := NewReplayClient([]Interaction{
+		{Method: MethodLoadStatus, TweetID: 1, Error: "No status found with that ID."},
+		{Method: MethodLoadStatus, TweetID: 2, Tweet: &twitter.Tweet{ID: 2, FullText: "first"}},
+		{Method: MethodLoadStatus, TweetID: 2, Tweet: &twitter.Tweet{ID: 2, FullText: "second"}},
+		{Method: MethodRetweet, TweetID: 3, Error: "You have already retweeted this Tweet.", ErrorKind: ErrAlreadyRetweeted.Error()},
+	})
+
+	if _, err := replay.LoadStatus(1); err == nil || err.Error() != "No status found with that ID." {
+		t.Errorf("expected recorded error, got %v", err)
+	}
+
+	// Responses are returned in order, the last one is repeated
+	for _, want := range []string{"first", "second", "second"} {
+		tw, err := replay.LoadStatus(2)
+		if err != nil || tw.FullText != want {
+			t.Errorf("expected %q, got %+v / %v", want, tw, err)
+		}
+	}
+
+	if _, err := replay.LoadStatus(4); err == nil {
+		t.Errorf("expected error for tweet that isn't in the cassette")
+	}
+
+	if err := replay.Retweet(&twitter.Tweet{ID: 3}); !errors.Is(err, ErrAlreadyRetweeted) {
+		t.Errorf("expected recorded retweet error, got %v", err)
+	}
+	if err := replay.Retweet(&twitter.Tweet{ID: 5}); err != nil {
+		t.Errorf("expected calls that were not recorded to succeed, got %s", err.Error())
+	}
+
+	if got := len(replay.Calls()); got != 7 {
+		t.Errorf("expected 7 calls, got %d", got)
+	}
+}
+
+func containsKey(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// sameKeys compares keys regardless of their order, because recorded responses are grouped by key
+func sameKeys(a, b []string) bool {
+	count := func(keys []string) map[string]int {
+		m := make(map[string]int)
+		for _, k := range keys {
+			m[k]++
+		}
+		return m
+	}
+	return reflect.DeepEqual(count(a), count(b))
+}
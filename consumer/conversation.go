@@ -0,0 +1,228 @@
+package consumer
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/xarantolus/spacex-hop-bot/util"
+)
+
+const (
+	// How long a fetched status is kept in the conversation cache
+	conversationCacheTTL = 30 * time.Minute
+	// How many parent tweets we look at when walking up a conversation
+	maxConversationDepth = 15
+	// How many statuses are kept in the conversation cache
+	conversationCacheSize = 5000
+)
+
+type cachedStatus struct {
+	tweet *twitter.Tweet
+	// err is set if the status could not be loaded, e.g. because it was deleted or is protected
+	err error
+}
+
+// conversationNode is what we know about a tweet in a conversation
+type conversationNode struct {
+	// children are the IDs of replies to the tweet we know about
+	children []int64
+
+	// threadMatched is whether the thread up to this tweet was on-topic, it is only valid if threadChecked is set
+	threadChecked, threadMatched bool
+	// reply is whether this tweet ends up being a reply to someone else when walking up its thread,
+	// it is only valid if replyChecked is set
+	replyChecked, reply bool
+}
+
+// conversationCache stores statuses that were loaded while looking at reply chains and how they relate to each other.
+// It makes sure that we don't request the same parent tweets for every reply in a long thread, and that replies
+// to the same tweet can reuse what we already know about their parents
+type conversationCache struct {
+	client TwitterClient
+
+	ttl      time.Duration
+	statuses *util.LRUCache
+
+	// nodesMu must be held while changing a node
+	nodesMu sync.Mutex
+	nodes   *util.LRUCache
+}
+
+func newConversationCache(client TwitterClient, ttl time.Duration) *conversationCache {
+	return &conversationCache{
+		client:   client,
+		ttl:      ttl,
+		statuses: util.NewLRUCache(conversationCacheSize),
+		nodes:    util.NewLRUCache(conversationCacheSize),
+	}
+}
+
+// Get returns the status with the given ID, either from the cache or by loading it.
+// Permanent errors are also cached, so e.g. a protected parent tweet is only requested once
+func (c *conversationCache) Get(tweetID int64) (*twitter.Tweet, error) {
+	var key = strconv.FormatInt(tweetID, 10)
+
+	if v, ok := c.statuses.Get(key); ok {
+		s := v.(cachedStatus)
+		return s.tweet, s.err
+	}
+
+	tweet, err := c.client.LoadStatus(tweetID)
+	if err == nil && tweet == nil {
+		err = &TwitterError{Kind: ErrNotFound, Err: fmt.Errorf("could not load status with id %d", tweetID)}
+	}
+
+	// Temporary errors like rate limits should not hide the status for the whole ttl
+	if err != nil && !IsPermanentError(err) {
+		return nil, err
+	}
+
+	c.statuses.Add(key, cachedStatus{
+		tweet: tweet,
+		err:   err,
+	}, c.ttl)
+	c.link(tweet)
+
+	return tweet, err
+}
+
+// Add stores a status we got from somewhere else, e.g. a timeline
+func (c *conversationCache) Add(tweet *twitter.Tweet) {
+	if tweet == nil {
+		return
+	}
+
+	c.statuses.Add(strconv.FormatInt(tweet.ID, 10), cachedStatus{tweet: tweet}, c.ttl)
+	c.link(tweet)
+}
+
+// node returns the node of the given tweet. If there is none, it is created if create is set, else nil is returned.
+// c.nodesMu must be held
+func (c *conversationCache) node(tweetID int64, create bool) *conversationNode {
+	var key = strconv.FormatInt(tweetID, 10)
+
+	if v, ok := c.nodes.Get(key); ok {
+		return v.(*conversationNode)
+	}
+	if !create {
+		return nil
+	}
+
+	n := &conversationNode{}
+	c.nodes.Add(key, n, c.ttl)
+
+	return n
+}
+
+// link records the tweet as child of the tweet it replies to
+func (c *conversationCache) link(tweet *twitter.Tweet) {
+	if tweet == nil || tweet.InReplyToStatusID == 0 {
+		return
+	}
+
+	c.nodesMu.Lock()
+	defer c.nodesMu.Unlock()
+
+	parent := c.node(tweet.InReplyToStatusID, true)
+	for _, id := range parent.children {
+		if id == tweet.ID {
+			return
+		}
+	}
+	parent.children = append(parent.children, tweet.ID)
+}
+
+// Children returns the IDs of all known replies to the given tweet
+func (c *conversationCache) Children(tweetID int64) []int64 {
+	c.nodesMu.Lock()
+	defer c.nodesMu.Unlock()
+
+	n := c.node(tweetID, false)
+	if n == nil {
+		return nil
+	}
+	return append([]int64(nil), n.children...)
+}
+
+// ThreadResult returns whether the thread up to the given tweet was on-topic the last time it was checked
+func (c *conversationCache) ThreadResult(tweetID int64) (matched, ok bool) {
+	c.nodesMu.Lock()
+	defer c.nodesMu.Unlock()
+
+	n := c.node(tweetID, false)
+	if n == nil {
+		return false, false
+	}
+	return n.threadMatched, n.threadChecked
+}
+
+// SetThreadResult remembers whether the thread up to the given tweet was on-topic
+func (c *conversationCache) SetThreadResult(tweetID int64, matched bool) {
+	c.nodesMu.Lock()
+	defer c.nodesMu.Unlock()
+
+	n := c.node(tweetID, true)
+	n.threadChecked, n.threadMatched = true, matched
+}
+
+// ReplyResult returns whether the given tweet ends up being a reply to someone else, if that is known
+func (c *conversationCache) ReplyResult(tweetID int64) (reply, ok bool) {
+	c.nodesMu.Lock()
+	defer c.nodesMu.Unlock()
+
+	n := c.node(tweetID, false)
+	if n == nil {
+		return false, false
+	}
+	return n.reply, n.replyChecked
+}
+
+// SetReplyResult remembers whether the given tweet ends up being a reply to someone else
+func (c *conversationCache) SetReplyResult(tweetID int64, reply bool) {
+	c.nodesMu.Lock()
+	defer c.nodesMu.Unlock()
+
+	n := c.node(tweetID, true)
+	n.replyChecked, n.reply = true, reply
+}
+
+// Retweeted forgets the thread results of all known replies below the given tweet. They were
+// checked before it was retweeted, so they might be on-topic now
+func (c *conversationCache) Retweeted(tweetID int64) {
+	c.nodesMu.Lock()
+	defer c.nodesMu.Unlock()
+
+	n := c.node(tweetID, false)
+	if n == nil {
+		return
+	}
+
+	var ids = n.children
+	for depth := 0; depth < maxConversationDepth && len(ids) > 0; depth++ {
+		var next []int64
+		for _, id := range ids {
+			n := c.node(id, false)
+			if n == nil {
+				continue
+			}
+			n.threadChecked, n.threadMatched = false, false
+			next = append(next, n.children...)
+		}
+		ids = next
+	}
+}
+
+func (c *conversationCache) Stats() map[string]interface{} {
+	size, hits, misses := c.statuses.Stats()
+	nodes, _, _ := c.nodes.Stats()
+
+	return map[string]interface{}{
+		"hits":   hits,
+		"misses": misses,
+		"size":   size,
+		"nodes":  nodes,
+	}
+}
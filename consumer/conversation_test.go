@@ -0,0 +1,223 @@
+package consumer
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/xarantolus/spacex-hop-bot/match"
+)
+
+// countingTwitterClient counts how often each status was loaded
+type countingTwitterClient struct {
+	TestTwitterClient
+
+	loads map[int64]int
+	// errs are returned instead of loading the status
+	errs map[int64]error
+}
+
+func (c *countingTwitterClient) LoadStatus(tweetID int64) (*twitter.Tweet, error) {
+	c.loads[tweetID]++
+	if err := c.errs[tweetID]; err != nil {
+		return nil, err
+	}
+	return c.TestTwitterClient.LoadStatus(tweetID)
+}
+
+func TestConversationCache(t *testing.T) {
+	client := &countingTwitterClient{
+		TestTwitterClient: TestTwitterClient{
+			retweetedTweetIDs: make(map[int64]bool),
+			tweets: map[int64]*twitter.Tweet{
+				1: {ID: 1},
+				2: {ID: 2, InReplyToStatusID: 1},
+			},
+		},
+		loads: make(map[int64]int),
+	}
+
+	cache := newConversationCache(client, time.Hour)
+
+	for i := 0; i < 3; i++ {
+		if _, err := cache.Get(2); err != nil {
+			t.Fatalf("unexpected error loading status: %s", err.Error())
+		}
+		if _, err := cache.Get(3); err == nil {
+			t.Fatalf("expected error loading unknown status")
+		}
+	}
+
+	if client.loads[2] != 1 || client.loads[3] != 1 {
+		t.Errorf("expected every status to be loaded once, but got %v", client.loads)
+	}
+
+	cache.Add(&twitter.Tweet{ID: 4, InReplyToStatusID: 1})
+	if children := cache.Children(1); !reflect.DeepEqual(children, []int64{2, 4}) {
+		t.Errorf("Children(1) = %v, want %v", children, []int64{2, 4})
+	}
+
+	stats := cache.Stats()
+	if stats["hits"] != uint64(4) || stats["misses"] != uint64(2) {
+		t.Errorf("unexpected cache stats %v", stats)
+	}
+}
+
+func TestConversationCache_temporaryErrors(t *testing.T) {
+	client := &countingTwitterClient{
+		TestTwitterClient: TestTwitterClient{
+			retweetedTweetIDs: make(map[int64]bool),
+			tweets:            make(map[int64]*twitter.Tweet),
+		},
+		loads: make(map[int64]int),
+		errs: map[int64]error{
+			1: &TwitterError{Kind: ErrRateLimited, Err: errors.New("rate limit exceeded")},
+			2: &TwitterError{Kind: ErrNotAuthorized, Err: errors.New("protected")},
+		},
+	}
+
+	cache := newConversationCache(client, time.Hour)
+
+	for i := 0; i < 3; i++ {
+		if _, err := cache.Get(1); !errors.Is(err, ErrRateLimited) {
+			t.Fatalf("expected rate limit error, got %v", err)
+		}
+		if _, err := cache.Get(2); !errors.Is(err, ErrNotAuthorized) {
+			t.Fatalf("expected not authorized error, got %v", err)
+		}
+	}
+
+	if client.loads[1] != 3 {
+		t.Errorf("temporary errors should not be cached, but status was loaded %d times", client.loads[1])
+	}
+	if client.loads[2] != 1 {
+		t.Errorf("permanent errors should be cached, but status was loaded %d times", client.loads[2])
+	}
+}
+
+func TestProcessor_isReplyDepthLimit(t *testing.T) {
+	client := &countingTwitterClient{
+		TestTwitterClient: TestTwitterClient{
+			retweetedTweetIDs: make(map[int64]bool),
+			tweets:            make(map[int64]*twitter.Tweet),
+		},
+		loads: make(map[int64]int),
+	}
+
+	// A very long thread by the same user
+	user := &twitter.User{ID: 80, ScreenName: "someone"}
+	for i := int64(1); i <= 3*maxConversationDepth; i++ {
+		client.tweets[i] = &twitter.Tweet{
+			ID:                i,
+			User:              user,
+			InReplyToStatusID: i - 1,
+			InReplyToUserID:   user.ID,
+		}
+	}
+
+	p := NewProcessor(false, true, client, &twitter.User{ID: testBotSelfUserID}, match.NewStarshipMatcherForTests())
+
+	last := client.tweets[3*maxConversationDepth]
+	if !p.isReply(match.PostFromTweet(last)) {
+		t.Errorf("expected thread that is too long to be treated as reply")
+	}
+	// Looking at it again should not load anything new
+	p.isReply(match.PostFromTweet(last))
+
+	var total int
+	for _, c := range client.loads {
+		total += c
+	}
+	if total > maxConversationDepth {
+		t.Errorf("expected at most %d status loads, but got %d", maxConversationDepth, total)
+	}
+}
+
+func TestProcessor_isReplySiblings(t *testing.T) {
+	client := &countingTwitterClient{
+		TestTwitterClient: TestTwitterClient{
+			retweetedTweetIDs: make(map[int64]bool),
+			tweets:            make(map[int64]*twitter.Tweet),
+		},
+		loads: make(map[int64]int),
+	}
+
+	// A thread by the same user with two replies to its last tweet
+	user := &twitter.User{ID: 80, ScreenName: "someone"}
+	for i := int64(1); i <= 5; i++ {
+		client.tweets[i] = &twitter.Tweet{ID: i, User: user}
+		if i > 1 {
+			client.tweets[i].InReplyToStatusID = i - 1
+			client.tweets[i].InReplyToUserID = user.ID
+		}
+	}
+	siblings := []*twitter.Tweet{
+		{ID: 10, User: user, InReplyToStatusID: 5, InReplyToUserID: user.ID},
+		{ID: 11, User: user, InReplyToStatusID: 5, InReplyToUserID: user.ID},
+	}
+
+	p := NewProcessor(false, true, client, &twitter.User{ID: testBotSelfUserID}, match.NewStarshipMatcherForTests())
+
+	if p.isReply(match.PostFromTweet(siblings[0])) {
+		t.Errorf("expected reply in own thread not to be treated as reply")
+	}
+	_, hits, _ := p.conversations.statuses.Stats()
+
+	// The second reply reuses what the first one found out about their parent
+	if p.isReply(match.PostFromTweet(siblings[1])) {
+		t.Errorf("expected reply in own thread not to be treated as reply")
+	}
+	if _, h, _ := p.conversations.statuses.Stats(); h != hits {
+		t.Errorf("expected sibling reply not to look at the thread again, but it looked up %d statuses", h-hits)
+	}
+}
+
+func TestProcessor_threadSiblings(t *testing.T) {
+	client := &countingTwitterClient{
+		TestTwitterClient: TestTwitterClient{
+			retweetedTweetIDs: make(map[int64]bool),
+			tweets:            make(map[int64]*twitter.Tweet),
+		},
+		loads: make(map[int64]int),
+	}
+
+	// An off-topic thread, Elon Musk replies to its last tweet twice
+	user := &twitter.User{ID: 80, ScreenName: "someone"}
+	for i := int64(1); i <= 5; i++ {
+		client.tweets[i] = &twitter.Tweet{ID: i, User: user, FullText: "What a nice day"}
+		if i > 1 {
+			client.tweets[i].InReplyToStatusID = i - 1
+		}
+	}
+	elon := &twitter.User{ID: 44196397, ScreenName: "elonmusk"}
+	siblings := []*twitter.Tweet{
+		{ID: 10, User: elon, FullText: "Yes", InReplyToStatusID: 5},
+		{ID: 11, User: elon, FullText: "Indeed", InReplyToStatusID: 5},
+	}
+
+	p := NewProcessor(false, true, client, &twitter.User{ID: testBotSelfUserID}, match.NewStarshipMatcherForTests())
+
+	if p.thread(siblings[0], 0) {
+		t.Errorf("expected off-topic thread not to match")
+	}
+	_, hits, _ := p.conversations.statuses.Stats()
+
+	// Only the parent of the second reply is looked at, the rest of the thread is known to be off-topic
+	if p.thread(siblings[1], 0) {
+		t.Errorf("expected off-topic thread not to match")
+	}
+	if _, h, _ := p.conversations.statuses.Stats(); h-hits != 1 {
+		t.Errorf("expected sibling reply to only look up its parent, but it looked up %d statuses", h-hits)
+	}
+
+	// Once the parent is retweeted, replies are checked again
+	p.retweet(client.tweets[5], "normal matcher", match.TweetSourceUnknown)
+	if !p.thread(&twitter.Tweet{ID: 12, User: elon, FullText: "Sure", InReplyToStatusID: 5}, 0) {
+		t.Errorf("expected reply to retweeted tweet to match")
+	}
+	if matched, ok := p.conversations.ThreadResult(10); ok || matched {
+		t.Errorf("expected thread result of reply to be forgotten after its parent was retweeted")
+	}
+}
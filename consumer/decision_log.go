@@ -0,0 +1,71 @@
+package consumer
+
+import (
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/xarantolus/spacex-hop-bot/decisions"
+	"github.com/xarantolus/spacex-hop-bot/match"
+	"github.com/xarantolus/spacex-hop-bot/util"
+)
+
+// SetDecisionLog sets the log every decision of the processor is written to
+func (p *Processor) SetDecisionLog(w *decisions.Writer) {
+	p.decisionLog = w
+}
+
+// startProcessing remembers when processing of a top-level tweet started and makes sure its explanation is recorded.
+// It returns whether tweet is a top-level tweet, in that case finishProcessing must be called when done
+func (p *Processor) startProcessing(tweet *match.TweetWrapper) bool {
+	if !p.processingStart.IsZero() {
+		return false
+	}
+
+	// The explanation is needed for the decision log and quote templates
+	if tweet.Explanation == nil && (p.decisionLog != nil || p.quotes()) {
+		tweet.Explanation = &match.Explanation{}
+	}
+
+	p.processingStart = time.Now()
+	p.explanation = tweet.Explanation
+
+	return true
+}
+
+func (p *Processor) finishProcessing() {
+	p.processingStart = time.Time{}
+	p.explanation = nil
+}
+
+// recordDecision writes the decision about tweet to the decision log
+func (p *Processor) recordDecision(tweet *twitter.Tweet, source match.TweetSource, action, reason string) {
+	if p.decisionLog == nil {
+		return
+	}
+
+	var r = decisions.Record{
+		Time:         p.now(),
+		TweetID:      tweet.ID,
+		Text:         tweet.Text(),
+		Source:       source.String(),
+		Action:       action,
+		Reason:       reason,
+		RulesVersion: p.matcher.RulesVersion(),
+	}
+
+	if tweet.Place != nil {
+		r.Place = tweet.Place.FullName
+	}
+	if tweet.User != nil {
+		r.AuthorID = tweet.User.ID
+		r.Author = tweet.User.ScreenName
+	}
+	if p.explanation != nil {
+		r.Explanation = append([]string(nil), p.explanation.Steps...)
+	}
+	if !p.processingStart.IsZero() {
+		r.LatencyMS = float64(time.Since(p.processingStart).Microseconds()) / 1000
+	}
+
+	util.LogError(p.decisionLog.Write(r), "writing decision for %s", util.TweetURL(tweet))
+}
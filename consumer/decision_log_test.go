@@ -0,0 +1,62 @@
+package consumer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/xarantolus/spacex-hop-bot/decisions"
+	"github.com/xarantolus/spacex-hop-bot/match"
+)
+
+func TestProcessor_decisionLog(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := decisions.NewWriter(dir, decisions.Options{})
+	if err != nil {
+		t.Fatalf("NewWriter: %s", err.Error())
+	}
+
+	client := &TestTwitterClient{
+		retweetedTweetIDs: make(map[int64]bool),
+		tweets:            make(map[int64]*twitter.Tweet),
+	}
+	p := NewProcessor(false, true, client, &twitter.User{ID: testBotSelfUserID}, match.NewStarshipMatcherForTests())
+	p.SetDecisionLog(w)
+
+	for i, text := range []string{"S20 standing on the pad", "Nice weather today"} {
+		p.Tweet(match.TweetWrapper{
+			TweetSource: match.TweetSourceKnownList,
+			Tweet: twitter.Tweet{
+				ID:        int64(100 + i),
+				FullText:  text,
+				CreatedAt: time.Now().Add(-time.Minute).Format(time.RubyDate),
+				User:      &twitter.User{ID: 80, ScreenName: "someone"},
+			},
+		})
+	}
+
+	if err = w.Close(); err != nil {
+		t.Fatalf("Close: %s", err.Error())
+	}
+
+	records, err := decisions.Since(dir, time.Hour)
+	if err != nil {
+		t.Fatalf("reading decisions: %s", err.Error())
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 decisions, but got %d", len(records))
+	}
+
+	retweeted, ignored := records[0], records[1]
+	if retweeted.TweetID != 100 || retweeted.Action != decisions.ActionRetweet || retweeted.Reason != "normal matcher" ||
+		retweeted.Author != "someone" || retweeted.Source != match.TweetSourceKnownList.String() {
+		t.Errorf("unexpected retweet decision %+v", retweeted)
+	}
+	if ignored.TweetID != 101 || ignored.Action != decisions.ActionIgnore || len(ignored.Explanation) == 0 {
+		t.Errorf("unexpected ignore decision %+v", ignored)
+	}
+	if retweeted.RulesVersion == "" || retweeted.RulesVersion != ignored.RulesVersion {
+		t.Errorf("expected the same non-empty rules version, but got %q and %q", retweeted.RulesVersion, ignored.RulesVersion)
+	}
+}
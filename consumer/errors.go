@@ -0,0 +1,214 @@
+package consumer
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/xarantolus/spacex-hop-bot/twitterv2"
+)
+
+// Kinds of errors returned by the Twitter API. TwitterClient methods return errors that can be compared
+// to them using errors.Is
+var (
+	ErrAlreadyRetweeted = errors.New("already retweeted")
+	// ErrNotAuthorized is returned for tweets of protected accounts and accounts that blocked the bot
+	ErrNotAuthorized   = errors.New("not authorized")
+	ErrNotFound        = errors.New("not found")
+	ErrRateLimited     = errors.New("rate limited")
+	ErrSuspended       = errors.New("account suspended")
+	ErrDuplicateStatus = errors.New("duplicate status")
+)
+
+var errorKinds = []error{ErrAlreadyRetweeted, ErrNotAuthorized, ErrNotFound, ErrRateLimited, ErrSuspended, ErrDuplicateStatus}
+
+// TwitterError is an error returned by the Twitter API
+type TwitterError struct {
+	// Kind is one of the Err* variables, or nil if the error is not known
+	Kind error
+
+	// Code is the error code of the v1.1 API
+	Code       int
+	StatusCode int
+
+	// Reset is when the rate limit resets, it is only set for rate limit errors
+	Reset time.Time
+
+	Err error
+}
+
+func (e *TwitterError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TwitterError) Unwrap() error {
+	return e.Err
+}
+
+func (e *TwitterError) Is(target error) bool {
+	return e.Kind != nil && e.Kind == target
+}
+
+// v1ErrorKinds maps error codes of the v1.1 API to error kinds.
+// See https://developer.twitter.com/en/support/twitter-api/error-troubleshooting#error-codes
+var v1ErrorKinds = map[int]error{
+	34:  ErrNotFound,
+	50:  ErrNotFound,
+	63:  ErrSuspended,
+	64:  ErrSuspended,
+	88:  ErrRateLimited,
+	136: ErrNotAuthorized,
+	144: ErrNotFound,
+	179: ErrNotAuthorized,
+	185: ErrRateLimited,
+	187: ErrDuplicateStatus,
+	327: ErrAlreadyRetweeted,
+}
+
+// ClassifyError converts errors of the go-twitter library and the twitterv2 package to a *TwitterError.
+// resp is the response of the request that failed and can be nil. Other errors are returned unchanged
+func ClassifyError(err error, resp *http.Response) error {
+	if err == nil {
+		return nil
+	}
+
+	var te *TwitterError
+	if errors.As(err, &te) {
+		return err
+	}
+
+	var classified = &TwitterError{Err: err}
+	if resp != nil {
+		classified.StatusCode = resp.StatusCode
+	}
+
+	var (
+		v1Err twitter.APIError
+		v2Err *twitterv2.Error
+	)
+	switch {
+	case errors.As(err, &v1Err) && !v1Err.Empty():
+		classified.Code = v1Err.Errors[0].Code
+		classified.Kind = v1ErrorKinds[classified.Code]
+	case errors.As(err, &v2Err):
+		classified.StatusCode = v2Err.StatusCode
+		classified.Kind = v2ErrorKind(v2Err)
+		classified.Reset = v2Err.Reset
+	case resp == nil:
+		// Network errors etc.
+		return err
+	}
+
+	if classified.Kind == nil && classified.StatusCode == http.StatusTooManyRequests {
+		classified.Kind = ErrRateLimited
+	}
+	if classified.Kind == ErrRateLimited && classified.Reset.IsZero() && resp != nil {
+		classified.Reset = rateLimitReset(resp.Header)
+	}
+
+	return classified
+}
+
+func v2ErrorKind(e *twitterv2.Error) error {
+	detail := strings.ToLower(e.Detail)
+
+	switch {
+	case e.StatusCode == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case e.Type == twitterv2.ProblemNotFound:
+		return ErrNotFound
+	case strings.Contains(detail, "suspended"):
+		return ErrSuspended
+	case strings.Contains(detail, "duplicate content"):
+		return ErrDuplicateStatus
+	case e.Type == twitterv2.ProblemNotAuthorized, e.StatusCode == http.StatusUnauthorized && strings.Contains(detail, "not authorized"):
+		return ErrNotAuthorized
+	}
+
+	return nil
+}
+
+// rateLimitReset returns when the rate limit resets according to the x-rate-limit-reset header
+func rateLimitReset(h http.Header) time.Time {
+	sec, err := strconv.ParseInt(h.Get("x-rate-limit-reset"), 10, 64)
+	if err != nil || sec <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
+
+// defaultRateLimitWait is how long to wait after a rate limit error without reset time. Rate limits use 15 minute windows
+const defaultRateLimitWait = 15 * time.Minute
+
+// RetryAfter returns how long to wait before trying again after err, and whether trying again makes sense at all.
+// Rate limits are waited out, errors about the tweet or account itself are permanent and everything else can be
+// retried with the usual delay
+func RetryAfter(err error) (wait time.Duration, retry bool) {
+	if err == nil {
+		return 0, false
+	}
+
+	if errors.Is(err, ErrRateLimited) {
+		var te *TwitterError
+		if errors.As(err, &te) && !te.Reset.IsZero() {
+			if wait = time.Until(te.Reset); wait < 0 {
+				wait = 0
+			}
+			return wait, true
+		}
+		return defaultRateLimitWait, true
+	}
+
+	return 0, !IsPermanentError(err)
+}
+
+// IsPermanentError returns whether doing the same request again will fail in the same way
+func IsPermanentError(err error) bool {
+	for _, kind := range []error{ErrAlreadyRetweeted, ErrNotAuthorized, ErrNotFound, ErrSuspended, ErrDuplicateStatus} {
+		if errors.Is(err, kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// isInaccessible returns whether err means that a tweet cannot be seen by the bot, which happens all the time
+func isInaccessible(err error) bool {
+	return errors.Is(err, ErrNotAuthorized) || errors.Is(err, ErrNotFound) || errors.Is(err, ErrSuspended)
+}
+
+// retryDelay is the delay before the first retry of failed requests, it doubles for every retry
+var retryDelay = time.Second
+
+// maxRetries is how often a request is retried after temporary errors
+const maxRetries = 2
+
+// withRetry calls f until it succeeds, returns a permanent error or maxRetries retries were made.
+// Rate limits are not waited out here, as that would block the processor for minutes
+func withRetry(f func() error) (err error) {
+	return withRetryDone(f, nil)
+}
+
+// withRetryDone is like withRetry for requests that change something, e.g. retweets. A request that failed with
+// a temporary error, e.g. a timeout, might still have been applied. So if a retry fails with done, the change was
+// already made by an earlier attempt and nil is returned. If the first attempt fails with done, it is returned
+func withRetryDone(f func() error, done error) (err error) {
+	var delay = retryDelay
+	for i := 0; ; i++ {
+		err = f()
+
+		if i > 0 && done != nil && errors.Is(err, done) {
+			return nil
+		}
+
+		if _, retry := RetryAfter(err); !retry || i >= maxRetries || errors.Is(err, ErrRateLimited) {
+			return
+		}
+
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
@@ -0,0 +1,143 @@
+package consumer
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/xarantolus/spacex-hop-bot/twitterv2"
+)
+
+func v1Error(code int, message string) error {
+	return twitter.APIError{Errors: []twitter.ErrorDetail{{Code: code, Message: message}}}
+}
+
+func TestClassifyError(t *testing.T) {
+	var reset = time.Now().Add(10 * time.Minute).Truncate(time.Second)
+
+	var rateLimitResp = &http.Response{
+		StatusCode: http.StatusTooManyRequests,
+		Header:     http.Header{},
+	}
+	rateLimitResp.Header.Set("x-rate-limit-reset", strconv.FormatInt(reset.Unix(), 10))
+
+	tests := []struct {
+		name string
+		err  error
+		resp *http.Response
+		want error
+	}{
+		{"already retweeted", v1Error(327, "You have already retweeted this Tweet."), nil, ErrAlreadyRetweeted},
+		{"protected", v1Error(179, "Sorry, you are not authorized to see this status."), nil, ErrNotAuthorized},
+		{"deleted", v1Error(144, "No status found with that ID."), nil, ErrNotFound},
+		{"suspended", v1Error(63, "User has been suspended."), nil, ErrSuspended},
+		{"duplicate", v1Error(187, "Status is a duplicate."), nil, ErrDuplicateStatus},
+		{"rate limit code", v1Error(88, "Rate limit exceeded"), rateLimitResp, ErrRateLimited},
+		{"rate limit status", errors.New("unexpected response"), rateLimitResp, ErrRateLimited},
+		{"v2 not found", &twitterv2.Error{StatusCode: 200, Type: twitterv2.ProblemNotFound}, nil, ErrNotFound},
+		{"v2 protected", &twitterv2.Error{StatusCode: 200, Type: twitterv2.ProblemNotAuthorized}, nil, ErrNotAuthorized},
+		{"v2 duplicate", &twitterv2.Error{StatusCode: 403, Detail: "You are not allowed to create a Tweet with duplicate content."}, nil, ErrDuplicateStatus},
+		{"v2 rate limit", &twitterv2.Error{StatusCode: 429, Reset: reset}, nil, ErrRateLimited},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ClassifyError(tt.err, tt.resp)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("ClassifyError(%v) = %#v, want %v", tt.err, err, tt.want)
+			}
+			if err.Error() != tt.err.Error() {
+				t.Errorf("expected original message %q, got %q", tt.err.Error(), err.Error())
+			}
+
+			if tt.want == ErrRateLimited {
+				wait, retry := RetryAfter(err)
+				if !retry || wait <= 9*time.Minute || wait > 10*time.Minute {
+					t.Errorf("expected to wait until reset, got %s / %v", wait, retry)
+				}
+			}
+		})
+	}
+
+	var apiErr twitter.APIError
+	if !errors.As(ClassifyError(v1Error(144, "No status found with that ID."), nil), &apiErr) || apiErr.Errors[0].Code != 144 {
+		t.Errorf("expected original error to be unwrappable")
+	}
+
+	if err := ClassifyError(v1Error(130, "Over capacity"), nil); IsPermanentError(err) || errors.Is(err, ErrRateLimited) {
+		t.Errorf("expected unknown error to be temporary, got %#v", err)
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+	defer func(d time.Duration) { retryDelay = d }(retryDelay)
+	retryDelay = time.Millisecond
+
+	tests := []struct {
+		name  string
+		err   error
+		calls int
+	}{
+		{"temporary", ClassifyError(v1Error(130, "Over capacity"), nil), maxRetries + 1},
+		{"permanent", ClassifyError(v1Error(144, "No status found with that ID."), nil), 1},
+		{"rate limit", ClassifyError(v1Error(88, "Rate limit exceeded"), nil), 1},
+		{"success", nil, 1},
+	}
+	for _, tt := range tests {
+		var calls int
+		err := withRetry(func() error {
+			calls++
+			return tt.err
+		})
+		if calls != tt.calls || err != tt.err {
+			t.Errorf("%s: expected %d calls, got %d (%v)", tt.name, tt.calls, calls, err)
+		}
+	}
+
+	var calls int
+	err := withRetry(func() error {
+		calls++
+		if calls < 2 {
+			return errors.New("connection reset by peer")
+		}
+		return nil
+	})
+	if err != nil || calls != 2 {
+		t.Errorf("expected success on second try, got %d calls (%v)", calls, err)
+	}
+}
+
+func TestWithRetryDone(t *testing.T) {
+	defer func(d time.Duration) { retryDelay = d }(retryDelay)
+	retryDelay = time.Millisecond
+
+	var (
+		timeout          = errors.New("context deadline exceeded")
+		alreadyRetweeted = ClassifyError(v1Error(327, "You have already retweeted this Tweet."), nil)
+	)
+
+	// The first attempt timed out, but the retweet was still made
+	var calls int
+	err := withRetryDone(func() error {
+		calls++
+		if calls == 1 {
+			return timeout
+		}
+		return alreadyRetweeted
+	}, ErrAlreadyRetweeted)
+	if err != nil || calls != 2 {
+		t.Errorf("expected retry that finds the retweet already done to succeed, got %d calls (%v)", calls, err)
+	}
+
+	// Without an earlier attempt, the tweet was retweeted before
+	calls = 0
+	err = withRetryDone(func() error {
+		calls++
+		return alreadyRetweeted
+	}, ErrAlreadyRetweeted)
+	if !errors.Is(err, ErrAlreadyRetweeted) || calls != 1 {
+		t.Errorf("expected first attempt to return ErrAlreadyRetweeted, got %d calls (%v)", calls, err)
+	}
+}
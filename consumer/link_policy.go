@@ -0,0 +1,341 @@
+package consumer
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xarantolus/spacex-hop-bot/util"
+	"gopkg.in/yaml.v3"
+)
+
+// LinkAction is what happens with tweets that contain a link matching a LinkRule
+type LinkAction string
+
+const (
+	// LinkActionIgnore ignores tweets containing the link
+	LinkActionIgnore LinkAction = "ignore"
+	// LinkActionAllow skips all checks for the link, even if it was seen recently. Other links in the tweet are still checked
+	LinkActionAllow LinkAction = "always-allow"
+	// LinkActionNoDedupTimeout allows the tweet without deduplicating the link or checking other links
+	LinkActionNoDedupTimeout LinkAction = "no-dedup-timeout"
+	// LinkActionDedupDelay deduplicates the link using the delay of the rule instead of the default one
+	LinkActionDedupDelay LinkAction = "dedup-delay"
+)
+
+// LinkRule is a rule for links to a host
+type LinkRule struct {
+	// Host matches the host and all its subdomains, e.g. "faa.gov" also matches "apps.faa.gov"
+	Host string `yaml:"host"`
+	// Path is a glob the path must match. "*" matches within a path segment, "**" matches anything.
+	// An empty path matches all paths
+	Path string `yaml:"path"`
+
+	Action LinkAction `yaml:"action"`
+
+	// DedupDelay is used by LinkActionDedupDelay, e.g. "48h"
+	DedupDelay time.Duration `yaml:"dedup_delay"`
+
+	pathRegex *regexp.Regexp
+}
+
+func (r *LinkRule) String() string {
+	var s = r.Host
+	if r.Path != "" {
+		s += r.Path
+	}
+	s += " -> " + string(r.Action)
+	if r.Action == LinkActionDedupDelay {
+		s += " " + r.DedupDelay.String()
+	}
+	return s
+}
+
+// YouTubeChannel is a channel whose live streams are not deduplicated
+type YouTubeChannel struct {
+	ID   string `yaml:"id"`
+	Name string `yaml:"name"`
+}
+
+// LinkPolicy decides how links in tweets are handled. It is usually loaded from a YAML file like this:
+//
+//	dedup_delay: 12h
+//	rules:
+//	  - host: gofundme.com
+//	    action: ignore
+//	  - host: nasaspaceflight.com
+//	    path: /starbaselive
+//	    action: always-allow
+//	  - host: spacex.com
+//	    action: no-dedup-timeout
+//	  - host: teslarati.com
+//	    path: /spacex-starship-*
+//	    action: dedup-delay
+//	    dedup_delay: 48h
+//	youtube_channels:
+//	  - id: UCSUu1lih2RifWkKtDOJdsBA
+//	    name: NASASpaceflight
+//
+// Rules are checked in order, the first matching rule is used
+type LinkPolicy struct {
+	// DedupDelay is how long after we've seen a link it is ignored. Defaults to seenLinkDelay
+	DedupDelay time.Duration `yaml:"dedup_delay"`
+
+	Rules []LinkRule `yaml:"rules"`
+
+	// YouTubeChannels are channels whose live streams can be retweeted even if they were linked recently,
+	// people often tweet updates with a link to them
+	YouTubeChannels []YouTubeChannel `yaml:"youtube_channels"`
+
+	channels map[string]bool
+}
+
+var defaultLinkPolicy = mustCompileLinkPolicy(&LinkPolicy{
+	Rules: []LinkRule{
+		// NSF live stream
+		{Host: "nasaspaceflight.com", Path: "/starbaselive", Action: LinkActionAllow},
+		// Road closures
+		{Host: "cameroncountytx.gov", Path: "/spacex", Action: LinkActionAllow},
+		// HQ media site often linked with image tweets
+		{Host: "cnunezimages.com", Action: LinkActionAllow},
+		{Host: "apps.fcc.gov", Action: LinkActionAllow},
+		{Host: "apps.faa.gov", Action: LinkActionNoDedupTimeout},
+		{Host: "faa.gov", Action: LinkActionAllow},
+
+		{Host: "gofundme.com", Action: LinkActionIgnore},
+		{Host: "opensea.io", Action: LinkActionIgnore},
+		{Host: "open.spotify.com", Action: LinkActionIgnore},
+		{Host: "music.apple.com", Action: LinkActionIgnore},
+		{Host: "spreadshirt.com", Action: LinkActionIgnore},
+		{Host: "instagram.com", Action: LinkActionIgnore},
+		{Host: "soundcloud.com", Action: LinkActionIgnore},
+		{Host: "blueorigin.com", Action: LinkActionIgnore},
+		{Host: "affinitweet.com", Action: LinkActionIgnore},
+		{Host: "boards.greenhouse.io", Action: LinkActionIgnore},
+		{Host: "etsy.com", Action: LinkActionIgnore},
+		// Most of their articles are paywalled, no additional benefit for retweeting them
+		{Host: "spaceq.ca", Action: LinkActionIgnore},
+
+		{Host: "patreon.com", Action: LinkActionNoDedupTimeout},
+		{Host: "starshipgazer.com", Action: LinkActionNoDedupTimeout},
+		{Host: "spacex.com", Action: LinkActionNoDedupTimeout},
+	},
+	YouTubeChannels: []YouTubeChannel{
+		// Do not ignore NASASpaceflight, people often tweet updates with a link to their 24/7 stream
+		{ID: "UCSUu1lih2RifWkKtDOJdsBA", Name: "NASASpaceflight"},
+		// Same for LabPadre
+		{ID: "UCFwMITSkc1Fms6PoJoh1OUQ", Name: "LabPadre"},
+		{ID: "UCtI0Hodo5o5dUb67FeUjDeA", Name: "SpaceX"},
+		{ID: "UCpThejfzN2EJiXa2mEwdEUw", Name: "Jessica Kirsh"},
+		{ID: "UCBVnapKtPTNYl4phaGXxYng", Name: "Starship Gazer"},
+	},
+})
+
+func mustCompileLinkPolicy(lp *LinkPolicy) *LinkPolicy {
+	err := lp.compile()
+	if err != nil {
+		panic("compiling link policy: " + err.Error())
+	}
+	return lp
+}
+
+// LoadLinkPolicy loads a link policy from the given YAML file
+func LoadLinkPolicy(filename string) (lp *LinkPolicy, err error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	lp = new(LinkPolicy)
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	err = dec.Decode(lp)
+	if err != nil {
+		return nil, err
+	}
+
+	err = lp.compile()
+	if err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+// compile validates the policy and prepares it for matching
+func (lp *LinkPolicy) compile() error {
+	if lp.DedupDelay <= 0 {
+		lp.DedupDelay = seenLinkDelay
+	}
+
+	for i := range lp.Rules {
+		r := &lp.Rules[i]
+
+		r.Host = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(r.Host)), "www.")
+		if r.Host == "" {
+			return fmt.Errorf("rule %d has no host", i+1)
+		}
+
+		switch r.Action {
+		case LinkActionIgnore, LinkActionAllow, LinkActionNoDedupTimeout:
+		case LinkActionDedupDelay:
+			if r.DedupDelay <= 0 {
+				return fmt.Errorf("rule %d (%s) has action %q, but no dedup_delay", i+1, r.Host, r.Action)
+			}
+		default:
+			return fmt.Errorf("rule %d (%s) has unknown action %q", i+1, r.Host, r.Action)
+		}
+
+		if r.Path != "" {
+			r.pathRegex = globRegex(r.Path)
+		}
+	}
+
+	lp.channels = make(map[string]bool)
+	for _, c := range lp.YouTubeChannels {
+		lp.channels[c.ID] = true
+	}
+
+	return nil
+}
+
+// globRegex converts a path glob to a regex. Trailing slashes are ignored
+func globRegex(glob string) *regexp.Regexp {
+	glob = "/" + strings.Trim(glob, "/")
+
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; {
+		case c == '*' && i+1 < len(glob) && glob[i+1] == '*':
+			sb.WriteString(".*")
+			i++
+		case c == '*':
+			sb.WriteString("[^/]*")
+		case c == '?':
+			sb.WriteString("[^/]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+
+	return regexp.MustCompile(sb.String())
+}
+
+// matches returns whether the rule applies to the given (lowercase, without "www.") host and path
+func (r *LinkRule) matches(host, path string) bool {
+	if host != r.Host && !strings.HasSuffix(host, "."+r.Host) {
+		return false
+	}
+
+	return r.pathRegex == nil || r.pathRegex.MatchString("/"+strings.Trim(path, "/"))
+}
+
+// Match returns the first rule that matches the URL, or nil if there is none
+func (lp *LinkPolicy) Match(uri string) *LinkRule {
+	parsed, err := url.ParseRequestURI(uri)
+	if err != nil {
+		return nil
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
+	for i := range lp.Rules {
+		if lp.Rules[i].matches(host, parsed.Path) {
+			return &lp.Rules[i]
+		}
+	}
+
+	return nil
+}
+
+// AllowedYouTubeChannel returns whether live streams of this channel should not be deduplicated
+func (lp *LinkPolicy) AllowedYouTubeChannel(channelID string) bool {
+	return lp.channels[channelID]
+}
+
+// dedupDelay returns how long a link matched by rule should be ignored after it was seen
+func (lp *LinkPolicy) dedupDelay(rule *LinkRule) time.Duration {
+	if rule != nil && rule.Action == LinkActionDedupDelay {
+		return rule.DedupDelay
+	}
+	return lp.DedupDelay
+}
+
+// maxDedupDelay returns the longest time any link is deduplicated for
+func (lp *LinkPolicy) maxDedupDelay() (max time.Duration) {
+	max = lp.DedupDelay
+	for _, r := range lp.Rules {
+		if r.Action == LinkActionDedupDelay && r.DedupDelay > max {
+			max = r.DedupDelay
+		}
+	}
+	return
+}
+
+const linkPolicyCheckInterval = time.Minute
+
+// LinkPolicyFile is a link policy that is reloaded when its file changes. It is safe for concurrent use
+type LinkPolicyFile struct {
+	filename string
+
+	mu        sync.Mutex
+	policy    *LinkPolicy
+	modTime   time.Time
+	lastCheck time.Time
+}
+
+// LoadLinkPolicyFile loads the link policy in filename. Later changes to the file are picked up automatically
+func LoadLinkPolicyFile(filename string) (f *LinkPolicyFile, err error) {
+	stat, err := os.Stat(filename)
+	if err != nil {
+		return
+	}
+
+	policy, err := LoadLinkPolicy(filename)
+	if err != nil {
+		return
+	}
+
+	return &LinkPolicyFile{
+		filename:  filename,
+		policy:    policy,
+		modTime:   stat.ModTime(),
+		lastCheck: time.Now(),
+	}, nil
+}
+
+// Policy returns the current policy. If the file was modified, it is reloaded; if the new version is invalid, the old one is kept
+func (f *LinkPolicyFile) Policy() *LinkPolicy {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if time.Since(f.lastCheck) < linkPolicyCheckInterval {
+		return f.policy
+	}
+	f.lastCheck = time.Now()
+
+	stat, err := os.Stat(f.filename)
+	if util.LogError(err, "checking link policy %s", f.filename) || stat.ModTime().Equal(f.modTime) {
+		return f.policy
+	}
+
+	policy, err := LoadLinkPolicy(f.filename)
+	if util.LogError(err, "reloading link policy %s, keeping the previous version", f.filename) {
+		return f.policy
+	}
+
+	f.policy = policy
+	f.modTime = stat.ModTime()
+
+	log.Printf("[Links] Reloaded link policy from %s with %d rules\n", f.filename, len(policy.Rules))
+
+	return f.policy
+}
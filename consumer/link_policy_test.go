@@ -0,0 +1,217 @@
+package consumer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/xarantolus/spacex-hop-bot/match"
+	"github.com/xarantolus/spacex-hop-bot/scrapers"
+)
+
+const testLinkPolicy = `
+dedup_delay: 6h
+rules:
+  - host: Example.com
+    path: /live/**
+    action: always-allow
+  - host: example.com
+    path: /articles/*/gallery
+    action: ignore
+  - host: www.news.example.org
+    path: /starship-*
+    action: dedup-delay
+    dedup_delay: 48h
+  - host: example.com
+    action: no-dedup-timeout
+youtube_channels:
+  - id: UCSUu1lih2RifWkKtDOJdsBA
+    name: NASASpaceflight
+`
+
+func writeLinkPolicy(t *testing.T, content string) string {
+	t.Helper()
+
+	fn := filepath.Join(t.TempDir(), "links.yaml")
+	if err := os.WriteFile(fn, []byte(content), 0644); err != nil {
+		t.Fatalf("writing link policy: %s", err.Error())
+	}
+	return fn
+}
+
+func TestLinkPolicyMatch(t *testing.T) {
+	policy, err := LoadLinkPolicy(writeLinkPolicy(t, testLinkPolicy))
+	if err != nil {
+		t.Fatalf("LoadLinkPolicy: %s", err.Error())
+	}
+
+	tests := []struct {
+		uri       string
+		want      LinkAction
+		wantDelay time.Duration
+	}{
+		{"https://example.com/live/starbase/cam-1", LinkActionAllow, 6 * time.Hour},
+		{"https://www.example.com/live", LinkActionNoDedupTimeout, 6 * time.Hour},
+		{"https://example.com/articles/starship/gallery/", LinkActionIgnore, 6 * time.Hour},
+		{"https://example.com/articles/a/b/gallery", LinkActionNoDedupTimeout, 6 * time.Hour},
+		{"https://sub.example.com/anything", LinkActionNoDedupTimeout, 6 * time.Hour},
+		{"https://news.example.org/starship-s20-rolls-out", LinkActionDedupDelay, 48 * time.Hour},
+		{"https://news.example.org/falcon-9", "", 6 * time.Hour},
+		{"https://badexample.com/live/x", "", 6 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			rule := policy.Match(tt.uri)
+
+			var got LinkAction
+			if rule != nil {
+				got = rule.Action
+			}
+			if got != tt.want {
+				t.Errorf("Match(%q) has action %q, want %q", tt.uri, got, tt.want)
+			}
+			if delay := policy.dedupDelay(rule); delay != tt.wantDelay {
+				t.Errorf("dedupDelay for %q is %s, want %s", tt.uri, delay, tt.wantDelay)
+			}
+		})
+	}
+
+	if !policy.AllowedYouTubeChannel("UCSUu1lih2RifWkKtDOJdsBA") || policy.AllowedYouTubeChannel("UCFwMITSkc1Fms6PoJoh1OUQ") {
+		t.Errorf("YouTube channel allowlist was not loaded correctly")
+	}
+	if policy.maxDedupDelay() != 48*time.Hour {
+		t.Errorf("expected max dedup delay of 48h, but got %s", policy.maxDedupDelay())
+	}
+}
+
+func TestLoadLinkPolicyInvalid(t *testing.T) {
+	for _, content := range []string{
+		"rules:\n  - host: example.com\n    action: retweet\n",
+		"rules:\n  - host: example.com\n    action: dedup-delay\n",
+		"rules:\n  - action: ignore\n",
+		"unknown_field: true\n",
+	} {
+		if _, err := LoadLinkPolicy(writeLinkPolicy(t, content)); err == nil {
+			t.Errorf("expected error for policy %q", content)
+		}
+	}
+}
+
+func TestLinkPolicyFileReload(t *testing.T) {
+	fn := writeLinkPolicy(t, "rules:\n  - host: example.com\n    action: ignore\n")
+
+	f, err := LoadLinkPolicyFile(fn)
+	if err != nil {
+		t.Fatalf("LoadLinkPolicyFile: %s", err.Error())
+	}
+
+	var reload = func(content string) {
+		if err := os.WriteFile(fn, []byte(content), 0644); err != nil {
+			t.Fatalf("writing link policy: %s", err.Error())
+		}
+		// Make sure the modification is detected
+		f.modTime, f.lastCheck = time.Time{}, time.Time{}
+	}
+
+	reload("rules:\n  - host: example.com\n    action: always-allow\n")
+	if rule := f.Policy().Match("https://example.com"); rule == nil || rule.Action != LinkActionAllow {
+		t.Fatalf("expected policy to be reloaded, but got rule %v", rule)
+	}
+
+	// Invalid policies are not used
+	reload("rules:\n  - host: example.com\n    action: invalid\n")
+	if rule := f.Policy().Match("https://example.com"); rule == nil || rule.Action != LinkActionAllow {
+		t.Errorf("expected previous policy to be kept, but got rule %v", rule)
+	}
+}
+
+func TestProcessor_linkPolicyExplanation(t *testing.T) {
+	f, err := LoadLinkPolicyFile(writeLinkPolicy(t, testLinkPolicy))
+	if err != nil {
+		t.Fatalf("LoadLinkPolicyFile: %s", err.Error())
+	}
+
+	client := &TestTwitterClient{
+		retweetedTweetIDs: make(map[int64]bool),
+		tweets:            make(map[int64]*twitter.Tweet),
+	}
+	p := NewProcessor(false, true, client, &twitter.User{ID: testBotSelfUserID}, match.NewStarshipMatcherForTests())
+	p.SetLinkPolicy(f)
+
+	var explanation match.Explanation
+	tweet := match.TweetWrapper{
+		TweetSource: match.TweetSourceKnownList,
+		Tweet: twitter.Tweet{
+			ID:       1,
+			FullText: "S20 is on the pad https://www.example.com/articles/s20/gallery?utm_source=twitter",
+			User:     &twitter.User{ID: 80, ScreenName: "someone"},
+		},
+		Explanation: &explanation,
+	}
+
+	if !p.shouldIgnoreLink(tweet.Post()) {
+		t.Fatalf("expected link to be ignored")
+	}
+
+	steps := strings.Join(explanation.Steps, "\n")
+	if !strings.Contains(steps, "example.com/articles/*/gallery -> ignore") {
+		t.Errorf("expected explanation to mention the link rule, but got %q", steps)
+	}
+}
+
+type testLinkResolver struct {
+	live map[string]scrapers.LiveVideo
+}
+
+func (r testLinkResolver) CanonicalURL(url string) string {
+	return url
+}
+
+func (r testLinkResolver) YouTubeLive(url string) (scrapers.LiveVideo, error) {
+	lv, ok := r.live[url]
+	if !ok {
+		return lv, scrapers.ErrNoVideo
+	}
+	return lv, nil
+}
+
+func TestProcessor_youTubeLiveStreamNotDeduplicated(t *testing.T) {
+	f, err := LoadLinkPolicyFile(writeLinkPolicy(t, testLinkPolicy))
+	if err != nil {
+		t.Fatalf("LoadLinkPolicyFile: %s", err.Error())
+	}
+
+	const stream = "https://www.youtube.com/watch?v=mhJRzQsLZGg"
+
+	client := &TestTwitterClient{
+		retweetedTweetIDs: make(map[int64]bool),
+		tweets:            make(map[int64]*twitter.Tweet),
+	}
+	p := NewProcessor(false, true, client, &twitter.User{ID: testBotSelfUserID}, match.NewStarshipMatcherForTests())
+	p.SetLinkPolicy(f)
+	p.SetLinkResolver(testLinkResolver{
+		live: map[string]scrapers.LiveVideo{
+			stream: {IsLive: true, ChannelID: "UCSUu1lih2RifWkKtDOJdsBA"},
+		},
+	})
+	// Links are only looked up outside of tests
+	p.test = false
+
+	for i := int64(1); i <= 2; i++ {
+		tweet := match.TweetWrapper{
+			TweetSource: match.TweetSourceKnownList,
+			Tweet: twitter.Tweet{
+				ID:       i,
+				FullText: "Starbase live: S24 rolling to the pad " + stream,
+				User:     &twitter.User{ID: 80, ScreenName: "someone"},
+			},
+		}
+
+		if p.shouldIgnoreLink(tweet.Post()) {
+			t.Fatalf("live stream %q was ignored in tweet %d", stream, i)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package consumer
+
+import (
+	"time"
+
+	"github.com/xarantolus/spacex-hop-bot/scrapers"
+	"github.com/xarantolus/spacex-hop-bot/util"
+)
+
+// LinkResolver looks up where links in tweets lead. The processor does all lookups through it,
+// so they can be recorded to a cassette and replayed without making requests
+type LinkResolver interface {
+	// CanonicalURL returns the canonical URL of the page at url, or url if there is none
+	CanonicalURL(url string) string
+	// YouTubeLive returns the live or upcoming stream at url, or scrapers.ErrNoVideo if there is none
+	YouTubeLive(url string) (scrapers.LiveVideo, error)
+}
+
+// WebLinkResolver resolves links by requesting the linked websites
+type WebLinkResolver struct{}
+
+func (WebLinkResolver) CanonicalURL(url string) string {
+	return util.FindCanonicalURL(url, false)
+}
+
+func (WebLinkResolver) YouTubeLive(url string) (scrapers.LiveVideo, error) {
+	return scrapers.CachedYouTubeLive(url)
+}
+
+// SetLinkResolver changes how links in tweets are looked up. In tests, links are not looked up unless a resolver is set
+func (p *Processor) SetLinkResolver(r LinkResolver) {
+	p.links = r
+}
+
+// SetClock changes what the processor and its matcher consider the current time. It is used to replay decisions
+// that were made in the past
+func (p *Processor) SetClock(now func() time.Time) {
+	p.now = now
+	p.matcher.SetClock(now)
+}
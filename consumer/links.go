@@ -3,74 +3,13 @@ package consumer
 import (
 	"net/url"
 	"regexp"
-	"strings"
-	"time"
 
 	"github.com/xarantolus/spacex-hop-bot/match"
-	"github.com/xarantolus/spacex-hop-bot/scrapers"
 	"github.com/xarantolus/spacex-hop-bot/util"
 	"mvdan.cc/xurls/v2"
 )
 
-var (
-	ignoredHosts = map[string]bool{
-		"gofundme.com":         true,
-		"opensea.io":           true,
-		"open.spotify.com":     true,
-		"music.apple.com":      true,
-		"shop.spreadshirt.com": true,
-		"spreadshirt.com":      true,
-		"instagram.com":        true,
-		"soundcloud.com":       true,
-		"blueorigin.com":       true,
-		"affinitweet.com":      true,
-		"boards.greenhouse.io": true,
-		"etsy.com":             true,
-		// Most of their articles are paywalled, no additional benefit for retweeting them
-		"spaceq.ca": true,
-	}
-
-	// This map contains very important URLs that should usually be retweeted
-	// map[host]path
-	importantURLs = map[string]string{
-		// NSF live stream
-		"nasaspaceflight.com": "/starbaselive",
-		// Road closures
-		"cameroncountytx.gov": "/spacex/",
-
-		// HQ media site often linked with image tweets
-		"cnunezimages.com": "*",
-
-		"apps.fcc.gov": "*",
-		"faa.gov":      "*",
-	}
-
-	highQualityYouTubeStreams = map[string]bool{
-		// Do not ignore NASASpaceflight, people often tweet updates with a link to their 24/7 stream
-		"UCSUu1lih2RifWkKtDOJdsBA": true,
-		// Same for LabPadre
-		"UCFwMITSkc1Fms6PoJoh1OUQ": true,
-		// SpaceX official channel
-		"UCtI0Hodo5o5dUb67FeUjDeA": true,
-
-		// Jessica Kirsh
-		"UCpThejfzN2EJiXa2mEwdEUw": true,
-
-		// Starship Gazer
-		"UCBVnapKtPTNYl4phaGXxYng": true,
-	}
-
-	noTimeoutHosts = map[string]bool{
-		"patreon.com":       true,
-		"faa.gov":           true,
-		"apps.faa.gov":      true,
-		"apps.fcc.gov":      true,
-		"starshipgazer.com": true,
-		"spacex.com":        true,
-	}
-
-	urlRegex *regexp.Regexp
-)
+var urlRegex *regexp.Regexp
 
 func init() {
 	var err error
@@ -80,59 +19,68 @@ func init() {
 	}
 }
 
-func isImportantURL(uri string) (important bool) {
-	parsed, err := url.ParseRequestURI(uri)
-	if err != nil {
-		return
-	}
-
-	host := strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
+// SetLinkPolicy sets the policy that decides how links in tweets are handled
+func (p *Processor) SetLinkPolicy(f *LinkPolicyFile) {
+	p.linkPolicyFile = f
+}
 
-	requiredPath, ok := importantURLs[host]
-	if !ok {
-		return false
+// linkPolicy returns the current link policy
+func (p *Processor) linkPolicy() *LinkPolicy {
+	if p.linkPolicyFile == nil {
+		return defaultLinkPolicy
 	}
-
-	return requiredPath == "*" ||
-		strings.Trim(requiredPath, "/") == strings.Trim(parsed.Path, "/")
+	return p.linkPolicyFile.Policy()
 }
 
-// shouldIgnoreLink returns whether this tweet should be ignored because of a linked article
-func (p *Processor) shouldIgnoreLink(tweet match.TweetWrapper) (ignore bool) {
+// shouldIgnoreLink returns whether this post should be ignored because of a linked article
+func (p *Processor) shouldIgnoreLink(post *match.Post) (ignore bool) {
 	// Get the text *with* URLs
-	var textWithURLs = tweet.TextWithURLs()
+	var textWithURLs = post.TextWithURLs
 
 	// Find all URLs
 	urls := urlRegex.FindAllString(textWithURLs, -1)
 
+	var policy = p.linkPolicy()
+
 	// Now check if any of these URLs is ignored
 	for _, u := range urls {
-		// Ignore important URLs
-		if isImportantURL(u) {
-			tweet.Log("URL %q is important", u)
+		// Tracking parameters, mobile/AMP variants etc. should not make us think this is a new link.
+		// The normalized URL is only used for matching though, requests go to the link as it was posted
+		key := util.NormalizeURL(u)
+
+		rule := policy.Match(key)
+		if rule != nil && rule.Action == LinkActionAllow {
+			post.Log("URL %q is allowed by link rule %s", u, rule)
 			continue
 		}
 
 		var canonical string = u
-		if !p.test {
-			canonical = util.FindCanonicalURL(u, false)
-		}
-		if isImportantURL(canonical) {
-			tweet.Log("URL %q is important", canonical)
-			continue
+		if p.links != nil {
+			canonical = p.links.CanonicalURL(u)
 		}
+		canonicalKey := util.NormalizeURL(canonical)
 
-		parsed, err := url.ParseRequestURI(canonical)
+		// The key has prefixes like "www." or "m." removed, so it is easier to check the host
+		parsed, err := url.ParseRequestURI(canonicalKey)
 		if err != nil {
 			util.LogError(err, "parse canonical for %s", u)
 			continue
 		}
 
-		// Check if the host is ignored
-		host := strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
-		if ignoredHosts[host] {
-			tweet.Log("URL %q has ignored host %q", parsed.String(), host)
-			return true
+		// The canonical URL is more specific, e.g. short links redirect to it
+		if canonicalRule := policy.Match(canonicalKey); canonicalRule != nil {
+			rule = canonicalRule
+		}
+
+		if rule != nil {
+			switch rule.Action {
+			case LinkActionAllow:
+				post.Log("URL %q is allowed by link rule %s", canonical, rule)
+				continue
+			case LinkActionIgnore:
+				post.Log("URL %q is ignored by link rule %s", canonical, rule)
+				return true
+			}
 		}
 
 		// Don't make requests when we're in a test, it makes no sense to have tests depend on behavior
@@ -141,16 +89,18 @@ func (p *Processor) shouldIgnoreLink(tweet match.TweetWrapper) (ignore bool) {
 			continue
 		}
 
-		if noTimeoutHosts[host] {
-			tweet.Log("URL %q is not timeouted because its host is %q", parsed.String(), host)
+		if rule != nil && rule.Action == LinkActionNoDedupTimeout {
+			post.Log("URL %q is not timeouted because of link rule %s", canonical, rule)
 			return false
 		}
 
-		if host == "youtube.com" || host == "youtu.be" {
-			stream, err := scrapers.YouTubeLive(canonical)
+		host := parsed.Hostname()
+		if (host == "youtube.com" || host == "youtu.be") && p.links != nil {
+			stream, err := p.links.YouTubeLive(canonical)
 			if err == nil {
 				// If we know the channel is good, then we don't ignore their live streams
-				if (stream.IsLive || stream.IsUpcoming) && highQualityYouTubeStreams[stream.ChannelID] {
+				if (stream.IsLive || stream.IsUpcoming) && policy.AllowedYouTubeChannel(stream.ChannelID) {
+					post.Log("URL %q is a live stream of allowed YouTube channel %s", canonical, stream.ChannelID)
 					continue
 				}
 
@@ -158,20 +108,24 @@ func (p *Processor) shouldIgnoreLink(tweet match.TweetWrapper) (ignore bool) {
 			}
 		}
 
+		var delay = policy.dedupDelay(rule)
+
 		// If we retweeted this link in the last 12 hours, we should
 		// definitely ignore it
-		lastRetweetTime, ok := p.seenLinks[u]
-		if ok && time.Since(lastRetweetTime) < seenLinkDelay {
+		lastRetweetTime, ok := p.seenLinks[key]
+		if ok && p.now().Sub(lastRetweetTime) < delay {
+			post.Log("URL %q was seen in the last %s", u, delay)
 			return true
 		}
-		lastRetweetTime, ok = p.seenLinks[canonical]
-		if ok && time.Since(lastRetweetTime) < seenLinkDelay {
+		lastRetweetTime, ok = p.seenLinks[canonicalKey]
+		if ok && p.now().Sub(lastRetweetTime) < delay {
+			post.Log("URL %q was seen in the last %s", canonical, delay)
 			return true
 		}
 
 		// Mark this link as seen, but allow a retweet
-		p.seenLinks[u] = time.Now()
-		p.seenLinks[canonical] = time.Now()
+		p.seenLinks[key] = p.now()
+		p.seenLinks[canonicalKey] = p.now()
 
 		p.cleanup(false)
 
@@ -183,3 +137,31 @@ func (p *Processor) shouldIgnoreLink(tweet match.TweetWrapper) (ignore bool) {
 
 	return false
 }
+
+// ignoredByLinkPolicy returns whether a link in this post is ignored by the link policy. Unlike shouldIgnoreLink,
+// it doesn't check or remember when links were seen
+func (p *Processor) ignoredByLinkPolicy(post *match.Post) bool {
+	var policy = p.linkPolicy()
+
+	for _, u := range urlRegex.FindAllString(post.TextWithURLs, -1) {
+		rule := policy.Match(util.NormalizeURL(u))
+		if rule != nil && rule.Action == LinkActionAllow {
+			continue
+		}
+
+		var canonical string = u
+		if p.links != nil {
+			canonical = p.links.CanonicalURL(u)
+		}
+		if canonicalRule := policy.Match(util.NormalizeURL(canonical)); canonicalRule != nil {
+			rule = canonicalRule
+		}
+
+		if rule != nil && rule.Action == LinkActionIgnore {
+			post.Log("URL %q is ignored by link rule %s", canonical, rule)
+			return true
+		}
+	}
+
+	return false
+}
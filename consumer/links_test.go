@@ -1,48 +1,45 @@
 package consumer
 
 import (
-	"strings"
 	"testing"
 )
 
-func TestImportantURLMap(t *testing.T) {
-	for host := range importantURLs {
-		t.Run(host, func(t *testing.T) {
-			if strings.ToLower(host) != host {
-				t.Errorf("host %q must be lowercase in importantURLs", host)
-			}
-			if strings.HasPrefix(host, "www.") {
-				t.Errorf("host %q must not start with 'www.' in importantURLs", host)
-			}
-		})
-	}
-}
-
-func Test_isImportantURL(t *testing.T) {
+func TestDefaultLinkPolicy(t *testing.T) {
 	tests := []struct {
-		uri           string
-		wantImportant bool
+		uri  string
+		want LinkAction
 	}{
-		{"http://nasaspaceflight.com/starbaselive", true},
-		{"https://nasaspaceflight.com/starbaselive", true},
-		{"https://www.nasaspaceflight.com/starbaselive", true},
+		{"http://nasaspaceflight.com/starbaselive", LinkActionAllow},
+		{"https://nasaspaceflight.com/starbaselive", LinkActionAllow},
+		{"https://www.nasaspaceflight.com/starbaselive", LinkActionAllow},
+		{"https://www.nasaspaceflight.com/2022/07/article", ""},
 
-		{"http://cnunezimages.com", true},
-		{"http://cnunezimages.com/", true},
-		{"http://cnunezimages.com/any-link-really", true},
+		{"http://cnunezimages.com", LinkActionAllow},
+		{"http://cnunezimages.com/", LinkActionAllow},
+		{"http://cnunezimages.com/any-link-really", LinkActionAllow},
 
-		{"https://www.cameroncountytx.gov/spacex/", true},
-		{"https://cameroncountytx.gov/spacex/", true},
+		{"https://www.cameroncountytx.gov/spacex/", LinkActionAllow},
+		{"https://cameroncountytx.gov/spacex/", LinkActionAllow},
+		{"https://cameroncountytx.gov/", ""},
 
-		{"https://cameroncountytx.gov/", false},
+		{"https://www.faa.gov/space", LinkActionAllow},
+		{"https://apps.faa.gov/something", LinkActionNoDedupTimeout},
 
-		{"Twitter dot com", false},
-		{"https://twitter.comcom", false},
+		{"https://shop.spreadshirt.com/starship", LinkActionIgnore},
+		{"https://www.instagram.com/p/abc", LinkActionIgnore},
+		{"https://notinstagram.com/p/abc", ""},
+
+		{"Twitter dot com", ""},
+		{"https://twitter.comcom", ""},
 	}
 	for _, tt := range tests {
-		t.Run(t.Name(), func(t *testing.T) {
-			if gotImportant := isImportantURL(tt.uri); gotImportant != tt.wantImportant {
-				t.Errorf("isImportantURL(%q) = %v, want %v", tt.uri, gotImportant, tt.wantImportant)
+		t.Run(tt.uri, func(t *testing.T) {
+			var got LinkAction
+			if rule := defaultLinkPolicy.Match(tt.uri); rule != nil {
+				got = rule.Action
+			}
+			if got != tt.want {
+				t.Errorf("defaultLinkPolicy.Match(%q) has action %q, want %q", tt.uri, got, tt.want)
 			}
 		})
 	}
@@ -1,11 +1,14 @@
 package consumer
 
 import (
+	"errors"
 	"log"
-	"strings"
+	"text/template"
 	"time"
 
 	"github.com/dghubble/go-twitter/twitter"
+	"github.com/xarantolus/spacex-hop-bot/config"
+	"github.com/xarantolus/spacex-hop-bot/decisions"
 	"github.com/xarantolus/spacex-hop-bot/match"
 	"github.com/xarantolus/spacex-hop-bot/util"
 )
@@ -19,6 +22,9 @@ type Processor struct {
 
 	matcher *match.StarshipMatcher
 
+	// shadow evaluates a candidate matcher next to matcher, without acting on its decisions
+	shadow *shadowEvaluator
+
 	selfUser *twitter.User
 
 	// map[URL]last Retweet time
@@ -26,21 +32,46 @@ type Processor struct {
 
 	seenTweets      map[int64]bool
 	retweetedTweets map[int64]bool
+	quotedTweets    map[int64]bool
+	likedTweets     map[int64]bool
+
+	// likeTier defines which tweets are liked because they narrowly missed being retweeted
+	likeTier    config.LikeTier
+	recentLikes []recentLike
+
+	// actions maps retweet reasons to the action that should be taken instead of a retweet
+	actions   map[string]Action
+	quoteTmpl *template.Template
+
+	// conversations caches statuses we load while walking up reply chains
+	conversations *conversationCache
+
+	// linkPolicyFile decides how links are handled, if it is nil the default policy is used
+	linkPolicyFile *LinkPolicyFile
+	// links looks up linked websites, it is nil in tests
+	links LinkResolver
 
-	spacePeopleListID      int64
-	spacePeopleListMembers map[int64]bool
+	// spacePeople is the list retweeted users are added to
+	spacePeople *SpacePeopleList
+
+	// decisionLog records every decision, processingStart and explanation belong to the tweet that is currently processed
+	decisionLog     *decisions.Writer
+	processingStart time.Time
+	explanation     *match.Explanation
 
 	startTime time.Time
+	// now returns the current time, it is only changed when replaying a cassette
+	now func() time.Time
 }
 
 const (
 	articlesFilename = "articles.json"
-	// How long after we've seen a link will we allow it to be retweeted again?
+	// How long after we've seen a link will we allow it to be retweeted again? Can be changed by the link policy
 	seenLinkDelay = 12 * time.Hour
 )
 
 // NewProcessor returns a new processor with the given options
-func NewProcessor(debug bool, inTest bool, client TwitterClient, selfUser *twitter.User, matcher *match.StarshipMatcher, spacePeopleListID int64) *Processor {
+func NewProcessor(debug bool, inTest bool, client TwitterClient, selfUser *twitter.User, matcher *match.StarshipMatcher) *Processor {
 	p := &Processor{
 		debug:   debug,
 		test:    inTest,
@@ -51,16 +82,22 @@ func NewProcessor(debug bool, inTest bool, client TwitterClient, selfUser *twitt
 
 		seenLinks: make(map[string]time.Time),
 
-		spacePeopleListID: spacePeopleListID,
+		conversations: newConversationCache(client, conversationCacheTTL),
+
+		seenTweets:      make(map[int64]bool),
+		retweetedTweets: make(map[int64]bool),
+		quotedTweets:    make(map[int64]bool),
+		likedTweets:     make(map[int64]bool),
 
-		seenTweets:             make(map[int64]bool),
-		retweetedTweets:        make(map[int64]bool),
-		spacePeopleListMembers: make(map[int64]bool),
+		quoteTmpl: defaultQuoteTmpl,
 
 		startTime: time.Now(),
+		now:       time.Now,
 	}
 
 	if !p.test {
+		p.links = WebLinkResolver{}
+
 		util.LogError(util.LoadJSON(articlesFilename, &p.seenLinks), "loading links")
 
 		p.cleanup(true)
@@ -70,14 +107,30 @@ func NewProcessor(debug bool, inTest bool, client TwitterClient, selfUser *twitt
 }
 
 func (p *Processor) Stats() map[string]interface{} {
-	return map[string]interface{}{
+	var stats = map[string]interface{}{
 		"tweets_seen_count":      len(p.seenTweets),
 		"tweets_retweeted_count": len(p.retweetedTweets),
+		"tweets_quoted_count":    len(p.quotedTweets),
+		"tweets_liked_count":     len(p.likedTweets),
 		"user":                   p.selfUser,
 		"seen_links":             p.seenLinks,
+		"conversation_cache":     p.conversations.Stats(),
+		"fetch":                  util.DefaultFetcher.Stats(),
 		"start_time":             p.startTime,
 		"uptime":                 time.Since(p.startTime).String(),
 	}
+
+	if p.shadow != nil {
+		stats["shadow"] = p.shadow.Stats()
+	}
+	if p.spacePeople != nil {
+		stats["space_people_list"] = p.spacePeople.Stats()
+	}
+	if pc, ok := p.client.(*PublishingClient); ok {
+		stats["publish"] = pc.Stats()
+	}
+
+	return stats
 }
 
 // Tweet processes the given tweet and checks whether it should be retweeted.
@@ -102,6 +155,11 @@ func (p *Processor) Tweet(tweet match.TweetWrapper) {
 		return
 	}
 
+	topLevel := p.startProcessing(&tweet)
+	if topLevel {
+		defer p.finishProcessing()
+	}
+
 	// Some tweets are truncated, this means that twitter did not send the full text of the tweet.
 	// Not 100% sure why this happens, but it happens
 	if tweet.Truncated {
@@ -114,14 +172,22 @@ func (p *Processor) Tweet(tweet match.TweetWrapper) {
 		tweet = tweet.Wrap(t)
 	}
 
+	// Tweets we look at because of another tweet, e.g. retweeted ones, are already compared with their top-level tweet
+	if p.shadow != nil && topLevel {
+		p.shadow.compare(p.matcher, p.decisionLog, tweet)
+	}
+
+	// Everything we look at is read from the post, the tweet is only needed to act on it
+	post := tweet.Post()
+
 	switch {
-	case isElonTweet(tweet):
+	case isElonTweet(post):
 		// When elon drops starship info, we want to retweet it.
 		// We basically detect if the thread/tweet is about starship and
 		// retweet everything that is appropriate
 		tweet.Log("is elon tweet")
-		p.thread(&tweet.Tweet)
-	case isSpaceXTweet(tweet):
+		p.thread(&tweet.Tweet, 0)
+	case isSpaceXTweet(post):
 		tweet.Log("is SpaceX tweet")
 		if tweet.QuotedStatus != nil {
 			p.Tweet(tweet.Wrap(tweet.QuotedStatus))
@@ -129,7 +195,7 @@ func (p *Processor) Tweet(tweet match.TweetWrapper) {
 		if tweet.RetweetedStatus != nil {
 			p.Tweet(tweet.Wrap(tweet.RetweetedStatus))
 		}
-		if p.isStarshipTweet(tweet) {
+		if p.isStarshipTweet(post) {
 			p.retweet(&tweet.Tweet, "SpaceX tweet", tweet.TweetSource)
 		}
 	case tweet.RetweetedStatus != nil:
@@ -142,24 +208,24 @@ func (p *Processor) Tweet(tweet match.TweetWrapper) {
 		// If we have a Starship-Tweet quoting a tweet that does not contain antikeywords,
 		// we assume that the quoted tweet also contains relevant information
 
-		if p.seenTweets[tweet.QuotedStatusID] && !match.IsImportantAcount(tweet.User) {
+		if p.seenTweets[tweet.QuotedStatusID] && !match.IsImportantAcount(post.Author) {
 			tweet.Log("already saw this quoted tweet")
 			break
 		}
 
 		// If the quoted tweet already is about starship, we maybe only look at that one
 		quotedWrap := tweet.Wrap(tweet.QuotedStatus)
-		if p.isStarshipTweet(quotedWrap) {
+		if p.isStarshipTweet(quotedWrap.Post()) {
 			tweet.Log("quoted is starship tweet")
 			// If it's from the *same* user, then we just assume they added additional info.
 			// We only retweet if it's media though
-			if sameUser(&tweet.Tweet, tweet.QuotedStatus) {
+			if post.SameAuthor(post.Quoted) {
 				tweet.Log("quoted is starship tweet with same user")
-				if hasMedia(tweet.QuotedStatus) {
+				if post.Quoted.HasMedia() {
 					tweet.Log("quoted is starship tweet with same user and has media")
 					p.retweet(tweet.QuotedStatus, "quoted media", tweet.TweetSource)
 				}
-				if hasMedia(&tweet.Tweet) && p.isStarshipTweet(tweet) {
+				if post.HasMedia() && p.isStarshipTweet(post) {
 					tweet.Log("quoting starship tweet has media")
 					p.retweet(&tweet.Tweet, "quoting starship tweet with media", tweet.TweetSource)
 				}
@@ -170,13 +236,13 @@ func (p *Processor) Tweet(tweet match.TweetWrapper) {
 		}
 
 		// The quoting tweet should be about starship AND have media
-		if !(p.isStarshipTweet(tweet) && (hasMedia(&tweet.Tweet) || match.IsImportantAcount(tweet.User))) {
+		if !(p.isStarshipTweet(post) && (post.HasMedia() || match.IsImportantAcount(post.Author))) {
 			tweet.Log("quoting tweet is not starship tweet with media")
 			break
 		}
 
 		// Make sure the quoted user is not ignored
-		if p.matcher.IsOrMentionsIgnoredAccount(tweet.QuotedStatus) {
+		if p.matcher.IsOrMentionsIgnoredAccount(post.Quoted) {
 			tweet.Log("quoting tweet user ignored")
 			break
 		}
@@ -189,12 +255,11 @@ func (p *Processor) Tweet(tweet match.TweetWrapper) {
 	case tweet.InReplyToStatusID != 0:
 		tweet.Log("tweet is reply")
 
-		parentTweet, err := p.client.LoadStatus(tweet.InReplyToStatusID)
+		parentTweet, err := p.loadStatus(tweet.InReplyToStatusID)
 		if err != nil {
 			// Most errors happen because we're not allowed to see protected accounts' tweets.
 			// We don't log these errors
-			if !strings.Contains(err.Error(), "179 Sorry, you are not authorized to see this status.") &&
-				!strings.Contains(err.Error(), "144 No status found with that ID") {
+			if !isInaccessible(err) {
 				util.LogError(err, "loading parent of %s", util.TweetURL(&tweet.Tweet))
 			}
 			break
@@ -206,25 +271,26 @@ func (p *Processor) Tweet(tweet match.TweetWrapper) {
 		// - the current tweet doesn't contain antiKeywords
 		// - it's from the same user who started the thread (looking through all tweets above parent tweet)
 		// then we want to go to the retweeting part below
-		isStarshipTweet := p.matcher.StarshipTweet(tweet)
-		hasAntiKeywords := match.ContainsStarshipAntiKeyword(tweet.Text())
-		hasMedia := hasMedia(&tweet.Tweet)
+		isStarshipTweet := p.matcher.StarshipPost(post)
+		hasAntiKeywords := match.ContainsStarshipAntiKeyword(post.Text)
+		hasMedia := post.HasMedia()
+		parent := match.PostFromTweet(parentTweet)
 		tweet.Log("reply is isStarshipTweet=%v, hasAntiKeywords=%v", isStarshipTweet, hasAntiKeywords)
 
 		if !(((parentTweet.Retweeted && hasMedia) ||
 			isStarshipTweet) &&
 			!hasAntiKeywords &&
-			!(isQuestion(&tweet.Tweet) && !hasMedia) &&
-			!isReactionGIF(&tweet.Tweet) &&
-			sameUser(parentTweet, &tweet.Tweet) &&
-			!p.isReply(parentTweet)) {
+			!(isQuestion(post) && !hasMedia) &&
+			!post.IsReactionGIF() &&
+			parent.SameAuthor(post) &&
+			!p.isReply(parent)) {
 			tweet.Log("reply does not match complex criteria")
 			break
 		}
 
 		tweet.Log("reply did match complex criteria")
 		fallthrough
-	case p.isStarshipTweet(tweet):
+	case p.isStarshipTweet(post):
 		// If the tweet itself is about starship, we retweet it
 		// We already filtered out replies, which is important because we don't want to
 		// retweet every question someone posts under an elon post, only those that
@@ -235,17 +301,17 @@ func (p *Processor) Tweet(tweet match.TweetWrapper) {
 		tweet.Log("tweet is starship tweet")
 
 		// Filter out non-english tweets (except for location stream)
-		if tweet.TweetSource != match.TweetSourceLocationStream && tweet.Lang != "" && tweet.Lang != "en" && tweet.Lang != "und" {
-			tweet.Log("ignored because tweet is starship tweet with language %s", tweet.Lang)
+		if tweet.TweetSource != match.TweetSourceLocationStream && isIgnoredLanguage(post) {
+			tweet.Log("ignored because tweet is starship tweet with language %s", post.Lang)
 			break
 		}
 
-		if p.shouldIgnoreLink(tweet) {
+		if p.shouldIgnoreLink(post) {
 			tweet.Log("ignored because of link")
 			break
 		}
 
-		if tweet.Tweet.PossiblySensitive {
+		if post.Sensitive {
 			tweet.Log("ignored because it's possibly sensitive")
 			break
 		}
@@ -253,29 +319,41 @@ func (p *Processor) Tweet(tweet match.TweetWrapper) {
 		// Depending on the tweet source, we require media
 		if tweet.TweetSource == match.TweetSourceLocationStream {
 			switch {
-			case hasMedia(&tweet.Tweet):
+			case post.HasMedia():
 				// If it's from the location stream, matches etc. and has media
 				p.retweet(&tweet.Tweet, "normal + location media", tweet.TweetSource)
-			case match.IsPadAnnouncement(tweet.Text()):
+			case match.IsPadAnnouncement(post.Text):
 				// If we have a pad announcement - those are usually tweets without media
 				p.retweet(&tweet.Tweet, "location + pad announcement", tweet.TweetSource)
-			case linksToLiveStream(&tweet.Tweet):
+			case p.linksToLiveStream(post):
 				p.retweet(&tweet.Tweet, "location + live stream", tweet.TweetSource)
 			default:
 				tweet.Log("location tweet ignored because it doesn't have media and is no pad announcement")
 				if !p.test {
 					log.Printf("[Processor] Ignoring %s because it's from the location stream and has no media", util.TweetURL(&tweet.Tweet))
 				}
+				p.like(&tweet.Tweet, borderlineLocationWithoutMedia, tweet.TweetSource)
+			}
+		} else if tweet.TweetSource == match.TweetSourceSearch {
+			// Search results can be from any account, not just those we follow or that are on our lists.
+			// So we only retweet them if they show something
+			switch {
+			case isTagsOnly(post.Text) || isQuestion(post):
+				tweet.Log("search result ignored because it only has tags or is a question")
+			case post.HasMedia():
+				p.retweet(&tweet.Tweet, "search + media", tweet.TweetSource)
+			default:
+				tweet.Log("search result ignored because it doesn't have media")
 			}
 		} else {
 			switch {
-			case isTagsOnly(tweet.Text()):
+			case isTagsOnly(post.Text):
 				// If a tweet contains *only hashtags*, we only retweet it if it has media
 				tweet.Log("tweet only has tags")
-				if hasMedia(&tweet.Tweet) {
+				if post.HasMedia() {
 					p.retweet(&tweet.Tweet, "normal matcher, only tags, but media", tweet.TweetSource)
 				}
-			case match.IsAtSpaceXSite(&tweet.Tweet) && linksToLiveStream(&tweet.Tweet):
+			case match.IsAtSpaceXSite(post) && p.linksToLiveStream(post):
 				p.retweet(&tweet.Tweet, "live stream at spacex site", tweet.TweetSource)
 			default:
 				p.retweet(&tweet.Tweet, "normal matcher", tweet.TweetSource)
@@ -283,56 +361,87 @@ func (p *Processor) Tweet(tweet match.TweetWrapper) {
 		}
 	}
 
+	// Questions about Starship aren't retweeted, but might still be interesting to some
+	if !tweet.Retweeted && p.likeTier.Questions.Enabled && p.isBorderlineQuestion(post) {
+		tweet.Log("tweet is a borderline question")
+		p.like(&tweet.Tweet, borderlineQuestion, tweet.TweetSource)
+	}
+
 	p.seenTweets[tweet.ID] = true
 
-	if !tweet.Retweeted {
-		p.saveNonRetweetedTweet(&tweet.Tweet)
+	// Other replies in the same conversation might need this tweet
+	p.conversations.Add(&tweet.Tweet)
+
+	if !tweet.Retweeted && !tweet.Favorited {
+		p.recordDecision(&tweet.Tweet, tweet.TweetSource, decisions.ActionIgnore, "")
 	}
 }
 
-// retweet retweets the given tweet, but if it fails it doesn't care
+// loadStatus loads a status that is part of a conversation. Statuses are cached for a while, so
+// looking at many replies in the same thread doesn't load the same parent tweets again
+func (p *Processor) loadStatus(tweetID int64) (*twitter.Tweet, error) {
+	t, err := p.conversations.Get(tweetID)
+	if t != nil && p.retweetedTweets[t.ID] {
+		t.Retweeted = true
+	}
+	return t, err
+}
+
+// retweet retweets the given tweet, but if it fails it doesn't care.
+// Depending on the configured action for reason, the tweet might be quoted instead
 func (p *Processor) retweet(tweet *twitter.Tweet, reason string, source match.TweetSource) {
 	// If we have already retweeted a tweet, we don't try to do it again, that just leads to errors
-	if tweet.Retweeted || tweet.RetweetedStatus != nil && tweet.RetweetedStatus.Retweeted {
+	if tweet.Retweeted || tweet.RetweetedStatus != nil && tweet.RetweetedStatus.Retweeted || p.quotedTweets[tweet.ID] {
 		return
 	}
 
-	err := p.client.Retweet(tweet)
+	var (
+		action = p.actionFor(reason)
+		err    error
+	)
+	if action == ActionQuote {
+		action, err = p.quote(tweet, reason, source)
+	} else {
+		err = p.client.Retweet(tweet)
+	}
 	if err != nil {
 		// Twitter often doesn't send the info that we have already retweeted a tweet.
 		// So here we don't log the error if that's the case
-		if !strings.Contains(err.Error(), "327 You have already retweeted this Tweet.") {
-			util.LogError(err, "retweeting %s", util.TweetURL(tweet))
+		if !errors.Is(err, ErrAlreadyRetweeted) {
+			util.LogError(err, "%s of %s", action, util.TweetURL(tweet))
 		}
 		return
 	}
 
 	p.retweetedTweets[tweet.ID] = true
 
-	if !p.test {
-		// save tweet so we can reproduce why it was matched
-		p.saveRetweetedTweet(tweet)
+	// save decision so we can reproduce why it was matched
+	p.recordDecision(tweet, source, string(action), reason)
 
+	if !p.test {
 		// Add the user to our space people list
-		// We ignore those from the location stream as they might not always tweet about starship
-		if source != match.TweetSourceLocationStream {
+		// We ignore those from the location stream and searches as they might not always tweet about starship
+		if source != match.TweetSourceLocationStream && source != match.TweetSourceSearch {
 			p.addSpaceMember(tweet)
 		}
 
 		twurl := util.TweetURL(tweet)
-		log.Printf("[Twitter] Retweeted %s (%s - %s)", twurl, reason, source.String())
+		log.Printf("[Twitter] %s %s (%s - %s)", action.pastTense(), twurl, reason, source.String())
 	}
 
 	// Setting Retweeted can help thread to detect that it should stop
 	tweet.Retweeted = true
+	// Replies below this tweet that were checked before might be on-topic now
+	p.conversations.Retweeted(tweet.ID)
 }
 
 // thread processes tweet threads and retweets everything on-topic.
 // This is useful because Elon Musk often replies to people that quote tweeted/asked a questions on his tweets
 // See this for example: https://twitter.com/elonmusk/status/1372826575293583366
 // or here: https://twitter.com/elonmusk/status/1372725108909957121
-func (p *Processor) thread(tweet *twitter.Tweet) (didRetweet bool) {
-	if tweet == nil {
+// depth is the number of tweets we already walked up in the thread, we stop after maxConversationDepth
+func (p *Processor) thread(tweet *twitter.Tweet, depth int) (didRetweet bool) {
+	if tweet == nil || depth > maxConversationDepth {
 		// Just in case
 		return false
 	}
@@ -343,15 +452,27 @@ func (p *Processor) thread(tweet *twitter.Tweet) (didRetweet bool) {
 		return true
 	}
 	p.seenTweets[tweet.ID] = true
+	p.conversations.Add(tweet)
+
+	// Replies to the same tweet share their parents, so we don't walk up the thread again
+	// if we already know that it's not on-topic
+	if matched, ok := p.conversations.ThreadResult(tweet.ID); ok && !matched {
+		return false
+	}
+	defer func() {
+		p.conversations.SetThreadResult(tweet.ID, didRetweet)
+	}()
 
 	// First process the rest of the thread
 	if tweet.InReplyToStatusID != 0 {
 		// Ok, there was a reply. Check if we can do something with that
-		parent, err := p.client.LoadStatus(tweet.InReplyToStatusID)
-		util.LogError(err, "fetching tweet reply with id %d in thread", tweet.InReplyToStatusID)
+		parent, err := p.loadStatus(tweet.InReplyToStatusID)
+		if !isInaccessible(err) {
+			util.LogError(err, "fetching tweet reply with id %d in thread", tweet.InReplyToStatusID)
+		}
 
 		// If we have a matching tweet thread
-		if err == nil && parent != nil && !match.ContainsStarshipAntiKeyword(parent.Text()) && p.thread(parent) {
+		if err == nil && parent != nil && !match.ContainsStarshipAntiKeyword(parent.Text()) && p.thread(parent, depth+1) {
 			p.seenTweets[parent.ID] = true
 			p.retweet(parent, "thread: matched parent", match.TweetSourceUnknown)
 			didRetweet = true
@@ -360,7 +481,7 @@ func (p *Processor) thread(tweet *twitter.Tweet) (didRetweet bool) {
 
 	// A quoted tweet. Let's see if there's anything interesting
 	if tweet.QuotedStatusID != 0 && tweet.QuotedStatus != nil {
-		if p.thread(tweet.QuotedStatus) {
+		if p.thread(tweet.QuotedStatus, depth+1) {
 			p.retweet(tweet, "thread: quoted", match.TweetSourceUnknown)
 			return true
 		}
@@ -373,8 +494,8 @@ func (p *Processor) thread(tweet *twitter.Tweet) (didRetweet bool) {
 
 	// Now actually match the tweet
 	if didRetweet ||
-		p.matcher.StarshipTweet(match.TweetWrapper{TweetSource: match.TweetSourceUnknown, Tweet: *realTweet}) ||
-		(match.ElonReplyIsStarshipRelated(tweet.Text()) && !isElonTweet(match.Wrap(tweet))) {
+		p.matcher.StarshipPost(match.PostFromTweet(realTweet)) ||
+		(match.ElonReplyIsStarshipRelated(tweet.Text()) && !isElonTweet(match.PostFromTweet(tweet))) {
 		p.retweet(tweet, "thread: matched", match.TweetSourceUnknown)
 		return true
 	}
@@ -382,14 +503,16 @@ func (p *Processor) thread(tweet *twitter.Tweet) (didRetweet bool) {
 	return didRetweet
 }
 
+// SetSpacePeopleList sets the list the authors of retweeted tweets are added to
+func (p *Processor) SetSpacePeopleList(l *SpacePeopleList) {
+	p.spacePeople = l
+}
+
 // addSpaceMember adds the user of the given tweet to the space people list
 func (p *Processor) addSpaceMember(tweet *twitter.Tweet) {
-	if tweet.User == nil || p.spacePeopleListMembers[tweet.User.ID] {
+	if p.spacePeople == nil {
 		return
 	}
 
-	p.spacePeopleListMembers[tweet.User.ID] = true
-
-	err := p.client.AddListMember(p.spacePeopleListID, tweet.User.ID)
-	util.LogError(err, "adding %s to list", tweet.User.ScreenName)
+	p.spacePeople.Retweeted(tweet.User)
 }
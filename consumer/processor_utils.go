@@ -1,13 +1,10 @@
 package consumer
 
 import (
-	"encoding/json"
 	"errors"
-	"os"
+	"strconv"
 	"strings"
-	"time"
 
-	"github.com/dghubble/go-twitter/twitter"
 	"github.com/xarantolus/spacex-hop-bot/match"
 	"github.com/xarantolus/spacex-hop-bot/scrapers"
 	"github.com/xarantolus/spacex-hop-bot/util"
@@ -18,10 +15,13 @@ func (p *Processor) cleanup(save bool) {
 		return
 	}
 
-	var changedLinks = false
+	var (
+		changedLinks = false
+		maxDelay     = p.linkPolicy().maxDedupDelay()
+	)
 
 	for k, d := range p.seenLinks {
-		if time.Since(d) > seenLinkDelay {
+		if p.now().Sub(d) > maxDelay {
 			// No point in keeping this info
 			delete(p.seenLinks, k)
 			changedLinks = true
@@ -33,44 +33,38 @@ func (p *Processor) cleanup(save bool) {
 	}
 }
 
-func isElonTweet(t match.TweetWrapper) bool {
-	return t.User != nil && strings.EqualFold(t.User.ScreenName, "elonmusk")
+func isElonTweet(post *match.Post) bool {
+	return post.IsBy("elonmusk")
 }
-func isSpaceXTweet(t match.TweetWrapper) bool {
-	return t.User != nil && strings.EqualFold(t.User.ScreenName, "SpaceX")
+func isSpaceXTweet(post *match.Post) bool {
+	return post.IsBy("SpaceX")
 }
 
-func sameUser(t1, t2 *twitter.Tweet) bool {
-	return t1.User != nil && t2.User != nil &&
-		(t1.User.ID == t2.User.ID ||
-			strings.EqualFold(t1.User.ScreenName, t2.User.ScreenName))
-}
-
-func (p *Processor) isStarshipTweet(t match.TweetWrapper) bool {
+func (p *Processor) isStarshipTweet(post *match.Post) bool {
 	// At first, we of course need to match some keywords
-	if !p.matcher.StarshipTweet(t) {
-		t.Log("tweet not considered a starship tweet by the matcher")
+	if !p.matcher.StarshipPost(post) {
+		post.Log("tweet not considered a starship tweet by the matcher")
 		return false
 	}
 
 	// However, we don't want reaction gifs
-	if isReactionGIF(&t.Tweet) {
-		t.Log("tweet has a reaction gif")
+	if post.IsReactionGIF() {
+		post.Log("tweet has a reaction gif")
 		return false
 	}
 
 	// Replies to other people should be filtered
-	if p.isReply(&t.Tweet) {
-		t.Log("tweet is a reply to other people")
+	if p.isReply(post) {
+		post.Log("tweet is a reply to other people")
 		return false
 	}
 
 	// If it's a question, we ignore it, except if at the launch site OR has media OR is a pad announcement
-	if isQuestion(&t.Tweet) &&
-		!(match.IsAtSpaceXSite(&t.Tweet) ||
-			hasMedia(&t.Tweet) ||
-			match.IsPadAnnouncement(t.Text())) {
-		t.Log("tweet is a question not (at a spacex site | has media | pad announcement)")
+	if isQuestion(post) &&
+		!(match.IsAtSpaceXSite(post) ||
+			post.HasMedia() ||
+			match.IsPadAnnouncement(post.Text)) {
+		post.Log("tweet is a question not (at a spacex site | has media | pad announcement)")
 		return false
 	}
 
@@ -78,37 +72,96 @@ func (p *Processor) isStarshipTweet(t match.TweetWrapper) bool {
 	return true
 }
 
-// isReply returns if the given tweet is a reply to another user
-func (p *Processor) isReply(t *twitter.Tweet) bool {
-	if t.User == nil || t.InReplyToStatusID == 0 {
+// ShouldRepublish returns whether a post from another platform should be republished. It runs the same checks
+// as Tweet does for posts about Starship, but doesn't remember links as seen. Unlike Tweet, it can be called from
+// other goroutines as long as the post is not from Twitter
+func (p *Processor) ShouldRepublish(post *match.Post) bool {
+	if !p.isStarshipTweet(post) {
+		return false
+	}
+
+	if isIgnoredLanguage(post) {
+		post.Log("ignored because post has language %s", post.Lang)
+		return false
+	}
+
+	if p.ignoredByLinkPolicy(post) {
+		post.Log("ignored because of link")
 		return false
 	}
 
-	if !(t.User.ID == t.InReplyToUserID || strings.EqualFold(t.User.ScreenName, t.InReplyToScreenName)) {
-		return true
+	if post.Sensitive {
+		post.Log("ignored because it's possibly sensitive")
+		return false
 	}
 
-	t, err := p.client.LoadStatus(t.InReplyToStatusID)
-	if err != nil {
+	return true
+}
+
+// isIgnoredLanguage returns if the post is in a language other than English
+func isIgnoredLanguage(post *match.Post) bool {
+	return post.Lang != "" && post.Lang != "en" && post.Lang != "und"
+}
+
+// isReply returns if the given post is a reply to another user
+func (p *Processor) isReply(post *match.Post) bool {
+	reply, _ := p.isReplyAt(post, 0)
+	return reply
+}
+
+// isReplyAt is like isReply, but depth is the number of tweets we already walked up in the thread.
+// known is false if the result is just an assumption, e.g. because the thread was too long
+func (p *Processor) isReplyAt(post *match.Post, depth int) (reply, known bool) {
+	if post.Author == (match.Author{}) || post.InReplyTo == nil {
+		return false, true
+	}
+
+	// If a thread is very long, we just assume it is a reply
+	if depth >= maxConversationDepth {
+		return true, false
+	}
+
+	if !post.IsSelfReply() {
+		return true, true
+	}
+
+	// Only tweets can be loaded to walk further up the thread
+	parentID, err := strconv.ParseInt(post.InReplyTo.ID, 10, 64)
+	if post.Platform != match.PlatformTwitter || err != nil {
+		return true, false
+	}
+
+	// Other replies to the same parent might already have walked up the thread
+	if reply, ok := p.conversations.ReplyResult(parentID); ok {
+		return reply, true
+	}
+
+	t, err := p.loadStatus(parentID)
+	if err != nil || t == nil {
 		// If something goes wrong, we just assume it is a reply
-		return true
+		return true, false
 	}
 
-	return p.isReply(t)
+	reply, known = p.isReplyAt(match.PostFromTweet(t), depth+1)
+	if known {
+		p.conversations.SetReplyResult(parentID, reply)
+	}
+
+	return reply, known
 }
 
-func linksToLiveStream(tweet *twitter.Tweet) bool {
-	if tweet.Entities == nil {
+func (p *Processor) linksToLiveStream(post *match.Post) bool {
+	if p.links == nil {
 		return false
 	}
 
-	for _, u := range tweet.Entities.Urls {
-		if !strings.Contains(u.ExpandedURL, "youtu") {
+	for _, u := range post.URLs {
+		if !strings.Contains(u, "youtu") {
 			continue
 		}
-		liveVid, err := scrapers.YouTubeLive(u.ExpandedURL)
+		liveVid, err := p.links.YouTubeLive(u)
 		if errors.Is(err, scrapers.ErrNoVideo) ||
-			util.LogError(err, "scraping youtube live at %q", u.URL) {
+			util.LogError(err, "scraping youtube live at %q", u) {
 			continue
 		}
 		if liveVid.IsLive || liveVid.IsUpcoming {
@@ -119,49 +172,12 @@ func linksToLiveStream(tweet *twitter.Tweet) bool {
 	return false
 }
 
-func isQuestion(tweet *twitter.Tweet) bool {
-	txt := tweet.Text()
+func isQuestion(post *match.Post) bool {
 	// Make sure we don't match "?" in an URL
-	txt = urlRegex.ReplaceAllString(txt, "")
+	txt := urlRegex.ReplaceAllString(post.Text, "")
 	return strings.Contains(txt, "?")
 }
 
-func isReactionGIF(tweet *twitter.Tweet) bool {
-	if tweet.ExtendedEntities == nil || len(tweet.ExtendedEntities.Media) != 1 {
-		return false
-	}
-
-	// Type of a GIF is animated_gif
-	return strings.Contains(tweet.ExtendedEntities.Media[0].Type, "gif")
-}
-
-func hasMedia(tweet *twitter.Tweet) bool {
-	return tweet.Entities != nil && len(tweet.Entities.Media) > 0 || tweet.ExtendedEntities != nil && len(tweet.ExtendedEntities.Media) > 0
-}
-
-// saveRetweetedTweet appends the given tweet to a JSON file for later inspections, especially in case of wrong retweets
-func (p *Processor) saveRetweetedTweet(tweet *twitter.Tweet) {
-	p.saveTweet(tweet, "retweeted.ndjson")
-}
-
-func (p *Processor) saveNonRetweetedTweet(tweet *twitter.Tweet) {
-	p.saveTweet(tweet, "not_retweeted.ndjson")
-}
-
-func (p *Processor) saveTweet(tweet *twitter.Tweet, filename string) {
-	f, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
-	if err != nil {
-		util.LogError(err, "open tweet file")
-		return
-	}
-	defer f.Close()
-
-	err = json.NewEncoder(f).Encode(tweet)
-	if err != nil {
-		util.LogError(err, "encoding tweet json")
-	}
-}
-
 // isTagsOnly returns if the given text only contains words that start with a tag or hashtag
 func isTagsOnly(text string) bool {
 	var fields = strings.Fields(text)
@@ -3,7 +3,7 @@ package consumer
 import (
 	"testing"
 
-	"github.com/dghubble/go-twitter/twitter"
+	"github.com/xarantolus/spacex-hop-bot/match"
 )
 
 func TestProcessor_isQuestion(t *testing.T) {
@@ -20,10 +20,7 @@ func TestProcessor_isQuestion(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(t.Name(), func(t *testing.T) {
-			var tw = twitter.Tweet{
-				FullText: tt.input,
-			}
-			if got := isQuestion(&tw); got != tt.want {
+			if got := isQuestion(&match.Post{Text: tt.input}); got != tt.want {
 				t.Errorf("Processor.isQuestion(%q) = %v, want %v", tt.input, got, tt.want)
 			}
 		})
@@ -53,42 +50,3 @@ func Test_isTagsOnly(t *testing.T) {
 		})
 	}
 }
-
-func Test_isReactionGIF(t *testing.T) {
-	tests := []struct {
-		tweet *twitter.Tweet
-		want  bool
-	}{
-		{
-			tweet: &twitter.Tweet{
-				ExtendedEntities: &twitter.ExtendedEntity{
-					Media: []twitter.MediaEntity{
-						{
-							Type: "video/gif",
-						},
-					},
-				},
-			},
-			want: true,
-		},
-		{
-			tweet: &twitter.Tweet{
-				ExtendedEntities: &twitter.ExtendedEntity{
-					Media: []twitter.MediaEntity{
-						{
-							Type: "image/jpeg",
-						},
-					},
-				},
-			},
-			want: false,
-		},
-	}
-	for _, tt := range tests {
-		t.Run(t.Name(), func(t *testing.T) {
-			if got := isReactionGIF(tt.tweet); got != tt.want {
-				t.Errorf("isReactionGIF() = %v, want %v", got, tt.want)
-			}
-		})
-	}
-}
@@ -0,0 +1,66 @@
+package consumer
+
+import (
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/xarantolus/spacex-hop-bot/publish"
+	"github.com/xarantolus/spacex-hop-bot/util"
+)
+
+// PublishingClient is a TwitterClient that also publishes everything the bot retweets or tweets to other platforms.
+// Posts are only published after Twitter accepted them, so nothing is published in debug mode
+type PublishingClient struct {
+	TwitterClient
+
+	fanout *publish.Fanout
+}
+
+// NewPublishingClient wraps client so that retweets and tweets are also published to fanout
+func NewPublishingClient(client TwitterClient, fanout *publish.Fanout) *PublishingClient {
+	return &PublishingClient{
+		TwitterClient: client,
+		fanout:        fanout,
+	}
+}
+
+func (c *PublishingClient) Retweet(tweet *twitter.Tweet) (err error) {
+	err = c.TwitterClient.Retweet(tweet)
+	if err != nil {
+		return
+	}
+
+	var author string
+	if tweet.User != nil {
+		author = tweet.User.ScreenName
+	}
+
+	c.fanout.Publish(publish.Post{
+		Kind:   publish.KindRepost,
+		Text:   tweet.TextWithURLs(),
+		URL:    util.TweetURL(tweet),
+		Author: author,
+	})
+
+	return
+}
+
+func (c *PublishingClient) Tweet(text string, inReplyToID *int64) (t *twitter.Tweet, err error) {
+	t, err = c.TwitterClient.Tweet(text, inReplyToID)
+	if err != nil {
+		return
+	}
+
+	// Replies only make sense in their thread on Twitter
+	if inReplyToID == nil {
+		c.fanout.Publish(publish.Post{
+			Kind: publish.KindStatus,
+			Text: text,
+		})
+	}
+
+	return
+}
+
+// Stats returns the stats of all publishing backends
+func (c *PublishingClient) Stats() map[string]publish.Stats {
+	return c.fanout.Stats()
+}
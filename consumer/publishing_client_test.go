@@ -0,0 +1,73 @@
+package consumer
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/xarantolus/spacex-hop-bot/publish"
+)
+
+type collectingPublisher struct {
+	mu    sync.Mutex
+	posts []publish.Post
+}
+
+func (c *collectingPublisher) Name() string { return "collect" }
+
+func (c *collectingPublisher) Publish(ctx context.Context, p publish.Post) error {
+	c.mu.Lock()
+	c.posts = append(c.posts, p)
+	c.mu.Unlock()
+	return nil
+}
+
+func TestPublishingClient(t *testing.T) {
+	var (
+		pub    = &collectingPublisher{}
+		fanout = publish.NewFanout(publish.Options{}, pub)
+		client = NewPublishingClient(&TestTwitterClient{
+			retweetedTweetIDs: make(map[int64]bool),
+		}, fanout)
+	)
+
+	err := client.Retweet(&twitter.Tweet{
+		ID:       15,
+		IDStr:    "15",
+		FullText: "Starship is rolling out",
+		User:     &twitter.User{ScreenName: "someone"},
+	})
+	if err != nil {
+		t.Fatalf("retweeting: %s", err.Error())
+	}
+
+	_, err = client.Tweet("SpaceX is live on YouTube", nil)
+	if err != nil {
+		t.Fatalf("tweeting: %s", err.Error())
+	}
+
+	// Replies are not published
+	var replyTo int64 = 15
+	_, err = client.Tweet("reply", &replyTo)
+	if err != nil {
+		t.Fatalf("replying: %s", err.Error())
+	}
+
+	fanout.Close()
+
+	if len(pub.posts) != 2 {
+		t.Fatalf("expected 2 published posts, got %d", len(pub.posts))
+	}
+
+	repost := pub.posts[0]
+	if repost.Kind != publish.KindRepost || repost.Author != "someone" ||
+		repost.URL != "https://twitter.com/someone/status/15" || repost.Text != "Starship is rolling out" {
+		t.Errorf("unexpected repost %+v", repost)
+	}
+
+	status := pub.posts[1]
+	if status.Kind != publish.KindStatus || status.Text != "SpaceX is live on YouTube" {
+		t.Errorf("unexpected status %+v", status)
+	}
+}
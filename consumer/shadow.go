@@ -0,0 +1,166 @@
+package consumer
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/xarantolus/spacex-hop-bot/decisions"
+	"github.com/xarantolus/spacex-hop-bot/match"
+	"github.com/xarantolus/spacex-hop-bot/util"
+)
+
+// How many disagreements are kept in memory for the API
+const maxShadowDisagreements = 1000
+
+// Disagreement is a tweet where the candidate matcher decided differently than the live one
+type Disagreement struct {
+	TweetID  int64     `json:"tweet_id"`
+	TweetURL string    `json:"tweet_url"`
+	Text     string    `json:"text"`
+	Source   string    `json:"source"`
+	Time     time.Time `json:"time"`
+
+	Live      bool `json:"live"`
+	Candidate bool `json:"candidate"`
+
+	LiveExplanation      []string `json:"live_explanation"`
+	CandidateExplanation []string `json:"candidate_explanation"`
+}
+
+// shadowEvaluator runs a candidate matcher next to the live one without acting on its decisions
+type shadowEvaluator struct {
+	candidate *match.StarshipMatcher
+
+	mu        sync.Mutex
+	evaluated int
+	// disagreed counts all disagreements, while disagreements only keeps the most recent ones
+	disagreed     int
+	disagreements []Disagreement
+}
+
+// SetShadowMatcher sets a candidate matcher that is evaluated for every incoming tweet.
+// Its decisions are never acted upon, but every tweet where it disagrees with the live matcher is recorded
+func (p *Processor) SetShadowMatcher(candidate *match.StarshipMatcher) {
+	if candidate == nil {
+		p.shadow = nil
+		return
+	}
+
+	p.shadow = &shadowEvaluator{
+		candidate: candidate,
+	}
+}
+
+// ShadowDisagreements returns the most recent disagreements between the candidate and live matcher, newest first
+func (p *Processor) ShadowDisagreements() []Disagreement {
+	if p.shadow == nil {
+		return nil
+	}
+
+	return p.shadow.Disagreements()
+}
+
+// compare evaluates both matchers on tweet and records a disagreement if they decide differently.
+// Disagreements are also written to decisionLog if it is set
+func (s *shadowEvaluator) compare(live *match.StarshipMatcher, decisionLog *decisions.Writer, tweet match.TweetWrapper) {
+	// For retweets we care about the decision on the retweeted tweet
+	if tweet.RetweetedStatus != nil {
+		tweet = tweet.Wrap(tweet.RetweetedStatus)
+	}
+
+	liveTweet, candidateTweet := tweet, tweet
+	liveTweet.EnableLogging, candidateTweet.EnableLogging = false, false
+	liveTweet.Explanation, candidateTweet.Explanation = &match.Explanation{}, &match.Explanation{}
+
+	liveDecision := live.StarshipTweet(liveTweet)
+	candidateDecision := s.candidate.StarshipTweet(candidateTweet)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.evaluated++
+
+	if liveDecision == candidateDecision {
+		return
+	}
+
+	s.disagreed++
+
+	tweet.Log("shadow matcher disagrees: live=%v, candidate=%v", liveDecision, candidateDecision)
+
+	d := Disagreement{
+		TweetID:  tweet.ID,
+		TweetURL: util.TweetURL(&tweet.Tweet),
+		Text:     tweet.Text(),
+		Source:   tweet.TweetSource.String(),
+		Time:     time.Now(),
+
+		Live:      liveDecision,
+		Candidate: candidateDecision,
+
+		LiveExplanation:      liveTweet.Explanation.Steps,
+		CandidateExplanation: candidateTweet.Explanation.Steps,
+	}
+
+	s.disagreements = append(s.disagreements, d)
+	if len(s.disagreements) > maxShadowDisagreements {
+		s.disagreements = s.disagreements[len(s.disagreements)-maxShadowDisagreements:]
+	}
+
+	if decisionLog != nil {
+		util.LogError(decisionLog.Write(s.record(tweet, d)), "writing shadow disagreement for %s", d.TweetURL)
+	}
+}
+
+// record returns the decision log record for a disagreement. Its rules version is the one of the candidate matcher,
+// so disagreements can be told apart from the decisions of the live matcher
+func (s *shadowEvaluator) record(tweet match.TweetWrapper, d Disagreement) decisions.Record {
+	var r = decisions.Record{
+		Time:         d.Time,
+		TweetID:      d.TweetID,
+		Text:         d.Text,
+		Source:       d.Source,
+		Action:       decisions.ActionShadowDisagreement,
+		Reason:       fmt.Sprintf("live=%v, candidate=%v", d.Live, d.Candidate),
+		RulesVersion: s.candidate.RulesVersion(),
+	}
+
+	if tweet.Place != nil {
+		r.Place = tweet.Place.FullName
+	}
+	if tweet.User != nil {
+		r.AuthorID = tweet.User.ID
+		r.Author = tweet.User.ScreenName
+	}
+	for _, step := range d.LiveExplanation {
+		r.Explanation = append(r.Explanation, "live: "+step)
+	}
+	for _, step := range d.CandidateExplanation {
+		r.Explanation = append(r.Explanation, "candidate: "+step)
+	}
+
+	return r
+}
+
+func (s *shadowEvaluator) Disagreements() (list []Disagreement) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list = make([]Disagreement, len(s.disagreements))
+	for i, d := range s.disagreements {
+		list[len(list)-1-i] = d
+	}
+
+	return
+}
+
+func (s *shadowEvaluator) Stats() map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return map[string]interface{}{
+		"evaluated":     s.evaluated,
+		"disagreements": s.disagreed,
+	}
+}
@@ -0,0 +1,127 @@
+package consumer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/xarantolus/spacex-hop-bot/decisions"
+	"github.com/xarantolus/spacex-hop-bot/match"
+)
+
+func TestProcessor_shadowMatcher(t *testing.T) {
+	client := &TestTwitterClient{
+		retweetedTweetIDs: make(map[int64]bool),
+		tweets:            make(map[int64]*twitter.Tweet),
+	}
+	p := NewProcessor(false, true, client, &twitter.User{ID: testBotSelfUserID}, match.NewStarshipMatcherForTests())
+
+	dir := t.TempDir()
+	w, err := decisions.NewWriter(dir, decisions.Options{})
+	if err != nil {
+		t.Fatalf("NewWriter: %s", err.Error())
+	}
+	p.SetDecisionLog(w)
+
+	candidate := match.NewCandidateStarshipMatcher(match.NewStarshipMatcherForTests().Ignorer, match.MatcherRules{
+		Keywords:     []string{"mechazilla"},
+		AntiKeywords: []string{"tank parade"},
+	})
+	p.SetShadowMatcher(candidate)
+
+	var texts = []string{
+		"Mechazilla is moving",
+		"Starbase tank parade",
+		"S20 standing on the pad",
+	}
+	for i, text := range texts {
+		p.Tweet(match.TweetWrapper{
+			Tweet: twitter.Tweet{
+				ID:        int64(100 + i),
+				FullText:  text,
+				CreatedAt: time.Now().Add(-time.Minute).Format(time.RubyDate),
+				User:      &twitter.User{ID: 80, ScreenName: "someone"},
+			},
+		})
+	}
+
+	// Shadow mode must never act on candidate decisions
+	if client.HasRetweeted(100) {
+		t.Errorf("tweet only matched by candidate was retweeted")
+	}
+	if !client.HasRetweeted(101) {
+		t.Errorf("tweet matched by live matcher was not retweeted")
+	}
+
+	disagreements := p.ShadowDisagreements()
+	if len(disagreements) != 2 {
+		t.Fatalf("expected 2 disagreements, but got %d: %+v", len(disagreements), disagreements)
+	}
+
+	// Newest first
+	if d := disagreements[0]; d.TweetID != 101 || !d.Live || d.Candidate || len(d.CandidateExplanation) == 0 {
+		t.Errorf("unexpected disagreement %+v", d)
+	}
+	if d := disagreements[1]; d.TweetID != 100 || d.Live || !d.Candidate {
+		t.Errorf("unexpected disagreement %+v", d)
+	}
+
+	// Disagreements are also written to the decision log
+	if err = w.Close(); err != nil {
+		t.Fatalf("Close: %s", err.Error())
+	}
+	records, err := decisions.Since(dir, time.Hour)
+	if err != nil {
+		t.Fatalf("reading decisions: %s", err.Error())
+	}
+
+	var logged []int64
+	for _, r := range records {
+		if r.Action != decisions.ActionShadowDisagreement {
+			continue
+		}
+		logged = append(logged, r.TweetID)
+		if r.RulesVersion != candidate.RulesVersion() || len(r.Explanation) == 0 {
+			t.Errorf("unexpected disagreement record %+v", r)
+		}
+	}
+	if len(logged) != 2 || logged[0] != 100 || logged[1] != 101 {
+		t.Errorf("expected disagreements about tweets 100 and 101 in the decision log, but got %v", logged)
+	}
+}
+
+func TestProcessor_shadowMatcherRetweet(t *testing.T) {
+	client := &TestTwitterClient{
+		retweetedTweetIDs: make(map[int64]bool),
+		tweets:            make(map[int64]*twitter.Tweet),
+	}
+	p := NewProcessor(false, true, client, &twitter.User{ID: testBotSelfUserID}, match.NewStarshipMatcherForTests())
+
+	p.SetShadowMatcher(match.NewCandidateStarshipMatcher(match.NewStarshipMatcherForTests().Ignorer, match.MatcherRules{
+		Keywords: []string{"mechazilla"},
+	}))
+
+	p.Tweet(match.TweetWrapper{
+		Tweet: twitter.Tweet{
+			ID:        201,
+			FullText:  "RT @someone: Mechazilla is moving",
+			CreatedAt: time.Now().Add(-time.Minute).Format(time.RubyDate),
+			User:      &twitter.User{ID: 81, ScreenName: "other"},
+			RetweetedStatus: &twitter.Tweet{
+				ID:        200,
+				FullText:  "Mechazilla is moving",
+				CreatedAt: time.Now().Add(-time.Minute).Format(time.RubyDate),
+				User:      &twitter.User{ID: 80, ScreenName: "someone"},
+			},
+		},
+	})
+
+	// The retweet and the retweeted tweet must only be compared once
+	disagreements := p.ShadowDisagreements()
+	if len(disagreements) != 1 || disagreements[0].TweetID != 200 {
+		t.Fatalf("expected one disagreement about tweet 200, but got %+v", disagreements)
+	}
+	if stats := p.shadow.Stats(); stats["evaluated"] != 1 || stats["disagreements"] != 1 {
+		t.Errorf("unexpected stats %v", stats)
+	}
+}
@@ -0,0 +1,397 @@
+package consumer
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/xarantolus/spacex-hop-bot/util"
+)
+
+// maxListBatchSize is the maximum number of users that can be added or removed in a single API call, see
+// https://developer.twitter.com/en/docs/twitter-api/v1/accounts-and-users/create-manage-lists/api-reference/post-lists-members-create_all
+const maxListBatchSize = 100
+
+// SpacePeopleOptions configure how the space people list is maintained
+type SpacePeopleOptions struct {
+	// Filename is where the membership is persisted. If it is empty, nothing is saved
+	Filename string
+
+	// PruneAfter is the time after which members that haven't been retweeted are removed. Zero disables pruning
+	PruneAfter time.Duration
+
+	// IsIgnored returns whether a user is ignored, such users are removed from the list
+	IsIgnored func(userID int64) bool
+}
+
+// SpacePerson is a member of the space people list
+type SpacePerson struct {
+	UserID     int64  `json:"user_id"`
+	ScreenName string `json:"screen_name,omitempty"`
+
+	// Added is when the user was added to the list on Twitter, it is zero while the addition is pending
+	Added time.Time `json:"added,omitempty"`
+	// LastRetweet is when we last retweeted a tweet of this user
+	LastRetweet time.Time `json:"last_retweet,omitempty"`
+}
+
+// lastActive returns when the user was last added or retweeted
+func (s *SpacePerson) lastActive() time.Time {
+	if s.LastRetweet.After(s.Added) {
+		return s.LastRetweet
+	}
+	return s.Added
+}
+
+// SpacePeopleList manages the list of accounts we retweeted. Changes are queued and applied in batches,
+// that way the list only has to be made private once per batch. It is safe for concurrent use
+type SpacePeopleList struct {
+	client TwitterClient
+	listID int64
+	opts   SpacePeopleOptions
+
+	// flushMu makes sure only one batch is sent at a time
+	flushMu sync.Mutex
+
+	mu      sync.Mutex
+	state   spacePeopleState
+	changed bool
+}
+
+type spacePeopleState struct {
+	Members       map[int64]*SpacePerson `json:"members"`
+	PendingAdd    map[int64]bool         `json:"pending_add"`
+	PendingRemove map[int64]bool         `json:"pending_remove"`
+}
+
+// NewSpacePeopleList returns a manager for the list with the given ID and loads its persisted membership
+func NewSpacePeopleList(client TwitterClient, listID int64, opts SpacePeopleOptions) *SpacePeopleList {
+	l := &SpacePeopleList{
+		client: client,
+		listID: listID,
+		opts:   opts,
+	}
+
+	if opts.Filename != "" {
+		err := util.LoadJSON(opts.Filename, &l.state)
+		if !os.IsNotExist(err) {
+			util.LogError(err, "loading space people list")
+		}
+	}
+
+	if l.state.Members == nil {
+		l.state.Members = make(map[int64]*SpacePerson)
+	}
+	if l.state.PendingAdd == nil {
+		l.state.PendingAdd = make(map[int64]bool)
+	}
+	if l.state.PendingRemove == nil {
+		l.state.PendingRemove = make(map[int64]bool)
+	}
+
+	return l
+}
+
+// LoadMembers adds the given users that are on the list on Twitter, but unknown to us, as members.
+// That way members that were added before the list was managed by the bot are also pruned.
+// We don't know when they were added, so their prune time starts now
+func (l *SpacePeopleList) LoadMembers(userIDs []int64) (added int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	for _, id := range userIDs {
+		if _, ok := l.state.Members[id]; ok {
+			continue
+		}
+
+		l.state.Members[id] = &SpacePerson{
+			UserID: id,
+			Added:  now,
+		}
+		l.changed = true
+		added++
+	}
+
+	return
+}
+
+// Retweeted notes that we retweeted a tweet of user. If they are not on the list yet, they will be added with the next batch
+func (l *SpacePeopleList) Retweeted(user *twitter.User) {
+	if user == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.changed = true
+
+	m, ok := l.state.Members[user.ID]
+	if !ok {
+		m = &SpacePerson{UserID: user.ID}
+		l.state.Members[user.ID] = m
+		l.state.PendingAdd[user.ID] = true
+	}
+
+	m.ScreenName = user.ScreenName
+	m.LastRetweet = time.Now()
+
+	// If we were about to remove them, we keep them instead
+	delete(l.state.PendingRemove, user.ID)
+}
+
+// Prune queues the removal of all members that haven't been retweeted in a while or are now ignored
+func (l *SpacePeopleList) Prune() (queued int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for id, m := range l.state.Members {
+		if l.state.PendingRemove[id] {
+			continue
+		}
+
+		ignored := l.opts.IsIgnored != nil && l.opts.IsIgnored(id)
+		inactive := l.opts.PruneAfter > 0 && time.Since(m.lastActive()) > l.opts.PruneAfter
+		if !ignored && !inactive {
+			continue
+		}
+
+		l.changed = true
+
+		// If the user was never added on Twitter, we can just forget about them
+		if l.state.PendingAdd[id] {
+			delete(l.state.PendingAdd, id)
+			delete(l.state.Members, id)
+			continue
+		}
+
+		l.state.PendingRemove[id] = true
+		queued++
+	}
+
+	return
+}
+
+// Flush applies up to one batch of queued changes to the list on Twitter and persists the membership.
+// The list can be changed while the batch is sent, those changes are applied with the next batch
+func (l *SpacePeopleList) Flush() (err error) {
+	l.flushMu.Lock()
+	defer l.flushMu.Unlock()
+
+	l.mu.Lock()
+	var (
+		add    = firstIDs(l.state.PendingAdd, maxListBatchSize)
+		remove = firstIDs(l.state.PendingRemove, maxListBatchSize)
+	)
+	l.mu.Unlock()
+
+	if len(add) > 0 || len(remove) > 0 {
+		err = l.client.UpdateListMembers(l.listID, add, remove)
+		if err != nil {
+			return fmt.Errorf("updating space people list: %w", err)
+		}
+
+		l.applyBatch(add, remove)
+
+		log.Printf("[List] Added %d and removed %d space people list members\n", len(add), len(remove))
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.changed && l.opts.Filename != "" {
+		err = util.SaveJSON(l.opts.Filename, l.state)
+		if err != nil {
+			return fmt.Errorf("saving space people list: %w", err)
+		}
+	}
+	l.changed = false
+
+	return nil
+}
+
+// applyBatch updates the membership after add and remove were applied on Twitter
+func (l *SpacePeopleList) applyBatch(add, remove []int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	for _, id := range add {
+		delete(l.state.PendingAdd, id)
+
+		m, ok := l.state.Members[id]
+		if !ok {
+			// Pruned while the batch was sent, but it is on the list now
+			m = &SpacePerson{UserID: id}
+			l.state.Members[id] = m
+			l.state.PendingRemove[id] = true
+		}
+		m.Added = now
+	}
+	for _, id := range remove {
+		if !l.state.PendingRemove[id] {
+			// Retweeted while the batch was sent, so they must be added again
+			l.state.PendingAdd[id] = true
+			continue
+		}
+
+		delete(l.state.PendingRemove, id)
+		delete(l.state.Members, id)
+	}
+	l.changed = true
+}
+
+// Stats returns the number of members and queued changes
+func (l *SpacePeopleList) Stats() map[string]int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return map[string]int{
+		"members":         len(l.state.Members) - len(l.state.PendingAdd),
+		"pending_adds":    len(l.state.PendingAdd),
+		"pending_removes": len(l.state.PendingRemove),
+	}
+}
+
+var spacePeopleCSVHeader = []string{"user_id", "screen_name", "added", "last_retweet"}
+
+// ExportCSV writes all members, including those that are still to be added, as CSV
+func (l *SpacePeopleList) ExportCSV(w io.Writer) error {
+	l.mu.Lock()
+	var members = make([]SpacePerson, 0, len(l.state.Members))
+	for id, m := range l.state.Members {
+		if !l.state.PendingRemove[id] {
+			members = append(members, *m)
+		}
+	}
+	l.mu.Unlock()
+
+	sort.Slice(members, func(i, j int) bool {
+		return members[i].UserID < members[j].UserID
+	})
+
+	cw := csv.NewWriter(w)
+	err := cw.Write(spacePeopleCSVHeader)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range members {
+		err = cw.Write([]string{
+			strconv.FormatInt(m.UserID, 10),
+			m.ScreenName,
+			formatCSVTime(m.Added),
+			formatCSVTime(m.LastRetweet),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// ImportCSV queues all users from a CSV file in the format of ExportCSV that are not yet members.
+// Only the user_id column is required
+func (l *SpacePeopleList) ImportCSV(r io.Reader) (queued int, err error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		return 0, fmt.Errorf("reading CSV header: %w", err)
+	}
+
+	var columns = make(map[string]int)
+	for i, h := range header {
+		columns[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	if _, ok := columns["user_id"]; !ok {
+		return 0, fmt.Errorf("CSV header must contain a user_id column")
+	}
+
+	var get = func(row []string, column string) string {
+		i, ok := columns[column]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+
+	var people []SpacePerson
+	for line := 2; ; line++ {
+		row, rerr := cr.Read()
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			return 0, rerr
+		}
+
+		id, perr := strconv.ParseInt(get(row, "user_id"), 10, 64)
+		if perr != nil {
+			return 0, fmt.Errorf("invalid user_id in line %d: %w", line, perr)
+		}
+
+		p := SpacePerson{
+			UserID:     id,
+			ScreenName: get(row, "screen_name"),
+		}
+		p.LastRetweet, _ = time.Parse(time.RFC3339, get(row, "last_retweet"))
+
+		people = append(people, p)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, p := range people {
+		if _, ok := l.state.Members[p.UserID]; ok {
+			continue
+		}
+		if l.opts.IsIgnored != nil && l.opts.IsIgnored(p.UserID) {
+			continue
+		}
+
+		p := p
+		l.state.Members[p.UserID] = &p
+		l.state.PendingAdd[p.UserID] = true
+		l.changed = true
+		queued++
+	}
+
+	return queued, nil
+}
+
+// firstIDs returns up to n of the IDs in set, sorted
+func firstIDs(set map[int64]bool, n int) (ids []int64) {
+	for id := range set {
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+
+	if len(ids) > n {
+		ids = ids[:n]
+	}
+	return
+}
+
+func formatCSVTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,229 @@
+package consumer
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+func TestSpacePeopleListBatches(t *testing.T) {
+	client := &TestTwitterClient{}
+	fn := filepath.Join(t.TempDir(), "space-people.json")
+
+	l := NewSpacePeopleList(client, 1, SpacePeopleOptions{Filename: fn})
+	for i := int64(1); i <= 150; i++ {
+		l.Retweeted(&twitter.User{ID: i, ScreenName: "user"})
+	}
+	// Retweeting someone twice doesn't add them twice
+	l.Retweeted(&twitter.User{ID: 1, ScreenName: "user"})
+
+	if client.listUpdates != 0 {
+		t.Fatalf("expected no list updates before flushing, but got %d", client.listUpdates)
+	}
+
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %s", err.Error())
+	}
+	if client.listUpdates != 1 || len(client.listAdds) != maxListBatchSize {
+		t.Fatalf("expected one update adding %d users, but got %d updates adding %d users", maxListBatchSize, client.listUpdates, len(client.listAdds))
+	}
+
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %s", err.Error())
+	}
+	if client.listUpdates != 2 || len(client.listAdds) != 150 {
+		t.Fatalf("expected all users to be added after two updates, but got %d updates adding %d users", client.listUpdates, len(client.listAdds))
+	}
+
+	// Nothing left to do
+	if err := l.Flush(); err != nil || client.listUpdates != 2 {
+		t.Fatalf("expected no update without queued changes, got %d updates and error %v", client.listUpdates, err)
+	}
+
+	// The membership is persisted, so after a restart nobody is added again
+	l = NewSpacePeopleList(client, 1, SpacePeopleOptions{Filename: fn})
+	l.Retweeted(&twitter.User{ID: 5})
+	if err := l.Flush(); err != nil || client.listUpdates != 2 {
+		t.Fatalf("expected known member not to be added again, got %d updates and error %v", client.listUpdates, err)
+	}
+	if got := l.Stats()["members"]; got != 150 {
+		t.Errorf("expected 150 members after reloading, but got %d", got)
+	}
+}
+
+func TestSpacePeopleListPrune(t *testing.T) {
+	client := &TestTwitterClient{}
+
+	l := NewSpacePeopleList(client, 1, SpacePeopleOptions{
+		PruneAfter: 90 * 24 * time.Hour,
+		IsIgnored: func(userID int64) bool {
+			return userID == 3
+		},
+	})
+
+	for i := int64(1); i <= 4; i++ {
+		l.Retweeted(&twitter.User{ID: i})
+	}
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %s", err.Error())
+	}
+
+	// User 2 hasn't been retweeted in a long time, user 4 was retweeted again after being queued for removal
+	l.state.Members[2].Added = time.Now().Add(-100 * 24 * time.Hour)
+	l.state.Members[2].LastRetweet = time.Now().Add(-95 * 24 * time.Hour)
+	l.state.Members[4].Added = time.Now().Add(-100 * 24 * time.Hour)
+	l.state.Members[4].LastRetweet = time.Now().Add(-95 * 24 * time.Hour)
+
+	// User 5 is queued, but ignored before being added, so it should never be added
+	l.Retweeted(&twitter.User{ID: 5})
+	l.opts.IsIgnored = func(userID int64) bool {
+		return userID == 3 || userID == 5
+	}
+
+	if n := l.Prune(); n != 3 {
+		t.Fatalf("expected 3 users to be queued for removal, but got %d", n)
+	}
+	l.Retweeted(&twitter.User{ID: 4})
+
+	client.listAdds, client.listRemoves = nil, nil
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %s", err.Error())
+	}
+
+	if len(client.listAdds) != 0 || !reflect.DeepEqual(client.listRemoves, []int64{2, 3}) {
+		t.Errorf("expected users 2 and 3 to be removed without additions, but got adds %v and removes %v", client.listAdds, client.listRemoves)
+	}
+}
+
+func TestSpacePeopleListLoadMembers(t *testing.T) {
+	client := &TestTwitterClient{}
+
+	l := NewSpacePeopleList(client, 1, SpacePeopleOptions{
+		IsIgnored: func(userID int64) bool {
+			return userID == 2
+		},
+	})
+	l.Retweeted(&twitter.User{ID: 1})
+
+	// Users 2 and 3 were already on the list on Twitter
+	if n := l.LoadMembers([]int64{1, 2, 3}); n != 2 {
+		t.Fatalf("expected 2 unknown members to be loaded, but got %d", n)
+	}
+
+	if n := l.Prune(); n != 1 {
+		t.Fatalf("expected 1 user to be queued for removal, but got %d", n)
+	}
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %s", err.Error())
+	}
+
+	// Loaded members are not added again
+	if !reflect.DeepEqual(client.listAdds, []int64{1}) || !reflect.DeepEqual(client.listRemoves, []int64{2}) {
+		t.Errorf("expected user 1 to be added and user 2 to be removed, but got adds %v and removes %v", client.listAdds, client.listRemoves)
+	}
+}
+
+// duringUpdateClient calls during while the list is updated on Twitter
+type duringUpdateClient struct {
+	TestTwitterClient
+
+	during func()
+}
+
+func (c *duringUpdateClient) UpdateListMembers(listID int64, add, remove []int64) (err error) {
+	if c.during != nil {
+		c.during()
+	}
+	return c.TestTwitterClient.UpdateListMembers(listID, add, remove)
+}
+
+func TestSpacePeopleListFlushConcurrentChanges(t *testing.T) {
+	client := &duringUpdateClient{}
+
+	l := NewSpacePeopleList(client, 1, SpacePeopleOptions{
+		PruneAfter: 90 * 24 * time.Hour,
+	})
+	l.Retweeted(&twitter.User{ID: 1})
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %s", err.Error())
+	}
+
+	l.state.Members[1].Added = time.Now().Add(-100 * 24 * time.Hour)
+	l.state.Members[1].LastRetweet = time.Now().Add(-95 * 24 * time.Hour)
+	if n := l.Prune(); n != 1 {
+		t.Fatalf("expected 1 user to be queued for removal, but got %d", n)
+	}
+
+	// The list must not be locked during the API call, and retweeting a user that is being removed adds them again
+	client.during = func() {
+		client.during = nil
+		l.Retweeted(&twitter.User{ID: 1})
+		l.Retweeted(&twitter.User{ID: 2})
+	}
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %s", err.Error())
+	}
+	if stats := l.Stats(); stats["pending_adds"] != 2 || stats["pending_removes"] != 0 {
+		t.Fatalf("expected users 1 and 2 to be queued for addition, but got %v", stats)
+	}
+
+	client.listAdds = nil
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %s", err.Error())
+	}
+	if !reflect.DeepEqual(client.listAdds, []int64{1, 2}) {
+		t.Errorf("expected users 1 and 2 to be added, but got %v", client.listAdds)
+	}
+}
+
+func TestSpacePeopleListCSV(t *testing.T) {
+	client := &TestTwitterClient{}
+
+	l := NewSpacePeopleList(client, 1, SpacePeopleOptions{
+		IsIgnored: func(userID int64) bool {
+			return userID == 3
+		},
+	})
+	l.Retweeted(&twitter.User{ID: 1, ScreenName: "first"})
+	if err := l.Flush(); err != nil {
+		t.Fatalf("Flush: %s", err.Error())
+	}
+
+	queued, err := l.ImportCSV(strings.NewReader("screen_name,user_id\nfirst,1\nsecond,2\nignored,3\n"))
+	if err != nil {
+		t.Fatalf("ImportCSV: %s", err.Error())
+	}
+	if queued != 1 {
+		t.Errorf("expected only user 2 to be queued, but %d users were queued", queued)
+	}
+
+	var buf bytes.Buffer
+	if err = l.ExportCSV(&buf); err != nil {
+		t.Fatalf("ExportCSV: %s", err.Error())
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || lines[0] != "user_id,screen_name,added,last_retweet" ||
+		!strings.HasPrefix(lines[1], "1,first,") || lines[2] != "2,second,," {
+		t.Errorf("unexpected export:\n%s", buf.String())
+	}
+
+	// An export can be imported into an empty list
+	other := NewSpacePeopleList(client, 2, SpacePeopleOptions{})
+	queued, err = other.ImportCSV(&buf)
+	if err != nil || queued != 2 {
+		t.Errorf("expected both exported users to be queued, got %d and error %v", queued, err)
+	}
+
+	if _, err = l.ImportCSV(strings.NewReader("screen_name\nfirst\n")); err == nil {
+		t.Errorf("expected error for CSV without user_id column")
+	}
+	if _, err = l.ImportCSV(strings.NewReader("user_id\nabc\n")); err == nil {
+		t.Errorf("expected error for invalid user_id")
+	}
+}
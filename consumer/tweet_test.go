@@ -828,6 +828,42 @@ func TestLocationTweets(t *testing.T) {
 	)
 }
 
+func TestSearchTweets(t *testing.T) {
+	testStarshipRetweets(t,
+		[]ttest{
+			{
+				// Search results are from unknown accounts, so they need media
+				text:        "Starship static fire happening right now!",
+				tweetSource: match.TweetSourceSearch,
+				hasMedia:    true,
+				want:        true,
+			},
+			{
+				text:        "Starship static fire happening right now!",
+				tweetSource: match.TweetSourceSearch,
+				want:        false,
+			},
+			{
+				// The same tweet from an account we follow doesn't need media
+				text: "Starship static fire happening right now!",
+				want: true,
+			},
+			{
+				text:        "#Starship #Starbase #SpaceX",
+				tweetSource: match.TweetSourceSearch,
+				hasMedia:    true,
+				want:        false,
+			},
+			{
+				text:        "Is the Starship static fire happening today?",
+				tweetSource: match.TweetSourceSearch,
+				hasMedia:    true,
+				want:        false,
+			},
+		},
+	)
+}
+
 func TestQuestionTweets(t *testing.T) {
 	testStarshipRetweets(t,
 		[]ttest{
@@ -32,6 +32,12 @@ type ttest struct {
 
 type TestTwitterClient struct {
 	retweetedTweetIDs map[int64]bool
+	likedTweetIDs     map[int64]bool
+
+	tweetedTexts []string
+
+	listAdds, listRemoves []int64
+	listUpdates           int
 
 	tweets map[int64]*twitter.Tweet
 }
@@ -47,10 +53,13 @@ func (r *TestTwitterClient) LoadStatus(tweetID int64) (*twitter.Tweet, error) {
 		return t, nil
 	}
 
-	return nil, fmt.Errorf("could not load status with id %d", tweetID)
+	return nil, &TwitterError{Kind: ErrNotFound, Code: 144, Err: fmt.Errorf("could not load status with id %d", tweetID)}
 }
 
-func (r *TestTwitterClient) AddListMember(listID int64, userID int64) (err error) {
+func (r *TestTwitterClient) UpdateListMembers(listID int64, add, remove []int64) (err error) {
+	r.listAdds = append(r.listAdds, add...)
+	r.listRemoves = append(r.listRemoves, remove...)
+	r.listUpdates++
 	return nil
 }
 
@@ -59,12 +68,21 @@ func (r *TestTwitterClient) Retweet(tweet *twitter.Tweet) error {
 	return nil
 }
 
+func (r *TestTwitterClient) Like(tweetID int64) error {
+	if r.likedTweetIDs == nil {
+		r.likedTweetIDs = make(map[int64]bool)
+	}
+	r.likedTweetIDs[tweetID] = true
+	return nil
+}
+
 func (r *TestTwitterClient) HasRetweeted(tweetID int64) bool {
 	return r.retweetedTweetIDs[tweetID]
 }
 
 func (r *TestTwitterClient) Tweet(text string, inReplyToID *int64) (t *twitter.Tweet, err error) {
-	panic("Tweet() called in test. Either implement it or this is a mistake")
+	r.tweetedTexts = append(r.tweetedTexts, text)
+	return &twitter.Tweet{FullText: text}, nil
 }
 
 const testBotSelfUserID = 513513
@@ -78,7 +96,7 @@ func testStarshipRetweets(t *testing.T, tweets []ttest) {
 			tweets:            make(map[int64]*twitter.Tweet),
 		}
 
-		p = NewProcessor(false, true, t, &twitter.User{ID: testBotSelfUserID}, match.NewStarshipMatcherForTests(), 0)
+		p = NewProcessor(false, true, t, &twitter.User{ID: testBotSelfUserID}, match.NewStarshipMatcherForTests())
 		return
 	}
 
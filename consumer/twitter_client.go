@@ -2,6 +2,9 @@ package consumer
 
 import (
 	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/dghubble/go-twitter/twitter"
 )
@@ -9,11 +12,14 @@ import (
 type TwitterClient interface {
 	LoadStatus(tweetID int64) (*twitter.Tweet, error)
 
-	AddListMember(listID int64, userID int64) (err error)
+	// UpdateListMembers adds and removes the given users from a list
+	UpdateListMembers(listID int64, add, remove []int64) (err error)
 
 	Retweet(*twitter.Tweet) error
 	UnRetweet(tweetID int64) error
 
+	Like(tweetID int64) error
+
 	Tweet(text string, inReplyToID *int64) (*twitter.Tweet, error)
 }
 
@@ -23,21 +29,27 @@ type NormalTwitterClient struct {
 }
 
 func (n *NormalTwitterClient) UnRetweet(tweetID int64) error {
-	_, _, err := n.Client.Statuses.Unretweet(tweetID, nil)
-	return err
+	return withRetry(func() error {
+		_, resp, err := n.Client.Statuses.Unretweet(tweetID, nil)
+		return ClassifyError(err, resp)
+	})
 }
 
 func (n *NormalTwitterClient) LoadStatus(tweetID int64) (tweet *twitter.Tweet, err error) {
-	tweet, _, err = n.Client.Statuses.Show(tweetID, &twitter.StatusShowParams{
-		IncludeEntities: twitter.Bool(true),
-		TweetMode:       "extended",
+	err = withRetry(func() (err error) {
+		var resp *http.Response
+		tweet, resp, err = n.Client.Statuses.Show(tweetID, &twitter.StatusShowParams{
+			IncludeEntities: twitter.Bool(true),
+			TweetMode:       "extended",
+		})
+		return ClassifyError(err, resp)
 	})
 
 	return
 }
 
-func (n *NormalTwitterClient) AddListMember(listID int64, userID int64) (err error) {
-	// Idea: We make the list private, add the member and then make it public again.
+func (n *NormalTwitterClient) UpdateListMembers(listID int64, add, remove []int64) (err error) {
+	// Idea: We make the list private, update the members and then make it public again.
 	// That way they are not notified/annoyed
 	defer n.Client.Lists.Update(&twitter.ListsUpdateParams{
 		ListID: listID,
@@ -49,22 +61,57 @@ func (n *NormalTwitterClient) AddListMember(listID int64, userID int64) (err err
 		Mode:   "private",
 	})
 
-	_, err = n.Client.Lists.MembersCreate(&twitter.ListsMembersCreateParams{
-		ListID: listID,
-		UserID: userID,
-	})
+	if len(add) > 0 {
+		resp, err := n.Client.Lists.MembersCreateAll(&twitter.ListsMembersCreateAllParams{
+			ListID: listID,
+			UserID: joinIDs(add),
+		})
+		if err != nil {
+			return ClassifyError(err, resp)
+		}
+	}
+
+	if len(remove) > 0 {
+		resp, err := n.Client.Lists.MembersDestroyAll(&twitter.ListsMembersDestroyAllParams{
+			ListID: listID,
+			UserID: joinIDs(remove),
+		})
+		return ClassifyError(err, resp)
+	}
 
 	return
 }
 
+func joinIDs(ids []int64) string {
+	var s = make([]string, len(ids))
+	for i, id := range ids {
+		s[i] = strconv.FormatInt(id, 10)
+	}
+	return strings.Join(s, ",")
+}
+
 func (n *NormalTwitterClient) Retweet(tweet *twitter.Tweet) error {
 	if n.Debug {
 		return fmt.Errorf("not retweeting tweets in debug mode")
 	}
 
-	_, _, err := n.Client.Statuses.Retweet(tweet.ID, nil)
+	return withRetryDone(func() error {
+		_, resp, err := n.Client.Statuses.Retweet(tweet.ID, nil)
+		return ClassifyError(err, resp)
+	}, ErrAlreadyRetweeted)
+}
+
+func (n *NormalTwitterClient) Like(tweetID int64) error {
+	if n.Debug {
+		return fmt.Errorf("not liking tweets in debug mode")
+	}
 
-	return err
+	return withRetry(func() error {
+		_, resp, err := n.Client.Favorites.Create(&twitter.FavoriteCreateParams{
+			ID: tweetID,
+		})
+		return ClassifyError(err, resp)
+	})
 }
 
 func (n *NormalTwitterClient) Tweet(text string, inReplyToID *int64) (t *twitter.Tweet, err error) {
@@ -80,6 +127,7 @@ func (n *NormalTwitterClient) Tweet(text string, inReplyToID *int64) (t *twitter
 		}
 	}
 
-	t, _, err = n.Client.Statuses.Update(text, updateParams)
-	return
+	// Tweets are not retried, as a request that timed out might still have been posted
+	t, resp, err := n.Client.Statuses.Update(text, updateParams)
+	return t, ClassifyError(err, resp)
 }
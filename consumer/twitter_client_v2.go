@@ -0,0 +1,99 @@
+package consumer
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/xarantolus/spacex-hop-bot/twitterv2"
+)
+
+// V2TwitterClient implements TwitterClient using the Twitter API v2
+type V2TwitterClient struct {
+	Client *twitterv2.Client
+	// User is the bot user, all actions are taken as this user
+	User  *twitter.User
+	Debug bool
+}
+
+func (v *V2TwitterClient) LoadStatus(tweetID int64) (tweet *twitter.Tweet, err error) {
+	err = withRetry(func() (err error) {
+		tweet, err = v.Client.LookupTweet(strconv.FormatInt(tweetID, 10))
+		return ClassifyError(err, nil)
+	})
+	return
+}
+
+func (v *V2TwitterClient) UpdateListMembers(listID int64, add, remove []int64) (err error) {
+	var lid = strconv.FormatInt(listID, 10)
+
+	// Same idea as in NormalTwitterClient: members are not notified while the list is private
+	defer v.Client.SetListPrivate(lid, false)
+	v.Client.SetListPrivate(lid, true)
+
+	// The v2 API has no batch endpoints, so every member is added/removed on its own
+	for _, id := range add {
+		err = ClassifyError(v.Client.AddListMember(lid, strconv.FormatInt(id, 10)), nil)
+		if err != nil {
+			return
+		}
+	}
+
+	for _, id := range remove {
+		err = ClassifyError(v.Client.RemoveListMember(lid, strconv.FormatInt(id, 10)), nil)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func (v *V2TwitterClient) Retweet(tweet *twitter.Tweet) error {
+	if v.Debug {
+		return fmt.Errorf("not retweeting tweets in debug mode")
+	}
+
+	return withRetryDone(func() error {
+		return ClassifyError(v.Client.Retweet(v.User.IDStr, strconv.FormatInt(tweet.ID, 10)), nil)
+	}, ErrAlreadyRetweeted)
+}
+
+func (v *V2TwitterClient) UnRetweet(tweetID int64) error {
+	return withRetry(func() error {
+		return ClassifyError(v.Client.UnRetweet(v.User.IDStr, strconv.FormatInt(tweetID, 10)), nil)
+	})
+}
+
+func (v *V2TwitterClient) Like(tweetID int64) error {
+	if v.Debug {
+		return fmt.Errorf("not liking tweets in debug mode")
+	}
+
+	return withRetry(func() error {
+		return ClassifyError(v.Client.Like(v.User.IDStr, strconv.FormatInt(tweetID, 10)), nil)
+	})
+}
+
+func (v *V2TwitterClient) Tweet(text string, inReplyToID *int64) (t *twitter.Tweet, err error) {
+	if v.Debug {
+		err = fmt.Errorf("not tweeting in debug mode")
+		return
+	}
+
+	var replyTo string
+	if inReplyToID != nil && *inReplyToID != 0 {
+		replyTo = strconv.FormatInt(*inReplyToID, 10)
+	}
+
+	t, err = v.Client.CreateTweet(text, replyTo)
+	if err != nil {
+		return t, ClassifyError(err, nil)
+	}
+	if t != nil {
+		// The API only returns ID and text of new tweets
+		t.User = v.User
+	}
+
+	return
+}
@@ -0,0 +1,374 @@
+package decisions
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const (
+	defaultSearchLimit = 50
+	maxSearchLimit     = 500
+)
+
+// Index keeps recent records in memory and allows searching them by text and filtering by other fields.
+// It is not persisted: on startup it is rebuilt from the decision log, but only with records of the last maxAge.
+// Older records are searched directly in the decision log, which is a lot slower. It is safe for concurrent use
+type Index struct {
+	maxAge time.Duration
+	// dir is the decision log directory the index was loaded from. If it is empty, older records can't be found
+	dir string
+
+	mu      sync.RWMutex
+	records []Record
+	// postings maps a lowercase term to the positions in records that contain it, in ascending order
+	postings map[string][]int
+	// expiring is set while old records are removed in the background
+	expiring bool
+}
+
+// Query describes which records should be returned by Search
+type Query struct {
+	// Text contains terms that must all be contained in the text, reason or author of a record
+	Text string
+
+	Author string
+	Action string
+	Source string
+
+	From, To time.Time
+
+	Offset, Limit int
+}
+
+// SearchResult is a page of records matching a query
+type SearchResult struct {
+	// Since is the time of the oldest records that are kept in memory. Older records are read from the decision log.
+	// It is not set if the index keeps records forever
+	Since *time.Time `json:"since,omitempty"`
+
+	// Total is the number of matching records, not just the ones in Records
+	Total  int `json:"total"`
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+
+	// Records are sorted newest first
+	Records []Record `json:"records"`
+}
+
+// NewIndex returns an empty index that keeps records for maxAge. If maxAge is zero, records are kept forever
+func NewIndex(maxAge time.Duration) *Index {
+	return &Index{
+		maxAge:   maxAge,
+		postings: make(map[string][]int),
+	}
+}
+
+// LoadIndex creates an index from all records in the decision log in dir that are newer than maxAge.
+// Searches for older records read the decision log
+func LoadIndex(dir string, maxAge time.Duration) (idx *Index, err error) {
+	idx = NewIndex(maxAge)
+	idx.dir = dir
+
+	var from time.Time
+	if maxAge > 0 {
+		from = time.Now().Add(-maxAge)
+	}
+
+	err = Read(dir, from, time.Time{}, func(r Record) error {
+		idx.add(r)
+		return nil
+	})
+	// Before the first decision is written, there is no log
+	if os.IsNotExist(err) {
+		err = nil
+	}
+
+	return
+}
+
+// Add adds a record to the index. Records should be added in the order they were written
+func (idx *Index) Add(r Record) {
+	idx.mu.Lock()
+	idx.add(r)
+	expire := !idx.expiring && idx.shouldExpire()
+	if expire {
+		idx.expiring = true
+	}
+	idx.mu.Unlock()
+
+	if expire {
+		go idx.expire()
+	}
+}
+
+// Len returns the number of records in the index
+func (idx *Index) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	return len(idx.records)
+}
+
+// add adds a record without locking
+func (idx *Index) add(r Record) {
+	pos := len(idx.records)
+	idx.records = append(idx.records, r)
+
+	for _, term := range recordTerms(&r) {
+		idx.postings[term] = append(idx.postings[term], pos)
+	}
+}
+
+// shouldExpire returns whether old records should be removed. To keep adding records fast, this is only done once
+// more than a day of records has expired. idx.mu must be held
+func (idx *Index) shouldExpire() bool {
+	return idx.maxAge > 0 && len(idx.records) > 0 && time.Since(idx.records[0].Time) >= idx.maxAge+24*time.Hour
+}
+
+// expire removes old records. The new postings are built without holding the lock, so adding and searching
+// records isn't blocked while that happens
+func (idx *Index) expire() {
+	idx.mu.RLock()
+	// Records are only appended, so existing ones don't change while we read them
+	old := idx.records
+	idx.mu.RUnlock()
+
+	var (
+		cutoff = time.Now().Add(-idx.maxAge)
+		fresh  = NewIndex(idx.maxAge)
+	)
+	for _, r := range old {
+		if r.Time.After(cutoff) {
+			fresh.add(r)
+		}
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	// Add records that were added in the meantime
+	for _, r := range idx.records[len(old):] {
+		fresh.add(r)
+	}
+
+	idx.records, idx.postings = fresh.records, fresh.postings
+	idx.expiring = false
+}
+
+// Search returns all records matching q, newest first. If q includes records older than the ones in memory,
+// they are read from the decision log
+func (idx *Index) Search(q Query) (res SearchResult, err error) {
+	if q.Limit <= 0 {
+		q.Limit = defaultSearchLimit
+	}
+	if q.Limit > maxSearchLimit {
+		q.Limit = maxSearchLimit
+	}
+	if q.Offset < 0 {
+		q.Offset = 0
+	}
+
+	res.Offset, res.Limit = q.Offset, q.Limit
+	res.Records = []Record{}
+
+	// Expired records are only removed from time to time, so they are never searched in memory
+	var memoryQuery = q
+	if idx.maxAge > 0 {
+		since := time.Now().Add(-idx.maxAge)
+		res.Since = &since
+		if memoryQuery.From.Before(since) {
+			memoryQuery.From = since
+		}
+	}
+
+	idx.search(memoryQuery, &res)
+
+	// Records in memory are newer than the ones in the log, so they come first
+	if res.Since != nil && idx.dir != "" && q.From.Before(*res.Since) {
+		err = idx.searchLog(q, *res.Since, &res)
+	}
+
+	return
+}
+
+// search adds all records in memory that match q to res
+func (idx *Index) search(q Query, res *SearchResult) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	candidates, all := idx.textCandidates(q.Text)
+
+	// Go from newest to oldest
+	var n = len(idx.records)
+	if !all {
+		n = len(candidates)
+	}
+	for i := n - 1; i >= 0; i-- {
+		pos := i
+		if !all {
+			pos = candidates[i]
+		}
+
+		r := &idx.records[pos]
+		if !q.matches(r) {
+			continue
+		}
+
+		if res.Total >= q.Offset && len(res.Records) < q.Limit {
+			res.Records = append(res.Records, *r)
+		}
+		res.Total++
+	}
+}
+
+// searchLog adds all records in the decision log that are older than before and match q to res
+func (idx *Index) searchLog(q Query, before time.Time, res *SearchResult) (err error) {
+	var (
+		terms = tokenize(q.Text)
+		to    = q.To
+
+		// The log is read oldest first, so we only keep the newest matches that could still be on the page
+		keep   = q.Offset + q.Limit - res.Total
+		newest []Record
+		total  int
+	)
+	if to.IsZero() || to.After(before) {
+		to = before
+	}
+
+	err = Read(idx.dir, q.From, to, func(r Record) error {
+		// Values written by other logs, e.g. cassettes, don't have a time
+		if r.Time.IsZero() || !r.Time.Before(before) || !q.matches(&r) || !containsTerms(&r, terms) {
+			return nil
+		}
+
+		total++
+		if keep > 0 {
+			if len(newest) == keep {
+				newest = newest[1:]
+			}
+			newest = append(newest, r)
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	if err != nil {
+		return
+	}
+
+	skip := q.Offset - res.Total
+	if skip < 0 {
+		skip = 0
+	}
+	for i := len(newest) - 1 - skip; i >= 0 && len(res.Records) < q.Limit; i-- {
+		res.Records = append(res.Records, newest[i])
+	}
+	res.Total += total
+
+	return nil
+}
+
+// textCandidates returns the positions of all records that contain all terms in text.
+// If text contains no terms, all is true
+func (idx *Index) textCandidates(text string) (positions []int, all bool) {
+	terms := tokenize(text)
+	if len(terms) == 0 {
+		return nil, true
+	}
+
+	// Start with the rarest term, that way the intersection stays small
+	sort.Slice(terms, func(i, j int) bool {
+		return len(idx.postings[terms[i]]) < len(idx.postings[terms[j]])
+	})
+
+	positions = idx.postings[terms[0]]
+	for _, term := range terms[1:] {
+		positions = intersect(positions, idx.postings[term])
+		if len(positions) == 0 {
+			break
+		}
+	}
+
+	return positions, false
+}
+
+func (q *Query) matches(r *Record) bool {
+	if q.Author != "" && !strings.EqualFold(strings.TrimPrefix(q.Author, "@"), r.Author) {
+		return false
+	}
+	if q.Action != "" && !strings.EqualFold(q.Action, r.Action) {
+		return false
+	}
+	// Sources can be given with or without prefix, e.g. "LocationStream" or "TweetSourceLocationStream"
+	if q.Source != "" && !strings.EqualFold(q.Source, r.Source) && !strings.EqualFold("TweetSource"+q.Source, r.Source) {
+		return false
+	}
+	if !q.From.IsZero() && r.Time.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && r.Time.After(q.To) {
+		return false
+	}
+	return true
+}
+
+// containsTerms returns whether r can be found by all terms
+func containsTerms(r *Record, terms []string) bool {
+	if len(terms) == 0 {
+		return true
+	}
+
+	var found = map[string]bool{}
+	for _, t := range recordTerms(r) {
+		found[t] = true
+	}
+	for _, t := range terms {
+		if !found[t] {
+			return false
+		}
+	}
+	return true
+}
+
+// recordTerms returns all distinct terms a record can be found by
+func recordTerms(r *Record) (terms []string) {
+	var seen = map[string]bool{}
+	for _, t := range tokenize(r.Text + " " + r.Reason + " " + r.Author + " " + r.Place) {
+		if !seen[t] {
+			seen[t] = true
+			terms = append(terms, t)
+		}
+	}
+	return
+}
+
+// tokenize splits text into lowercase words. Hashtags and mentions are indexed without their prefix
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// intersect returns all elements contained in both sorted slices
+func intersect(a, b []int) (res []int) {
+	var i, j int
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			res = append(res, a[i])
+			i++
+			j++
+		}
+	}
+	return
+}
@@ -0,0 +1,177 @@
+package decisions
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestIndexSearch(t *testing.T) {
+	now := time.Now()
+
+	idx := NewIndex(0)
+	records := []Record{
+		{TweetID: 1, Time: now.Add(-5 * time.Hour), Author: "someone", Text: "S20 standing on the pad", Action: ActionRetweet, Reason: "normal matcher", Source: "TweetSourceKnownList"},
+		{TweetID: 2, Time: now.Add(-4 * time.Hour), Author: "other", Text: "Nice weather at #Starbase today", Action: ActionIgnore, Source: "TweetSourceLocationStream", Place: "Starbase, TX"},
+		{TweetID: 3, Time: now.Add(-3 * time.Hour), Author: "Someone", Text: "Is S20 on the pad?", Action: ActionLike, Reason: "borderline: question", Source: "TweetSourceTimeline"},
+		{TweetID: 4, Time: now.Add(-2 * time.Hour), Author: "other", Text: "Pad announcement: clear the pad", Action: ActionRetweet, Reason: "location + pad announcement", Source: "TweetSourceLocationStream"},
+	}
+	for _, r := range records {
+		idx.Add(r)
+	}
+
+	tests := []struct {
+		name      string
+		q         Query
+		wantIDs   []int64
+		wantTotal int
+	}{
+		{"everything, newest first", Query{}, []int64{4, 3, 2, 1}, 4},
+		{"text", Query{Text: "pad S20"}, []int64{3, 1}, 2},
+		{"hashtag without prefix", Query{Text: "starbase"}, []int64{2}, 1},
+		{"reason", Query{Text: "borderline"}, []int64{3}, 1},
+		{"unknown term", Query{Text: "pad raptor"}, []int64{}, 0},
+		{"author is case insensitive", Query{Author: "@someone"}, []int64{3, 1}, 2},
+		{"action", Query{Action: "retweet"}, []int64{4, 1}, 2},
+		{"source without prefix", Query{Source: "LocationStream"}, []int64{4, 2}, 2},
+		{"time range", Query{From: now.Add(-4*time.Hour - time.Minute), To: now.Add(-3*time.Hour + time.Minute)}, []int64{3, 2}, 2},
+		{"pagination", Query{Offset: 1, Limit: 2}, []int64{3, 2}, 4},
+		{"combined", Query{Text: "pad", Action: "retweet", Source: "TweetSourceLocationStream"}, []int64{4}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := idx.Search(tt.q)
+			if err != nil {
+				t.Fatalf("Search: %s", err.Error())
+			}
+
+			var ids = []int64{}
+			for _, r := range res.Records {
+				ids = append(ids, r.TweetID)
+			}
+
+			if !reflect.DeepEqual(ids, tt.wantIDs) || res.Total != tt.wantTotal {
+				t.Errorf("Search(%+v) = %v (total %d), want %v (total %d)", tt.q, ids, res.Total, tt.wantIDs, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestLoadIndex(t *testing.T) {
+	dir := t.TempDir()
+
+	idx, err := LoadIndex(filepath.Join(dir, "does-not-exist"), time.Hour)
+	if err != nil || idx.Len() != 0 {
+		t.Fatalf("expected empty index for missing log, got %d records and error %v", idx.Len(), err)
+	}
+
+	w, err := NewWriter(dir, Options{})
+	if err != nil {
+		t.Fatalf("NewWriter: %s", err.Error())
+	}
+	_ = w.Write(Record{TweetID: 1, Time: time.Now().Add(-2 * time.Hour), Text: "old"})
+	_ = w.Write(Record{TweetID: 2, Text: "recent starship"})
+	if err = w.Close(); err != nil {
+		t.Fatalf("Close: %s", err.Error())
+	}
+
+	idx, err = LoadIndex(dir, time.Hour)
+	if err != nil {
+		t.Fatalf("LoadIndex: %s", err.Error())
+	}
+
+	res, err := idx.Search(Query{Text: "starship"})
+	if err != nil {
+		t.Fatalf("Search: %s", err.Error())
+	}
+	if idx.Len() != 1 || res.Total != 1 || res.Records[0].TweetID != 2 {
+		t.Errorf("expected only the recent record to be loaded, but got %+v", res)
+	}
+}
+
+func TestIndexSearch_maxAge(t *testing.T) {
+	idx := NewIndex(time.Hour)
+	idx.Add(Record{TweetID: 1, Time: time.Now().Add(-2 * time.Hour), Text: "old starship"})
+	idx.Add(Record{TweetID: 2, Time: time.Now(), Text: "recent starship"})
+
+	res, _ := idx.Search(Query{Text: "starship"})
+	if res.Total != 1 || res.Records[0].TweetID != 2 {
+		t.Errorf("expected only the recent record, got %+v", res.Records)
+	}
+	if res.Since == nil || time.Since(*res.Since) < time.Hour-time.Minute {
+		t.Errorf("expected since to be about an hour ago, got %v", res.Since)
+	}
+
+	if res, _ = NewIndex(0).Search(Query{}); res.Since != nil {
+		t.Errorf("expected no since for an index that keeps records forever, got %v", res.Since)
+	}
+}
+
+func TestIndexSearch_olderRecordsFromLog(t *testing.T) {
+	dir := t.TempDir()
+
+	// Every record is in its own segment that was started when it was written
+	for i := int64(1); i <= 4; i++ {
+		r := Record{TweetID: i, Time: time.Now().Add(time.Duration(i-5) * time.Hour), Text: "starship"}
+		data, err := json.Marshal(r)
+		if err != nil {
+			t.Fatalf("Marshal: %s", err.Error())
+		}
+
+		fn := filepath.Join(dir, r.Time.UTC().Format(segmentTimeFormat)+segmentExtension)
+		if err = os.WriteFile(fn, append(data, '\n'), 0644); err != nil {
+			t.Fatalf("writing segment: %s", err.Error())
+		}
+	}
+
+	// Only records 3 and 4 are in memory
+	idx, err := LoadIndex(dir, 150*time.Minute)
+	if err != nil {
+		t.Fatalf("LoadIndex: %s", err.Error())
+	}
+
+	tests := []struct {
+		name      string
+		q         Query
+		wantIDs   []int64
+		wantTotal int
+	}{
+		{"recent only", Query{Text: "starship", From: time.Now().Add(-2*time.Hour - 30*time.Minute)}, []int64{4, 3}, 2},
+		{"everything", Query{Text: "starship"}, []int64{4, 3, 2, 1}, 4},
+		{"page in log", Query{Text: "starship", Offset: 2, Limit: 1}, []int64{2}, 4},
+		{"page across memory and log", Query{Offset: 1, Limit: 2}, []int64{3, 2}, 4},
+		{"only old", Query{To: time.Now().Add(-3*time.Hour - 30*time.Minute)}, []int64{1}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := idx.Search(tt.q)
+			if err != nil {
+				t.Fatalf("Search: %s", err.Error())
+			}
+
+			var ids = []int64{}
+			for _, r := range res.Records {
+				ids = append(ids, r.TweetID)
+			}
+
+			if !reflect.DeepEqual(ids, tt.wantIDs) || res.Total != tt.wantTotal {
+				t.Errorf("Search(%+v) = %v (total %d), want %v (total %d)", tt.q, ids, res.Total, tt.wantIDs, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestIndexExpire(t *testing.T) {
+	idx := NewIndex(time.Hour)
+	idx.add(Record{TweetID: 1, Time: time.Now().Add(-48 * time.Hour), Text: "old starship"})
+	idx.add(Record{TweetID: 2, Time: time.Now(), Text: "recent"})
+
+	idx.expire()
+
+	if idx.Len() != 1 || len(idx.postings["starship"]) != 0 || !reflect.DeepEqual(idx.postings["recent"], []int{0}) {
+		t.Errorf("expected only the recent record to be kept, got %+v with postings %v", idx.records, idx.postings)
+	}
+}
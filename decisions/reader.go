@@ -0,0 +1,204 @@
+package decisions
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrStop can be returned from a callback to stop reading without an error
+var ErrStop = errors.New("stop reading")
+
+type segment struct {
+	path       string
+	compressed bool
+
+	// start is the time the segment was started, end is the start of the next segment
+	start, end time.Time
+}
+
+// listSegments returns all segments in dir, sorted by time
+func listSegments(dir string) (segments []segment, err error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+
+		var (
+			name       = e.Name()
+			compressed bool
+			base       string
+		)
+		switch {
+		case strings.HasSuffix(name, compressedSegmentExtension):
+			compressed = true
+			base = strings.TrimSuffix(name, compressedSegmentExtension)
+		case strings.HasSuffix(name, closedSegmentExtension):
+			base = strings.TrimSuffix(name, closedSegmentExtension)
+		case strings.HasSuffix(name, segmentExtension):
+			base = strings.TrimSuffix(name, segmentExtension)
+		default:
+			continue
+		}
+
+		start, perr := time.ParseInLocation(segmentTimeFormat, base, time.UTC)
+		if perr != nil {
+			continue
+		}
+
+		segments = append(segments, segment{
+			path:       filepath.Join(dir, name),
+			compressed: compressed,
+			start:      start,
+		})
+	}
+
+	sort.Slice(segments, func(i, j int) bool {
+		if segments[i].start.Equal(segments[j].start) {
+			return segments[i].compressed
+		}
+		return segments[i].start.Before(segments[j].start)
+	})
+
+	// While a closed segment is being compressed, both files can exist for a short time
+	var deduplicated = segments[:0]
+	for i, s := range segments {
+		if i > 0 && s.start.Equal(segments[i-1].start) {
+			continue
+		}
+		deduplicated = append(deduplicated, s)
+	}
+	segments = deduplicated
+
+	for i := range segments {
+		if i+1 < len(segments) {
+			segments[i].end = segments[i+1].start
+		} else {
+			segments[i].end = time.Now()
+		}
+	}
+
+	return
+}
+
+// Read calls fn for every record in dir with a time between from and to, oldest first.
+// A zero from or to means that there is no limit. If fn returns ErrStop, reading stops without an error
+func Read(dir string, from, to time.Time, fn func(r Record) error) (err error) {
+	segments, err := listSegments(dir)
+	if err != nil {
+		return
+	}
+
+	for _, s := range segments {
+		// Skip segments that cannot contain matching records
+		if !to.IsZero() && s.start.After(to) {
+			break
+		}
+		if !from.IsZero() && s.end.Before(from) {
+			continue
+		}
+
+		err = readSegment(s, func(r Record) error {
+			if (!from.IsZero() && r.Time.Before(from)) || (!to.IsZero() && r.Time.After(to)) {
+				return nil
+			}
+			return fn(r)
+		})
+		if errors.Is(err, ErrStop) {
+			return nil
+		}
+		if err != nil {
+			return
+		}
+	}
+
+	return nil
+}
+
+// Since returns all records that were written within maxAge
+func Since(dir string, maxAge time.Duration) (records []Record, err error) {
+	err = Read(dir, time.Now().Add(-maxAge), time.Time{}, func(r Record) error {
+		records = append(records, r)
+		return nil
+	})
+	return
+}
+
+// ReadLines calls fn for every line in the segments of dir, oldest first. It reads values that were
+// written using Writer.WriteValue. If fn returns ErrStop, reading stops without an error
+func ReadLines(dir string, fn func(line []byte) error) (err error) {
+	segments, err := listSegments(dir)
+	if err != nil {
+		return
+	}
+
+	for _, s := range segments {
+		err = readLines(s, fn)
+		if errors.Is(err, ErrStop) {
+			return nil
+		}
+		if err != nil {
+			return
+		}
+	}
+
+	return nil
+}
+
+func readSegment(s segment, fn func(r Record) error) (err error) {
+	return readLines(s, func(line []byte) error {
+		var r Record
+		// The last line of a segment that is still being written might be incomplete
+		if json.Unmarshal(line, &r) != nil {
+			return nil
+		}
+
+		return fn(r)
+	})
+}
+
+func readLines(s segment, fn func(line []byte) error) (err error) {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	var reader io.Reader = f
+	if s.compressed {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 16<<20)
+
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		err = fn(scanner.Bytes())
+		if err != nil {
+			return
+		}
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,49 @@
+// Package decisions implements the decision log, which records what the bot did with every tweet it processed and why
+package decisions
+
+import "time"
+
+const (
+	ActionRetweet = "retweet"
+	ActionQuote   = "quote"
+	ActionLike    = "like"
+	ActionIgnore  = "ignore"
+
+	// ActionShadowDisagreement is recorded when the candidate matcher of shadow mode decided differently than the live
+	// one. Nothing was done with the tweet
+	ActionShadowDisagreement = "shadow_disagreement"
+
+	// ActionRetract is recorded when a retweet was undone because the tweet is no longer eligible.
+	// The reason says why, retweets of deleted tweets are gone without us doing anything
+	ActionRetract = "retract"
+)
+
+// Record is a single decision about a tweet
+type Record struct {
+	Time time.Time `json:"time"`
+
+	TweetID  int64  `json:"tweet_id"`
+	AuthorID int64  `json:"author_id,omitempty"`
+	Author   string `json:"author,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Source   string `json:"source"`
+	// Place is the full name of the place the tweet was tagged with
+	Place string `json:"place,omitempty"`
+
+	// Action is what was done with the tweet, e.g. ActionRetweet
+	Action string `json:"action"`
+	// Reason is why the action was taken, e.g. "location + pad announcement"
+	Reason string `json:"reason,omitempty"`
+	// Explanation contains the steps the matcher took to reach its decision
+	Explanation []string `json:"explanation,omitempty"`
+
+	// RulesVersion identifies the matcher rules that were used
+	RulesVersion string `json:"rules_version"`
+	// LatencyMS is how long processing the tweet took until this decision was made
+	LatencyMS float64 `json:"latency_ms"`
+}
+
+// Shared returns whether the record is about a tweet we retweeted or quoted
+func (r *Record) Shared() bool {
+	return r.Action == ActionRetweet || r.Action == ActionQuote
+}
@@ -0,0 +1,307 @@
+package decisions
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/xarantolus/spacex-hop-bot/util"
+)
+
+const (
+	segmentTimeFormat = "2006-01-02T15-04-05"
+
+	segmentExtension           = ".jsonl"
+	compressedSegmentExtension = ".jsonl.gz"
+	// Complete segments are renamed to this extension until they are compressed in the background
+	closedSegmentExtension = ".closed.jsonl"
+
+	defaultMaxSegmentSize = 64 << 20
+	defaultMaxSegmentAge  = 24 * time.Hour
+)
+
+// Options configure a Writer
+type Options struct {
+	// Retention is how long segments are kept. Zero keeps them forever
+	Retention time.Duration
+
+	// MaxSegmentSize is the size after which a new segment is started
+	MaxSegmentSize int64
+	// MaxSegmentAge is the age after which a new segment is started
+	MaxSegmentAge time.Duration
+
+	// Index, if set, gets every record that is written
+	Index *Index
+}
+
+// Writer writes records to a directory of JSONL segments. Segments are compressed once they are complete
+// and deleted after the retention time. It is safe for concurrent use
+type Writer struct {
+	dir  string
+	opts Options
+
+	mu           sync.Mutex
+	current      *os.File
+	currentStart time.Time
+	currentSize  int64
+
+	// compressing tracks segments that are compressed in the background, compressErr is the last error doing that
+	compressing sync.WaitGroup
+	compressMu  sync.Mutex
+	compressErr error
+}
+
+// NewWriter creates a writer for the given directory. Any segments that were left uncompressed,
+// e.g. because the bot was stopped, are compressed
+func NewWriter(dir string, opts Options) (w *Writer, err error) {
+	if opts.MaxSegmentSize <= 0 {
+		opts.MaxSegmentSize = defaultMaxSegmentSize
+	}
+	if opts.MaxSegmentAge <= 0 {
+		opts.MaxSegmentAge = defaultMaxSegmentAge
+	}
+
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return
+	}
+
+	w = &Writer{
+		dir:  dir,
+		opts: opts,
+	}
+
+	segments, err := listSegments(dir)
+	if err != nil {
+		return
+	}
+	for _, s := range segments {
+		if !s.compressed {
+			err = compressSegment(s.path)
+			if err != nil {
+				return nil, fmt.Errorf("compressing old segment %s: %w", s.path, err)
+			}
+		}
+	}
+
+	err = w.applyRetention()
+
+	return
+}
+
+// Write appends a record to the current segment
+func (w *Writer) Write(r Record) (err error) {
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+
+	err = w.WriteValue(r)
+
+	if err == nil && w.opts.Index != nil {
+		w.opts.Index.Add(r)
+	}
+
+	return
+}
+
+// WriteValue appends any value as a JSON line to the current segment. It allows other logs, e.g. cassettes,
+// to use the same segments and retention as the decision log. Values written this way are not indexed
+func (w *Writer) WriteValue(v interface{}) (err error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	data = append(data, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.current != nil && (w.currentSize+int64(len(data)) > w.opts.MaxSegmentSize || time.Since(w.currentStart) > w.opts.MaxSegmentAge) {
+		// Even if the old segment can't be closed, the record is written to a new one
+		util.LogError(w.rotate(), "rotating segment in %s", w.dir)
+	}
+
+	if w.current == nil {
+		err = w.open()
+		if err != nil {
+			return
+		}
+	}
+
+	n, err := w.current.Write(data)
+	w.currentSize += int64(n)
+
+	return
+}
+
+// Close closes the current segment and waits until all segments are compressed
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	err := w.rotate()
+	w.mu.Unlock()
+
+	w.compressing.Wait()
+
+	if err != nil {
+		return err
+	}
+
+	w.compressMu.Lock()
+	defer w.compressMu.Unlock()
+
+	return w.compressErr
+}
+
+// open starts a new segment. w.mu must be held
+func (w *Writer) open() (err error) {
+	now := time.Now().UTC()
+
+	// Segment names have a resolution of one second, so we make sure not to overwrite a segment that was just closed
+	for w.segmentExists(now) {
+		now = now.Add(time.Second)
+	}
+
+	fn := filepath.Join(w.dir, now.Format(segmentTimeFormat)+segmentExtension)
+	f, err := os.OpenFile(fn, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return
+	}
+
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return
+	}
+
+	w.current = f
+	w.currentStart = now
+	w.currentSize = stat.Size()
+
+	return nil
+}
+
+// segmentExists returns whether a closed or compressed segment was started at the given time
+func (w *Writer) segmentExists(start time.Time) bool {
+	for _, ext := range []string{compressedSegmentExtension, closedSegmentExtension} {
+		_, err := os.Stat(filepath.Join(w.dir, start.Format(segmentTimeFormat)+ext))
+		if !os.IsNotExist(err) {
+			return true
+		}
+	}
+	return false
+}
+
+// rotate closes the current segment and compresses it in the background, then removes old segments. w.mu must be held
+func (w *Writer) rotate() (err error) {
+	if w.current == nil {
+		return nil
+	}
+
+	fn := w.current.Name()
+	err = w.current.Close()
+	w.current = nil
+	if err != nil {
+		return
+	}
+
+	// The new name makes sure that the next segment doesn't append to this one while it is compressed
+	closed := strings.TrimSuffix(fn, segmentExtension) + closedSegmentExtension
+	err = os.Rename(fn, closed)
+	if err != nil {
+		return
+	}
+
+	w.compressing.Add(1)
+	go func() {
+		defer w.compressing.Done()
+
+		err := compressSegment(closed)
+		if err == nil {
+			err = w.applyRetention()
+		}
+		if util.LogError(err, "compressing segment %s", closed) {
+			w.compressMu.Lock()
+			w.compressErr = err
+			w.compressMu.Unlock()
+		}
+	}()
+
+	return nil
+}
+
+// applyRetention removes all compressed segments older than the retention time
+func (w *Writer) applyRetention() error {
+	if w.opts.Retention <= 0 {
+		return nil
+	}
+
+	segments, err := listSegments(w.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range segments {
+		// A segment can contain records until the next segment starts, so we use the start of the
+		// next segment to decide whether all records are older than the retention time
+		if !s.compressed || time.Since(s.end) < w.opts.Retention {
+			continue
+		}
+
+		// Segments are compressed in the background, so another retention run might have removed it already
+		err = os.Remove(s.path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func compressSegment(fn string) (err error) {
+	in, err := os.Open(fn)
+	if err != nil {
+		return
+	}
+	defer in.Close()
+
+	outFn := strings.TrimSuffix(strings.TrimSuffix(fn, closedSegmentExtension), segmentExtension) + compressedSegmentExtension
+	tmpFn := outFn + ".tmp"
+
+	out, err := os.Create(tmpFn)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			out.Close()
+			_ = os.Remove(tmpFn)
+		}
+	}()
+
+	gz := gzip.NewWriter(out)
+	_, err = io.Copy(gz, in)
+	if err != nil {
+		return
+	}
+	err = gz.Close()
+	if err != nil {
+		return
+	}
+	err = out.Close()
+	if err != nil {
+		return
+	}
+
+	err = os.Rename(tmpFn, outFn)
+	if err != nil {
+		return
+	}
+
+	return os.Remove(fn)
+}
@@ -0,0 +1,176 @@
+package decisions
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriterRotationAndRead(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := NewWriter(dir, Options{
+		// Every record is larger than this, so every write starts a new segment
+		MaxSegmentSize: 10,
+	})
+	if err != nil {
+		t.Fatalf("NewWriter: %s", err.Error())
+	}
+
+	for i := int64(1); i <= 3; i++ {
+		err = w.Write(Record{TweetID: i, Action: ActionRetweet, Time: time.Now().Add(time.Duration(i-10) * time.Minute)})
+		if err != nil {
+			t.Fatalf("Write: %s", err.Error())
+		}
+	}
+	if err = w.Close(); err != nil {
+		t.Fatalf("Close: %s", err.Error())
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %s", err.Error())
+	}
+	if len(entries) != 3 {
+		t.Errorf("expected 3 segments, but got %d", len(entries))
+	}
+	for _, e := range entries {
+		if !strings.HasSuffix(e.Name(), compressedSegmentExtension) {
+			t.Errorf("segment %s was not compressed", e.Name())
+		}
+	}
+
+	var ids []int64
+	err = Read(dir, time.Time{}, time.Time{}, func(r Record) error {
+		ids = append(ids, r.TweetID)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Read: %s", err.Error())
+	}
+	if len(ids) != 3 || ids[0] != 1 || ids[2] != 3 {
+		t.Errorf("expected to read records 1, 2, 3 in order, but got %v", ids)
+	}
+
+	recent, err := Since(dir, 7*time.Minute+30*time.Second)
+	if err != nil {
+		t.Fatalf("Since: %s", err.Error())
+	}
+	if len(recent) != 1 || recent[0].TweetID != 3 {
+		t.Errorf("expected only record 3 to be recent, but got %+v", recent)
+	}
+
+	var count int
+	err = Read(dir, time.Time{}, time.Time{}, func(r Record) error {
+		count++
+		return ErrStop
+	})
+	if err != nil || count != 1 {
+		t.Errorf("expected ErrStop to stop reading without error, but got count=%d, err=%v", count, err)
+	}
+}
+
+func TestWriterRetention(t *testing.T) {
+	dir := t.TempDir()
+
+	// Create a compressed segment that is way too old, followed by a newer one
+	old := time.Now().Add(-72 * time.Hour).UTC()
+	newer := time.Now().Add(-48 * time.Hour).UTC()
+	for _, d := range []time.Time{old, newer} {
+		fn := filepath.Join(dir, d.Format(segmentTimeFormat)+segmentExtension)
+		if err := os.WriteFile(fn, []byte(`{"tweet_id":1}`+"\n"), 0644); err != nil {
+			t.Fatalf("writing segment: %s", err.Error())
+		}
+	}
+
+	w, err := NewWriter(dir, Options{Retention: 24 * time.Hour})
+	if err != nil {
+		t.Fatalf("NewWriter: %s", err.Error())
+	}
+	defer w.Close()
+
+	segments, err := listSegments(dir)
+	if err != nil {
+		t.Fatalf("listSegments: %s", err.Error())
+	}
+
+	// The newer segment might still contain records within the retention time, as
+	// there is no segment after it
+	if len(segments) != 1 || !segments[0].start.Equal(newer.Truncate(time.Second)) {
+		t.Errorf("expected only the newer segment to be kept, but got %+v", segments)
+	}
+}
+
+func TestWriteValueAndReadLines(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := NewWriter(dir, Options{MaxSegmentSize: 10})
+	if err != nil {
+		t.Fatalf("NewWriter: %s", err.Error())
+	}
+
+	for _, v := range []string{"first", "second"} {
+		if err = w.WriteValue(map[string]string{"value": v}); err != nil {
+			t.Fatalf("WriteValue: %s", err.Error())
+		}
+	}
+	if err = w.Close(); err != nil {
+		t.Fatalf("Close: %s", err.Error())
+	}
+
+	var lines []string
+	err = ReadLines(dir, func(line []byte) error {
+		lines = append(lines, string(line))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ReadLines: %s", err.Error())
+	}
+	if len(lines) != 2 || lines[0] != `{"value":"first"}` || lines[1] != `{"value":"second"}` {
+		t.Errorf("expected both values in order, got %v", lines)
+	}
+}
+
+func TestWriterCompressionFailureKeepsRecords(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := NewWriter(dir, Options{MaxSegmentSize: 10})
+	if err != nil {
+		t.Fatalf("NewWriter: %s", err.Error())
+	}
+
+	if err = w.Write(Record{TweetID: 1, Action: ActionRetweet}); err != nil {
+		t.Fatalf("Write: %s", err.Error())
+	}
+
+	// A directory where the temporary compressed file should be created makes compressing the first segment fail
+	base := strings.TrimSuffix(w.current.Name(), segmentExtension)
+	if err = os.Mkdir(base+compressedSegmentExtension+".tmp", 0755); err != nil {
+		t.Fatalf("Mkdir: %s", err.Error())
+	}
+
+	if err = w.Write(Record{TweetID: 2, Action: ActionRetweet}); err != nil {
+		t.Fatalf("expected the record to be written even though the old segment can't be compressed, but got %s", err.Error())
+	}
+	if err = w.Close(); err == nil {
+		t.Errorf("expected Close to return the compression error")
+	}
+
+	if _, err = os.Stat(base + closedSegmentExtension); err != nil {
+		t.Errorf("expected the closed segment to be kept: %s", err.Error())
+	}
+
+	var ids []int64
+	err = Read(dir, time.Time{}, time.Time{}, func(r Record) error {
+		ids = append(ids, r.TweetID)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Read: %s", err.Error())
+	}
+	if len(ids) != 2 || ids[0] != 1 || ids[1] != 2 {
+		t.Errorf("expected to read records 1 and 2, but got %v", ids)
+	}
+}
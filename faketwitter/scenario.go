@@ -0,0 +1,136 @@
+// Package faketwitter implements a fake server for the Twitter API v1.1 endpoints the bot uses.
+// It serves tweets from a scripted scenario, so the whole bot can be run without real credentials
+package faketwitter
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario describes the accounts, lists and tweets the fake server knows about
+type Scenario struct {
+	// User is the account the bot is logged in as
+	User ScenarioUser `yaml:"user"`
+
+	// Users are all other accounts, tweets and lists refer to them by ID
+	Users []ScenarioUser `yaml:"users"`
+
+	Lists []ScenarioList `yaml:"lists"`
+
+	Tweets []ScenarioTweet `yaml:"tweets"`
+}
+
+type ScenarioUser struct {
+	ID          int64  `yaml:"id"`
+	ScreenName  string `yaml:"screen_name"`
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	Protected   bool   `yaml:"protected"`
+}
+
+type ScenarioList struct {
+	ID   int64  `yaml:"id"`
+	Name string `yaml:"name"`
+	// Owner is the ID of the user that owns the list, by default the bot user
+	Owner int64 `yaml:"owner"`
+	// Members are user IDs. Tweets by members appear in the list timeline
+	Members []int64 `yaml:"members"`
+}
+
+// Timelines a tweet can appear in, lists are "list:<id>"
+const (
+	TimelineHome   = "home"
+	TimelineStream = "stream"
+)
+
+type ScenarioTweet struct {
+	ID int64 `yaml:"id"`
+	// User is the ID of the author
+	User int64  `yaml:"user"`
+	Text string `yaml:"text"`
+
+	// URLs are appended to the text as t.co links
+	URLs []string `yaml:"urls"`
+	// Media adds a photo to the tweet
+	Media bool `yaml:"media"`
+
+	// Place is the ID of the place the tweet was tagged with, PlaceName is its full name
+	Place     string `yaml:"place"`
+	PlaceName string `yaml:"place_name"`
+
+	// ReplyTo and Quote are IDs of other tweets in the scenario
+	ReplyTo int64 `yaml:"reply_to"`
+	Quote   int64 `yaml:"quote"`
+
+	// After is how long after the server started the tweet becomes visible. It is also used as creation time.
+	// Jobs ignore what they see in their first request, so tweets should appear after the bot started
+	After time.Duration `yaml:"after"`
+
+	// Timelines are "home", "stream" or "list:<id>". Visible tweets can always be loaded by ID and
+	// are in the user timeline of their author
+	Timelines []string `yaml:"timelines"`
+}
+
+func (t *ScenarioTweet) inTimeline(name string) bool {
+	for _, tl := range t.Timelines {
+		if strings.EqualFold(tl, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadScenario reads a scenario from a YAML file
+func LoadScenario(filename string) (s Scenario, err error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err = dec.Decode(&s); err != nil {
+		return s, fmt.Errorf("parsing scenario %s: %w", filename, err)
+	}
+
+	return s, s.validate()
+}
+
+func (s *Scenario) validate() error {
+	var (
+		users  = map[int64]bool{s.User.ID: true}
+		tweets = make(map[int64]bool)
+	)
+	for _, u := range s.Users {
+		users[u.ID] = true
+	}
+	for _, t := range s.Tweets {
+		if tweets[t.ID] {
+			return fmt.Errorf("scenario: duplicate tweet %d", t.ID)
+		}
+		tweets[t.ID] = true
+	}
+
+	for _, l := range s.Lists {
+		for _, m := range l.Members {
+			if !users[m] {
+				return fmt.Errorf("scenario: list %d has unknown member %d", l.ID, m)
+			}
+		}
+	}
+	for _, t := range s.Tweets {
+		if !users[t.User] {
+			return fmt.Errorf("scenario: tweet %d is by unknown user %d", t.ID, t.User)
+		}
+		if t.ReplyTo != 0 && !tweets[t.ReplyTo] || t.Quote != 0 && !tweets[t.Quote] {
+			return fmt.Errorf("scenario: tweet %d refers to an unknown tweet", t.ID)
+		}
+	}
+
+	return nil
+}
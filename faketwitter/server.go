@@ -0,0 +1,639 @@
+package faketwitter
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+// Action is something the bot did, e.g. retweeting a tweet
+type Action struct {
+	Time   time.Time `json:"time"`
+	Action string    `json:"action"`
+
+	TweetID int64   `json:"tweet_id,omitempty"`
+	Text    string  `json:"text,omitempty"`
+	ListID  int64   `json:"list_id,omitempty"`
+	UserIDs []int64 `json:"user_ids,omitempty"`
+}
+
+// Server is a fake Twitter API server
+type Server struct {
+	scenario Scenario
+	start    time.Time
+
+	// now returns the current time, it can be changed in tests
+	now func() time.Time
+
+	mu sync.Mutex
+
+	users   map[int64]*ScenarioUser
+	members map[int64]map[int64]bool
+	tweets  map[int64]*ScenarioTweet
+
+	retweeted map[int64]bool
+	// streamed contains tweets that were already sent on a stream, so they are not sent again after reconnecting
+	streamed map[int64]bool
+	nextID   int64
+
+	actions []Action
+}
+
+// New returns a server for the scenario. Time in the scenario starts now
+func New(s Scenario) *Server {
+	var srv = &Server{
+		scenario:  s,
+		start:     time.Now(),
+		now:       time.Now,
+		users:     make(map[int64]*ScenarioUser),
+		members:   make(map[int64]map[int64]bool),
+		tweets:    make(map[int64]*ScenarioTweet),
+		retweeted: make(map[int64]bool),
+		streamed:  make(map[int64]bool),
+		nextID:    1,
+	}
+
+	srv.users[s.User.ID] = &srv.scenario.User
+	for i, u := range srv.scenario.Users {
+		srv.users[u.ID] = &srv.scenario.Users[i]
+	}
+	for _, l := range srv.scenario.Lists {
+		srv.members[l.ID] = make(map[int64]bool)
+		for _, m := range l.Members {
+			srv.members[l.ID][m] = true
+		}
+	}
+	for i, t := range srv.scenario.Tweets {
+		srv.tweets[t.ID] = &srv.scenario.Tweets[i]
+		if t.ID >= srv.nextID {
+			srv.nextID = t.ID + 1
+		}
+	}
+
+	return srv
+}
+
+// Actions returns everything the bot did so far
+func (s *Server) Actions() []Action {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Action(nil), s.actions...)
+}
+
+// Handler returns the handler for all endpoints. Both API and stream requests can be sent to it
+func (s *Server) Handler() http.Handler {
+	var mux = http.NewServeMux()
+
+	mux.HandleFunc("/1.1/account/verify_credentials.json", s.verifyCredentials)
+	mux.HandleFunc("/1.1/statuses/home_timeline.json", s.homeTimeline)
+	mux.HandleFunc("/1.1/statuses/user_timeline.json", s.userTimeline)
+	mux.HandleFunc("/1.1/statuses/show.json", s.show)
+	mux.HandleFunc("/1.1/search/tweets.json", s.search)
+	mux.HandleFunc("/1.1/statuses/update.json", s.update)
+	mux.HandleFunc("/1.1/statuses/retweet/", s.retweet)
+	mux.HandleFunc("/1.1/statuses/unretweet/", s.unretweet)
+	mux.HandleFunc("/1.1/statuses/filter.json", s.filterStream)
+	mux.HandleFunc("/1.1/favorites/create.json", s.like)
+	mux.HandleFunc("/1.1/lists/list.json", s.lists)
+	mux.HandleFunc("/1.1/lists/ownerships.json", s.listOwnerships)
+	mux.HandleFunc("/1.1/lists/subscriptions.json", s.listSubscriptions)
+	mux.HandleFunc("/1.1/lists/statuses.json", s.listStatuses)
+	mux.HandleFunc("/1.1/lists/members.json", s.listMembers)
+	mux.HandleFunc("/1.1/lists/update.json", s.listUpdate)
+	mux.HandleFunc("/1.1/lists/members/create_all.json", s.listMembersChange("add list members"))
+	mux.HandleFunc("/1.1/lists/members/destroy_all.json", s.listMembersChange("remove list members"))
+	mux.HandleFunc("/1.1/application/rate_limit_status.json", s.rateLimitStatus)
+
+	// Not part of the Twitter API, this allows checking what the bot did
+	mux.HandleFunc("/fake/actions", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, s.Actions())
+	})
+
+	return logRequests(mux)
+}
+
+func logRequests(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("[Fake Twitter] %s %s\n", r.Method, r.URL.Path)
+		h.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes an error in the format of the v1.1 API
+func writeError(w http.ResponseWriter, status, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(twitter.APIError{
+		Errors: []twitter.ErrorDetail{{Code: code, Message: message}},
+	})
+}
+
+func requireMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method != method {
+		writeError(w, http.StatusMethodNotAllowed, 0, "method not allowed")
+		return false
+	}
+	return true
+}
+
+func (s *Server) record(a Action) {
+	a.Time = s.now()
+	s.actions = append(s.actions, a)
+	log.Printf("[Fake Twitter] Bot action: %s %d %s\n", a.Action, a.TweetID, a.Text)
+}
+
+// visible returns whether the tweet already appeared. s.mu must be held
+func (s *Server) visible(t *ScenarioTweet) bool {
+	return !s.now().Before(s.start.Add(t.After))
+}
+
+// tweet converts a scenario tweet to the API model. s.mu must be held
+func (s *Server) tweet(t *ScenarioTweet, depth int) *twitter.Tweet {
+	var tw = &twitter.Tweet{
+		ID:        t.ID,
+		IDStr:     strconv.FormatInt(t.ID, 10),
+		CreatedAt: s.start.Add(t.After).UTC().Format(time.RubyDate),
+		Retweeted: s.retweeted[t.ID],
+		Lang:      "en",
+		Entities:  &twitter.Entities{},
+	}
+
+	if u := s.users[t.User]; u != nil {
+		tw.User = apiUser(u)
+	}
+
+	var text = t.Text
+	for i, u := range t.URLs {
+		short := fmt.Sprintf("https://t.co/fake%d_%d", t.ID, i)
+		text += " " + short
+		tw.Entities.Urls = append(tw.Entities.Urls, twitter.URLEntity{URL: short, ExpandedURL: u, DisplayURL: u})
+	}
+	if t.Media {
+		short := fmt.Sprintf("https://t.co/fakemedia%d", t.ID)
+		text += " " + short
+		media := twitter.MediaEntity{
+			URLEntity:     twitter.URLEntity{URL: short},
+			ID:            t.ID,
+			MediaURLHttps: fmt.Sprintf("https://pbs.twimg.com/media/fake%d.jpg", t.ID),
+			Type:          "photo",
+		}
+		tw.Entities.Media = []twitter.MediaEntity{media}
+		tw.ExtendedEntities = &twitter.ExtendedEntity{Media: []twitter.MediaEntity{media}}
+	}
+	tw.FullText = text
+	tw.SimpleText = text
+
+	if t.Place != "" {
+		tw.Place = &twitter.Place{ID: t.Place, FullName: t.PlaceName}
+	}
+
+	if parent := s.tweets[t.ReplyTo]; parent != nil {
+		tw.InReplyToStatusID = parent.ID
+		tw.InReplyToStatusIDStr = strconv.FormatInt(parent.ID, 10)
+		if u := s.users[parent.User]; u != nil {
+			tw.InReplyToUserID = u.ID
+			tw.InReplyToUserIDStr = strconv.FormatInt(u.ID, 10)
+			tw.InReplyToScreenName = u.ScreenName
+		}
+	}
+
+	if quoted := s.tweets[t.Quote]; quoted != nil {
+		tw.QuotedStatusID = quoted.ID
+		tw.QuotedStatusIDStr = strconv.FormatInt(quoted.ID, 10)
+		if depth == 0 {
+			tw.QuotedStatus = s.tweet(quoted, depth+1)
+		}
+	}
+
+	return tw
+}
+
+func apiUser(u *ScenarioUser) *twitter.User {
+	return &twitter.User{
+		ID:          u.ID,
+		IDStr:       strconv.FormatInt(u.ID, 10),
+		ScreenName:  u.ScreenName,
+		Name:        u.Name,
+		Description: u.Description,
+		Protected:   u.Protected,
+	}
+}
+
+// timeline returns visible tweets for which include returns true, newest first, like the API does
+func (s *Server) timeline(r *http.Request, include func(t *ScenarioTweet) bool) (tweets []*twitter.Tweet) {
+	sinceID, _ := strconv.ParseInt(r.URL.Query().Get("since_id"), 10, 64)
+	count, _ := strconv.Atoi(r.URL.Query().Get("count"))
+	if count <= 0 || count > 200 {
+		count = 200
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var matching []*ScenarioTweet
+	for _, t := range s.tweets {
+		if t.ID > sinceID && s.visible(t) && include(t) {
+			matching = append(matching, t)
+		}
+	}
+
+	sort.Slice(matching, func(i, j int) bool {
+		return matching[i].ID > matching[j].ID
+	})
+	if len(matching) > count {
+		matching = matching[:count]
+	}
+
+	tweets = []*twitter.Tweet{}
+	for _, t := range matching {
+		tweets = append(tweets, s.tweet(t, 0))
+	}
+	return
+}
+
+// rateLimitStatus reports no limits, the fake server doesn't have any
+func (s *Server) rateLimitStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, twitter.RateLimit{Resources: &twitter.RateLimitResources{}})
+}
+
+func (s *Server) verifyCredentials(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, apiUser(&s.scenario.User))
+}
+
+func (s *Server) homeTimeline(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.timeline(r, func(t *ScenarioTweet) bool {
+		return t.inTimeline(TimelineHome)
+	}))
+}
+
+func (s *Server) userTimeline(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("screen_name")
+	writeJSON(w, s.timeline(r, func(t *ScenarioTweet) bool {
+		u := s.users[t.User]
+		return u != nil && strings.EqualFold(u.ScreenName, name)
+	}))
+}
+
+// search returns tweets that contain all words of the query. Operators like "filter:media" are ignored
+func (s *Server) search(w http.ResponseWriter, r *http.Request) {
+	var words []string
+	for _, w := range strings.Fields(strings.ToLower(r.URL.Query().Get("q"))) {
+		if !strings.Contains(w, ":") {
+			words = append(words, w)
+		}
+	}
+
+	writeJSON(w, twitter.Search{Statuses: derefTweets(s.timeline(r, func(t *ScenarioTweet) bool {
+		text := strings.ToLower(t.Text)
+		for _, w := range words {
+			if !strings.Contains(text, w) {
+				return false
+			}
+		}
+		return true
+	}))})
+}
+
+func derefTweets(tweets []*twitter.Tweet) (result []twitter.Tweet) {
+	result = []twitter.Tweet{}
+	for _, t := range tweets {
+		result = append(result, *t)
+	}
+	return
+}
+
+func (s *Server) show(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := s.tweets[id]
+	if t == nil || !s.visible(t) {
+		writeError(w, http.StatusNotFound, 144, "No status found with that ID.")
+		return
+	}
+
+	writeJSON(w, s.tweet(t, 0))
+}
+
+// pathID returns the ID in paths like /1.1/statuses/retweet/123.json
+func pathID(r *http.Request) int64 {
+	base := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+	id, _ := strconv.ParseInt(strings.TrimSuffix(base, ".json"), 10, 64)
+	return id
+}
+
+func (s *Server) retweet(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	id := pathID(r)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := s.tweets[id]
+	if t == nil || !s.visible(t) {
+		writeError(w, http.StatusNotFound, 144, "No status found with that ID.")
+		return
+	}
+	if s.retweeted[id] {
+		writeError(w, http.StatusForbidden, 327, "You have already retweeted this Tweet.")
+		return
+	}
+
+	s.retweeted[id] = true
+	s.record(Action{Action: "retweet", TweetID: id})
+
+	writeJSON(w, s.tweet(t, 0))
+}
+
+func (s *Server) unretweet(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	id := pathID(r)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := s.tweets[id]
+	if t == nil {
+		writeError(w, http.StatusNotFound, 144, "No status found with that ID.")
+		return
+	}
+
+	delete(s.retweeted, id)
+	s.record(Action{Action: "unretweet", TweetID: id})
+
+	writeJSON(w, s.tweet(t, 0))
+}
+
+func (s *Server) like(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	id, _ := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := s.tweets[id]
+	if t == nil || !s.visible(t) {
+		writeError(w, http.StatusNotFound, 144, "No status found with that ID.")
+		return
+	}
+
+	s.record(Action{Action: "like", TweetID: id})
+	writeJSON(w, s.tweet(t, 0))
+}
+
+func (s *Server) update(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	// go-twitter sends parameters in the query, forms are accepted too
+	_ = r.ParseForm()
+	replyTo, _ := strconv.ParseInt(r.Form.Get("in_reply_to_status_id"), 10, 64)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The bot's own tweets become part of the scenario, so they can be loaded and replied to
+	var t = &ScenarioTweet{
+		ID:      s.nextID,
+		User:    s.scenario.User.ID,
+		Text:    r.Form.Get("status"),
+		ReplyTo: replyTo,
+		After:   s.now().Sub(s.start),
+	}
+	s.nextID++
+	s.tweets[t.ID] = t
+
+	s.record(Action{Action: "tweet", TweetID: t.ID, Text: t.Text})
+
+	writeJSON(w, s.tweet(t, 0))
+}
+
+func (s *Server) lists(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	writeJSON(w, s.apiLists(func(owned bool) bool { return true }))
+}
+
+// listOwnerships and listSubscriptions return the lists the bot owns and those it follows from other users.
+// All lists fit on one page, so the cursor is always 0
+func (s *Server) listOwnerships(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	writeJSON(w, twitter.Ownership{Lists: s.apiLists(func(owned bool) bool { return owned })})
+}
+
+func (s *Server) listSubscriptions(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	writeJSON(w, twitter.Membership{Lists: s.apiLists(func(owned bool) bool { return !owned })})
+}
+
+// apiLists returns the scenario lists for which include returns true, it is called with whether the bot owns a list
+func (s *Server) apiLists(include func(owned bool) bool) []twitter.List {
+	var lists = []twitter.List{}
+	for _, l := range s.scenario.Lists {
+		owner := l.Owner
+		if owner == 0 {
+			owner = s.scenario.User.ID
+		}
+		if !include(owner == s.scenario.User.ID) {
+			continue
+		}
+
+		var list = twitter.List{
+			ID:          l.ID,
+			IDStr:       strconv.FormatInt(l.ID, 10),
+			Name:        l.Name,
+			FullName:    "@" + s.scenario.User.ScreenName + "/" + l.Name,
+			Mode:        "public",
+			MemberCount: len(s.members[l.ID]),
+		}
+		if u := s.users[owner]; u != nil {
+			list.User = apiUser(u)
+			list.FullName = "@" + u.ScreenName + "/" + l.Name
+		}
+		lists = append(lists, list)
+	}
+
+	return lists
+}
+
+func listID(r *http.Request) int64 {
+	_ = r.ParseForm()
+	id, _ := strconv.ParseInt(r.Form.Get("list_id"), 10, 64)
+	return id
+}
+
+func (s *Server) listStatuses(w http.ResponseWriter, r *http.Request) {
+	id := listID(r)
+
+	s.mu.Lock()
+	members, ok := s.members[id]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, 34, "Sorry, that page does not exist.")
+		return
+	}
+
+	var timeline = "list:" + strconv.FormatInt(id, 10)
+	writeJSON(w, s.timeline(r, func(t *ScenarioTweet) bool {
+		return members[t.User] || t.inTimeline(timeline)
+	}))
+}
+
+func (s *Server) listMembers(w http.ResponseWriter, r *http.Request) {
+	id := listID(r)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	members, ok := s.members[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, 34, "Sorry, that page does not exist.")
+		return
+	}
+
+	var ids []int64
+	for m := range members {
+		ids = append(ids, m)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	var resp = twitter.Members{Users: []twitter.User{}}
+	for _, m := range ids {
+		if u := s.users[m]; u != nil {
+			resp.Users = append(resp.Users, *apiUser(u))
+		} else {
+			resp.Users = append(resp.Users, twitter.User{ID: m, IDStr: strconv.FormatInt(m, 10)})
+		}
+	}
+
+	writeJSON(w, resp)
+}
+
+func (s *Server) listUpdate(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	writeJSON(w, twitter.List{ID: listID(r)})
+}
+
+func (s *Server) listMembersChange(action string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !requireMethod(w, r, http.MethodPost) {
+			return
+		}
+		id := listID(r)
+
+		var users []int64
+		for _, u := range strings.Split(r.Form.Get("user_id"), ",") {
+			if uid, err := strconv.ParseInt(u, 10, 64); err == nil {
+				users = append(users, uid)
+			}
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		members, ok := s.members[id]
+		if !ok {
+			writeError(w, http.StatusNotFound, 34, "Sorry, that page does not exist.")
+			return
+		}
+		for _, u := range users {
+			if strings.HasPrefix(action, "add") {
+				members[u] = true
+			} else {
+				delete(members, u)
+			}
+		}
+
+		s.record(Action{Action: action, ListID: id, UserIDs: users})
+		writeJSON(w, twitter.List{ID: id})
+	}
+}
+
+// streamPollInterval is how often the stream checks for tweets that became visible
+const streamPollInterval = 100 * time.Millisecond
+
+func (s *Server) filterStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, 0, "streaming not supported")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	var ticker = time.NewTicker(streamPollInterval)
+	defer ticker.Stop()
+
+	for {
+		for _, t := range s.pendingStreamTweets() {
+			buf, err := json.Marshal(t)
+			if err != nil {
+				continue
+			}
+			// Messages in the v1.1 stream are separated by "\r\n"
+			if _, err = w.Write(append(buf, '\r', '\n')); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// pendingStreamTweets returns all visible stream tweets that were not yet streamed, oldest first
+func (s *Server) pendingStreamTweets() (tweets []*twitter.Tweet) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var pending []*ScenarioTweet
+	for _, t := range s.tweets {
+		if !s.streamed[t.ID] && t.inTimeline(TimelineStream) && s.visible(t) {
+			pending = append(pending, t)
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].ID < pending[j].ID
+	})
+
+	for _, t := range pending {
+		s.streamed[t.ID] = true
+		tweets = append(tweets, s.tweet(t, 0))
+	}
+	return
+}
@@ -0,0 +1,183 @@
+package faketwitter
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+// hostTransport sends all requests to the test server, like the bot does when twitter.api_url is set
+type hostTransport struct {
+	target *url.URL
+}
+
+func (h *hostTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.URL.Scheme = h.target.Scheme
+	r.URL.Host = h.target.Host
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func testServer(t *testing.T, s Scenario) (*Server, *twitter.Client, func(time.Duration)) {
+	t.Helper()
+
+	srv := New(s)
+	var now = srv.start
+	srv.now = func() time.Time { return now }
+
+	hs := httptest.NewServer(srv.Handler())
+	t.Cleanup(hs.Close)
+
+	target, _ := url.Parse(hs.URL)
+	client := twitter.NewClient(&http.Client{Transport: &hostTransport{target: target}})
+
+	advance := func(d time.Duration) {
+		srv.mu.Lock()
+		now = now.Add(d)
+		srv.mu.Unlock()
+	}
+
+	return srv, client, advance
+}
+
+func TestExampleScenario(t *testing.T) {
+	s, err := LoadScenario("../cmd/faketwitter/scenario.example.yaml")
+	if err != nil {
+		t.Fatalf("loading example scenario: %s", err.Error())
+	}
+	if len(s.Tweets) == 0 || s.Tweets[1].After != 90*time.Second {
+		t.Errorf("unexpected tweets %+v", s.Tweets)
+	}
+}
+
+var testScenario = Scenario{
+	User:  ScenarioUser{ID: 1, ScreenName: "bot"},
+	Users: []ScenarioUser{{ID: 80, ScreenName: "photos"}, {ID: 81, ScreenName: "other"}},
+	Lists: []ScenarioList{{ID: 10, Name: "Starship", Members: []int64{80}}},
+	Tweets: []ScenarioTweet{
+		{ID: 100, User: 80, Text: "S25 rollout", Media: true, URLs: []string{"https://spacex.com"}},
+		{ID: 101, User: 80, Text: "Another angle", ReplyTo: 100, After: time.Minute},
+		{ID: 102, User: 81, Text: "At Starbase", Place: "124bed061b8e4e2f", Timelines: []string{TimelineStream}},
+	},
+}
+
+func TestServer_Timelines(t *testing.T) {
+	_, client, advance := testServer(t, testScenario)
+
+	user, _, err := client.Accounts.VerifyCredentials(nil)
+	if err != nil || user.ScreenName != "bot" {
+		t.Fatalf("unexpected user %+v / %v", user, err)
+	}
+
+	lists, _, err := client.Lists.List(&twitter.ListsListParams{})
+	if err != nil || len(lists) != 1 || lists[0].User.ScreenName != "bot" {
+		t.Fatalf("unexpected lists %+v / %v", lists, err)
+	}
+	search, _, err := client.Search.Tweets(&twitter.SearchTweetParams{Query: "starbase filter:media"})
+	if err != nil || len(search.Statuses) != 1 || search.Statuses[0].ID != 102 {
+		t.Fatalf("unexpected search results %+v / %v", search, err)
+	}
+
+	owned, _, err := client.Lists.Ownerships(&twitter.ListsOwnershipsParams{Cursor: -1})
+	if err != nil || len(owned.Lists) != 1 || owned.NextCursor != 0 {
+		t.Fatalf("unexpected owned lists %+v / %v", owned, err)
+	}
+	subscribed, _, err := client.Lists.Subscriptions(&twitter.ListsSubscriptionsParams{Cursor: -1})
+	if err != nil || len(subscribed.Lists) != 0 {
+		t.Fatalf("unexpected subscribed lists %+v / %v", subscribed, err)
+	}
+
+	tweets, _, err := client.Lists.Statuses(&twitter.ListsStatusesParams{ListID: 10})
+	if err != nil || len(tweets) != 1 || tweets[0].ID != 100 {
+		t.Fatalf("expected only visible tweet, got %+v / %v", tweets, err)
+	}
+	if tweets[0].TextWithURLs() != "S25 rollout https://spacex.com " || tweets[0].ExtendedEntities == nil {
+		t.Errorf("unexpected tweet %q %+v", tweets[0].TextWithURLs(), tweets[0].ExtendedEntities)
+	}
+
+	_, _, err = client.Statuses.Show(101, nil)
+	var apiErr twitter.APIError
+	if !errors.As(err, &apiErr) || apiErr.Errors[0].Code != 144 {
+		t.Errorf("expected tweet to not be visible yet, got %v", err)
+	}
+
+	advance(time.Minute)
+
+	tweets, _, err = client.Lists.Statuses(&twitter.ListsStatusesParams{ListID: 10, SinceID: 100})
+	if err != nil || len(tweets) != 1 || tweets[0].ID != 101 || tweets[0].InReplyToStatusID != 100 || tweets[0].InReplyToScreenName != "photos" {
+		t.Fatalf("expected reply after a minute, got %+v / %v", tweets, err)
+	}
+
+	tweets, _, err = client.Timelines.UserTimeline(&twitter.UserTimelineParams{ScreenName: "other"})
+	if err != nil || len(tweets) != 1 || tweets[0].Place == nil {
+		t.Errorf("unexpected user timeline %+v / %v", tweets, err)
+	}
+
+	members, _, err := client.Lists.Members(&twitter.ListsMembersParams{ListID: 10})
+	if err != nil || len(members.Users) != 1 || members.Users[0].ID != 80 {
+		t.Errorf("unexpected members %+v / %v", members, err)
+	}
+}
+
+func TestServer_Actions(t *testing.T) {
+	srv, client, _ := testServer(t, testScenario)
+
+	if _, _, err := client.Statuses.Retweet(100, nil); err != nil {
+		t.Fatalf("retweeting: %s", err.Error())
+	}
+
+	_, _, err := client.Statuses.Retweet(100, nil)
+	var apiErr twitter.APIError
+	if !errors.As(err, &apiErr) || apiErr.Errors[0].Code != 327 {
+		t.Errorf("expected error for retweeting twice, got %v", err)
+	}
+
+	tweet, _, err := client.Statuses.Show(100, nil)
+	if err != nil || !tweet.Retweeted {
+		t.Errorf("expected tweet to be marked as retweeted, got %+v / %v", tweet, err)
+	}
+
+	own, _, err := client.Statuses.Update("SpaceX is live", &twitter.StatusUpdateParams{InReplyToStatusID: 100})
+	if err != nil || own.User.ScreenName != "bot" || own.InReplyToStatusID != 100 {
+		t.Fatalf("unexpected own tweet %+v / %v", own, err)
+	}
+	if loaded, _, err := client.Statuses.Show(own.ID, nil); err != nil || loaded.Text() != "SpaceX is live" {
+		t.Errorf("expected own tweet to be loadable, got %+v / %v", loaded, err)
+	}
+
+	_, err = client.Lists.MembersCreateAll(&twitter.ListsMembersCreateAllParams{ListID: 10, UserID: "81"})
+	if err != nil {
+		t.Errorf("adding list members: %s", err.Error())
+	}
+
+	actions := srv.Actions()
+	if len(actions) != 3 || actions[0].Action != "retweet" || actions[1].Text != "SpaceX is live" ||
+		len(actions[2].UserIDs) != 1 || actions[2].UserIDs[0] != 81 {
+		t.Errorf("unexpected actions %+v", actions)
+	}
+}
+
+func TestServer_Stream(t *testing.T) {
+	_, client, _ := testServer(t, testScenario)
+
+	stream, err := client.Streams.Filter(&twitter.StreamFilterParams{Locations: []string{"-97.3,25.8,-96.9,26.1"}})
+	if err != nil {
+		t.Fatalf("connecting to stream: %s", err.Error())
+	}
+	defer stream.Stop()
+
+	select {
+	case m := <-stream.Messages:
+		tweet, ok := m.(*twitter.Tweet)
+		if !ok || tweet.ID != 102 || tweet.Place == nil || tweet.Place.ID != "124bed061b8e4e2f" {
+			t.Errorf("unexpected stream message %#v", m)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no tweet received on stream")
+	}
+}
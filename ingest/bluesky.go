@@ -0,0 +1,185 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/xarantolus/spacex-hop-bot/match"
+	"github.com/xarantolus/spacex-hop-bot/publish"
+)
+
+// DefaultBlueskyService is the public Bluesky AppView, it doesn't require authentication
+const DefaultBlueskyService = "https://public.api.bsky.app"
+
+// BlueskyFeed polls the posts of an account or a custom feed on Bluesky
+type BlueskyFeed struct {
+	service string
+
+	// Exactly one of actor and feed is set. actor is a handle or DID, feed the at:// URI of a feed generator
+	actor string
+	feed  string
+}
+
+// NewBlueskyAuthor returns a source for the posts of an account
+func NewBlueskyAuthor(service, actor string) *BlueskyFeed {
+	return newBlueskyFeed(service, strings.TrimPrefix(actor, "@"), "")
+}
+
+// NewBlueskyFeed returns a source for a custom feed, e.g. at://did:plc:.../app.bsky.feed.generator/starship
+func NewBlueskyFeed(service, feed string) *BlueskyFeed {
+	return newBlueskyFeed(service, "", feed)
+}
+
+func newBlueskyFeed(service, actor, feed string) *BlueskyFeed {
+	if service == "" {
+		service = DefaultBlueskyService
+	}
+
+	return &BlueskyFeed{
+		service: strings.TrimSuffix(service, "/"),
+		actor:   actor,
+		feed:    feed,
+	}
+}
+
+func (b *BlueskyFeed) Name() string {
+	if b.actor != "" {
+		return "bluesky (@" + b.actor + ")"
+	}
+	return "bluesky (" + b.feed + ")"
+}
+
+type blueskyFeedResponse struct {
+	Feed []struct {
+		Post struct {
+			URI    string `json:"uri"`
+			CID    string `json:"cid"`
+			Author struct {
+				Handle string `json:"handle"`
+			} `json:"author"`
+			Record struct {
+				Text      string    `json:"text"`
+				CreatedAt time.Time `json:"createdAt"`
+				Langs     []string  `json:"langs"`
+				Reply     *struct {
+					Parent struct {
+						URI string `json:"uri"`
+					} `json:"parent"`
+				} `json:"reply"`
+				Facets []struct {
+					Features []struct {
+						Type string `json:"$type"`
+						URI  string `json:"uri"`
+					} `json:"features"`
+				} `json:"facets"`
+			} `json:"record"`
+			Embed *struct {
+				Images []struct {
+					Fullsize string `json:"fullsize"`
+				} `json:"images"`
+				Playlist string `json:"playlist"`
+				External *struct {
+					URI string `json:"uri"`
+				} `json:"external"`
+			} `json:"embed"`
+			Labels []struct {
+				Val string `json:"val"`
+			} `json:"labels"`
+		} `json:"post"`
+	} `json:"feed"`
+}
+
+func (b *BlueskyFeed) Poll(ctx context.Context) (items []Item, err error) {
+	var query = url.Values{}
+	query.Set("limit", "50")
+
+	var endpoint string
+	if b.actor != "" {
+		query.Set("actor", b.actor)
+		endpoint = b.service + "/xrpc/app.bsky.feed.getAuthorFeed?" + query.Encode()
+	} else {
+		query.Set("feed", b.feed)
+		endpoint = b.service + "/xrpc/app.bsky.feed.getFeed?" + query.Encode()
+	}
+
+	var resp blueskyFeedResponse
+	err = getJSON(ctx, endpoint, &resp)
+	if err != nil {
+		return
+	}
+
+	for _, f := range resp.Feed {
+		p := f.Post
+
+		// Links are shortened in the text, the full URL is only in the facets
+		text := p.Record.Text
+		for _, facet := range p.Record.Facets {
+			for _, feature := range facet.Features {
+				if feature.Type == "app.bsky.richtext.facet#link" && !strings.Contains(text, feature.URI) {
+					text += "\n" + feature.URI
+				}
+			}
+		}
+
+		var media []string
+		if p.Embed != nil {
+			for _, img := range p.Embed.Images {
+				media = append(media, img.Fullsize)
+			}
+			if p.Embed.Playlist != "" {
+				media = append(media, p.Embed.Playlist)
+			}
+			if p.Embed.External != nil && !strings.Contains(text, p.Embed.External.URI) {
+				text += "\n" + p.Embed.External.URI
+			}
+		}
+
+		item := Item{
+			Source:   match.TweetSourceBluesky,
+			Platform: publish.PlatformBluesky,
+			ID:       p.URI,
+			CID:      p.CID,
+			URL:      blueskyPostURL(p.URI, p.Author.Handle),
+			Author:   p.Author.Handle,
+			Text:     text,
+			Media:    media,
+			Created:  p.Record.CreatedAt,
+		}
+		if p.Record.Reply != nil {
+			item.InReplyTo = p.Record.Reply.Parent.URI
+		}
+		if len(p.Record.Langs) > 0 {
+			item.Lang = p.Record.Langs[0]
+		}
+		for _, l := range p.Labels {
+			if sensitiveBlueskyLabels[l.Val] {
+				item.Sensitive = true
+			}
+		}
+
+		items = append(items, item)
+	}
+
+	return
+}
+
+// sensitiveBlueskyLabels are the self-labels and moderation labels that mark adult or graphic content
+var sensitiveBlueskyLabels = map[string]bool{
+	"porn":          true,
+	"sexual":        true,
+	"nudity":        true,
+	"graphic-media": true,
+	"gore":          true,
+}
+
+// blueskyPostURL returns the web URL for the post with the given at:// URI
+func blueskyPostURL(uri, handle string) string {
+	idx := strings.LastIndex(uri, "/")
+	if idx < 0 {
+		return uri
+	}
+	return fmt.Sprintf("https://bsky.app/profile/%s/post/%s", handle, uri[idx+1:])
+}
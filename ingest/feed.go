@@ -0,0 +1,153 @@
+package ingest
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/xarantolus/spacex-hop-bot/match"
+)
+
+// Feed polls an RSS or Atom feed, e.g. the articles of a news site
+type Feed struct {
+	url string
+}
+
+// NewFeed returns a source for the RSS or Atom feed at url
+func NewFeed(url string) *Feed {
+	return &Feed{url: url}
+}
+
+func (f *Feed) Name() string {
+	return "feed (" + f.url + ")"
+}
+
+// feedDocument can hold both RSS 2.0 and Atom feeds
+type feedDocument struct {
+	XMLName xml.Name
+
+	// RSS
+	Channel struct {
+		Items []struct {
+			Title       string `xml:"title"`
+			Link        string `xml:"link"`
+			GUID        string `xml:"guid"`
+			Description string `xml:"description"`
+			PubDate     string `xml:"pubDate"`
+		} `xml:"item"`
+	} `xml:"channel"`
+
+	// Atom
+	Entries []struct {
+		Title string `xml:"title"`
+		ID    string `xml:"id"`
+		Links []struct {
+			Href string `xml:"href,attr"`
+			Rel  string `xml:"rel,attr"`
+		} `xml:"link"`
+		Summary   string `xml:"summary"`
+		Content   string `xml:"content"`
+		Published string `xml:"published"`
+		Updated   string `xml:"updated"`
+	} `xml:"entry"`
+}
+
+func (f *Feed) Poll(ctx context.Context) (items []Item, err error) {
+	resp, err := get(ctx, f.url, "application/rss+xml, application/atom+xml, application/xml;q=0.9, text/xml;q=0.8")
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	var doc feedDocument
+	err = xml.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&doc)
+	if err != nil {
+		return nil, fmt.Errorf("parsing feed: %w", err)
+	}
+
+	switch doc.XMLName.Local {
+	case "rss":
+		for _, it := range doc.Channel.Items {
+			id := it.GUID
+			if id == "" {
+				id = it.Link
+			}
+			items = append(items, feedItem(id, it.Link, it.Title, it.Description, it.PubDate))
+		}
+	case "feed":
+		for _, e := range doc.Entries {
+			var link string
+			for _, l := range e.Links {
+				if l.Rel == "" || l.Rel == "alternate" {
+					link = l.Href
+					break
+				}
+			}
+
+			summary := e.Summary
+			if summary == "" {
+				summary = e.Content
+			}
+
+			date := e.Published
+			if date == "" {
+				date = e.Updated
+			}
+
+			id := e.ID
+			if id == "" {
+				id = link
+			}
+			items = append(items, feedItem(id, link, e.Title, summary, date))
+		}
+	default:
+		return nil, fmt.Errorf("unsupported feed format %q", doc.XMLName.Local)
+	}
+
+	return
+}
+
+// maxFeedSummaryLength limits how much of an article summary is matched, some feeds contain the whole article
+const maxFeedSummaryLength = 500
+
+func feedItem(id, link, title, summary, date string) Item {
+	text := strings.TrimSpace(htmlText(title))
+	if s := []rune(htmlText(summary)); len(s) > 0 {
+		if len(s) > maxFeedSummaryLength {
+			s = append(s[:maxFeedSummaryLength], '…')
+		}
+		text += "\n\n" + string(s)
+	}
+
+	return Item{
+		Source:   match.TweetSourceFeed,
+		Platform: PlatformFeed,
+		ID:       id,
+		URL:      strings.TrimSpace(link),
+		Text:     text,
+		Created:  parseFeedDate(date),
+	}
+}
+
+var feedDateFormats = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC3339,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2006-01-02T15:04:05Z0700",
+}
+
+// parseFeedDate parses the date of a feed item. If it can't be parsed, the zero time is returned
+func parseFeedDate(s string) time.Time {
+	s = strings.TrimSpace(s)
+	for _, format := range feedDateFormats {
+		if t, err := time.Parse(format, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,27 @@
+package ingest
+
+import (
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// htmlText returns the text of an HTML snippet, e.g. the content of a Mastodon status.
+// Line breaks and paragraphs are kept as newlines
+func htmlText(html string) string {
+	if !strings.Contains(html, "<") {
+		return strings.TrimSpace(html)
+	}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		return strings.TrimSpace(html)
+	}
+
+	doc.Find("br").ReplaceWithHtml("\n")
+	doc.Find("p").Each(func(_ int, s *goquery.Selection) {
+		s.AppendHtml("\n\n")
+	})
+
+	return strings.TrimSpace(doc.Text())
+}
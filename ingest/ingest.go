@@ -0,0 +1,145 @@
+// Package ingest loads posts from platforms other than Twitter, e.g. Mastodon, Bluesky and RSS/Atom feeds
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/xarantolus/spacex-hop-bot/match"
+	"github.com/xarantolus/spacex-hop-bot/publish"
+	"mvdan.cc/xurls/v2"
+)
+
+// Item is a post loaded from another platform
+type Item struct {
+	Source match.TweetSource
+
+	// Platform is one of the publish.Platform* constants, or "feed"
+	Platform string
+	// ID identifies the post on its platform. For Mastodon it is only valid on Server
+	ID     string
+	CID    string
+	Server string
+
+	URL string
+
+	// Author is the full handle of the author, e.g. "user@mastodon.social". It is empty for feeds
+	Author string
+	Text   string
+	// Media contains the URLs of attached images and videos
+	Media []string
+
+	// InReplyTo is the ID of the post this item replies to, if any
+	InReplyTo string
+	// Lang is the language of the text, e.g. "en". It is empty if unknown
+	Lang      string
+	Sensitive bool
+
+	Created time.Time
+}
+
+// PlatformFeed is the platform of items from RSS and Atom feeds
+const PlatformFeed = "feed"
+
+// Source is a timeline, feed or similar that can be polled for new items
+type Source interface {
+	// Name identifies the source in logs
+	Name() string
+
+	// Poll returns the newest items of this source. Sources may return items they already returned before
+	Poll(ctx context.Context) ([]Item, error)
+}
+
+// Key identifies the item across all sources
+func (i *Item) Key() string {
+	return i.Platform + ":" + i.Server + ":" + i.ID
+}
+
+var urlRegex = xurls.Strict()
+
+// MatchPost converts the item to a post the matcher can check
+func (i *Item) MatchPost() *match.Post {
+	var media []match.Media
+	for _, m := range i.Media {
+		media = append(media, match.Media{URL: m})
+	}
+
+	var inReplyTo *match.Reference
+	if i.InReplyTo != "" {
+		inReplyTo = &match.Reference{ID: i.InReplyTo}
+	}
+
+	// Links are kept in TextWithURLs and removed from Text, like t.co links in tweets
+	return &match.Post{
+		Platform:     i.Platform,
+		ID:           i.ID,
+		URL:          i.URL,
+		Source:       i.Source,
+		Author:       match.Author{Handle: i.Author},
+		Text:         strings.TrimSpace(urlRegex.ReplaceAllString(i.Text, "")),
+		TextWithURLs: i.Text,
+		URLs:         urlRegex.FindAllString(i.Text, -1),
+		Media:        media,
+		InReplyTo:    inReplyTo,
+		Lang:         i.Lang,
+		Sensitive:    i.Sensitive,
+		Created:      i.Created,
+	}
+}
+
+// Post returns the post that republishes this item
+func (i *Item) Post() publish.Post {
+	return publish.Post{
+		Kind:     publish.KindRepost,
+		Text:     i.Text,
+		URL:      i.URL,
+		Author:   i.Author,
+		Platform: i.Platform,
+		ID:       i.ID,
+		CID:      i.CID,
+		Server:   i.Server,
+		Time:     i.Created,
+	}
+}
+
+var httpClient = &http.Client{
+	Timeout: 30 * time.Second,
+}
+
+// getJSON loads url and decodes the JSON response into out
+func getJSON(ctx context.Context, url string, out interface{}) (err error) {
+	resp, err := get(ctx, url, "application/json")
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	return json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(out)
+}
+
+const maxResponseSize = 5 << 20
+
+func get(ctx context.Context, url, accept string) (resp *http.Response, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set("Accept", accept)
+
+	resp, err = httpClient.Do(req)
+	if err != nil {
+		return
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status %s while loading %s", resp.Status, url)
+	}
+
+	return
+}
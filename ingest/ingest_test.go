@@ -0,0 +1,209 @@
+package ingest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/xarantolus/spacex-hop-bot/match"
+	"github.com/xarantolus/spacex-hop-bot/publish"
+)
+
+func TestHTMLText(t *testing.T) {
+	var tests = []struct {
+		html string
+		want string
+	}{
+		{"plain text", "plain text"},
+		{"<p>Starship <a href=\"https://example.com/tags/starship\">#<span>Starship</span></a> rolls out</p>", "Starship #Starship rolls out"},
+		{"<p>first<br>second</p><p>third</p>", "first\nsecond\n\nthird"},
+		{"<p>Link: <a href=\"https://spacex.com\"><span class=\"invisible\">https://</span><span>spacex.com</span></a></p>", "Link: https://spacex.com"},
+	}
+
+	for _, tt := range tests {
+		if got := htmlText(tt.html); got != tt.want {
+			t.Errorf("htmlText(%q) = %q, want %q", tt.html, got, tt.want)
+		}
+	}
+}
+
+func TestMastodonTimeline(t *testing.T) {
+	var sinceIDs []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/accounts/lookup":
+			if r.URL.Query().Get("acct") != "starbase" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write([]byte(`{"id": "42"}`))
+		case "/api/v1/accounts/42/statuses":
+			sinceIDs = append(sinceIDs, r.URL.Query().Get("since_id"))
+			_, _ = w.Write([]byte(`[
+				{"id": "110", "url": "https://social.example/@starbase/110", "created_at": "2023-04-20T13:33:00.000Z",
+				 "content": "<p>Starship is stacked</p>", "account": {"acct": "starbase"}, "language": "en",
+				 "media_attachments": [{"url": "https://social.example/media/1.jpg"}]},
+				{"id": "99", "url": "https://social.example/@starbase/99", "created_at": "2023-04-20T12:00:00.000Z",
+				 "content": "<p>boosted</p>", "account": {"acct": "starbase"},
+				 "reblog": {"id": "98", "url": "https://other.example/@someone/1", "created_at": "2023-04-20T11:00:00.000Z",
+				            "content": "<p>Booster 7</p>", "account": {"acct": "someone@other.example"},
+				            "in_reply_to_id": "97", "language": "de", "sensitive": true}}
+			]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	m := NewMastodonAccount(srv.URL, "@starbase")
+
+	items, err := m.Poll(context.Background())
+	if err != nil {
+		t.Fatalf("polling: %s", err.Error())
+	}
+	if len(items) != 2 {
+		t.Fatalf("expected 2 items, got %d", len(items))
+	}
+
+	first := items[0]
+	host := strings.TrimPrefix(srv.URL, "http://")
+	if first.ID != "110" || first.Server != host || first.Author != "starbase@"+host ||
+		first.Text != "Starship is stacked" || len(first.Media) != 1 || first.Source != match.TweetSourceMastodon ||
+		first.Lang != "en" || first.InReplyTo != "" || first.Sensitive {
+		t.Errorf("unexpected item %+v", first)
+	}
+	if items[1].ID != "98" || items[1].Author != "someone@other.example" || items[1].Text != "Booster 7" {
+		t.Errorf("expected boost to be shared as original status, got %+v", items[1])
+	}
+	if reply := items[1].MatchPost(); reply.InReplyTo == nil || reply.InReplyTo.ID != "97" || reply.Lang != "de" || !reply.Sensitive {
+		t.Errorf("expected reply, language and sensitive flag of the original status, got %+v", reply)
+	}
+
+	_, err = m.Poll(context.Background())
+	if err != nil {
+		t.Fatalf("polling again: %s", err.Error())
+	}
+	if len(sinceIDs) != 2 || sinceIDs[0] != "" || sinceIDs[1] != "110" {
+		t.Errorf("expected second poll to only request newer statuses, got since IDs %v", sinceIDs)
+	}
+
+	post := first.Post()
+	if post.Platform != publish.PlatformMastodon || post.ID != "110" || post.Server != host {
+		t.Errorf("unexpected post %+v", post)
+	}
+}
+
+func TestBlueskyFeed(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/xrpc/app.bsky.feed.getAuthorFeed" || r.URL.Query().Get("actor") != "starbase.bsky.social" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{"feed": [{"post": {
+			"uri": "at://did:plc:abc/app.bsky.feed.post/3k2",
+			"cid": "bafy",
+			"author": {"handle": "starbase.bsky.social"},
+			"record": {"text": "Ship 25 static fire, details: spacex.com/vehi...", "createdAt": "2023-04-20T13:33:00Z",
+			           "facets": [{"features": [{"$type": "app.bsky.richtext.facet#link", "uri": "https://www.spacex.com/vehicles/starship/"}]}]},
+			"embed": {"images": [{"fullsize": "https://cdn.bsky.app/img/1.jpg"}]}
+		}}, {"post": {
+			"uri": "at://did:plc:abc/app.bsky.feed.post/3k3",
+			"cid": "bafz",
+			"author": {"handle": "starbase.bsky.social"},
+			"record": {"text": "Ship 25 static fire", "createdAt": "2023-04-20T13:34:00Z", "langs": ["de", "en"],
+			           "reply": {"parent": {"uri": "at://did:plc:abc/app.bsky.feed.post/3k2"}}},
+			"labels": [{"val": "graphic-media"}]
+		}}]}`))
+	}))
+	defer srv.Close()
+
+	items, err := NewBlueskyAuthor(srv.URL, "starbase.bsky.social").Poll(context.Background())
+	if err != nil {
+		t.Fatalf("polling: %s", err.Error())
+	}
+	if len(items) != 2 {
+		t.Fatalf("expected 2 items, got %d", len(items))
+	}
+
+	item := items[0]
+	if item.URL != "https://bsky.app/profile/starbase.bsky.social/post/3k2" || item.CID != "bafy" ||
+		!strings.HasSuffix(item.Text, "\nhttps://www.spacex.com/vehicles/starship/") || len(item.Media) != 1 {
+		t.Errorf("unexpected item %+v", item)
+	}
+
+	// Links are kept out of the text that is matched, like links in tweets
+	post := item.MatchPost()
+	if len(post.URLs) != 1 || post.URLs[0] != "https://www.spacex.com/vehicles/starship/" ||
+		strings.Contains(post.Text, "https://www.spacex.com") || !post.HasMedia() || post.Source != match.TweetSourceBluesky {
+		t.Errorf("unexpected match post %+v", post)
+	}
+	if post.InReplyTo != nil || post.Sensitive || post.Lang != "" {
+		t.Errorf("expected post without reply, language or labels, got %+v", post)
+	}
+
+	reply := items[1]
+	if reply.InReplyTo != "at://did:plc:abc/app.bsky.feed.post/3k2" || reply.Lang != "de" || !reply.Sensitive {
+		t.Errorf("unexpected reply item %+v", reply)
+	}
+}
+
+func TestFeed(t *testing.T) {
+	var feeds = map[string]string{
+		"/rss": `<?xml version="1.0"?>
+<rss version="2.0"><channel><title>News</title>
+	<item>
+		<title>Starship stacked for flight test</title>
+		<link>https://news.example/starship</link>
+		<guid>https://news.example/?p=1</guid>
+		<description><![CDATA[<p>SpaceX stacked <b>Ship 24</b> on Booster 7.</p>]]></description>
+		<pubDate>Thu, 20 Apr 2023 13:33:00 +0000</pubDate>
+	</item>
+</channel></rss>`,
+		"/atom": `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom"><title>News</title>
+	<entry>
+		<title>Booster 9 rolls out</title>
+		<id>tag:news.example,2023:2</id>
+		<link rel="alternate" href="https://news.example/booster"/>
+		<summary>Rollout to the launch site</summary>
+		<published>2023-04-20T13:33:00Z</published>
+	</entry>
+</feed>`,
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		feed, ok := feeds[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		_, _ = w.Write([]byte(feed))
+	}))
+	defer srv.Close()
+
+	items, err := NewFeed(srv.URL + "/rss").Poll(context.Background())
+	if err != nil {
+		t.Fatalf("polling RSS: %s", err.Error())
+	}
+	if len(items) != 1 || items[0].ID != "https://news.example/?p=1" || items[0].URL != "https://news.example/starship" ||
+		items[0].Text != "Starship stacked for flight test\n\nSpaceX stacked Ship 24 on Booster 7." || items[0].Created.IsZero() {
+		t.Errorf("unexpected RSS items %+v", items)
+	}
+
+	items, err = NewFeed(srv.URL + "/atom").Poll(context.Background())
+	if err != nil {
+		t.Fatalf("polling Atom: %s", err.Error())
+	}
+	if len(items) != 1 || items[0].ID != "tag:news.example,2023:2" || items[0].URL != "https://news.example/booster" ||
+		items[0].Text != "Booster 9 rolls out\n\nRollout to the launch site" || items[0].Source != match.TweetSourceFeed {
+		t.Errorf("unexpected Atom items %+v", items)
+	}
+
+	_, err = NewFeed(srv.URL + "/missing").Poll(context.Background())
+	if err == nil {
+		t.Errorf("expected error for missing feed")
+	}
+}
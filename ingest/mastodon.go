@@ -0,0 +1,172 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/xarantolus/spacex-hop-bot/match"
+	"github.com/xarantolus/spacex-hop-bot/publish"
+)
+
+// MastodonTimeline polls a hashtag or account timeline of a Mastodon server. Only public timelines are supported,
+// so no access token is needed
+type MastodonTimeline struct {
+	server string
+	host   string
+
+	// Exactly one of hashtag and account is set
+	hashtag string
+	account string
+
+	// accountID is looked up on the first poll
+	accountID string
+
+	// sinceID is the ID of the newest status we have seen
+	sinceID string
+}
+
+// NewMastodonHashtag returns a source for public statuses with the given hashtag
+func NewMastodonHashtag(server, hashtag string) *MastodonTimeline {
+	return newMastodonTimeline(server, strings.TrimPrefix(hashtag, "#"), "")
+}
+
+// NewMastodonAccount returns a source for the statuses of an account, e.g. "user" or "user@other.server"
+func NewMastodonAccount(server, account string) *MastodonTimeline {
+	return newMastodonTimeline(server, "", strings.TrimPrefix(account, "@"))
+}
+
+func newMastodonTimeline(server, hashtag, account string) *MastodonTimeline {
+	server = strings.TrimSuffix(server, "/")
+
+	var host = server
+	if u, err := url.Parse(server); err == nil {
+		host = u.Host
+	}
+
+	return &MastodonTimeline{
+		server:  server,
+		host:    host,
+		hashtag: hashtag,
+		account: account,
+	}
+}
+
+func (m *MastodonTimeline) Name() string {
+	if m.hashtag != "" {
+		return fmt.Sprintf("mastodon (%s #%s)", m.host, m.hashtag)
+	}
+	return fmt.Sprintf("mastodon (%s @%s)", m.host, m.account)
+}
+
+type mastodonStatus struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	URI       string    `json:"uri"`
+	CreatedAt time.Time `json:"created_at"`
+	Content   string    `json:"content"`
+
+	InReplyToID string `json:"in_reply_to_id"`
+	Language    string `json:"language"`
+	Sensitive   bool   `json:"sensitive"`
+
+	Reblog *mastodonStatus `json:"reblog"`
+
+	Account struct {
+		Acct string `json:"acct"`
+	} `json:"account"`
+
+	MediaAttachments []struct {
+		URL string `json:"url"`
+	} `json:"media_attachments"`
+}
+
+func (m *MastodonTimeline) Poll(ctx context.Context) (items []Item, err error) {
+	var endpoint string
+	if m.hashtag != "" {
+		endpoint = m.server + "/api/v1/timelines/tag/" + url.PathEscape(m.hashtag)
+	} else {
+		if m.accountID == "" {
+			var account struct {
+				ID string `json:"id"`
+			}
+			err = getJSON(ctx, m.server+"/api/v1/accounts/lookup?acct="+url.QueryEscape(m.account), &account)
+			if err != nil {
+				return nil, fmt.Errorf("looking up account: %w", err)
+			}
+			m.accountID = account.ID
+		}
+		endpoint = m.server + "/api/v1/accounts/" + url.PathEscape(m.accountID) + "/statuses"
+	}
+
+	var query = url.Values{}
+	query.Set("limit", "40")
+	if m.sinceID != "" {
+		query.Set("since_id", m.sinceID)
+	}
+
+	var statuses []mastodonStatus
+	err = getJSON(ctx, endpoint+"?"+query.Encode(), &statuses)
+	if err != nil {
+		return
+	}
+
+	for _, s := range statuses {
+		if newerMastodonID(s.ID, m.sinceID) {
+			m.sinceID = s.ID
+		}
+
+		// Boosts are shared as the original status, which has an ID on this server
+		if s.Reblog != nil {
+			s = *s.Reblog
+		}
+
+		items = append(items, m.item(s))
+	}
+
+	return
+}
+
+func (m *MastodonTimeline) item(s mastodonStatus) Item {
+	author := s.Account.Acct
+	if !strings.Contains(author, "@") {
+		author += "@" + m.host
+	}
+
+	link := s.URL
+	if link == "" {
+		link = s.URI
+	}
+
+	var media []string
+	for _, a := range s.MediaAttachments {
+		media = append(media, a.URL)
+	}
+
+	return Item{
+		Source:   match.TweetSourceMastodon,
+		Platform: publish.PlatformMastodon,
+		ID:       s.ID,
+		Server:   m.host,
+		URL:      link,
+		Author:   author,
+		Text:     htmlText(s.Content),
+		Media:    media,
+
+		InReplyTo: s.InReplyToID,
+		Lang:      s.Language,
+		Sensitive: s.Sensitive,
+
+		Created: s.CreatedAt,
+	}
+}
+
+// newerMastodonID returns whether a is newer than b. Mastodon IDs are numbers that can be too large for int64
+func newerMastodonID(a, b string) bool {
+	if len(a) != len(b) {
+		return len(a) > len(b)
+	}
+	return a > b
+}
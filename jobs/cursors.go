@@ -0,0 +1,168 @@
+package jobs
+
+import (
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/xarantolus/spacex-hop-bot/util"
+)
+
+// Cursors stores the ID of the newest tweet every timeline job has seen, so jobs can continue where they stopped
+// after a restart instead of losing everything that was posted in between
+type Cursors struct {
+	mu       sync.Mutex
+	filename string
+	ids      map[string]string
+
+	// maxAge is the maximum age of missed tweets that are processed when catching up, zero means no limit.
+	// If it is negative, the first batch of tweets after a restart is skipped like it is without a cursor
+	maxAge time.Duration
+}
+
+// LoadCursors loads the cursors stored in filename. It is fine if the file doesn't exist yet
+func LoadCursors(filename string, maxAge time.Duration) (c *Cursors, err error) {
+	c = &Cursors{
+		filename: filename,
+		ids:      make(map[string]string),
+		maxAge:   maxAge,
+	}
+
+	err = util.LoadJSON(filename, &c.ids)
+	if errors.Is(err, os.ErrNotExist) {
+		err = nil
+	}
+	if c.ids == nil {
+		c.ids = make(map[string]string)
+	}
+
+	return c, err
+}
+
+// Get returns the ID of the newest tweet seen by the timeline with the given key
+func (c *Cursors) Get(key string) string {
+	if c == nil {
+		return ""
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.ids[key]
+}
+
+// Set stores id as the newest tweet seen by the timeline with the given key
+func (c *Cursors) Set(key, id string) error {
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ids[key] == id {
+		return nil
+	}
+	c.ids[key] = id
+
+	if c.filename == "" {
+		return nil
+	}
+	return util.SaveJSON(c.filename, c.ids)
+}
+
+// Cursor keys are "home", "user:<screen name>", "list:<id>" and "search:<query>". The v1.1 and v2 API use the same tweet IDs,
+// so switching between them keeps the cursors
+const homeTimelineKey = "home"
+
+// timelineCursor decides which tweets of a timeline job are processed
+type timelineCursor struct {
+	cursors *Cursors
+	key     string
+	name    string
+
+	sinceID string
+	// catchingUp is set if we continue from a stored cursor
+	catchingUp bool
+	first      bool
+}
+
+func newTimelineCursor(cursors *Cursors, key, name string) *timelineCursor {
+	c := &timelineCursor{
+		cursors: cursors,
+		key:     key,
+		name:    name,
+		sinceID: cursors.Get(key),
+		first:   true,
+	}
+	c.catchingUp = c.sinceID != "" && cursors.maxAge >= 0
+
+	return c
+}
+
+// SinceID returns the ID that should be requested as since_id, it is empty if nothing is known
+func (c *timelineCursor) SinceID() string {
+	return c.sinceID
+}
+
+// SinceIDInt is SinceID for the v1.1 API
+func (c *timelineCursor) SinceIDInt() int64 {
+	id, _ := strconv.ParseInt(c.sinceID, 10, 64)
+	return id
+}
+
+// Batch returns the tweets that should be processed, tweets must be sorted from oldest to newest. The cursor is only
+// moved past tweets that are skipped, callers must call Advance for every returned tweet once it was sent.
+// Without a stored cursor, the first batch is skipped, as we don't know which tweets we already saw.
+// When catching up after a restart, only tweets that are not older than the maximum age are returned
+func (c *timelineCursor) Batch(tweets []twitter.Tweet) (process []twitter.Tweet) {
+	if !c.first {
+		return tweets
+	}
+	c.first = false
+
+	if !c.catchingUp {
+		if len(tweets) > 0 {
+			c.Advance(tweets[len(tweets)-1])
+		}
+		return nil
+	}
+
+	var maxAge = c.cursors.maxAge
+	for _, t := range tweets {
+		created, err := t.CreatedAtTime()
+		if err != nil || maxAge > 0 && time.Since(created) > maxAge {
+			// Skipped tweets before the first one we process will never be processed, so we can move past them
+			if len(process) == 0 {
+				c.Advance(t)
+			}
+			continue
+		}
+		process = append(process, t)
+	}
+
+	if len(process) > 0 {
+		log.Printf("[Twitter] Catching up on %d missed tweets of %s\n", len(process), c.name)
+	}
+
+	return
+}
+
+// Advance moves the cursor past tweet and stores it. If the bot stops while a batch is sent, it continues
+// with the first tweet that wasn't sent
+func (c *timelineCursor) Advance(tweet twitter.Tweet) {
+	// Tweets sorted by their creation time can be out of order if they were posted in the same second
+	if tweet.ID <= c.SinceIDInt() {
+		return
+	}
+
+	c.sinceID = tweet.IDStr
+	if c.sinceID == "" {
+		c.sinceID = strconv.FormatInt(tweet.ID, 10)
+	}
+	util.LogError(c.cursors.Set(c.key, c.sinceID), "saving cursor of %s", c.name)
+}
@@ -0,0 +1,98 @@
+package jobs
+
+import (
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+func cursorTestTweet(id int64, age time.Duration) twitter.Tweet {
+	return twitter.Tweet{
+		ID:        id,
+		IDStr:     strconv.FormatInt(id, 10),
+		CreatedAt: time.Now().Add(-age).Format(time.RubyDate),
+	}
+}
+
+func tweetIDs(tweets []twitter.Tweet) (ids []int64) {
+	for _, t := range tweets {
+		ids = append(ids, t.ID)
+	}
+	return
+}
+
+func TestTimelineCursor(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "cursors.json")
+
+	cursors, err := LoadCursors(file, time.Hour)
+	if err != nil {
+		t.Fatalf("loading cursors without file: %s", err.Error())
+	}
+
+	// Without a stored cursor, the first batch is only used to know where to continue
+	cursor := newTimelineCursor(cursors, "list:10", "test list")
+	if got := cursor.Batch([]twitter.Tweet{cursorTestTweet(1, time.Minute), cursorTestTweet(2, time.Minute)}); len(got) != 0 {
+		t.Errorf("expected first batch to be skipped, got %v", tweetIDs(got))
+	}
+	got := cursor.Batch([]twitter.Tweet{cursorTestTweet(3, time.Minute)})
+	if len(got) != 1 || cursor.SinceIDInt() != 2 {
+		t.Errorf("expected new tweet without moving the cursor, got %v (since %s)", tweetIDs(got), cursor.SinceID())
+	}
+	cursor.Advance(got[0])
+	if cursor.SinceIDInt() != 3 {
+		t.Errorf("expected cursor to move past sent tweet, got %s", cursor.SinceID())
+	}
+
+	// After a restart, missed tweets are processed unless they are too old
+	cursors, err = LoadCursors(file, time.Hour)
+	if err != nil {
+		t.Fatalf("loading stored cursors: %s", err.Error())
+	}
+	cursor = newTimelineCursor(cursors, "list:10", "test list")
+	if cursor.SinceID() != "3" {
+		t.Fatalf("expected stored cursor 3, got %q", cursor.SinceID())
+	}
+
+	got = cursor.Batch([]twitter.Tweet{cursorTestTweet(4, 2*time.Hour), cursorTestTweet(5, 30*time.Minute), cursorTestTweet(6, time.Minute)})
+	if ids := tweetIDs(got); len(ids) != 2 || ids[0] != 5 || ids[1] != 6 {
+		t.Errorf("expected missed tweets 5 and 6, got %v", ids)
+	}
+	if cursor.SinceID() != "4" {
+		t.Errorf("expected cursor to move past skipped tweet 4, got %q", cursor.SinceID())
+	}
+	for _, tweet := range got {
+		cursor.Advance(tweet)
+	}
+	if got := cursor.Batch(nil); len(got) != 0 || cursor.SinceID() != "6" {
+		t.Errorf("expected cursor to stay at 6 without new tweets, got %q", cursor.SinceID())
+	}
+
+	// If the bot stops while a batch is sent, it continues with the first tweet that wasn't sent
+	got = cursor.Batch([]twitter.Tweet{cursorTestTweet(7, time.Minute), cursorTestTweet(8, time.Minute), cursorTestTweet(9, time.Minute)})
+	cursor.Advance(got[0])
+	cursors, _ = LoadCursors(file, time.Hour)
+	if c := newTimelineCursor(cursors, "list:10", "test list"); c.SinceID() != "7" {
+		t.Errorf("expected stored cursor 7 after sending only the first tweet, got %q", c.SinceID())
+	}
+
+	// The cursor never moves back
+	cursor.Advance(cursorTestTweet(5, time.Minute))
+	if cursor.SinceID() != "7" {
+		t.Errorf("expected cursor to stay at 7, got %q", cursor.SinceID())
+	}
+
+	// Other timelines are not affected
+	if other := newTimelineCursor(cursors, "home", "home timeline"); other.SinceID() != "" {
+		t.Errorf("expected no cursor for home timeline, got %q", other.SinceID())
+	}
+
+	// Catching up can be disabled
+	cursors, _ = LoadCursors(file, -1)
+	cursor = newTimelineCursor(cursors, "list:10", "test list")
+	if got := cursor.Batch([]twitter.Tweet{cursorTestTweet(10, time.Minute)}); len(got) != 0 || cursor.SinceID() != "10" {
+		t.Errorf("expected first batch to be skipped when catching up is disabled, got %v", tweetIDs(got))
+	}
+}
@@ -0,0 +1,22 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/xarantolus/spacex-hop-bot/consumer"
+)
+
+// waitForRateLimit sleeps until the rate limit resets if err is a rate limit error.
+// Jobs still do their usual sleep afterwards
+func waitForRateLimit(ctx context.Context, err error, what string) {
+	if !errors.Is(err, consumer.ErrRateLimited) {
+		return
+	}
+
+	wait, _ := consumer.RetryAfter(err)
+	log.Printf("[Twitter] Rate limited while loading %s, waiting %s\n", what, wait.Round(time.Second))
+	sleep(ctx, wait)
+}
@@ -0,0 +1,122 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/xarantolus/spacex-hop-bot/config"
+	"github.com/xarantolus/spacex-hop-bot/consumer"
+	"github.com/xarantolus/spacex-hop-bot/ingest"
+	"github.com/xarantolus/spacex-hop-bot/publish"
+	"github.com/xarantolus/spacex-hop-bot/util"
+)
+
+// IngestPosts polls sources on other platforms and republishes posts about Starship to all publishing backends.
+// Posts must pass the same checks as tweets, e.g. replies, questions and non-English posts are not republished
+func IngestPosts(ctx context.Context, sources []ingest.Source, processor *consumer.Processor, fanout *publish.Fanout, interval time.Duration) error {
+	log.Printf("[Ingest] Watching %d sources on other platforms\n", len(sources))
+
+	var in = newIngester(processor, fanout)
+	for {
+		for _, src := range sources {
+			in.poll(ctx, src)
+		}
+		reportSuccess(ctx)
+
+		if !sleep(ctx, interval+time.Duration(rand.Intn(30))*time.Second) {
+			return nil
+		}
+	}
+}
+
+// IngestSources returns all configured sources on other platforms
+func IngestSources(c config.Config) (sources []ingest.Source) {
+	for _, m := range c.Ingest.Mastodon {
+		for _, h := range m.Hashtags {
+			sources = append(sources, ingest.NewMastodonHashtag(m.Server, h))
+		}
+		for _, a := range m.Accounts {
+			sources = append(sources, ingest.NewMastodonAccount(m.Server, a))
+		}
+	}
+	for _, a := range c.Ingest.Bluesky.Actors {
+		sources = append(sources, ingest.NewBlueskyAuthor(c.Ingest.Bluesky.Service, a))
+	}
+	for _, f := range c.Ingest.Bluesky.Feeds {
+		sources = append(sources, ingest.NewBlueskyFeed(c.Ingest.Bluesky.Service, f))
+	}
+	for _, f := range c.Ingest.Feeds {
+		sources = append(sources, ingest.NewFeed(f))
+	}
+	return
+}
+
+// seenItemsRetention is how long we remember items we have seen
+const seenItemsRetention = 7 * 24 * time.Hour
+
+type ingester struct {
+	processor *consumer.Processor
+	fanout    *publish.Fanout
+
+	// seen maps item keys to the time we first saw them
+	seen map[string]time.Time
+	// polled contains the names of sources that were polled successfully before
+	polled map[string]bool
+}
+
+func newIngester(processor *consumer.Processor, fanout *publish.Fanout) *ingester {
+	return &ingester{
+		processor: processor,
+		fanout:    fanout,
+		seen:      make(map[string]time.Time),
+		polled:    make(map[string]bool),
+	}
+}
+
+// poll loads new items from src and republishes the ones the processor would retweet
+func (in *ingester) poll(ctx context.Context, src ingest.Source) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	items, err := src.Poll(ctx)
+	cancel()
+	if util.LogError(err, "polling %s", src.Name()) {
+		return
+	}
+
+	// Like with Twitter timelines, the first batch of items is not acted upon
+	isFirstPoll := !in.polled[src.Name()]
+	in.polled[src.Name()] = true
+
+	// Process the oldest item first
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Created.Before(items[j].Created)
+	})
+
+	now := time.Now()
+	for _, item := range items {
+		key := item.Key()
+		if _, ok := in.seen[key]; ok {
+			continue
+		}
+		in.seen[key] = now
+
+		if isFirstPoll {
+			continue
+		}
+
+		if !in.processor.ShouldRepublish(item.MatchPost()) {
+			continue
+		}
+
+		log.Printf("[Ingest] Republishing %s from %s\n", item.URL, src.Name())
+		in.fanout.Publish(item.Post())
+	}
+
+	for key, t := range in.seen {
+		if now.Sub(t) > seenItemsRetention {
+			delete(in.seen, key)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package jobs
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/xarantolus/spacex-hop-bot/consumer"
+	"github.com/xarantolus/spacex-hop-bot/ingest"
+	"github.com/xarantolus/spacex-hop-bot/match"
+	"github.com/xarantolus/spacex-hop-bot/publish"
+)
+
+type testSource struct {
+	items []ingest.Item
+}
+
+func (s *testSource) Name() string { return "test" }
+
+func (s *testSource) Poll(ctx context.Context) ([]ingest.Item, error) {
+	return s.items, nil
+}
+
+type collectingPublisher struct {
+	mu    sync.Mutex
+	posts []publish.Post
+}
+
+func (c *collectingPublisher) Name() string { return "collect" }
+
+func (c *collectingPublisher) Publish(ctx context.Context, p publish.Post) error {
+	c.mu.Lock()
+	c.posts = append(c.posts, p)
+	c.mu.Unlock()
+	return nil
+}
+
+func TestIngester(t *testing.T) {
+	var (
+		pub    = &collectingPublisher{}
+		fanout = publish.NewFanout(publish.Options{}, pub)
+		proc   = consumer.NewProcessor(false, true, &TestTweetingClient{}, &twitter.User{ID: 1}, match.NewStarshipMatcherForTests())
+		in     = newIngester(proc, fanout)
+		now    = time.Now()
+	)
+
+	var item = func(id, text string) ingest.Item {
+		return ingest.Item{
+			Source:   match.TweetSourceMastodon,
+			Platform: publish.PlatformMastodon,
+			Server:   "social.example",
+			ID:       id,
+			URL:      "https://social.example/@someone/" + id,
+			Author:   "someone@social.example",
+			Text:     text,
+			Created:  now,
+		}
+	}
+
+	src := &testSource{items: []ingest.Item{item("1", "S20 standing on the pad")}}
+
+	// The first poll only marks items as seen
+	in.poll(context.Background(), src)
+
+	src.items = []ingest.Item{
+		item("1", "S20 standing on the pad"),
+		item("2", "Starship S24 is rolling out to the launch pad"),
+		item("3", "Nice weather today"),
+	}
+
+	// Posts that Tweet would not retweet are not republished either
+	reply := item("4", "Starship S24 is rolling out to the launch pad")
+	reply.InReplyTo = "1"
+	question := item("5", "Is Starship S24 rolling out to the launch pad today?")
+	german := item("6", "Starship S24 is rolling out to the launch pad")
+	german.Lang = "de"
+	sensitive := item("7", "Starship S24 is rolling out to the launch pad")
+	sensitive.Sensitive = true
+	ignoredLink := item("8", "Starship S24 is rolling out to the launch pad https://opensea.io/collection/s24")
+
+	src.items = append(src.items, reply, question, german, sensitive, ignoredLink)
+	in.poll(context.Background(), src)
+	// Items are only republished once
+	in.poll(context.Background(), src)
+
+	fanout.Close()
+
+	if len(pub.posts) != 1 {
+		t.Fatalf("expected 1 republished post, got %d: %+v", len(pub.posts), pub.posts)
+	}
+	if p := pub.posts[0]; p.ID != "2" || p.Kind != publish.KindRepost || p.Platform != publish.PlatformMastodon {
+		t.Errorf("unexpected post %+v", p)
+	}
+}
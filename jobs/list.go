@@ -1,41 +1,46 @@
 package jobs
 
 import (
+	"context"
+	"log"
 	"math/rand"
 	"sort"
+	"strconv"
 	"time"
 
 	"github.com/dghubble/go-twitter/twitter"
+	"github.com/xarantolus/spacex-hop-bot/consumer"
 	"github.com/xarantolus/spacex-hop-bot/match"
+	"github.com/xarantolus/spacex-hop-bot/ratelimit"
 	"github.com/xarantolus/spacex-hop-bot/util"
 )
 
-// CheckListTimeline requests the given lists about every minute or so. Any new tweets are put in tweetChan.
-func CheckListTimeline(client *twitter.Client, list twitter.List, tweetChan chan<- match.TweetWrapper) {
-	defer panic("list (" + list.Name + ") follower stopped processing even though it shouldn't")
+// CheckListTimeline requests the given lists about every minute or so. All lists share the rate limit of the
+// list endpoint, so with many lists it is requested less often. Any new tweets are put in tweetChan.
+func CheckListTimeline(ctx context.Context, client *twitter.Client, list twitter.List, cursors *Cursors, tweetChan chan<- match.TweetWrapper) error {
+	ratelimit.Default.Register(ratelimit.ListStatuses)
+	defer ratelimit.Default.Unregister(ratelimit.ListStatuses)
 
-	var (
-		// lastSeenID is the ID of the last tweet we saw
-		lastSeenID int64
+	var cursor = newTimelineCursor(cursors, "list:"+strconv.FormatInt(list.ID, 10), "list "+list.FullName)
 
-		// The first batch of tweets we receive should not acted upon
-		isFirstRequest = true
-	)
 	for {
 		// https://developer.twitter.com/en/docs/twitter-api/v1/accounts-and-users/create-manage-lists/api-reference/get-lists-statuses
-		tweets, _, err := client.Lists.Statuses(&twitter.ListsStatusesParams{
+		tweets, resp, err := client.Lists.Statuses(&twitter.ListsStatusesParams{
 			ListID: list.ID,
 
 			IncludeRetweets: twitter.Bool(true),
 			IncludeEntities: twitter.Bool(true),
-			SinceID:         lastSeenID, // everything since our last request
-			Count:           200,        // Maximum number of tweets we can get at once
+			SinceID:         cursor.SinceIDInt(), // everything since our last request
+			Count:           200,                 // Maximum number of tweets we can get at once
 		})
 
 		if err != nil {
+			err = consumer.ClassifyError(err, resp)
 			util.LogError(err, "list %q", list.FullName)
+			waitForRateLimit(ctx, err, "list "+list.FullName)
 			goto sleep
 		}
+		reportSuccess(ctx)
 
 		// Sort tweets so the first tweet we process is the oldest one
 		sort.Slice(tweets, func(i, j int) bool {
@@ -45,27 +50,43 @@ func CheckListTimeline(client *twitter.Client, list twitter.List, tweetChan chan
 			return dj.After(di)
 		})
 
-		for _, tweet := range tweets {
-			lastSeenID = tweet.ID
-
-			// We only look at tweets that appeared after the bot started
-			if isFirstRequest {
-				continue
-			}
-
+		for _, tweet := range cursor.Batch(tweets) {
 			// OK, process this tweet
-			tweetChan <- match.TweetWrapper{
+			if !sendTweet(ctx, tweetChan, match.TweetWrapper{
 				TweetSource: match.TweetSourceKnownList,
 				Tweet:       tweet,
+			}) {
+				return nil
 			}
-		}
-
-		if isFirstRequest {
-			isFirstRequest = false
+			cursor.Advance(tweet)
 		}
 
 	sleep:
 		// Add a random delay
-		time.Sleep(time.Minute + time.Duration(rand.Intn(45))*time.Second)
+		if !ratelimit.Default.Wait(ctx, ratelimit.ListStatuses, time.Minute, time.Duration(rand.Intn(45))*time.Second) {
+			return nil
+		}
+	}
+}
+
+// MaintainSpacePeopleList applies queued changes to the space people list every interval and prunes it once a day
+func MaintainSpacePeopleList(ctx context.Context, list *consumer.SpacePeopleList, interval time.Duration) error {
+	var lastPrune time.Time
+	for {
+		if time.Since(lastPrune) > 24*time.Hour {
+			if n := list.Prune(); n > 0 {
+				log.Printf("[List] Queued removal of %d space people list members\n", n)
+			}
+			lastPrune = time.Now()
+		}
+
+		if !util.LogError(list.Flush(), "maintaining space people list") {
+			reportSuccess(ctx)
+		}
+
+		if !sleep(ctx, interval) {
+			// Apply the last changes before shutting down
+			return list.Flush()
+		}
 	}
 }
@@ -0,0 +1,205 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/xarantolus/spacex-hop-bot/twitterv2"
+	"github.com/xarantolus/spacex-hop-bot/util"
+)
+
+// listRefreshInterval is how often the lists the bot owns or follows are loaded again
+const listRefreshInterval = 30 * time.Minute
+
+// WatchedList is a list whose timeline is polled for new tweets
+type WatchedList struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Owner string `json:"owner,omitempty"`
+
+	// Since is when we started polling the list
+	Since time.Time `json:"since"`
+}
+
+// discoveredList is a list together with the job that polls it
+type discoveredList struct {
+	WatchedList
+	job JobFunc
+}
+
+// ListWatcher polls the timeline of every list the bot owns or follows. Lists are discovered again periodically,
+// so lists that are followed later are picked up and pollers of lists that were unfollowed or are now skipped are stopped
+type ListWatcher struct {
+	supervisor *Supervisor
+
+	// load returns all lists that should be watched
+	load func() ([]discoveredList, error)
+
+	mu     sync.Mutex
+	active map[string]*activeList
+}
+
+type activeList struct {
+	WatchedList
+	stop func()
+}
+
+func newListWatcher(s *Supervisor, load func() ([]discoveredList, error)) *ListWatcher {
+	return &ListWatcher{
+		supervisor: s,
+		load:       load,
+		active:     make(map[string]*activeList),
+	}
+}
+
+// Run refreshes the watched lists every interval until ctx is cancelled
+func (w *ListWatcher) Run(ctx context.Context, interval time.Duration) error {
+	for {
+		if !sleep(ctx, interval) {
+			return nil
+		}
+
+		if !util.LogError(w.refresh(), "refreshing watched lists") {
+			reportSuccess(ctx)
+		}
+	}
+}
+
+// refresh starts pollers for new lists and stops those of lists that should no longer be watched.
+// If the lists cannot be loaded, nothing is changed
+func (w *ListWatcher) refresh() error {
+	lists, err := w.load()
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var (
+		current = make(map[string]bool, len(lists))
+		started []string
+		stopped []string
+	)
+	for _, l := range lists {
+		current[l.ID] = true
+		if _, ok := w.active[l.ID]; ok {
+			continue
+		}
+
+		l.Since = time.Now()
+		w.active[l.ID] = &activeList{
+			WatchedList: l.WatchedList,
+			stop:        w.supervisor.Go("list "+l.Name, l.job),
+		}
+		started = append(started, strconv.Quote(l.Name))
+	}
+
+	for id, l := range w.active {
+		if current[id] {
+			continue
+		}
+
+		l.stop()
+		delete(w.active, id)
+		stopped = append(stopped, strconv.Quote(l.Name))
+	}
+
+	if len(started) > 0 {
+		sort.Strings(started)
+		log.Printf("[Twitter] Started watching %d lists (%s)\n", len(started), strings.Join(started, ", "))
+	}
+	if len(stopped) > 0 {
+		sort.Strings(stopped)
+		log.Printf("[Twitter] Stopped watching %d lists (%s)\n", len(stopped), strings.Join(stopped, ", "))
+	}
+
+	return nil
+}
+
+// Lists returns all lists that are currently watched, sorted by name
+func (w *ListWatcher) Lists() (lists []WatchedList) {
+	if w == nil {
+		return []WatchedList{}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	lists = make([]WatchedList, 0, len(w.active))
+	for _, l := range w.active {
+		lists = append(lists, l.WatchedList)
+	}
+	sort.Slice(lists, func(i, j int) bool {
+		return lists[i].Name < lists[j].Name
+	})
+
+	return
+}
+
+// loadListsV1 returns all lists the user owns or is subscribed to, except skipped lists and lists of protected users,
+// whose tweets we cannot request
+func loadListsV1(client *twitter.Client, user *twitter.User, skipLists map[int64]bool) (lists []twitter.List, err error) {
+	var seen = make(map[int64]bool)
+	add := func(ls []twitter.List) {
+		for _, l := range ls {
+			if seen[l.ID] || skipLists[l.ID] || l.User != nil && l.User.Protected {
+				continue
+			}
+			seen[l.ID] = true
+			lists = append(lists, l)
+		}
+	}
+
+	// https://developer.twitter.com/en/docs/twitter-api/v1/accounts-and-users/create-manage-lists/api-reference/get-lists-ownerships
+	for cursor := int64(-1); cursor != 0; {
+		owned, _, err := client.Lists.Ownerships(&twitter.ListsOwnershipsParams{UserID: user.ID, Count: 1000, Cursor: cursor})
+		if err != nil {
+			return nil, err
+		}
+		add(owned.Lists)
+		cursor = owned.NextCursor
+	}
+
+	// https://developer.twitter.com/en/docs/twitter-api/v1/accounts-and-users/create-manage-lists/api-reference/get-lists-subscriptions
+	for cursor := int64(-1); cursor != 0; {
+		subscribed, _, err := client.Lists.Subscriptions(&twitter.ListsSubscriptionsParams{UserID: user.ID, Count: 1000, Cursor: cursor})
+		if err != nil {
+			return nil, err
+		}
+		add(subscribed.Lists)
+		cursor = subscribed.NextCursor
+	}
+
+	return
+}
+
+// loadListsV2 is loadListsV1 for the v2 API
+func loadListsV2(client *twitterv2.Client, user *twitter.User, skipLists map[int64]bool) (lists []twitterv2.List, err error) {
+	owned, err := client.OwnedLists(user.IDStr)
+	if err != nil {
+		return
+	}
+	followed, err := client.FollowedLists(user.IDStr)
+	if err != nil {
+		return
+	}
+
+	var seen = make(map[string]bool)
+	for _, l := range append(owned, followed...) {
+		id, _ := strconv.ParseInt(l.ID, 10, 64)
+		if seen[l.ID] || skipLists[id] {
+			continue
+		}
+		seen[l.ID] = true
+		lists = append(lists, l)
+	}
+
+	return
+}
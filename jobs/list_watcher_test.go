@@ -0,0 +1,104 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestListWatcher(t *testing.T) {
+	s := NewSupervisor(context.Background())
+
+	var (
+		mu      sync.Mutex
+		running = make(map[string]bool)
+
+		lists   []discoveredList
+		loadErr error
+	)
+	watcher := newListWatcher(s, func() ([]discoveredList, error) {
+		return lists, loadErr
+	})
+
+	pollerFor := func(name string) discoveredList {
+		return discoveredList{
+			WatchedList: WatchedList{ID: name, Name: name},
+			job: func(ctx context.Context) error {
+				mu.Lock()
+				running[name] = true
+				mu.Unlock()
+
+				<-ctx.Done()
+
+				mu.Lock()
+				delete(running, name)
+				mu.Unlock()
+				return nil
+			},
+		}
+	}
+	waitFor := func(names ...string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			mu.Lock()
+			var ok = len(running) == len(names)
+			for _, n := range names {
+				ok = ok && running[n]
+			}
+			mu.Unlock()
+			if ok {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("expected pollers %v to run, status: %+v", names, s.Status())
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	checkLists := func(names ...string) {
+		t.Helper()
+		lists := watcher.Lists()
+		if len(lists) != len(names) {
+			t.Fatalf("expected lists %v, got %+v", names, lists)
+		}
+		for i, l := range lists {
+			if l.Name != names[i] || l.Since.IsZero() {
+				t.Errorf("expected list %q, got %+v", names[i], l)
+			}
+		}
+		if status := s.Status(); len(status) != len(names) {
+			t.Errorf("expected %d supervised jobs, got %+v", len(names), status)
+		}
+	}
+
+	lists = []discoveredList{pollerFor("Starship"), pollerFor("Starbase")}
+	if err := watcher.refresh(); err != nil {
+		t.Fatalf("refreshing lists: %s", err.Error())
+	}
+	waitFor("Starship", "Starbase")
+	checkLists("Starbase", "Starship")
+
+	// A new list is picked up, a removed one is stopped
+	lists = []discoveredList{pollerFor("Starship"), pollerFor("Raptor")}
+	if err := watcher.refresh(); err != nil {
+		t.Fatalf("refreshing lists: %s", err.Error())
+	}
+	waitFor("Starship", "Raptor")
+	checkLists("Raptor", "Starship")
+
+	// If the lists cannot be loaded, the current pollers keep running
+	lists, loadErr = nil, errors.New("rate limit exceeded")
+	if err := watcher.refresh(); err == nil {
+		t.Fatalf("expected error when lists cannot be loaded")
+	}
+	waitFor("Starship", "Raptor")
+	checkLists("Raptor", "Starship")
+
+	if err := s.Shutdown(time.Second); err != nil {
+		t.Fatalf("shutting down: %s", err.Error())
+	}
+	waitFor()
+}
@@ -1,63 +1,217 @@
 package jobs
 
 import (
+	"context"
 	"fmt"
-	"log"
-	"strings"
+	"strconv"
+	"time"
 
 	"github.com/dghubble/go-twitter/twitter"
 	"github.com/xarantolus/spacex-hop-bot/consumer"
 	"github.com/xarantolus/spacex-hop-bot/match"
+	"github.com/xarantolus/spacex-hop-bot/ratelimit"
+	"github.com/xarantolus/spacex-hop-bot/twitterv2"
+	"github.com/xarantolus/spacex-hop-bot/util"
 )
 
 func Register(
-	client *twitter.Client, wrappedTwitterClient consumer.TwitterClient, selfUser *twitter.User,
-	matcher *match.StarshipMatcher, tweetChan chan match.TweetWrapper,
-	skipLists map[int64]bool) (err error) {
-	var linkChan = make(chan string, 2)
+	s *Supervisor, cursors *Cursors, client *twitter.Client, wrappedTwitterClient consumer.TwitterClient, selfUser *twitter.User,
+	matcher *match.StarshipMatcher, tweetChan chan match.TweetWrapper, sources TwitterSources,
+	skipLists func() map[int64]bool, retraction RetractionOptions) (watcher *ListWatcher, err error) {
+	var (
+		linkChan  = make(chan string, 2)
+		deletions = make(chan int64, 50)
+	)
+
+	// Start with the current limits, the bot might have been restarted in the middle of a rate limit window
+	util.LogError(ratelimit.Default.Sync(client), "loading rate limits")
 
 	// Run YouTube scraper in the background,
 	// it will tweet if it discovers that SpaceX is online with a Starship stream
-	go CheckYouTubeLive(wrappedTwitterClient, selfUser, matcher, linkChan)
+	s.Go("youtube", func(ctx context.Context) error {
+		return CheckYouTubeLive(ctx, wrappedTwitterClient, selfUser, matcher, linkChan)
+	})
 
 	// When the webpage mentions a new date/starship, we tweet about that
-	go StarshipWebsiteChanges(wrappedTwitterClient, linkChan)
+	s.Go("website", func(ctx context.Context) error {
+		return StarshipWebsiteChanges(ctx, wrappedTwitterClient, linkChan)
+	})
 
 	// Check out the home timeline of the bot user, it will contain all kinds of tweets from all kinds of people
-	go CheckHomeTimeline(client, tweetChan)
+	s.Go("home timeline", func(ctx context.Context) error {
+		return CheckHomeTimeline(ctx, client, cursors, tweetChan)
+	})
+
+	// Get tweets from the general area around boca chica and other configured areas
+	sources.logLocationBoxes()
+	s.Go("location stream", func(ctx context.Context) error {
+		return CheckLocationStream(ctx, client, sources.LocationBoxes, tweetChan, deletions)
+	})
 
-	// Get tweets from the general area around boca chica
-	go CheckLocationStream(client, tweetChan)
+	// Undo retweets of tweets that should no longer be retweeted
+	if retraction.Enabled {
+		s.Go("retraction", func(ctx context.Context) error {
+			return RetractRetweets(ctx, wrappedTwitterClient, matcher, deletions, retraction)
+		})
+	}
 
 	// Make we get all tweets from certain users, before this we sometimes missed stuff
-	go CheckUserTimeline(client, "elonmusk", tweetChan)
-	go CheckUserTimeline(client, "SpaceX", tweetChan)
-
-	// Start watching all lists the bot account follows
-	lists, _, err := client.Lists.List(&twitter.ListsListParams{})
-	if len(lists) == 100 {
-		// See https://developer.twitter.com/en/docs/twitter-api/v1/accounts-and-users/create-manage-lists/api-reference/get-lists-list
-		log.Println("[Warning] Lists API call returned 100 lists, which means that it is likely that some lists were not included. See API URL in comment above this line")
-	}
-	if err != nil {
-		return fmt.Errorf("initializing bot: couldn't retrieve lists: %s", err.Error())
-	}
-
-	// Those are also background jobs
-	var watchedLists int
-	var listNames []string
-	for _, l := range lists {
-		// We cannot request tweets from a list created by a protected user
-		if skipLists[l.ID] || l.User != nil && l.User.Protected {
-			continue
+	sources.logUsers()
+	for _, u := range sources.Users {
+		var user = u
+		s.Go("user "+user.ScreenName, func(ctx context.Context) error {
+			return CheckUserTimeline(ctx, client, user, cursors, tweetChan)
+		})
+	}
+
+	// Searches find tweets from accounts we don't follow, e.g. first reports of something happening
+	sources.logSearches()
+	for _, q := range sources.Searches {
+		var query = q
+		s.Go("search "+strconv.Quote(query.Query), func(ctx context.Context) error {
+			return CheckSearch(ctx, client, query, cursors, tweetChan)
+		})
+	}
+
+	// Watch all lists the bot account owns or follows, lists that are followed later are picked up automatically
+	watcher = newListWatcher(s, func() (discovered []discoveredList, err error) {
+		lists, err := loadListsV1(client, selfUser, skipLists())
+		if err != nil {
+			return
 		}
-		go CheckListTimeline(client, l, tweetChan)
 
-		listNames = append(listNames, fmt.Sprintf("%s's %q", l.User.ScreenName, l.Name))
-		watchedLists++
+		for _, l := range lists {
+			var list = l
+			discovered = append(discovered, discoveredList{
+				WatchedList: WatchedList{ID: l.IDStr, Name: l.Name, Owner: listOwner(l.User)},
+				job: func(ctx context.Context) error {
+					return CheckListTimeline(ctx, client, list, cursors, tweetChan)
+				},
+			})
+		}
+		return
+	})
+	if err = watcher.refresh(); err != nil {
+		return nil, fmt.Errorf("initializing bot: couldn't retrieve lists: %s", err.Error())
 	}
+	s.Go("list watcher", func(ctx context.Context) error {
+		return watcher.Run(ctx, listRefreshInterval)
+	})
 
-	log.Printf("[Twitter] Started watching %d lists (%s)\n", watchedLists, strings.Join(listNames, ", "))
+	return watcher, nil
+}
+
+// RegisterV2 starts the same jobs as Register, but uses the Twitter API v2. The location stream is replaced
+// by a filtered stream with the given rules, or rules for the location boxes of sources if there are none
+func RegisterV2(
+	s *Supervisor, cursors *Cursors, client *twitterv2.Client, wrappedTwitterClient consumer.TwitterClient, selfUser *twitter.User,
+	matcher *match.StarshipMatcher, tweetChan chan match.TweetWrapper, sources TwitterSources,
+	skipLists func() map[int64]bool, retraction RetractionOptions, streamRules []twitterv2.Rule) (watcher *ListWatcher, err error) {
+	var (
+		linkChan = make(chan string, 2)
+		// The filtered stream doesn't include deletions
+		deletions = make(chan int64)
+	)
+
+	s.Go("youtube", func(ctx context.Context) error {
+		return CheckYouTubeLive(ctx, wrappedTwitterClient, selfUser, matcher, linkChan)
+	})
+
+	s.Go("website", func(ctx context.Context) error {
+		return StarshipWebsiteChanges(ctx, wrappedTwitterClient, linkChan)
+	})
 
-	return nil
+	s.Go("home timeline", func(ctx context.Context) error {
+		return CheckTimelineV2(ctx, "home timeline", match.TweetSourceTimeline, ratelimit.HomeTimelineV2, func(sinceID string) ([]twitter.Tweet, error) {
+			return client.HomeTimeline(selfUser.IDStr, sinceID)
+		}, time.Minute, cursors, homeTimelineKey, tweetChan)
+	})
+
+	// Without configured rules, the filtered stream covers the same areas as the location stream
+	if len(streamRules) == 0 {
+		streamRules = sources.locationStreamRules()
+		sources.logLocationBoxes()
+	}
+	s.Go("filtered stream", func(ctx context.Context) error {
+		return CheckFilteredStream(ctx, client, streamRules, tweetChan)
+	})
+
+	if retraction.Enabled {
+		s.Go("retraction", func(ctx context.Context) error {
+			return RetractRetweets(ctx, wrappedTwitterClient, matcher, deletions, retraction)
+		})
+	}
+
+	sources.logUsers()
+	for _, u := range sources.Users {
+		user, err := client.UserByUsername(u.ScreenName)
+		if err != nil {
+			return nil, fmt.Errorf("initializing bot: couldn't look up user %q: %s", u.ScreenName, err.Error())
+		}
+
+		var (
+			source   = u.Source
+			interval = u.Interval
+			jobName  = u.ScreenName + "'s Twitter profile"
+			key      = "user:" + u.ScreenName
+		)
+		s.Go("user "+u.ScreenName, func(ctx context.Context) error {
+			return CheckTimelineV2(ctx, jobName, source, ratelimit.UserTimelineV2, func(sinceID string) ([]twitter.Tweet, error) {
+				return client.UserTimeline(user.IDStr, sinceID)
+			}, interval, cursors, key, tweetChan)
+		})
+	}
+
+	sources.logSearches()
+	for _, q := range sources.Searches {
+		var (
+			query    = q.Query
+			interval = q.Interval()
+			jobName  = "search " + strconv.Quote(q.Query)
+		)
+		s.Go(jobName, func(ctx context.Context) error {
+			return CheckTimelineV2(ctx, jobName, match.TweetSourceSearch, ratelimit.SearchRecentV2, func(sinceID string) ([]twitter.Tweet, error) {
+				return client.SearchRecent(query, sinceID)
+			}, interval, cursors, searchKey(query), tweetChan)
+		})
+	}
+
+	// Watch all lists the bot account owns or follows
+	watcher = newListWatcher(s, func() (discovered []discoveredList, err error) {
+		lists, err := loadListsV2(client, selfUser, skipLists())
+		if err != nil {
+			return
+		}
+
+		for _, l := range lists {
+			var (
+				listID  = l.ID
+				jobName = fmt.Sprintf("list %q", l.Name)
+			)
+			discovered = append(discovered, discoveredList{
+				WatchedList: WatchedList{ID: l.ID, Name: l.Name},
+				job: func(ctx context.Context) error {
+					return CheckTimelineV2(ctx, jobName, match.TweetSourceKnownList, ratelimit.ListStatusesV2, func(sinceID string) ([]twitter.Tweet, error) {
+						return client.ListTimeline(listID, sinceID)
+					}, time.Minute, cursors, "list:"+listID, tweetChan)
+				},
+			})
+		}
+		return
+	})
+	if err = watcher.refresh(); err != nil {
+		return nil, fmt.Errorf("initializing bot: couldn't retrieve lists: %s", err.Error())
+	}
+	s.Go("list watcher", func(ctx context.Context) error {
+		return watcher.Run(ctx, listRefreshInterval)
+	})
+
+	return watcher, nil
+}
+
+func listOwner(u *twitter.User) string {
+	if u == nil {
+		return ""
+	}
+	return u.ScreenName
 }
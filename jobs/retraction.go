@@ -0,0 +1,237 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/xarantolus/spacex-hop-bot/consumer"
+	"github.com/xarantolus/spacex-hop-bot/decisions"
+	"github.com/xarantolus/spacex-hop-bot/match"
+	"github.com/xarantolus/spacex-hop-bot/util"
+)
+
+// RetractionOptions configures how retweets of tweets that are no longer eligible are undone
+type RetractionOptions struct {
+	Enabled bool
+
+	// DryRun only logs and records retractions, but doesn't actually unretweet anything
+	DryRun bool
+	// MaxPerRun is the maximum number of retweets that are retracted in a single run. Zero means no limit
+	MaxPerRun int
+	// MaxAge is how far back retweets are checked again
+	MaxAge time.Duration
+
+	// DecisionLogDir is the directory of the decision log, recent retweets and retractions are read from it
+	DecisionLogDir string
+	// DecisionLog is where retractions are recorded
+	DecisionLog *decisions.Writer
+}
+
+// Reasons for retractions of deleted tweets. The retweet disappears together with the tweet, so they are only recorded
+const (
+	reasonDeleted             = "deleted"
+	reasonDeletedStreamNotice = "deleted (stream notice)"
+)
+
+// dryRunSuffix is added to the reason of retractions that were only recorded
+const dryRunSuffix = " (dry run)"
+
+// RetractRetweets periodically checks recent retweets again and unretweets those that are no longer eligible,
+// e.g. because the author is now ignored, the tweet was deleted or it was edited to something we don't want to retweet.
+// Deletion notices from streams can be sent on deletions. Quotes are not retracted, as they are our own tweets
+func RetractRetweets(ctx context.Context, client consumer.TwitterClient, matcher *match.StarshipMatcher, deletions <-chan int64, opts RetractionOptions) error {
+	if opts.MaxAge <= 0 {
+		opts.MaxAge = 48 * time.Hour
+	}
+
+	var dryRunText string
+	if opts.DryRun {
+		dryRunText = dryRunSuffix
+	}
+	log.Printf("[Retraction] Checking retweets of the last %s again%s", opts.MaxAge.String(), dryRunText)
+
+	var (
+		// recent contains all recent retweets we know about
+		recent = make(map[int64]*twitter.Tweet)
+		// retracted contains all tweets we already handled
+		retracted = make(map[int64]bool)
+	)
+
+	for {
+		tweets, handled, err := recentRetweets(opts.DecisionLogDir, opts.MaxAge)
+		if !util.LogError(err, "loading recent retweets") {
+			reportSuccess(ctx)
+
+			// Retractions from before a restart are in the decision log
+			for id := range handled {
+				retracted[id] = true
+			}
+
+			recent = make(map[int64]*twitter.Tweet, len(tweets))
+			for i := range tweets {
+				recent[tweets[i].ID] = &tweets[i]
+			}
+
+			var count int
+			for _, t := range tweets {
+				if opts.MaxPerRun > 0 && count >= opts.MaxPerRun {
+					log.Printf("[Retraction] Reached limit of %d retractions for this run", opts.MaxPerRun)
+					break
+				}
+				if retracted[t.ID] {
+					continue
+				}
+				if ctx.Err() != nil {
+					return nil
+				}
+
+				reason := retractionReason(client, matcher, &t)
+				if reason == "" {
+					continue
+				}
+
+				if retract(client, matcher, &t, reason, opts) {
+					retracted[t.ID] = true
+					count++
+				}
+			}
+		}
+
+		timeout := time.After(30*time.Minute + time.Duration(rand.Intn(600))*time.Second)
+	wait:
+		for {
+			select {
+			case id := <-deletions:
+				t, ok := recent[id]
+				if !ok || retracted[id] {
+					continue
+				}
+
+				if retract(client, matcher, t, reasonDeletedStreamNotice, opts) {
+					retracted[id] = true
+				}
+			case <-timeout:
+				break wait
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	}
+}
+
+// recentRetweets returns the tweets that were retweeted within maxAge and the IDs of tweets that were already retracted
+// outside of dry runs, according to the decision log
+func recentRetweets(decisionLogDir string, maxAge time.Duration) (tweets []twitter.Tweet, retracted map[int64]bool, err error) {
+	records, err := decisions.Since(decisionLogDir, maxAge)
+	if err != nil {
+		return
+	}
+
+	retracted = make(map[int64]bool)
+	for _, r := range records {
+		switch r.Action {
+		case decisions.ActionRetract:
+			// Tweets that were only retracted in a dry run should be retracted once dry runs are disabled
+			if !strings.HasSuffix(r.Reason, dryRunSuffix) {
+				retracted[r.TweetID] = true
+			}
+		case decisions.ActionRetweet:
+			// Quotes are our own tweets, so they can't be unretweeted
+			tweets = append(tweets, twitter.Tweet{
+				ID:       r.TweetID,
+				IDStr:    strconv.FormatInt(r.TweetID, 10),
+				FullText: r.Text,
+				User: &twitter.User{
+					ID:         r.AuthorID,
+					ScreenName: r.Author,
+				},
+			})
+		}
+	}
+
+	return
+}
+
+// retractionReason returns why the retweet of the given logged tweet should be undone.
+// If it should stay, the reason is empty
+func retractionReason(client consumer.TwitterClient, matcher *match.StarshipMatcher, logged *twitter.Tweet) (reason string) {
+	current, err := client.LoadStatus(logged.ID)
+	if err != nil {
+		if errors.Is(err, consumer.ErrNotFound) {
+			return reasonDeleted
+		}
+		// Any other error could just be temporary
+		return ""
+	}
+
+	// We already unretweeted it, e.g. via the API
+	if current == nil || !current.Retweeted {
+		return ""
+	}
+
+	if current.User != nil && matcher.IsOrMentionsIgnoredAccount(match.PostFromTweet(current)) {
+		return "author or mentioned account is ignored"
+	}
+
+	if current.Text() != logged.Text() {
+		if match.ContainsStarshipAntiKeyword(current.Text()) {
+			return "edited to contain anti keywords"
+		}
+
+		if matcher.StarshipText(logged.Text(), nil, false) && !matcher.StarshipText(current.Text(), nil, false) {
+			return "edited to no longer be about Starship"
+		}
+	}
+
+	return ""
+}
+
+// retract unretweets the given tweet and records why in the decision log. It returns whether the retraction was successful
+func retract(client consumer.TwitterClient, matcher *match.StarshipMatcher, tweet *twitter.Tweet, reason string, opts RetractionOptions) bool {
+	var deleted = reason == reasonDeleted || reason == reasonDeletedStreamNotice
+
+	if !opts.DryRun && !deleted {
+		err := client.UnRetweet(tweet.ID)
+		// If the tweet was deleted in the meantime, our retweet is gone too
+		if err != nil && !errors.Is(err, consumer.ErrNotFound) {
+			util.LogError(err, "unretweeting %s", util.TweetURL(tweet))
+			return false
+		}
+	}
+
+	switch {
+	case deleted:
+		log.Printf("[Retraction] Retweeted tweet %s was deleted (%s)", util.TweetURL(tweet), reason)
+	case opts.DryRun:
+		reason += dryRunSuffix
+		log.Printf("[Retraction] Would unretweet %s (%s)", util.TweetURL(tweet), reason)
+	default:
+		log.Printf("[Retraction] Unretweeted %s (%s)", util.TweetURL(tweet), reason)
+	}
+
+	if opts.DecisionLog == nil {
+		return true
+	}
+
+	var r = decisions.Record{
+		TweetID:      tweet.ID,
+		Text:         tweet.Text(),
+		Source:       "retraction",
+		Action:       decisions.ActionRetract,
+		Reason:       reason,
+		RulesVersion: matcher.RulesVersion(),
+	}
+	if tweet.User != nil {
+		r.AuthorID = tweet.User.ID
+		r.Author = tweet.User.ScreenName
+	}
+	util.LogError(opts.DecisionLog.Write(r), "recording retraction of %s", util.TweetURL(tweet))
+
+	return true
+}
@@ -0,0 +1,174 @@
+package jobs
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/xarantolus/spacex-hop-bot/consumer"
+	"github.com/xarantolus/spacex-hop-bot/decisions"
+	"github.com/xarantolus/spacex-hop-bot/match"
+)
+
+type retractionTestClient struct {
+	TestTweetingClient
+
+	tweets map[int64]*twitter.Tweet
+
+	unretweeted []int64
+}
+
+func notFoundError() error {
+	return &consumer.TwitterError{Kind: consumer.ErrNotFound, Code: 144, Err: errors.New("twitter: 144 No status found with that ID.")}
+}
+
+func (r *retractionTestClient) LoadStatus(tweetID int64) (*twitter.Tweet, error) {
+	t, ok := r.tweets[tweetID]
+	if !ok {
+		return nil, notFoundError()
+	}
+	return t, nil
+}
+
+func (r *retractionTestClient) UnRetweet(tweetID int64) error {
+	r.unretweeted = append(r.unretweeted, tweetID)
+	if _, ok := r.tweets[tweetID]; !ok {
+		return notFoundError()
+	}
+	return nil
+}
+
+func Test_retractionReason(t *testing.T) {
+	var (
+		user        = &twitter.User{ID: 80, ScreenName: "someone"}
+		ignoredUser = &twitter.User{ID: match.TestIgnoredUserID, ScreenName: "ignored"}
+	)
+
+	client := &retractionTestClient{
+		tweets: map[int64]*twitter.Tweet{
+			1: {ID: 1, User: user, FullText: "S20 standing on the pad", Retweeted: true},
+			2: {ID: 2, User: ignoredUser, FullText: "S20 standing on the pad", Retweeted: true},
+			3: {ID: 3, User: user, FullText: "Buy my Starship NFT now", Retweeted: true},
+			4: {ID: 4, User: user, FullText: "Nice weather today", Retweeted: true},
+			5: {ID: 5, User: user, FullText: "S20 standing on the pad", Retweeted: false},
+		},
+	}
+
+	tests := []struct {
+		logged     twitter.Tweet
+		wantReason string
+	}{
+		{twitter.Tweet{ID: 1, User: user, FullText: "S20 standing on the pad"}, ""},
+		{twitter.Tweet{ID: 2, User: ignoredUser, FullText: "S20 standing on the pad"}, "author or mentioned account is ignored"},
+		{twitter.Tweet{ID: 3, User: user, FullText: "S20 standing on the pad"}, "edited to contain anti keywords"},
+		{twitter.Tweet{ID: 4, User: user, FullText: "S20 standing on the pad"}, "edited to no longer be about Starship"},
+		{twitter.Tweet{ID: 5, User: user, FullText: "S20 standing on the pad"}, ""},
+		{twitter.Tweet{ID: 6, User: user, FullText: "S20 standing on the pad"}, "deleted"},
+	}
+
+	matcher := match.NewStarshipMatcherForTests()
+	for _, tt := range tests {
+		t.Run(t.Name(), func(t *testing.T) {
+			if got := retractionReason(client, matcher, &tt.logged); got != tt.wantReason {
+				t.Errorf("retractionReason(%d) = %q, want %q", tt.logged.ID, got, tt.wantReason)
+			}
+		})
+	}
+}
+
+func Test_retractDeleted(t *testing.T) {
+	dir := t.TempDir()
+	w, err := decisions.NewWriter(dir, decisions.Options{})
+	if err != nil {
+		t.Fatalf("NewWriter: %s", err.Error())
+	}
+	var (
+		opts    = RetractionOptions{DecisionLogDir: dir, DecisionLog: w}
+		matcher = match.NewStarshipMatcherForTests()
+	)
+
+	var (
+		user    = &twitter.User{ID: 80, ScreenName: "someone"}
+		deleted = twitter.Tweet{ID: 6, User: user, FullText: "S20 standing on the pad"}
+	)
+
+	// Both loading and unretweeting a deleted tweet fail with ErrNotFound
+	client := &retractionTestClient{
+		tweets: map[int64]*twitter.Tweet{},
+	}
+
+	reason := retractionReason(client, matcher, &deleted)
+	if reason != reasonDeleted {
+		t.Fatalf("expected reason %q, got %q", reasonDeleted, reason)
+	}
+	if !retract(client, matcher, &deleted, reason, opts) {
+		t.Errorf("retracting a deleted tweet should succeed")
+	}
+	if len(client.unretweeted) != 0 {
+		t.Errorf("deleted tweets should not be unretweeted, but UnRetweet was called for %v", client.unretweeted)
+	}
+
+	// The tweet might also be deleted between checking and unretweeting it
+	if !retract(client, matcher, &deleted, "edited to contain anti keywords", opts) {
+		t.Errorf("retracting a tweet that was deleted in the meantime should succeed")
+	}
+	if len(client.unretweeted) != 1 {
+		t.Errorf("expected one call to UnRetweet, got %v", client.unretweeted)
+	}
+
+	// Retractions are recorded in the decision log, so they are not made again after a restart
+	if err = w.Close(); err != nil {
+		t.Fatalf("Close: %s", err.Error())
+	}
+	_, retracted, err := recentRetweets(dir, time.Hour)
+	if err != nil {
+		t.Fatalf("recentRetweets: %s", err.Error())
+	}
+	if !retracted[deleted.ID] {
+		t.Errorf("expected retraction of %d to be loaded from the decision log, got %v", deleted.ID, retracted)
+	}
+}
+
+func Test_recentRetweets(t *testing.T) {
+	dir := t.TempDir()
+	w, err := decisions.NewWriter(dir, decisions.Options{})
+	if err != nil {
+		t.Fatalf("NewWriter: %s", err.Error())
+	}
+
+	var records = []decisions.Record{
+		{TweetID: 1, Author: "someone", Text: "S20 standing on the pad", Action: decisions.ActionRetweet},
+		{TweetID: 2, Author: "someone", Text: "S20 standing on the pad", Action: decisions.ActionQuote},
+		{TweetID: 3, Author: "someone", Text: "Nice weather today", Action: decisions.ActionIgnore},
+		{TweetID: 4, Author: "someone", Text: "S20 standing on the pad", Action: decisions.ActionRetweet},
+		{TweetID: 4, Author: "someone", Text: "S20 standing on the pad", Action: decisions.ActionRetract, Reason: "deleted"},
+		{TweetID: 5, Author: "someone", Text: "S20 standing on the pad", Action: decisions.ActionRetweet},
+		{TweetID: 5, Author: "someone", Text: "S20 standing on the pad", Action: decisions.ActionRetract, Reason: "edited to contain anti keywords" + dryRunSuffix},
+	}
+	for _, r := range records {
+		if err = w.Write(r); err != nil {
+			t.Fatalf("Write: %s", err.Error())
+		}
+	}
+	if err = w.Close(); err != nil {
+		t.Fatalf("Close: %s", err.Error())
+	}
+
+	tweets, retracted, err := recentRetweets(dir, time.Hour)
+	if err != nil {
+		t.Fatalf("recentRetweets: %s", err.Error())
+	}
+
+	// Quotes are our own tweets and can't be unretweeted
+	var ids []int64
+	for _, t := range tweets {
+		ids = append(ids, t.ID)
+	}
+	if len(ids) != 3 || ids[0] != 1 || ids[1] != 4 || ids[2] != 5 {
+		t.Errorf("expected retweets 1, 4 and 5, got %v", ids)
+	}
+	if len(retracted) != 1 || !retracted[4] {
+		t.Errorf("expected tweet 4 to be retracted, got %v", retracted)
+	}
+}
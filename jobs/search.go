@@ -0,0 +1,74 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/xarantolus/spacex-hop-bot/config"
+	"github.com/xarantolus/spacex-hop-bot/consumer"
+	"github.com/xarantolus/spacex-hop-bot/match"
+	"github.com/xarantolus/spacex-hop-bot/ratelimit"
+	"github.com/xarantolus/spacex-hop-bot/util"
+)
+
+// searchKey returns the cursor key of a search query
+func searchKey(query string) string {
+	return "search:" + query
+}
+
+// CheckSearch runs the given search about every query.Interval and puts all new results in tweetChan.
+// All searches share the rate limit of the search endpoint, so every query only uses its part of it
+func CheckSearch(ctx context.Context, client *twitter.Client, query config.SearchQuery, cursors *Cursors, tweetChan chan<- match.TweetWrapper) error {
+	var name = "search " + strconv.Quote(query.Query)
+	log.Printf("[Twitter] Start watching %s\n", name)
+
+	var cursor = newTimelineCursor(cursors, searchKey(query.Query), name)
+
+	ratelimit.Default.Register(ratelimit.SearchTweets)
+	defer ratelimit.Default.Unregister(ratelimit.SearchTweets)
+
+	for {
+		// https://developer.twitter.com/en/docs/twitter-api/v1/tweets/search/api-reference/get-search-tweets
+		result, resp, err := client.Search.Tweets(&twitter.SearchTweetParams{
+			Query:           query.Query,
+			ResultType:      "recent",
+			Count:           100,
+			SinceID:         cursor.SinceIDInt(),
+			TweetMode:       "extended",
+			IncludeEntities: twitter.Bool(true),
+		})
+		if err != nil {
+			err = consumer.ClassifyError(err, resp)
+			util.LogError(err, "%s", name)
+			waitForRateLimit(ctx, err, name)
+			goto sleep
+		}
+		reportSuccess(ctx)
+
+		// Sort tweets so the first tweet we process is the oldest one
+		sort.Slice(result.Statuses, func(i, j int) bool {
+			return result.Statuses[i].ID < result.Statuses[j].ID
+		})
+
+		for _, tweet := range cursor.Batch(result.Statuses) {
+			if !sendTweet(ctx, tweetChan, match.TweetWrapper{
+				TweetSource: match.TweetSourceSearch,
+				Tweet:       tweet,
+			}) {
+				return nil
+			}
+			cursor.Advance(tweet)
+		}
+
+	sleep:
+		// Add a random delay
+		if !ratelimit.Default.Wait(ctx, ratelimit.SearchTweets, query.Interval(), time.Duration(rand.Intn(60))*time.Second) {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package jobs
+
+import (
+	"context"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/xarantolus/spacex-hop-bot/bot"
+	"github.com/xarantolus/spacex-hop-bot/config"
+	"github.com/xarantolus/spacex-hop-bot/faketwitter"
+	"github.com/xarantolus/spacex-hop-bot/match"
+)
+
+func TestCheckSearch(t *testing.T) {
+	srv := httptest.NewServer(faketwitter.New(faketwitter.Scenario{
+		User:  faketwitter.ScenarioUser{ID: 1, ScreenName: "bot"},
+		Users: []faketwitter.ScenarioUser{{ID: 80, ScreenName: "photos"}},
+		Tweets: []faketwitter.ScenarioTweet{
+			{ID: 100, User: 80, Text: "Starship static fire at Starbase", Media: true},
+			{ID: 101, User: 80, Text: "Sunset at the beach"},
+			{ID: 102, User: 80, Text: "Another Starship static fire", Media: true},
+		},
+	}).Handler())
+	defer srv.Close()
+
+	var cfg config.Config
+	cfg.Twitter.APIURL = srv.URL
+	client, _, err := bot.Login(cfg)
+	if err != nil {
+		t.Fatalf("logging in: %s", err.Error())
+	}
+
+	// We already saw tweet 100 before a restart, so only the newer result should be processed
+	cursors, err := LoadCursors(filepath.Join(t.TempDir(), "cursors.json"), 0)
+	if err != nil {
+		t.Fatalf("loading cursors: %s", err.Error())
+	}
+	if err = cursors.Set(searchKey("starship static fire"), "100"); err != nil {
+		t.Fatalf("setting cursor: %s", err.Error())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		tweetChan = make(chan match.TweetWrapper)
+		done      = make(chan error, 1)
+	)
+	go func() {
+		done <- CheckSearch(ctx, client, config.SearchQuery{Query: "starship static fire"}, cursors, tweetChan)
+	}()
+
+	select {
+	case tw := <-tweetChan:
+		if tw.ID != 102 || tw.TweetSource != match.TweetSourceSearch {
+			t.Errorf("expected tweet 102 from search, got %d from %s", tw.ID, tw.TweetSource)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("search didn't return any tweet")
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("search job returned error: %s", err.Error())
+	}
+	if id := cursors.Get(searchKey("starship static fire")); id != "102" {
+		t.Errorf("expected cursor to be at 102, got %q", id)
+	}
+}
@@ -1,6 +1,7 @@
 package jobs
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -13,13 +14,19 @@ import (
 
 	"github.com/xarantolus/spacex-hop-bot/config"
 	"github.com/xarantolus/spacex-hop-bot/consumer"
+	"github.com/xarantolus/spacex-hop-bot/decisions"
 	"github.com/xarantolus/spacex-hop-bot/match"
+	"github.com/xarantolus/spacex-hop-bot/ratelimit"
 )
 
 type httpServer struct {
-	twitter   consumer.TwitterClient
-	processor *consumer.Processor
-	tweetChan chan<- match.TweetWrapper
+	supervisor  *Supervisor
+	lists       *ListWatcher
+	twitter     consumer.TwitterClient
+	processor   *consumer.Processor
+	decisions   *decisions.Index
+	spacePeople *consumer.SpacePeopleList
+	tweetChan   chan<- match.TweetWrapper
 }
 
 func httpErrWrapper(f func(w http.ResponseWriter, r *http.Request) error) http.HandlerFunc {
@@ -110,26 +117,161 @@ func (h *httpServer) submitTweet(w http.ResponseWriter, r *http.Request) (err er
 
 func (s *httpServer) stats(w http.ResponseWriter, r *http.Request) (err error) {
 	w.Header().Set("Content-Type", "application/json")
-	return json.NewEncoder(w).Encode(s.processor.Stats())
+	stats := s.processor.Stats()
+	stats["rate_limits"] = ratelimit.Default.Stats()
+
+	return json.NewEncoder(w).Encode(stats)
+}
+
+func (s *httpServer) jobs(w http.ResponseWriter, r *http.Request) (err error) {
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(s.supervisor.Status())
+}
+
+// watchedLists returns the lists whose timelines are currently polled
+func (s *httpServer) watchedLists(w http.ResponseWriter, r *http.Request) (err error) {
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(s.lists.Lists())
+}
+
+func (s *httpServer) shadowDisagreements(w http.ResponseWriter, r *http.Request) (err error) {
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(s.processor.ShadowDisagreements())
+}
+
+// searchDecisions searches the decision archive. Supported query parameters are q (full text), author, action, source,
+// from and to (RFC 3339), offset and limit.
+// Decisions of the last decision_log.search_days days are kept in memory, the "since" field of the result says from when.
+// Searching older decisions reads the decision log, which is a lot slower
+func (s *httpServer) searchDecisions(w http.ResponseWriter, r *http.Request) (err error) {
+	if s.decisions == nil {
+		return fmt.Errorf("decision archive is not available")
+	}
+
+	query, err := parseDecisionQuery(r.URL.Query())
+	if err != nil {
+		return
+	}
+
+	result, err := s.decisions.Search(query)
+	if err != nil {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(result)
 }
 
-func RunWebServer(c config.Config, t consumer.TwitterClient, p *consumer.Processor, tweetChan chan<- match.TweetWrapper) {
-	defer panic("web server stopped running, but it should never do that")
+// spacePeopleList exports the space people list as CSV on GET and imports a CSV file in the same format on POST.
+// Imported users are added with the next batch
+func (s *httpServer) spacePeopleList(w http.ResponseWriter, r *http.Request) (err error) {
+	if s.spacePeople == nil {
+		return fmt.Errorf("space people list is not available")
+	}
 
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="space-people.csv"`)
+		return s.spacePeople.ExportCSV(w)
+	case http.MethodPost:
+		queued, err := s.spacePeople.ImportCSV(io.LimitReader(r.Body, 10<<20))
+		if err != nil {
+			return err
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		return json.NewEncoder(w).Encode(map[string]interface{}{
+			"queued": queued,
+		})
+	default:
+		return fmt.Errorf("method %s is not supported", r.Method)
+	}
+}
+
+func parseDecisionQuery(v url.Values) (q decisions.Query, err error) {
+	q = decisions.Query{
+		Text:   v.Get("q"),
+		Author: v.Get("author"),
+		Action: v.Get("action"),
+		Source: v.Get("source"),
+	}
+
+	if f := v.Get("from"); f != "" {
+		q.From, err = time.Parse(time.RFC3339, f)
+		if err != nil {
+			return q, fmt.Errorf("parsing from: %w", err)
+		}
+	}
+	if t := v.Get("to"); t != "" {
+		q.To, err = time.Parse(time.RFC3339, t)
+		if err != nil {
+			return q, fmt.Errorf("parsing to: %w", err)
+		}
+	}
+
+	if o := v.Get("offset"); o != "" {
+		q.Offset, err = strconv.Atoi(o)
+		if err != nil {
+			return q, fmt.Errorf("parsing offset: %w", err)
+		}
+	}
+	if l := v.Get("limit"); l != "" {
+		q.Limit, err = strconv.Atoi(l)
+		if err != nil {
+			return q, fmt.Errorf("parsing limit: %w", err)
+		}
+	}
+
+	return q, nil
+}
+
+func RunWebServer(ctx context.Context, c config.Config, s *Supervisor, lw *ListWatcher, t consumer.TwitterClient, p *consumer.Processor, d *decisions.Index, l *consumer.SpacePeopleList, tweetChan chan<- match.TweetWrapper) error {
 	server := &httpServer{
-		twitter:   t,
-		tweetChan: tweetChan,
-		processor: p,
+		supervisor:  s,
+		lists:       lw,
+		twitter:     t,
+		tweetChan:   tweetChan,
+		processor:   p,
+		decisions:   d,
+		spacePeople: l,
 	}
 
-	http.HandleFunc("/api/v1/tweet/submit", httpErrWrapper(server.submitTweet))
-	http.HandleFunc("/api/v1/stats", httpErrWrapper(server.stats))
+	// The supervisor might start this function again, so handlers can't be registered on the default mux
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/tweet/submit", httpErrWrapper(server.submitTweet))
+	mux.HandleFunc("/api/v1/stats", httpErrWrapper(server.stats))
+	mux.HandleFunc("/api/v1/jobs", httpErrWrapper(server.jobs))
+	mux.HandleFunc("/api/v1/lists", httpErrWrapper(server.watchedLists))
+	mux.HandleFunc("/api/v1/decisions", httpErrWrapper(server.searchDecisions))
+	mux.HandleFunc("/api/v1/shadow/disagreements", httpErrWrapper(server.shadowDisagreements))
+	mux.HandleFunc("/api/v1/lists/space-people", httpErrWrapper(server.spacePeopleList))
 
 	port := strconv.Itoa(int(c.Server.Port))
 	log.Printf("[HTTP] Server listening on port %s", port)
 
-	err := http.ListenAndServe(":"+port, nil)
-	if err != nil {
-		panic("running web server: " + err.Error())
+	srv := &http.Server{Addr: ":" + port, Handler: mux}
+
+	var (
+		shutdownErr = make(chan error, 1)
+		done        = make(chan struct{})
+	)
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-done:
+			return
+		}
+
+		sctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		shutdownErr <- srv.Shutdown(sctx)
+	}()
+
+	err := srv.ListenAndServe()
+	if err == http.ErrServerClosed {
+		return <-shutdownErr
 	}
+	return err
 }
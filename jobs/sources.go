@@ -0,0 +1,158 @@
+package jobs
+
+import (
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xarantolus/spacex-hop-bot/config"
+	"github.com/xarantolus/spacex-hop-bot/match"
+	"github.com/xarantolus/spacex-hop-bot/twitterv2"
+)
+
+// TrustedUser is an account whose timeline is polled
+type TrustedUser struct {
+	ScreenName string
+	Interval   time.Duration
+	Source     match.TweetSource
+}
+
+// TwitterSources are the accounts, areas and searches the Twitter jobs watch
+type TwitterSources struct {
+	Users         []TrustedUser
+	LocationBoxes []config.LocationBox
+	Searches      []config.SearchQuery
+}
+
+// ConfiguredTwitterSources returns the trusted users, location boxes and searches from the config
+func ConfiguredTwitterSources(c config.Config) (s TwitterSources) {
+	for _, u := range c.TrustedUsers() {
+		var source = match.TweetSourceTrustedUser
+		if u.Source == config.SourceTimeline {
+			source = match.TweetSourceTimeline
+		}
+
+		s.Users = append(s.Users, TrustedUser{
+			ScreenName: u.ScreenName,
+			Interval:   u.Interval(),
+			Source:     source,
+		})
+	}
+	s.LocationBoxes = c.LocationBoxes()
+	s.Searches = c.Twitter.Searches
+
+	return
+}
+
+// streamLocations returns boxes in the format of the location stream
+func streamLocations(boxes []config.LocationBox) (locations []string) {
+	for _, b := range boxes {
+		locations = append(locations, b.String())
+	}
+	return
+}
+
+const (
+	// maxStreamBoxMiles is the maximum width and height of a bounding_box in filtered stream rules. The API allows 25 miles,
+	// the difference makes sure rounding and our approximation of distances never produce a box that is too large
+	maxStreamBoxMiles = 24.0
+	// maxStreamRuleLength is the maximum length of a filtered stream rule
+	maxStreamRuleLength = 512
+
+	// Miles per degree of latitude, and per degree of longitude at the equator
+	milesPerDegreeLatitude  = 69.0
+	milesPerDegreeLongitude = 69.2
+)
+
+// locationStreamRules returns filtered stream rules for the same areas as the location stream. Unlike the location
+// stream, the filtered stream only accepts small bounding boxes, so larger areas are split into several boxes.
+// The boxes are combined into as few rules as possible
+func (s TwitterSources) locationStreamRules() (rules []twitterv2.Rule) {
+	var value string
+	for _, box := range s.LocationBoxes {
+		for _, b := range splitStreamBox(box) {
+			operator := "bounding_box:[" + strings.Join(b, " ") + "]"
+
+			if value != "" && len(value)+len(" OR ")+len(operator) > maxStreamRuleLength {
+				rules = append(rules, twitterv2.Rule{Value: value, Tag: "location"})
+				value = ""
+			}
+			if value != "" {
+				value += " OR "
+			}
+			value += operator
+		}
+	}
+	if value != "" {
+		rules = append(rules, twitterv2.Rule{Value: value, Tag: "location"})
+	}
+	return
+}
+
+// splitStreamBox splits box into a grid of boxes that are small enough for bounding_box operators. Every box
+// is returned as its west, south, east and north coordinates
+func splitStreamBox(box config.LocationBox) (boxes [][]string) {
+	// Degrees of longitude are widest at the edge closest to the equator
+	var latitude = math.Min(math.Abs(box.South), math.Abs(box.North))
+	if box.South < 0 && box.North > 0 {
+		latitude = 0
+	}
+
+	var (
+		width  = (box.East - box.West) * milesPerDegreeLongitude * math.Cos(latitude*math.Pi/180)
+		height = (box.North - box.South) * milesPerDegreeLatitude
+
+		columns = int(math.Ceil(width / maxStreamBoxMiles))
+		rows    = int(math.Ceil(height / maxStreamBoxMiles))
+
+		lonStep = (box.East - box.West) / float64(columns)
+		latStep = (box.North - box.South) / float64(rows)
+	)
+
+	var format = func(c float64) string {
+		return strconv.FormatFloat(c, 'f', 6, 64)
+	}
+
+	for row := 0; row < rows; row++ {
+		for col := 0; col < columns; col++ {
+			boxes = append(boxes, []string{
+				format(box.West + float64(col)*lonStep),
+				format(box.South + float64(row)*latStep),
+				format(box.West + float64(col+1)*lonStep),
+				format(box.South + float64(row+1)*latStep),
+			})
+		}
+	}
+
+	return
+}
+
+func (s TwitterSources) logUsers() {
+	var names []string
+	for _, u := range s.Users {
+		names = append(names, u.ScreenName+" every "+u.Interval.String())
+	}
+	log.Printf("[Twitter] Watching timelines of %d users (%s)\n", len(names), strings.Join(names, ", "))
+}
+
+func (s TwitterSources) logLocationBoxes() {
+	var names []string
+	for _, b := range s.LocationBoxes {
+		names = append(names, b.Name)
+	}
+	log.Printf("[Twitter] Streaming tweets from %d areas (%s)\n", len(names), strings.Join(names, ", "))
+}
+
+func (s TwitterSources) logSearches() {
+	if len(s.Searches) == 0 {
+		return
+	}
+
+	var queries []string
+	for _, q := range s.Searches {
+		queries = append(queries, strconv.Quote(q.Query)+" every "+q.Interval().String())
+	}
+	log.Printf("[Twitter] Running %d searches (%s)\n", len(queries), strings.Join(queries, ", "))
+}
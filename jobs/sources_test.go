@@ -0,0 +1,141 @@
+package jobs
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xarantolus/spacex-hop-bot/config"
+	"github.com/xarantolus/spacex-hop-bot/match"
+	"github.com/xarantolus/spacex-hop-bot/twitterv2"
+)
+
+func TestConfiguredTwitterSources(t *testing.T) {
+	var c config.Config
+	c.Twitter.Users = []config.TrustedUser{
+		{ScreenName: "SpaceX"},
+		{ScreenName: "NASASpaceflight", IntervalMinutes: 10, Source: config.SourceTimeline},
+	}
+	c.Twitter.LocationBoxes = []config.LocationBox{
+		{Name: "Starbase", West: -97.321014, South: 25.838213, East: -96.942673, North: 26.121535},
+	}
+
+	sources := ConfiguredTwitterSources(c)
+	if len(sources.Users) != 2 {
+		t.Fatalf("expected 2 users, got %+v", sources.Users)
+	}
+	if u := sources.Users[0]; u.Interval != 2*time.Minute || u.Source != match.TweetSourceTrustedUser {
+		t.Errorf("unexpected user %+v", u)
+	}
+	if u := sources.Users[1]; u.Interval != 10*time.Minute || u.Source != match.TweetSourceTimeline {
+		t.Errorf("unexpected user %+v", u)
+	}
+
+	rules := sources.locationStreamRules()
+	if len(rules) != 1 || rules[0].Value != "bounding_box:[-97.321014 25.838213 -96.942673 26.121535]" {
+		t.Errorf("unexpected stream rules %+v", rules)
+	}
+}
+
+var boundingBoxRegex = regexp.MustCompile(`bounding_box:\[([^\]]*)\]`)
+
+// streamRulesServer answers like the filtered stream rules endpoint, rules with bounding boxes that are
+// larger than 25 miles in any direction are rejected with the recorded response in testdata
+func streamRulesServer(t *testing.T) *twitterv2.Client {
+	t.Helper()
+
+	invalid, err := os.ReadFile(filepath.Join("testdata", "stream_rules_invalid.json"))
+	if err != nil {
+		t.Fatalf("reading fixture: %s", err.Error())
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodGet {
+			_, _ = w.Write([]byte(`{"meta": {"sent": "2023-04-20T13:33:00.000Z", "result_count": 0}}`))
+			return
+		}
+
+		var body struct {
+			Add []twitterv2.Rule `json:"add"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decoding request: %s", err.Error())
+		}
+
+		for _, rule := range body.Add {
+			for _, m := range boundingBoxRegex.FindAllStringSubmatch(rule.Value, -1) {
+				var c []float64
+				for _, f := range strings.Fields(m[1]) {
+					v, _ := strconv.ParseFloat(f, 64)
+					c = append(c, v)
+				}
+				west, south, east, north := c[0], c[1], c[2], c[3]
+
+				width := math.Max(distanceMiles(south, west, south, east), distanceMiles(north, west, north, east))
+				if width > 25 || distanceMiles(south, west, north, west) > 25 {
+					_, _ = w.Write(invalid)
+					return
+				}
+			}
+		}
+		_, _ = w.Write([]byte(`{"meta": {"sent": "2023-04-20T13:33:00.000Z", "summary": {"created": 1}}}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	c := twitterv2.NewClient(srv.Client(), "app-token", nil)
+	c.BaseURL = srv.URL
+	return c
+}
+
+// distanceMiles returns the great-circle distance between two points
+func distanceMiles(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadiusMiles = 3958.8
+	var rad = func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	a := math.Pow(math.Sin(rad(lat2-lat1)/2), 2) + math.Cos(rad(lat1))*math.Cos(rad(lat2))*math.Pow(math.Sin(rad(lon2-lon1)/2), 2)
+	return 2 * earthRadiusMiles * math.Asin(math.Sqrt(a))
+}
+
+func TestLocationStreamRules(t *testing.T) {
+	client := streamRulesServer(t)
+
+	// A single box for an area that is too large is rejected
+	err := client.SetRules([]twitterv2.Rule{{Value: "bounding_box:[-80.79552 28.2191 -79.96262 28.88617]"}})
+	if err == nil {
+		t.Fatalf("expected fixture to reject large bounding box")
+	}
+
+	// The default areas include large ones like Cape Canaveral, they must be split
+	var sources = ConfiguredTwitterSources(config.Config{})
+	rules := sources.locationStreamRules()
+	if len(rules) == 0 {
+		t.Fatalf("expected location stream rules")
+	}
+	for _, r := range rules {
+		if len(r.Value) > maxStreamRuleLength || strings.Contains(r.Value, "lang:") {
+			t.Errorf("invalid rule %q", r.Value)
+		}
+	}
+
+	if err = client.SetRules(rules); err != nil {
+		t.Errorf("setting rules: %s", err.Error())
+	}
+
+	// Cape Canaveral is about 50 miles wide and 46 miles high, so it needs a 2x2 grid
+	var boxes int
+	for _, r := range rules {
+		boxes += len(boundingBoxRegex.FindAllString(r.Value, -1))
+	}
+	if boxes < len(sources.LocationBoxes)+3 {
+		t.Errorf("expected Cape Canaveral to be split into at least 4 boxes, but got %d boxes for %d areas", boxes, len(sources.LocationBoxes))
+	}
+}
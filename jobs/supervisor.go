@@ -0,0 +1,231 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/xarantolus/spacex-hop-bot/match"
+)
+
+// JobFunc is a background job. It should run until ctx is cancelled and then return.
+// Returning before that, with or without error, or panicking makes the supervisor restart it
+type JobFunc func(ctx context.Context) error
+
+// Backoff between restarts of a job that keeps failing
+var (
+	minRestartBackoff = 5 * time.Second
+	maxRestartBackoff = 10 * time.Minute
+)
+
+// Supervisor runs jobs, restarts them when they fail and stops all of them on shutdown
+type Supervisor struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu   sync.Mutex
+	jobs []*jobState
+}
+
+type jobState struct {
+	mu sync.Mutex
+	JobStatus
+}
+
+// JobStatus is shown on the jobs API endpoint
+type JobStatus struct {
+	Name    string `json:"name"`
+	Running bool   `json:"running"`
+
+	// Restarts counts how often the job failed and was started again
+	Restarts int `json:"restarts"`
+
+	// LastRun is when the job was last (re)started
+	LastRun time.Time `json:"last_run"`
+	// LastSuccess is when the job last reported that it did its work, e.g. loaded a timeline
+	LastSuccess time.Time `json:"last_success,omitempty"`
+
+	LastError     string    `json:"last_error,omitempty"`
+	LastErrorTime time.Time `json:"last_error_time,omitempty"`
+}
+
+func NewSupervisor(ctx context.Context) *Supervisor {
+	ctx, cancel := context.WithCancel(ctx)
+	return &Supervisor{
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Go starts a job in the background. The returned function stops only this job, it is then no longer listed in Status
+func (s *Supervisor) Go(name string, job JobFunc) (stop func()) {
+	var state = &jobState{JobStatus: JobStatus{Name: name}}
+
+	s.mu.Lock()
+	s.jobs = append(s.jobs, state)
+	s.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(s.ctx)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.supervise(ctx, state, job)
+	}()
+
+	return func() {
+		cancel()
+		s.remove(state)
+	}
+}
+
+func (s *Supervisor) remove(state *jobState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, j := range s.jobs {
+		if j == state {
+			s.jobs = append(s.jobs[:i], s.jobs[i+1:]...)
+			return
+		}
+	}
+}
+
+func (s *Supervisor) supervise(jobCtx context.Context, state *jobState, job JobFunc) {
+	var (
+		backoff = minRestartBackoff
+		ctx     = context.WithValue(jobCtx, jobStateKey{}, state)
+	)
+	for {
+		state.mu.Lock()
+		state.Running = true
+		state.LastRun = time.Now()
+		state.mu.Unlock()
+
+		started := time.Now()
+		err := runJob(ctx, job)
+
+		state.mu.Lock()
+		state.Running = false
+		state.mu.Unlock()
+
+		if ctx.Err() != nil {
+			// We are shutting down or the job was stopped, so it is supposed to return
+			return
+		}
+
+		if err == nil {
+			err = fmt.Errorf("stopped even though it should run until shutdown")
+		}
+
+		// A job that ran for a while before failing doesn't need to wait long
+		if time.Since(started) > maxRestartBackoff {
+			backoff = minRestartBackoff
+		}
+
+		log.Printf("[Jobs] Job %q failed, restarting in %s: %s\n", state.Name, backoff, err.Error())
+
+		state.mu.Lock()
+		state.Restarts++
+		state.LastError = err.Error()
+		state.LastErrorTime = time.Now()
+		state.mu.Unlock()
+
+		if !sleep(ctx, backoff) {
+			return
+		}
+
+		if backoff *= 2; backoff > maxRestartBackoff {
+			backoff = maxRestartBackoff
+		}
+	}
+}
+
+// runJob runs job and converts panics to errors
+func runJob(ctx context.Context, job JobFunc) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v\n%s", r, debug.Stack())
+		}
+	}()
+
+	return job(ctx)
+}
+
+// Shutdown stops all jobs and waits until they returned or timeout is over
+func (s *Supervisor) Shutdown(timeout time.Duration) (err error) {
+	s.cancel()
+
+	var done = make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-time.After(timeout):
+		var running []string
+		for _, j := range s.Status() {
+			if j.Running {
+				running = append(running, j.Name)
+			}
+		}
+		return fmt.Errorf("jobs %v did not stop within %s", running, timeout)
+	}
+}
+
+// Status returns the status of all jobs in the order they were started
+func (s *Supervisor) Status() (status []JobStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, j := range s.jobs {
+		j.mu.Lock()
+		status = append(status, j.JobStatus)
+		j.mu.Unlock()
+	}
+	return
+}
+
+type jobStateKey struct{}
+
+// reportSuccess records that the job running with ctx did its work successfully
+func reportSuccess(ctx context.Context) {
+	state, ok := ctx.Value(jobStateKey{}).(*jobState)
+	if !ok {
+		return
+	}
+
+	state.mu.Lock()
+	state.LastSuccess = time.Now()
+	state.mu.Unlock()
+}
+
+// sleep waits for d and returns false if ctx was cancelled before that
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// sendTweet puts tweet in tweetChan and returns false if ctx was cancelled before that
+func sendTweet(ctx context.Context, tweetChan chan<- match.TweetWrapper, tweet match.TweetWrapper) bool {
+	select {
+	case tweetChan <- tweet:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,87 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSupervisor(t *testing.T) {
+	defer func(min, max time.Duration) { minRestartBackoff, maxRestartBackoff = min, max }(minRestartBackoff, maxRestartBackoff)
+	minRestartBackoff, maxRestartBackoff = time.Millisecond, 4*time.Millisecond
+
+	s := NewSupervisor(context.Background())
+
+	var runs int32
+	s.Go("flaky", func(ctx context.Context) error {
+		switch atomic.AddInt32(&runs, 1) {
+		case 1:
+			panic("something went wrong")
+		case 2:
+			return errors.New("timeline not available")
+		}
+
+		reportSuccess(ctx)
+		<-ctx.Done()
+		return nil
+	})
+
+	var stopped = make(chan struct{})
+	s.Go("steady", func(ctx context.Context) error {
+		<-ctx.Done()
+		close(stopped)
+		return nil
+	})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(&runs) < 3 || s.Status()[0].LastSuccess.IsZero() {
+		if time.Now().After(deadline) {
+			t.Fatalf("job was not restarted, status: %+v", s.Status())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	status := s.Status()
+	if len(status) != 2 || status[0].Name != "flaky" || status[1].Name != "steady" {
+		t.Fatalf("unexpected jobs %+v", status)
+	}
+	if flaky := status[0]; !flaky.Running || flaky.Restarts != 2 || flaky.LastError != "timeline not available" {
+		t.Errorf("unexpected status %+v", flaky)
+	}
+	if steady := status[1]; !steady.Running || steady.Restarts != 0 || steady.LastError != "" {
+		t.Errorf("unexpected status %+v", steady)
+	}
+
+	if err := s.Shutdown(time.Second); err != nil {
+		t.Fatalf("shutting down: %s", err.Error())
+	}
+	select {
+	case <-stopped:
+	default:
+		t.Errorf("job was not stopped on shutdown")
+	}
+	for _, j := range s.Status() {
+		if j.Running {
+			t.Errorf("job %q still running after shutdown", j.Name)
+		}
+	}
+}
+
+func TestSupervisor_ShutdownTimeout(t *testing.T) {
+	s := NewSupervisor(context.Background())
+
+	var release = make(chan struct{})
+	defer close(release)
+	s.Go("stuck", func(ctx context.Context) error {
+		<-release
+		return nil
+	})
+
+	err := s.Shutdown(10 * time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "stuck") {
+		t.Errorf("expected error naming the stuck job, got %v", err)
+	}
+}
@@ -1,46 +1,47 @@
 package jobs
 
 import (
+	"context"
 	"log"
 	"math/rand"
 	"sort"
 	"time"
 
 	"github.com/dghubble/go-twitter/twitter"
+	"github.com/xarantolus/spacex-hop-bot/consumer"
 	"github.com/xarantolus/spacex-hop-bot/match"
+	"github.com/xarantolus/spacex-hop-bot/ratelimit"
 	"github.com/xarantolus/spacex-hop-bot/util"
 )
 
 // CheckHomeTimeline requests the user home timeline about every minute and puts all new tweets in tweetChan.
 // it also includes replies which would normally not be shown in the timeline.
 // TL;DR: it stalks all users the account follows, even their replies
-func CheckHomeTimeline(client *twitter.Client, tweetChan chan<- match.TweetWrapper) {
-	defer panic("home timeline follower stopped processing even though it shouldn't")
+func CheckHomeTimeline(ctx context.Context, client *twitter.Client, cursors *Cursors, tweetChan chan<- match.TweetWrapper) error {
+	var cursor = newTimelineCursor(cursors, homeTimelineKey, "home timeline")
 
-	var (
-		// lastSeenID is the ID of the last tweet we saw
-		lastSeenID int64
-
-		// The first batch of tweets we receive should not acted upon
-		isFirstRequest = true
-	)
+	ratelimit.Default.Register(ratelimit.HomeTimeline)
+	defer ratelimit.Default.Unregister(ratelimit.HomeTimeline)
 
 	log.Println("[Twitter] Watching home timeline")
 
 	for {
 		// https://developer.twitter.com/en/docs/twitter-api/v1/tweets/timelines/api-reference/get-statuses-home_timeline
-		tweets, _, err := client.Timelines.HomeTimeline(&twitter.HomeTimelineParams{
+		tweets, resp, err := client.Timelines.HomeTimeline(&twitter.HomeTimelineParams{
 			ExcludeReplies:  twitter.Bool(false), // We want to get everything, including replies to tweets
 			TrimUser:        twitter.Bool(false), // We care about the user
 			IncludeEntities: twitter.Bool(true),  // We do care about who was mentioned etc.
-			SinceID:         lastSeenID,          // everything since our last request
+			SinceID:         cursor.SinceIDInt(), // everything since our last request
 			Count:           200,                 // Maximum number of tweets we can get at once
 			TweetMode:       "extended",
 		})
 		if err != nil {
+			err = consumer.ClassifyError(err, resp)
 			util.LogError(err, "home timeline")
+			waitForRateLimit(ctx, err, "home timeline")
 			goto sleep
 		}
+		reportSuccess(ctx)
 
 		// Sort tweets so the first tweet we process is the oldest one
 		sort.Slice(tweets, func(i, j int) bool {
@@ -50,26 +51,21 @@ func CheckHomeTimeline(client *twitter.Client, tweetChan chan<- match.TweetWrapp
 			return dj.After(di)
 		})
 
-		for _, tweet := range tweets {
-			lastSeenID = tweet.ID
-
-			// We only look at tweets that appeared after the bot started
-			if isFirstRequest {
-				continue
-			}
+		for _, tweet := range cursor.Batch(tweets) {
 			// OK, process this tweet
-			tweetChan <- match.TweetWrapper{
+			if !sendTweet(ctx, tweetChan, match.TweetWrapper{
 				TweetSource: match.TweetSourceTimeline,
 				Tweet:       tweet,
+			}) {
+				return nil
 			}
-		}
-
-		if isFirstRequest {
-			isFirstRequest = false
+			cursor.Advance(tweet)
 		}
 
 	sleep:
 		// I guess one request every minute is ok
-		time.Sleep(time.Minute + time.Duration(rand.Intn(45))*time.Second)
+		if !ratelimit.Default.Wait(ctx, ratelimit.HomeTimeline, time.Minute, time.Duration(rand.Intn(45))*time.Second) {
+			return nil
+		}
 	}
 }
@@ -0,0 +1,94 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/xarantolus/spacex-hop-bot/consumer"
+	"github.com/xarantolus/spacex-hop-bot/match"
+	"github.com/xarantolus/spacex-hop-bot/ratelimit"
+	"github.com/xarantolus/spacex-hop-bot/twitterv2"
+	"github.com/xarantolus/spacex-hop-bot/util"
+)
+
+// CheckTimelineV2 calls load about every interval and puts all new tweets in tweetChan. load must return
+// tweets newer than the given ID sorted from oldest to newest, like the timeline methods of twitterv2.Client.
+// endpoint is the ratelimit endpoint load requests, it is polled less often if its rate limit is running out.
+// The newest tweet is stored in cursors under key, so missed tweets can be processed after a restart
+func CheckTimelineV2(ctx context.Context, name string, source match.TweetSource, endpoint string, load func(sinceID string) ([]twitter.Tweet, error), interval time.Duration, cursors *Cursors, key string, tweetChan chan<- match.TweetWrapper) error {
+	ratelimit.Default.Register(endpoint)
+	defer ratelimit.Default.Unregister(endpoint)
+
+	log.Printf("[Twitter] Start watching %s\n", name)
+
+	var cursor = newTimelineCursor(cursors, key, name)
+
+	for {
+		tweets, err := load(cursor.SinceID())
+		if err != nil {
+			err = consumer.ClassifyError(err, nil)
+			util.LogError(err, "%s", name)
+			waitForRateLimit(ctx, err, name)
+			goto sleep
+		}
+		reportSuccess(ctx)
+
+		for _, tweet := range cursor.Batch(tweets) {
+			if !sendTweet(ctx, tweetChan, match.TweetWrapper{
+				TweetSource: source,
+				Tweet:       tweet,
+			}) {
+				return nil
+			}
+			cursor.Advance(tweet)
+		}
+
+	sleep:
+		// Add a random delay
+		if !ratelimit.Default.Wait(ctx, endpoint, interval, time.Duration(rand.Intn(45))*time.Second) {
+			return nil
+		}
+	}
+}
+
+// CheckFilteredStream sets the rules of the filtered stream and puts all tweets from it in tweetChan.
+// It replaces the location stream when using the v2 API
+func CheckFilteredStream(ctx context.Context, client *twitterv2.Client, rules []twitterv2.Rule, tweetChan chan<- match.TweetWrapper) error {
+	var backoff = 1
+	for {
+		// Rules are set again on every connection in case they were changed somewhere else
+		err := client.SetRules(rules)
+		if util.LogError(err, "setting filtered stream rules") {
+			backoff *= 2
+			goto sleep
+		}
+
+		log.Println("[Twitter] Connecting to filtered stream")
+
+		err = client.Stream(ctx, func(st twitterv2.StreamTweet) {
+			backoff = 1
+			reportSuccess(ctx)
+
+			sendTweet(ctx, tweetChan, match.TweetWrapper{
+				TweetSource: match.TweetSourceLocationStream,
+				Tweet:       st.Tweet,
+			})
+		})
+		if ctx.Err() != nil {
+			return nil
+		}
+		backoff *= 2
+
+		log.Printf("[Twitter] Filtered stream ended (%s), trying again in %d seconds", err.Error(), backoff*5)
+	sleep:
+		if backoff > 64 {
+			backoff = 64
+		}
+		if !sleep(ctx, time.Duration(backoff)*5*time.Second) {
+			return nil
+		}
+	}
+}
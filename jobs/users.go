@@ -1,42 +1,44 @@
 package jobs
 
 import (
+	"context"
 	"log"
 	"math/rand"
 	"sort"
 	"time"
 
 	"github.com/dghubble/go-twitter/twitter"
+	"github.com/xarantolus/spacex-hop-bot/consumer"
 	"github.com/xarantolus/spacex-hop-bot/match"
+	"github.com/xarantolus/spacex-hop-bot/ratelimit"
 	"github.com/xarantolus/spacex-hop-bot/util"
 )
 
-// CheckUserTimeline requests the given user profile every few minutes or so
-func CheckUserTimeline(client *twitter.Client, name string, tweetChan chan<- match.TweetWrapper) {
-	defer panic("user (" + name + ") follower stopped processing even though it shouldn't")
-
+// CheckUserTimeline requests the profile of the given user about every user.Interval
+func CheckUserTimeline(ctx context.Context, client *twitter.Client, user TrustedUser, cursors *Cursors, tweetChan chan<- match.TweetWrapper) error {
+	var name = user.ScreenName
 	log.Printf("[Twitter] Start watching %s's Twitter profile", name)
 
-	var (
-		// lastSeenID is the ID of the last tweet we saw
-		lastSeenID int64
+	var cursor = newTimelineCursor(cursors, "user:"+name, name+"'s Twitter profile")
 
-		// The first batch of tweets we receive should not acted upon
-		isFirstRequest = true
-	)
+	ratelimit.Default.Register(ratelimit.UserTimeline)
+	defer ratelimit.Default.Unregister(ratelimit.UserTimeline)
 
 	for {
-		tweets, _, err := client.Timelines.UserTimeline(&twitter.UserTimelineParams{
+		tweets, resp, err := client.Timelines.UserTimeline(&twitter.UserTimelineParams{
 			ScreenName:     name,
 			TweetMode:      "extended",
 			ExcludeReplies: twitter.Bool(false),
-			SinceID:        lastSeenID,
+			SinceID:        cursor.SinceIDInt(),
 		})
 
 		if err != nil {
+			err = consumer.ClassifyError(err, resp)
 			util.LogError(err, "timeline of user %q", name)
+			waitForRateLimit(ctx, err, "timeline of "+name)
 			goto sleep
 		}
+		reportSuccess(ctx)
 
 		// Sort tweets so the first tweet we process is the oldest one
 		sort.Slice(tweets, func(i, j int) bool {
@@ -46,25 +48,21 @@ func CheckUserTimeline(client *twitter.Client, name string, tweetChan chan<- mat
 			return dj.After(di)
 		})
 
-		for _, tweet := range tweets {
-			lastSeenID = tweet.ID
-
-			if isFirstRequest {
-				continue
-			}
-
+		for _, tweet := range cursor.Batch(tweets) {
 			// OK, process this tweet
-			tweetChan <- match.TweetWrapper{
-				TweetSource: match.TweetSourceTrustedUser,
+			if !sendTweet(ctx, tweetChan, match.TweetWrapper{
+				TweetSource: user.Source,
 				Tweet:       tweet,
+			}) {
+				return nil
 			}
-		}
-		if isFirstRequest {
-			isFirstRequest = false
+			cursor.Advance(tweet)
 		}
 
 	sleep:
 		// Add a random delay
-		time.Sleep(2*time.Minute + time.Duration(rand.Intn(500))*time.Second)
+		if !ratelimit.Default.Wait(ctx, ratelimit.UserTimeline, user.Interval, time.Duration(rand.Intn(500))*time.Second) {
+			return nil
+		}
 	}
 }
@@ -1,6 +1,7 @@
 package jobs
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"math/rand"
@@ -17,9 +18,7 @@ const (
 )
 
 // StarshipWebsiteChanges watches the SpaceX starship page and tweets when the date or starship serial number change
-func StarshipWebsiteChanges(client consumer.TwitterClient, linkChan chan<- string) {
-	defer panic("website watcher stopped even though it never should")
-
+func StarshipWebsiteChanges(ctx context.Context, client consumer.TwitterClient, linkChan chan<- string) error {
 	log.Println("[SpaceX] Watching Starship page for updates")
 
 	var lastChange scrapers.StarshipInfo
@@ -40,6 +39,7 @@ func StarshipWebsiteChanges(client consumer.TwitterClient, linkChan chan<- strin
 			util.LogError(err, "scraping SpaceX Starship website")
 			goto sleep
 		}
+		reportSuccess(ctx)
 
 		if !reflect.DeepEqual(lastChange, info) {
 			util.LogError(util.SaveJSON(changesFile, info), "saving changes file")
@@ -49,7 +49,9 @@ func StarshipWebsiteChanges(client consumer.TwitterClient, linkChan chan<- strin
 
 	sleep:
 		// Wait 2-4 minutes until checking again
-		time.Sleep(2*time.Minute + time.Duration(rand.Intn(120))*time.Second)
+		if !sleep(ctx, 2*time.Minute+time.Duration(rand.Intn(120))*time.Second) {
+			return nil
+		}
 	}
 }
 
@@ -28,14 +28,18 @@ func (r *TestTweetingClient) LoadStatus(tweetID int64) (*twitter.Tweet, error) {
 	panic("LoadStatus not implemented")
 }
 
-func (r *TestTweetingClient) AddListMember(listID int64, userID int64) (err error) {
-	panic("AddListMember not implemented")
+func (r *TestTweetingClient) UpdateListMembers(listID int64, add, remove []int64) (err error) {
+	panic("UpdateListMembers not implemented")
 }
 
 func (r *TestTweetingClient) Retweet(tweet *twitter.Tweet) error {
 	panic("Retweet not implemented")
 }
 
+func (r *TestTweetingClient) Like(tweetID int64) error {
+	panic("Like not implemented")
+}
+
 func (r *TestTweetingClient) Tweet(text string, inReplyToID *int64) (t *twitter.Tweet, err error) {
 	if r.HasTweeted() {
 		panic("test tweeted more than once")
@@ -153,6 +157,11 @@ func Test_runWebsiteScrape(t *testing.T) {
 			wantTweet: "The SpaceX #Starship website now mentions February 16 for an orbital flight of #S20\n#WenHop\n" + scrapers.StarshipURL,
 		},
 	}
+	// The test server runs on localhost, which is usually blocked
+	defaultFetcher := util.DefaultFetcher
+	util.DefaultFetcher = util.NewFetcher(util.FetcherOptions{AllowPrivateNetworks: true})
+	defer func() { util.DefaultFetcher = defaultFetcher }()
+
 	for _, tt := range tests {
 		t.Run(t.Name(), func(t *testing.T) {
 			twitterClient := &TestTweetingClient{}
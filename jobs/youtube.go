@@ -2,6 +2,7 @@ package jobs
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"log"
 	"math/rand"
@@ -19,9 +20,7 @@ import (
 )
 
 // CheckYouTubeLive checks SpaceX's youtube live stream every 1-2 minutes and tweets if there is a starship launch stream
-func CheckYouTubeLive(client consumer.TwitterClient, user *twitter.User, matcher *match.StarshipMatcher, linkChan <-chan string) {
-	defer panic("for some reason, the youtube live checker stopped running even though it never should")
-
+func CheckYouTubeLive(ctx context.Context, client consumer.TwitterClient, user *twitter.User, matcher *match.StarshipMatcher, linkChan <-chan string) error {
 	log.Println("[YouTube] Watching SpaceX channel for live Starship streams")
 
 	const spaceXLiveURL = "https://www.youtube.com/spacex/live"
@@ -47,6 +46,9 @@ func CheckYouTubeLive(client consumer.TwitterClient, user *twitter.User, matcher
 		}
 
 		linkOverwrite = ""
+		if err == nil || errors.Is(err, scrapers.ErrNoVideo) {
+			reportSuccess(ctx)
+		}
 
 		if liveVideo.VideoID == "" || err != nil {
 			goto sleep
@@ -91,6 +93,8 @@ func CheckYouTubeLive(client consumer.TwitterClient, user *twitter.User, matcher
 		select {
 		case <-time.After(time.Minute + time.Duration(rand.Intn(60))*time.Second):
 		case linkOverwrite = <-linkChan:
+		case <-ctx.Done():
+			return nil
 		}
 	}
 }
@@ -1,16 +1,24 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
 	"math/rand"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
+	"github.com/dghubble/go-twitter/twitter"
 	"github.com/xarantolus/spacex-hop-bot/bot"
 	"github.com/xarantolus/spacex-hop-bot/config"
 	"github.com/xarantolus/spacex-hop-bot/consumer"
+	"github.com/xarantolus/spacex-hop-bot/decisions"
 	"github.com/xarantolus/spacex-hop-bot/jobs"
 	"github.com/xarantolus/spacex-hop-bot/match"
+	"github.com/xarantolus/spacex-hop-bot/publish"
+	"github.com/xarantolus/spacex-hop-bot/twitterv2"
 	"github.com/xarantolus/spacex-hop-bot/util"
 )
 
@@ -38,15 +46,38 @@ func main() {
 		panic("parsing configuration file: " + err.Error())
 	}
 
-	// Log in to Twitter
-	client, selfUser, err := bot.Login(cfg)
+	// All requests to websites linked in tweets go through this fetcher
+	util.DefaultFetcher = util.NewFetcher(util.FetcherOptions{
+		MaxConcurrent: cfg.Fetch.MaxConcurrent,
+		MaxBodySize:   cfg.Fetch.MaxBodyMB << 20,
+		MaxRedirects:  cfg.Fetch.MaxRedirects,
+		CacheSize:     cfg.Fetch.CacheSize,
+	})
+
+	// Log in to Twitter, either using the v1.1 or the v2 API
+	var (
+		client   *twitter.Client
+		clientV2 *twitterv2.Client
+		selfUser *twitter.User
+
+		ignoredUserMatcher *match.Ignorer
+	)
+	if cfg.UseAPIv2() {
+		clientV2, selfUser, err = bot.LoginV2(cfg)
+	} else {
+		client, selfUser, err = bot.Login(cfg)
+	}
 	if err != nil {
 		panic("logging in to twitter: " + err.Error())
 	}
 	log.Printf("[Twitter] Logged in @%s\n", selfUser.ScreenName)
 
 	// Load all ignored accounts to make sure we don't retweet them
-	ignoredUserMatcher := match.LoadIgnoredList(client, cfg.Lists.IgnoredListIDs...)
+	if clientV2 != nil {
+		ignoredUserMatcher = match.LoadIgnoredListV2(clientV2, cfg.Lists.IgnoredListIDs...)
+	} else {
+		ignoredUserMatcher = match.LoadIgnoredList(client, cfg.Lists.IgnoredListIDs...)
+	}
 	iu := ignoredUserMatcher.UserIDs()
 	if len(iu) > 5 {
 		util.LogError(util.SaveJSON("ignored-users.json", iu), "startup: saving ignored users")
@@ -59,29 +90,261 @@ func main() {
 		Client: client,
 		Debug:  *flagDebug,
 	}
+	if clientV2 != nil {
+		twitterClient = &consumer.V2TwitterClient{
+			Client: clientV2,
+			User:   selfUser,
+			Debug:  *flagDebug,
+		}
+	}
+
+	// The decision log and the cassette are split into segments that are deleted after the same time
+	var logOptions = decisions.Options{
+		Retention:      time.Duration(cfg.DecisionLog.RetentionDays) * 24 * time.Hour,
+		MaxSegmentSize: cfg.DecisionLog.SegmentSizeMB << 20,
+	}
+
+	// All API calls and link lookups can be recorded to a cassette, so misjudged threads can be reproduced offline
+	var (
+		recorder *consumer.RecordingClient
+		cassette *decisions.Writer
+	)
+	if cfg.Record.Cassette != "" {
+		cassette, err = decisions.NewWriter(cfg.Record.Cassette, logOptions)
+		if err != nil {
+			panic("opening cassette: " + err.Error())
+		}
+		recorder = consumer.NewRecordingClient(twitterClient, cassette)
+		twitterClient = recorder
+		log.Printf("[Startup] Recording API calls to %s\n", cfg.Record.Cassette)
+	}
+
+	// Recent decisions can be searched using the API
+	searchDays := cfg.DecisionLog.SearchDays
+	if searchDays <= 0 {
+		searchDays = 7
+	}
+	decisionIndex, err := decisions.LoadIndex(cfg.DecisionLogDirectory(), time.Duration(searchDays)*24*time.Hour)
+	util.LogError(err, "loading decision archive")
+	log.Printf("[Startup] Loaded %d decisions for searching\n", decisionIndex.Len())
+
+	// Every decision is written to the decision log, so we can later reproduce why something was (not) retweeted
+	var decisionLogOptions = logOptions
+	decisionLogOptions.Index = decisionIndex
+	decisionLog, err := decisions.NewWriter(cfg.DecisionLogDirectory(), decisionLogOptions)
+	if err != nil {
+		panic("opening decision log: " + err.Error())
+	}
+
+	// Everything we retweet or tweet can also be published to other platforms
+	var fanout *publish.Fanout
+	if pubs := publishers(cfg); len(pubs) > 0 {
+		fanout = publish.NewFanout(publish.Options{
+			MaxAttempts: cfg.Publish.MaxAttempts,
+			PauseAfter:  cfg.Publish.PauseAfter,
+			PauseFor:    time.Duration(cfg.Publish.PauseMinutes) * time.Minute,
+		}, pubs...)
+		twitterClient = consumer.NewPublishingClient(twitterClient, fanout)
+
+		for _, p := range pubs {
+			log.Printf("[Startup] Publishing to %s\n", p.Name())
+		}
+	}
 
 	// This is the main channel tweets will be sent on. Basically many jobs *search* for tweets
 	// and send them on this channel, then the processor will handle each incoming tweet
 	var tweetChan = make(chan match.TweetWrapper, 250)
 
+	// All background jobs are restarted when they fail and stopped on shutdown
+	var supervisor = jobs.NewSupervisor(context.Background())
+
+	// Timeline jobs continue where they stopped, so tweets posted while the bot was down are not lost
+	cursors, err := jobs.LoadCursors(cfg.CursorFile(), cfg.CatchUpMaxAge())
+	util.LogError(err, "loading timeline cursors")
+
+	// listWatcher knows which lists are watched, it stays nil in debug mode
+	var listWatcher *jobs.ListWatcher
+
 	if *flagDebug {
 		log.Println("[Info] Running in debug mode, no background jobs are started")
 	} else {
 		// Register all background jobs, most of them send tweets on tweetChan
-		err = jobs.Register(client, twitterClient, selfUser, starshipMatcher, tweetChan, cfg.IgnoredListsMapping())
+		var retraction = jobs.RetractionOptions{
+			Enabled:   cfg.Retraction.Enabled,
+			DryRun:    cfg.Retraction.DryRun,
+			MaxPerRun: cfg.Retraction.MaxPerRun,
+			MaxAge:    time.Duration(cfg.Retraction.MaxAgeHours) * time.Hour,
+
+			DecisionLogDir: cfg.DecisionLogDirectory(),
+			DecisionLog:    decisionLog,
+		}
+		// Ignored lists are read from the config file again whenever the watched lists are refreshed,
+		// so lists can be ignored without restarting the bot
+		var ignoredLists = cfg.IgnoredListsMapping()
+		var skipLists = func() map[int64]bool {
+			c, err := config.Parse(*flagConfigFile)
+			if !util.LogError(err, "reloading ignored lists, keeping the previous ones") {
+				ignoredLists = c.IgnoredListsMapping()
+			}
+			return ignoredLists
+		}
+
+		var sources = jobs.ConfiguredTwitterSources(cfg)
+
+		if clientV2 != nil {
+			var streamRules []twitterv2.Rule
+			for _, r := range cfg.Twitter.StreamRules {
+				streamRules = append(streamRules, twitterv2.Rule{Value: r})
+			}
+			listWatcher, err = jobs.RegisterV2(supervisor, cursors, clientV2, twitterClient, selfUser, starshipMatcher, tweetChan, sources, skipLists, retraction, streamRules)
+		} else {
+			listWatcher, err = jobs.Register(supervisor, cursors, client, twitterClient, selfUser, starshipMatcher, tweetChan, sources, skipLists, retraction)
+		}
 		if err != nil {
 			panic("registering jobs: " + err.Error())
 		}
 	}
 
 	// handler handles tweets by filtering & retweeting the interesting ones
-	var handler = consumer.NewProcessor(*flagDebug, false, twitterClient, selfUser, starshipMatcher, cfg.Lists.MainStarshipListID)
+	var handler = consumer.NewProcessor(*flagDebug, false, twitterClient, selfUser, starshipMatcher)
+	err = handler.SetActions(cfg.Actions.Reasons, cfg.Actions.QuoteTemplate)
+	if err != nil {
+		panic("configuring actions: " + err.Error())
+	}
+	handler.SetLikeTier(cfg.Actions.Like)
+
+	if recorder != nil {
+		handler.SetLinkResolver(recorder)
+	}
+
+	if cfg.Links.PolicyFile != "" {
+		linkPolicy, err := consumer.LoadLinkPolicyFile(cfg.Links.PolicyFile)
+		if err != nil {
+			panic("loading link policy: " + err.Error())
+		}
+		handler.SetLinkPolicy(linkPolicy)
+		log.Printf("[Startup] Using link policy from %s\n", cfg.Links.PolicyFile)
+	}
+
+	// Posts from other platforms are only republished, so they are not needed without publishing backends.
+	// They are checked by the handler, so this job is started once it is configured
+	if sources := jobs.IngestSources(cfg); len(sources) > 0 && !*flagDebug {
+		if fanout == nil {
+			log.Println("[Warning] Sources on other platforms are configured, but there are no publishing backends")
+		} else {
+			var interval = time.Duration(cfg.Ingest.IntervalMinutes) * time.Minute
+			if interval <= 0 {
+				interval = 5 * time.Minute
+			}
+			supervisor.Go("ingest", func(ctx context.Context) error {
+				return jobs.IngestPosts(ctx, sources, handler, fanout, interval)
+			})
+		}
+	}
+
+	// Authors of retweeted tweets are added to the space people list in batches
+	var pruneAfter = time.Duration(cfg.Lists.SpacePeople.PruneAfterMonths) * 30 * 24 * time.Hour
+	var spacePeople = consumer.NewSpacePeopleList(twitterClient, cfg.Lists.MainStarshipListID, consumer.SpacePeopleOptions{
+		Filename:   cfg.SpacePeopleListFile(),
+		PruneAfter: pruneAfter,
+		IsIgnored:  ignoredUserMatcher.IsIgnoredUser,
+	})
+	handler.SetSpacePeopleList(spacePeople)
+
+	// Members that were added before the bot managed the list should also be pruned
+	var spacePeopleMembers *bot.UserList
+	if clientV2 != nil {
+		spacePeopleMembers = bot.ListMembersV2(clientV2, "space people", cfg.Lists.MainStarshipListID)
+	} else {
+		spacePeopleMembers = bot.ListMembers(client, "space people", cfg.Lists.MainStarshipListID)
+	}
+	if n := spacePeople.LoadMembers(spacePeopleMembers.ContainedIDs()); n > 0 {
+		log.Printf("[Startup] Loaded %d space people list members that were added outside of the bot\n", n)
+	}
+	if !*flagDebug {
+		var batchInterval = time.Duration(cfg.Lists.SpacePeople.BatchMinutes) * time.Minute
+		if batchInterval <= 0 {
+			batchInterval = 15 * time.Minute
+		}
+		supervisor.Go("space people list", func(ctx context.Context) error {
+			return jobs.MaintainSpacePeopleList(ctx, spacePeople, batchInterval)
+		})
+	}
+
+	handler.SetDecisionLog(decisionLog)
+
+	// A candidate matcher can be evaluated on real traffic before its rules are added to the code
+	if cfg.Shadow.CandidateRules != "" {
+		rules, err := match.LoadMatcherRules(cfg.Shadow.CandidateRules)
+		if err != nil {
+			panic("loading candidate matcher rules: " + err.Error())
+		}
+		handler.SetShadowMatcher(match.NewCandidateStarshipMatcher(ignoredUserMatcher, rules))
+		log.Printf("[Startup] Evaluating candidate rules from %s in shadow mode\n", cfg.Shadow.CandidateRules)
+	}
 
 	// The web server should always run, regardless of debug mode or not
-	go jobs.RunWebServer(cfg, twitterClient, handler, tweetChan)
+	supervisor.Go("web server", func(ctx context.Context) error {
+		return jobs.RunWebServer(ctx, cfg, supervisor, listWatcher, twitterClient, handler, decisionIndex, spacePeople, tweetChan)
+	})
 
-	// Now we just process every tweet we come across
-	for tweet := range tweetChan {
+	var processTweet = func(tweet match.TweetWrapper) {
+		if recorder != nil {
+			recorder.Incoming(tweet)
+		}
 		handler.Tweet(tweet)
 	}
+
+	var stop = make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, os.Interrupt)
+
+	// Now we just process every tweet we come across
+	for {
+		select {
+		case tweet := <-tweetChan:
+			processTweet(tweet)
+		case sig := <-stop:
+			log.Printf("[Shutdown] Received %s, stopping all jobs\n", sig)
+			util.LogError(supervisor.Shutdown(30*time.Second), "stopping jobs")
+
+			// No job sends tweets anymore, but there might still be some in the channel
+			var drained int
+		drain:
+			for {
+				select {
+				case tweet := <-tweetChan:
+					processTweet(tweet)
+					drained++
+				default:
+					break drain
+				}
+			}
+			log.Printf("[Shutdown] Processed %d remaining tweets\n", drained)
+
+			if fanout != nil {
+				fanout.Close()
+			}
+			util.LogError(decisionLog.Close(), "closing decision log")
+			if cassette != nil {
+				util.LogError(cassette.Close(), "closing cassette")
+			}
+
+			log.Println("[Shutdown] Bye")
+			return
+		}
+	}
+}
+
+// publishers returns all configured publishing backends
+func publishers(cfg config.Config) (pubs []publish.Publisher) {
+	for _, m := range cfg.Publish.Mastodon {
+		pubs = append(pubs, publish.NewMastodon(m.Server, m.AccessToken, m.Visibility))
+	}
+	for _, b := range cfg.Publish.Bluesky {
+		pubs = append(pubs, publish.NewBluesky(b.Service, b.Identifier, b.AppPassword))
+	}
+	for _, w := range cfg.Publish.Webhooks {
+		pubs = append(pubs, publish.NewWebhook(w.URL, w.Secret))
+	}
+	return
 }
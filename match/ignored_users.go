@@ -5,6 +5,7 @@ import (
 
 	"github.com/dghubble/go-twitter/twitter"
 	"github.com/xarantolus/spacex-hop-bot/bot"
+	"github.com/xarantolus/spacex-hop-bot/twitterv2"
 )
 
 type Ignorer struct {
@@ -15,16 +16,23 @@ type Ignorer struct {
 
 // LoadIgnoredList marks the members of this list as ignored accounts
 func LoadIgnoredList(client *twitter.Client, ignoredListIDs ...int64) *Ignorer {
-	var list = bot.ListMembers(client, "ignored", ignoredListIDs...)
+	return newIgnorer(bot.ListMembers(client, "ignored", ignoredListIDs...))
+}
+
+// LoadIgnoredListV2 is like LoadIgnoredList, but loads the lists using the v2 API
+func LoadIgnoredListV2(client *twitterv2.Client, ignoredListIDs ...int64) *Ignorer {
+	return newIgnorer(bot.ListMembersV2(client, "ignored", ignoredListIDs...))
+}
 
+func newIgnorer(list *bot.UserList) *Ignorer {
 	return &Ignorer{
 		list:     list,
 		keywords: ignoredAccountDescriptionKeywords,
 	}
 }
 
-func (i *Ignorer) IsOrMentionsIgnoredAccount(tweet *twitter.Tweet) bool {
-	username := strings.ToLower(tweet.User.ScreenName)
+func (i *Ignorer) IsOrMentionsIgnoredAccount(post *Post) bool {
+	username := strings.ToLower(post.Author.Handle)
 
 	// If we know the user, they can't be ignored
 	_, known1 := specificUserMatchers[username]
@@ -35,12 +43,14 @@ func (i *Ignorer) IsOrMentionsIgnoredAccount(tweet *twitter.Tweet) bool {
 
 	// If the list of accounts we ignore contains *anything* related to this account
 	// we ignore the tweet
-	if i.list.TweetAssociatedWithAny(tweet) {
-		return true
+	for _, id := range post.twitterUserIDs() {
+		if i.list.ContainsByID(id) {
+			return true
+		}
 	}
 
 	// Now search the user description to see if any negative keywords stand out
-	desc := strings.ToLower(tweet.User.Description)
+	desc := strings.ToLower(post.Author.Description)
 	for _, k := range i.keywords {
 		if strings.Contains(desc, k) {
 			return true
@@ -50,6 +60,11 @@ func (i *Ignorer) IsOrMentionsIgnoredAccount(tweet *twitter.Tweet) bool {
 	return false
 }
 
+// IsIgnoredUser returns whether the user with the given ID is on one of the ignored lists
+func (i *Ignorer) IsIgnoredUser(userID int64) bool {
+	return i.list.ContainsByID(userID)
+}
+
 func (i Ignorer) UserIDs() []int64 {
 	return i.list.ContainedIDs()
 }
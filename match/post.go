@@ -0,0 +1,247 @@
+package match
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+	"github.com/xarantolus/spacex-hop-bot/util"
+)
+
+// PlatformTwitter is the platform of posts converted from tweets
+const PlatformTwitter = "twitter"
+
+// Post is a platform-neutral post, e.g. a tweet, Mastodon status or feed item. The matcher only looks at posts,
+// so posts from other platforms and test fixtures don't need to fabricate tweets
+type Post struct {
+	Platform string
+	ID       string
+	URL      string
+	Source   TweetSource
+
+	Author Author
+
+	// Text is the text without links, TextWithURLs is the text with all links expanded
+	Text         string
+	TextWithURLs string
+	// URLs are the expanded links in the text
+	URLs []string
+	// Mentions are the accounts mentioned in the text
+	Mentions []Author
+	Media    []Media
+
+	Geo *Geo
+
+	InReplyTo *Reference
+	Quoted    *Post
+	Reposted  *Post
+
+	Lang      string
+	Sensitive bool
+	Created   time.Time
+
+	EnableLogging bool
+
+	// Explanation records every step that is logged for this post, if it is set
+	Explanation *Explanation
+}
+
+// Author is the author of a post or an account mentioned in it
+type Author struct {
+	// ID is the ID on the platform of the post, it might be empty
+	ID          string
+	Handle      string
+	Name        string
+	Description string
+}
+
+// Media is an image, video or GIF attached to a post
+type Media struct {
+	// Type is e.g. "photo", "video" or "animated_gif"
+	Type string
+	URL  string
+}
+
+// Geo is the place a post was tagged with
+type Geo struct {
+	// PlaceID is a Twitter place ID, see starship_places.go
+	PlaceID   string
+	PlaceName string
+}
+
+// Reference points to another post, e.g. the one a post replies to
+type Reference struct {
+	ID           string
+	AuthorID     string
+	AuthorHandle string
+}
+
+// PostFromTweet converts a tweet to a post. Quoted and retweeted tweets are converted too
+func PostFromTweet(t *twitter.Tweet) *Post {
+	if t == nil {
+		return nil
+	}
+
+	var p = &Post{
+		Platform:     PlatformTwitter,
+		ID:           tweetIDString(t),
+		URL:          util.TweetURL(t),
+		Text:         t.Text(),
+		TextWithURLs: t.TextWithURLs(),
+		Lang:         t.Lang,
+		Sensitive:    t.PossiblySensitive,
+	}
+
+	if created, err := t.CreatedAtTime(); err == nil {
+		p.Created = created
+	}
+
+	if t.User != nil {
+		p.Author = Author{
+			ID:          strconv.FormatInt(t.User.ID, 10),
+			Handle:      t.User.ScreenName,
+			Name:        t.User.Name,
+			Description: t.User.Description,
+		}
+	}
+
+	for _, e := range []*twitter.Entities{t.Entities, extendedTweetEntities(t)} {
+		if e == nil {
+			continue
+		}
+		for _, u := range e.Urls {
+			p.URLs = append(p.URLs, u.ExpandedURL)
+		}
+		for _, m := range e.UserMentions {
+			p.Mentions = append(p.Mentions, Author{
+				ID:     strconv.FormatInt(m.ID, 10),
+				Handle: m.ScreenName,
+				Name:   m.Name,
+			})
+		}
+	}
+
+	// Extended entities contain all media, entities only the first one
+	var media []twitter.MediaEntity
+	if t.ExtendedEntities != nil && len(t.ExtendedEntities.Media) > 0 {
+		media = t.ExtendedEntities.Media
+	} else if t.Entities != nil {
+		media = t.Entities.Media
+	}
+	for _, m := range media {
+		p.Media = append(p.Media, Media{
+			Type: m.Type,
+			URL:  m.MediaURLHttps,
+		})
+	}
+
+	if t.Place != nil {
+		p.Geo = &Geo{
+			PlaceID:   t.Place.ID,
+			PlaceName: t.Place.FullName,
+		}
+	}
+
+	if t.InReplyToStatusID != 0 {
+		p.InReplyTo = &Reference{
+			ID:           strconv.FormatInt(t.InReplyToStatusID, 10),
+			AuthorID:     strconv.FormatInt(t.InReplyToUserID, 10),
+			AuthorHandle: t.InReplyToScreenName,
+		}
+	}
+
+	p.Quoted = PostFromTweet(t.QuotedStatus)
+	p.Reposted = PostFromTweet(t.RetweetedStatus)
+
+	return p
+}
+
+func tweetIDString(t *twitter.Tweet) string {
+	if t.IDStr != "" {
+		return t.IDStr
+	}
+	return strconv.FormatInt(t.ID, 10)
+}
+
+func extendedTweetEntities(t *twitter.Tweet) *twitter.Entities {
+	if t.ExtendedTweet == nil {
+		return nil
+	}
+	return t.ExtendedTweet.Entities
+}
+
+// Post converts the wrapped tweet to a post that shares the source, logging and explanation of the wrapper
+func (t *TweetWrapper) Post() *Post {
+	p := PostFromTweet(&t.Tweet)
+	p.Source = t.TweetSource
+	p.EnableLogging = t.EnableLogging
+	p.Explanation = t.Explanation
+	return p
+}
+
+// Log records a step for this post, see TweetWrapper.Log
+func (p *Post) Log(format string, a ...interface{}) {
+	if !p.EnableLogging && p.Explanation == nil {
+		return
+	}
+
+	msg := fmt.Sprintf(format, a...)
+
+	if p.Explanation != nil {
+		p.Explanation.Steps = append(p.Explanation.Steps, msg)
+	}
+	if p.EnableLogging {
+		log.Printf("[Processor] %s (%s): %s", p.URL, p.Source.String(), msg)
+	}
+}
+
+// HasMedia returns whether images, videos or GIFs are attached to the post
+func (p *Post) HasMedia() bool {
+	return len(p.Media) > 0
+}
+
+// IsReactionGIF returns whether the only attachment of the post is a GIF
+func (p *Post) IsReactionGIF() bool {
+	return len(p.Media) == 1 && strings.Contains(p.Media[0].Type, "gif")
+}
+
+// IsBy returns whether the post was written by the account with the given handle
+func (p *Post) IsBy(handle string) bool {
+	return strings.EqualFold(p.Author.Handle, handle)
+}
+
+// SameAuthor returns whether both posts were written by the same account
+func (p *Post) SameAuthor(other *Post) bool {
+	return p.Platform == other.Platform &&
+		(p.Author.ID != "" && p.Author.ID == other.Author.ID ||
+			p.Author.Handle != "" && strings.EqualFold(p.Author.Handle, other.Author.Handle))
+}
+
+// IsSelfReply returns whether the post replies to a post by the same author, e.g. in a thread
+func (p *Post) IsSelfReply() bool {
+	return p.InReplyTo != nil &&
+		(p.Author.ID != "" && p.Author.ID == p.InReplyTo.AuthorID ||
+			p.Author.Handle != "" && strings.EqualFold(p.Author.Handle, p.InReplyTo.AuthorHandle))
+}
+
+// twitterUserIDs returns the Twitter user IDs of the author and everyone mentioned in this post,
+// including those of quoted and reposted posts
+func (p *Post) twitterUserIDs() (ids []int64) {
+	if p == nil || p.Platform != PlatformTwitter {
+		return nil
+	}
+
+	for _, a := range append([]Author{p.Author}, p.Mentions...) {
+		if id, err := strconv.ParseInt(a.ID, 10, 64); err == nil && id != 0 {
+			ids = append(ids, id)
+		}
+	}
+
+	ids = append(ids, p.Quoted.twitterUserIDs()...)
+	ids = append(ids, p.Reposted.twitterUserIDs()...)
+
+	return
+}
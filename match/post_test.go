@@ -0,0 +1,105 @@
+package match
+
+import (
+	"testing"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+func TestPostFromTweet(t *testing.T) {
+	var tweet = &twitter.Tweet{
+		ID:                20,
+		IDStr:             "20",
+		CreatedAt:         "Wed Dec 11 17:52:17 +0000 2021",
+		FullText:          "S20 on the pad https://t.co/1 https://t.co/2",
+		Lang:              "en",
+		PossiblySensitive: true,
+		User:              &twitter.User{ID: 80, ScreenName: "someone", Description: "Photographer"},
+		Entities: &twitter.Entities{
+			Urls:         []twitter.URLEntity{{URL: "https://t.co/1", ExpandedURL: "https://spacex.com/"}},
+			UserMentions: []twitter.MentionEntity{{ID: 81, ScreenName: "other"}},
+			Media:        []twitter.MediaEntity{{URLEntity: twitter.URLEntity{URL: "https://t.co/2"}, Type: "photo"}},
+		},
+		ExtendedEntities: &twitter.ExtendedEntity{
+			Media: []twitter.MediaEntity{{URLEntity: twitter.URLEntity{URL: "https://t.co/2"}, Type: "photo"}, {Type: "photo"}},
+		},
+		Place:               &twitter.Place{ID: StarbasePlaceID, FullName: "Starbase, TX"},
+		InReplyToStatusID:   19,
+		InReplyToUserID:     80,
+		InReplyToScreenName: "someone",
+		QuotedStatus:        &twitter.Tweet{ID: 5, User: &twitter.User{ID: 82, ScreenName: "quoted"}},
+	}
+
+	p := PostFromTweet(tweet)
+
+	if p.Platform != PlatformTwitter || p.ID != "20" || p.URL != "https://twitter.com/someone/status/20" {
+		t.Errorf("unexpected identity %q %q %q", p.Platform, p.ID, p.URL)
+	}
+	if p.Author.ID != "80" || p.Author.Handle != "someone" || p.Author.Description != "Photographer" {
+		t.Errorf("unexpected author %+v", p.Author)
+	}
+	if p.Text != "S20 on the pad  " || p.TextWithURLs != "S20 on the pad https://spacex.com/ " {
+		t.Errorf("unexpected text %q / %q", p.Text, p.TextWithURLs)
+	}
+	if len(p.URLs) != 1 || p.URLs[0] != "https://spacex.com/" {
+		t.Errorf("unexpected URLs %v", p.URLs)
+	}
+	// Extended entities contain all media
+	if len(p.Media) != 2 || !p.HasMedia() || p.IsReactionGIF() {
+		t.Errorf("unexpected media %+v", p.Media)
+	}
+	if p.Geo == nil || !IsAtStarshipLocation(p) || p.Geo.PlaceName != "Starbase, TX" {
+		t.Errorf("unexpected geo %+v", p.Geo)
+	}
+	if p.InReplyTo == nil || p.InReplyTo.ID != "19" || !p.IsSelfReply() {
+		t.Errorf("unexpected reply reference %+v", p.InReplyTo)
+	}
+	if p.Quoted == nil || p.Quoted.Author.Handle != "quoted" || p.Reposted != nil {
+		t.Errorf("unexpected quoted/reposted posts %+v / %+v", p.Quoted, p.Reposted)
+	}
+	if p.Lang != "en" || !p.Sensitive || p.Created.Year() != 2021 {
+		t.Errorf("unexpected lang %q, sensitive %v or created %s", p.Lang, p.Sensitive, p.Created)
+	}
+
+	ids := p.twitterUserIDs()
+	if len(ids) != 3 || ids[0] != 80 || ids[1] != 81 || ids[2] != 82 {
+		t.Errorf("unexpected associated user IDs %v", ids)
+	}
+}
+
+func TestPost_IsReactionGIF(t *testing.T) {
+	tests := []struct {
+		media []Media
+		want  bool
+	}{
+		{[]Media{{Type: "video/gif"}}, true},
+		{[]Media{{Type: "animated_gif"}}, true},
+		{[]Media{{Type: "image/jpeg"}}, false},
+		{[]Media{{Type: "animated_gif"}, {Type: "photo"}}, false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		p := &Post{Media: tt.media}
+		if got := p.IsReactionGIF(); got != tt.want {
+			t.Errorf("IsReactionGIF(%v) = %v, want %v", tt.media, got, tt.want)
+		}
+	}
+}
+
+func TestIgnorer_PostsFromOtherPlatforms(t *testing.T) {
+	matcher := NewStarshipMatcherForTests()
+
+	// Numeric IDs from other platforms must not be confused with Twitter user IDs
+	var p = &Post{
+		Platform: "mastodon",
+		Author:   Author{ID: "1983513", Handle: "someone@social.example"},
+	}
+	if matcher.IsOrMentionsIgnoredAccount(p) {
+		t.Errorf("expected Mastodon account not to be ignored")
+	}
+
+	p.Platform = PlatformTwitter
+	if !matcher.IsOrMentionsIgnoredAccount(p) {
+		t.Errorf("expected Twitter account on the ignored list to be ignored")
+	}
+}
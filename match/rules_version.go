@@ -0,0 +1,35 @@
+package match
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// builtinRulesVersion is a hash over all keywords, regexes and account lists defined in code
+var builtinRulesVersion = hashRules(
+	starshipKeywords, starshipMediaKeywords, starshipMatchers, moreSpecificKeywords,
+	locationKeywords, specificUserMatchers, userAntikeywordsOverwrite, hqMediaAccounts,
+	veryImportantAccounts, ignoredAccountDescriptionKeywords, antiKeywordRegexes,
+	antiStarshipKeywords, moreSpecificAntiKeywords, padMappings, starshipRelatedWhenElonReplies,
+)
+
+func hashRules(rules ...interface{}) string {
+	h := sha256.New()
+	for _, r := range rules {
+		// fmt prints maps sorted by key and regexes as their pattern, so this is deterministic
+		fmt.Fprintf(h, "%v\n", r)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:12]
+}
+
+// RulesVersion returns a short identifier for the rules this matcher uses.
+// It changes whenever keywords, regexes or account lists are changed
+func (m *StarshipMatcher) RulesVersion() string {
+	r := m.rules
+	if len(r.Keywords) == 0 && len(r.AntiKeywords) == 0 && len(r.RemoveKeywords) == 0 && len(r.RemoveAntiKeywords) == 0 {
+		return builtinRulesVersion
+	}
+
+	return builtinRulesVersion + "+" + hashRules(r.Keywords, r.AntiKeywords, r.RemoveKeywords, r.RemoveAntiKeywords)
+}
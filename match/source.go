@@ -3,6 +3,7 @@ package match
 import (
 	"fmt"
 	"log"
+	"strings"
 
 	"github.com/dghubble/go-twitter/twitter"
 	"github.com/xarantolus/spacex-hop-bot/util"
@@ -19,6 +20,14 @@ const (
 	TweetSourceKnownList
 	TweetSourceTimeline
 	TweetSourceTrustedUser
+
+	// Posts from other platforms, see the ingest package
+	TweetSourceMastodon
+	TweetSourceBluesky
+	TweetSourceFeed
+
+	// Tweets found by keyword searches, they can be from any account
+	TweetSourceSearch
 )
 
 type TweetWrapper struct {
@@ -26,16 +35,40 @@ type TweetWrapper struct {
 	twitter.Tweet
 
 	EnableLogging bool
+
+	// Explanation records every step that is logged for this tweet, if it is set
+	Explanation *Explanation
+}
+
+// Explanation collects the steps the matcher and processor took to reach a decision about a tweet
+type Explanation struct {
+	Steps []string `json:"steps"`
+}
+
+func (e *Explanation) String() string {
+	if e == nil {
+		return ""
+	}
+	return strings.Join(e.Steps, "; ")
 }
 
 func (t *TweetWrapper) Log(format string, a ...interface{}) {
+	if !t.EnableLogging && t.Explanation == nil {
+		return
+	}
+
+	msg := fmt.Sprintf(format, a...)
+
+	if t.Explanation != nil {
+		t.Explanation.Steps = append(t.Explanation.Steps, msg)
+	}
 	if t.EnableLogging {
-		log.Printf("[Processor] %s (%s): %s", util.TweetURL(&t.Tweet), t.TweetSource.String(), fmt.Sprintf(format, a...))
+		log.Printf("[Processor] %s (%s): %s", util.TweetURL(&t.Tweet), t.TweetSource.String(), msg)
 	}
 }
 
 func (t *TweetWrapper) Wrap(tweet *twitter.Tweet) TweetWrapper {
-	return TweetWrapper{TweetSource: t.TweetSource, Tweet: *tweet, EnableLogging: t.EnableLogging}
+	return TweetWrapper{TweetSource: t.TweetSource, Tweet: *tweet, EnableLogging: t.EnableLogging, Explanation: t.Explanation}
 }
 
 func Wrap(tweet *twitter.Tweet) TweetWrapper {
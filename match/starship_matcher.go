@@ -1,15 +1,123 @@
 package match
 
-import "github.com/xarantolus/spacex-hop-bot/bot"
+import (
+	"os"
+	"strings"
+	"time"
+
+	"github.com/xarantolus/spacex-hop-bot/bot"
+	"gopkg.in/yaml.v3"
+)
 
 type StarshipMatcher struct {
 	*Ignorer
+
+	// rules are additional keywords on top of the ones defined in code
+	rules MatcherRules
+
+	// keywords replaces starshipKeywords if rules remove some of them
+	keywords []string
+	// removedAntiKeywords are built-in anti keywords that are no longer matched
+	removedAntiKeywords map[string]bool
+
+	// now returns the current time, it is only set when replaying decisions
+	now func() time.Time
+}
+
+// MatcherRules are keywords that are used in addition to the built-in ones, or built-in keywords that are removed.
+// They are mostly used for trying out new keywords before adding them to the code.
+// A built-in keyword can be replaced by removing it and adding the new one
+type MatcherRules struct {
+	// Keywords are matched like starshipKeywords
+	Keywords []string `yaml:"keywords"`
+	// AntiKeywords are matched like antiStarshipKeywords
+	AntiKeywords []string `yaml:"anti_keywords"`
+
+	// RemoveKeywords are removed from starshipKeywords
+	RemoveKeywords []string `yaml:"remove_keywords"`
+	// RemoveAntiKeywords are removed from antiStarshipKeywords and the anti keywords of specific users
+	RemoveAntiKeywords []string `yaml:"remove_anti_keywords"`
+}
+
+// LoadMatcherRules loads matcher rules from the given YAML file
+func LoadMatcherRules(filename string) (r MatcherRules, err error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	err = yaml.NewDecoder(f).Decode(&r)
+	if err != nil {
+		return
+	}
+
+	// The matcher works on lowercase text
+	for _, words := range []*[]string{&r.Keywords, &r.AntiKeywords, &r.RemoveKeywords, &r.RemoveAntiKeywords} {
+		for i, k := range *words {
+			(*words)[i] = strings.ToLower(k)
+		}
+		*words = ignoreSpaces(*words)
+	}
+
+	return
 }
 
 func NewStarshipMatcher(ignoredUsers *Ignorer) *StarshipMatcher {
 	return &StarshipMatcher{
-		ignoredUsers,
+		Ignorer: ignoredUsers,
+	}
+}
+
+// NewCandidateStarshipMatcher returns a matcher that applies the given rules to the built-in keywords
+func NewCandidateStarshipMatcher(ignoredUsers *Ignorer, rules MatcherRules) *StarshipMatcher {
+	m := &StarshipMatcher{
+		Ignorer: ignoredUsers,
+		rules:   rules,
+	}
+
+	if len(rules.RemoveKeywords) > 0 {
+		var removed = toSet(rules.RemoveKeywords)
+		for _, k := range starshipKeywords {
+			if !removed[k] {
+				m.keywords = append(m.keywords, k)
+			}
+		}
+	}
+	if len(rules.RemoveAntiKeywords) > 0 {
+		m.removedAntiKeywords = toSet(rules.RemoveAntiKeywords)
+	}
+
+	return m
+}
+
+// starshipKeywords returns the built-in keywords without those removed by the rules
+func (m *StarshipMatcher) starshipKeywords() []string {
+	if m.keywords != nil {
+		return m.keywords
+	}
+	return starshipKeywords
+}
+
+// SetClock changes what the matcher considers the current time, e.g. when deciding whether a post is too old.
+// It is used to replay decisions that were made in the past
+func (m *StarshipMatcher) SetClock(now func() time.Time) {
+	m.now = now
+}
+
+func (m *StarshipMatcher) time() time.Time {
+	if m.now != nil {
+		return m.now()
+	}
+	return time.Now()
+}
+
+func toSet(words []string) map[string]bool {
+	var set = make(map[string]bool, len(words))
+	for _, w := range words {
+		set[w] = true
 	}
+	return set
 }
 
 var TestIgnoredUserID int64 = 1983513
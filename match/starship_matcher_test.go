@@ -0,0 +1,66 @@
+package match
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadMatcherRules(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "rules.yaml")
+	err := os.WriteFile(fn, []byte("keywords:\n  - Chop Sticks\nanti_keywords:\n  - NFT\nremove_keywords:\n  - Star Factory\nremove_anti_keywords:\n  - Falcon\n"), 0644)
+	if err != nil {
+		t.Fatalf("writing rules file: %s", err.Error())
+	}
+
+	rules, err := LoadMatcherRules(fn)
+	if err != nil {
+		t.Fatalf("LoadMatcherRules: %s", err.Error())
+	}
+
+	want := MatcherRules{
+		Keywords:     []string{"chop sticks", "chopsticks", "chop-sticks", "chop_sticks"},
+		AntiKeywords: []string{"nft"},
+
+		RemoveKeywords:     []string{"star factory", "starfactory", "star-factory", "star_factory"},
+		RemoveAntiKeywords: []string{"falcon"},
+	}
+	if !reflect.DeepEqual(rules, want) {
+		t.Errorf("LoadMatcherRules() = %+v, want %+v", rules, want)
+	}
+}
+
+func TestCandidateStarshipMatcher(t *testing.T) {
+	live := NewStarshipMatcherForTests()
+	candidate := NewCandidateStarshipMatcher(live.Ignorer, MatcherRules{
+		Keywords:     []string{"mechazilla", "mega factory"},
+		AntiKeywords: []string{"papercraft"},
+
+		// Replaces "star factory" with "mega factory"
+		RemoveKeywords:     ignoreSpaces([]string{"star factory"}),
+		RemoveAntiKeywords: []string{"falcon"},
+	})
+
+	tests := []struct {
+		text                string
+		wantLive, wantCandi bool
+	}{
+		{"mechazilla is moving", false, true},
+		{"My papercraft starship is done", true, false},
+		{"Starship stacked", true, true},
+		{"The star factory is growing", true, false},
+		{"The mega factory is growing", false, true},
+		{"Falcon 9 and Starship side by side", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(t.Name(), func(t *testing.T) {
+			if got := live.StarshipText(tt.text, antiStarshipKeywords, false); got != tt.wantLive {
+				t.Errorf("live.StarshipText(%q) = %v, want %v", tt.text, got, tt.wantLive)
+			}
+			if got := candidate.StarshipText(tt.text, antiStarshipKeywords, false); got != tt.wantCandi {
+				t.Errorf("candidate.StarshipText(%q) = %v, want %v", tt.text, got, tt.wantCandi)
+			}
+		})
+	}
+}
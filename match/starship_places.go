@@ -1,7 +1,5 @@
 package match
 
-import "github.com/dghubble/go-twitter/twitter"
-
 const (
 	// TODO: find IDs for "Mesa del Gavilan", Stargate and generally places around/between the site.
 	// The data seems to come from foursquare, but the IDs are *not* the same on both services
@@ -35,17 +33,17 @@ const (
 	// "Cape Canaveral, FL": https://twitter.com/places/1739d72c18edbb1e
 )
 
-// IsAtSpaceXSite returns whether the tweet is tagged with a location that is used by SpaceX
-func IsAtSpaceXSite(tweet *twitter.Tweet) bool {
-	return tweet.Place != nil &&
-		(tweet.Place.ID == SpaceXMcGregorPlaceID || IsAtStarshipLocation(tweet))
+// IsAtSpaceXSite returns whether the post is tagged with a location that is used by SpaceX
+func IsAtSpaceXSite(post *Post) bool {
+	return post.Geo != nil &&
+		(post.Geo.PlaceID == SpaceXMcGregorPlaceID || IsAtStarshipLocation(post))
 }
 
-// IsAtStarshipLocation returns if the tweet is tagged with a location that is used *only* for the Starship program
-func IsAtStarshipLocation(tweet *twitter.Tweet) bool {
-	return tweet.Place != nil && (tweet.Place.ID == StarbasePlaceID ||
-		tweet.Place.ID == SpaceXLaunchSiteID ||
-		tweet.Place.ID == SpaceXBuildSiteID ||
-		tweet.Place.ID == BocaChicaPlaceID ||
-		tweet.Place.ID == BocaChicaBeachPlaceID)
+// IsAtStarshipLocation returns if the post is tagged with a location that is used *only* for the Starship program
+func IsAtStarshipLocation(post *Post) bool {
+	return post.Geo != nil && (post.Geo.PlaceID == StarbasePlaceID ||
+		post.Geo.PlaceID == SpaceXLaunchSiteID ||
+		post.Geo.PlaceID == SpaceXBuildSiteID ||
+		post.Geo.PlaceID == BocaChicaPlaceID ||
+		post.Geo.PlaceID == BocaChicaBeachPlaceID)
 }
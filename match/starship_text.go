@@ -9,12 +9,15 @@ func (m *StarshipMatcher) StarshipText(text string, antiKeywords []string, skipM
 	text = strings.ToLower(text)
 
 	// If we find ignored words, we ignore the tweet
-	if _, contains := containsAntikeyword(antiKeywords, text); contains {
+	if _, contains := m.containsAntikeyword(antiKeywords, text); contains {
 		return false
 	}
 
 	// else we check if there are any interesting keywords
-	if _, contains := startsWithAny(text, starshipKeywords...); contains {
+	if _, contains := startsWithAny(text, m.starshipKeywords()...); contains {
+		return true
+	}
+	if _, contains := startsWithAny(text, m.rules.Keywords...); contains {
 		return true
 	}
 
@@ -45,6 +48,26 @@ func ContainsStarshipAntiKeyword(text string) bool {
 	return contains
 }
 
+// containsAntikeyword is like the containsAntikeyword function, but also checks the additional anti keywords
+// of the matcher and skips anti keywords its rules removed
+func (m *StarshipMatcher) containsAntikeyword(antiKeywords []string, text string) (word string, contains bool) {
+	if word, contains = startsWithAny(text, m.rules.AntiKeywords...); contains {
+		return "(rules)" + word, true
+	}
+
+	if m.removedAntiKeywords != nil {
+		var remaining = make([]string, 0, len(antiKeywords))
+		for _, k := range antiKeywords {
+			if !m.removedAntiKeywords[k] {
+				remaining = append(remaining, k)
+			}
+		}
+		antiKeywords = remaining
+	}
+
+	return containsAntikeyword(antiKeywords, text)
+}
+
 func containsAntikeyword(antiKeywords []string, text string) (word string, contains bool) {
 	for _, antiRegex := range antiKeywordRegexes {
 		if antiRegex.MatchString(text) {
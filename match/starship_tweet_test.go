@@ -1,12 +1,11 @@
 package match
 
 import (
-	"fmt"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
 
-	"github.com/dghubble/go-twitter/twitter"
 	"mvdan.cc/xurls/v2"
 )
 
@@ -33,80 +32,50 @@ func testStarshipTweets(t *testing.T, tweets []ttest) {
 
 	var urlRegex = xurls.Strict()
 
-	var tweet = func(t ttest) TweetWrapper {
-		var tweetText string = t.text
-		shortURLCounter := 0
-		var tweetURLs []twitter.URLEntity
-		for _, url := range urlRegex.FindAllString(t.text, -1) {
-			var fakeShortURL = fmt.Sprintf("https://t.co/%d", shortURLCounter)
-			shortURLCounter++
-
-			tweetText = strings.ReplaceAll(tweetText, url, fakeShortURL)
-
-			tweetURLs = append(tweetURLs, twitter.URLEntity{
-				DisplayURL:  url,
-				ExpandedURL: url,
-				URL:         fakeShortURL,
-			})
-		}
-
-		var tw = TweetWrapper{
-			Tweet: twitter.Tweet{
-				CreatedAt: t.date,
-				User: &twitter.User{
-					ScreenName:  t.acc,
-					ID:          t.userID,
-					Description: t.userDescription,
-				},
-				FullText: tweetText,
+	var post = func(t ttest) *Post {
+		var p = &Post{
+			Platform: PlatformTwitter,
+			Author: Author{
+				ID:          strconv.FormatInt(t.userID, 10),
+				Handle:      t.acc,
+				Description: t.userDescription,
 			},
+			Text:         strings.TrimSpace(urlRegex.ReplaceAllString(t.text, "")),
+			TextWithURLs: t.text,
+			URLs:         urlRegex.FindAllString(t.text, -1),
+			// Set a recent date, aka now (the bot usually sees very recent tweets)
+			Created: time.Now().Add(-time.Minute),
 		}
 
-		// Set a recent date, aka now (the bot usually sees very recent tweets)
-		if tw.CreatedAt == "" {
-			tw.CreatedAt = time.Now().Add(-time.Minute).Format(time.RubyDate)
+		if t.date != "" {
+			created, err := time.Parse(time.RubyDate, t.date)
+			if err != nil {
+				panic("invalid date in test: " + err.Error())
+			}
+			p.Created = created
 		}
 
-		if tw.User.ScreenName == "" {
-			tw.User = &twitter.User{
-				ScreenName:  "default_name",
-				Description: t.userDescription,
-				ID:          t.userID,
-			}
+		if p.Author.Handle == "" {
+			p.Author.Handle = "default_name"
 		}
 
 		if t.location != "" {
-			tw.Place = &twitter.Place{
-				ID: t.location,
+			p.Geo = &Geo{
+				PlaceID: t.location,
 			}
 		}
 
 		// Just add a dummy photo
 		if t.hasMedia {
-			tw.Entities = &twitter.Entities{
-				Media: []twitter.MediaEntity{
-					{
-						ID: 1024,
-					},
-				},
-			}
-		}
-		if len(tweetURLs) > 0 {
-			if tw.Entities == nil {
-				tw.Entities = &twitter.Entities{
-					Urls: tweetURLs,
-				}
-			} else {
-				tw.Entities.Urls = tweetURLs
-			}
+			p.Media = []Media{{Type: "photo"}}
 		}
 
-		return tw
+		return p
 	}
 
 	for _, tt := range tweets {
 		t.Run(t.Name(), func(t *testing.T) {
-			if got := matcher.StarshipTweet(tweet(tt)); got != tt.want {
+			if got := matcher.StarshipPost(post(tt)); got != tt.want {
 				t.Errorf("StarshipTweet(%q by %q) = %v, want %v", tt.text, tt.acc, got, tt.want)
 			}
 		})
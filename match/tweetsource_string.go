@@ -13,11 +13,15 @@ func _() {
 	_ = x[TweetSourceKnownList-2]
 	_ = x[TweetSourceTimeline-3]
 	_ = x[TweetSourceTrustedUser-4]
+	_ = x[TweetSourceMastodon-5]
+	_ = x[TweetSourceBluesky-6]
+	_ = x[TweetSourceFeed-7]
+	_ = x[TweetSourceSearch-8]
 }
 
-const _TweetSource_name = "TweetSourceUnknownTweetSourceLocationStreamTweetSourceKnownListTweetSourceTimelineTweetSourceTrustedUser"
+const _TweetSource_name = "TweetSourceUnknownTweetSourceLocationStreamTweetSourceKnownListTweetSourceTimelineTweetSourceTrustedUserTweetSourceMastodonTweetSourceBlueskyTweetSourceFeedTweetSourceSearch"
 
-var _TweetSource_index = [...]uint8{0, 18, 43, 63, 82, 104}
+var _TweetSource_index = [...]uint8{0, 18, 43, 63, 82, 104, 123, 141, 156, 173}
 
 func (i TweetSource) String() string {
 	if i < 0 || i >= TweetSource(len(_TweetSource_index)-1) {
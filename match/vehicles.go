@@ -0,0 +1,74 @@
+package match
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	// shipNameRegex is like the Starship matcher, but also matches names like "Ship-24"
+	shipNameRegex = regexp.MustCompile(`\b((s\d{2}\b)|(ship[\s-]?\d{2}\b)|(sn-?|starship|starship number)\s?\d['’]?s?)`)
+	// boosterNameRegex is like the Booster matcher, but also matches names like "Booster-9"
+	boosterNameRegex = regexp.MustCompile(`(((?:#|\s|^)b\d{1,2}\b([^-]|$))|\b(bn|booster|booster number)(['’]|s)*\s?\d{1,3}['’]?s?\b|\bbooster-\d{1,3}['’]?s?\b)`)
+)
+
+// VehicleNames extracts the names of Starship and Super Heavy vehicles mentioned in text.
+// Names are normalized, e.g. "Ship 24" and "SN24" both become "S24" and "Booster 7" becomes "B7".
+// Every vehicle is only returned once, in the order it is first mentioned.
+// Its patterns are based on the matchers that decide whether a tweet is about Starship, so times or temperatures
+// like "it's 3 am" are not mistaken for vehicles. They are separate, so names only used for generated
+// text don't change which tweets are matched
+func VehicleNames(text string) (names []string) {
+	text = strings.ToLower(text)
+
+	type mention struct {
+		index int
+		name  string
+	}
+	var mentions []mention
+
+	add := func(prefix string, matcher *regexp.Regexp) {
+		for _, loc := range matcher.FindAllStringIndex(text, -1) {
+			name := prefix + strings.TrimLeft(vehicleNumber(text, loc[0], loc[1]), "0")
+			if name == prefix {
+				continue
+			}
+			mentions = append(mentions, mention{index: loc[0], name: name})
+		}
+	}
+
+	add("S", shipNameRegex)
+	add("B", boosterNameRegex)
+
+	sort.SliceStable(mentions, func(i, j int) bool {
+		return mentions[i].index < mentions[j].index
+	})
+
+	var seen = map[string]bool{}
+	for _, m := range mentions {
+		if seen[m.name] {
+			continue
+		}
+		seen[m.name] = true
+		names = append(names, m.name)
+	}
+
+	return
+}
+
+// vehicleNumber returns the first number in text[start:end]. Some matchers only match the first digit
+// of a number, so the number may continue after end
+func vehicleNumber(text string, start, end int) string {
+	var i = strings.IndexAny(text[start:end], "0123456789")
+	if i < 0 {
+		return ""
+	}
+	i += start
+
+	var j = i
+	for j < len(text) && text[j] >= '0' && text[j] <= '9' {
+		j++
+	}
+	return text[i:j]
+}
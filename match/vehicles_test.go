@@ -0,0 +1,35 @@
+package match
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestVehicleNames(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"", nil},
+		{"Nothing to see here", nil},
+		{"S24 and B7 on the pad", []string{"S24", "B7"}},
+		{"Ship 24 rolling out, SN24 again", []string{"S24"}},
+		{"Booster 9 static fire", []string{"B9"}},
+		{"Starship 25 stacked on Booster-9", []string{"S25", "B9"}},
+		{"Starship 25 stacked on Booster 9", []string{"S25", "B9"}},
+		{"B7 and S24", []string{"B7", "S24"}},
+		{"Booster 7 lifted onto the OLM, Ship-24 next, then B7 again", []string{"B7", "S24"}},
+		{"SN15 and BN3", []string{"S15", "B3"}},
+		{"B0 is not a booster", nil},
+		{"It's 3 am and S24 is rolling", []string{"S24"}},
+		{"it's 20 degrees", nil},
+		{"Doors open at 5 pm, 2 buses", nil},
+	}
+	for _, tt := range tests {
+		t.Run(t.Name(), func(t *testing.T) {
+			if got := VehicleNames(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("VehicleNames(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
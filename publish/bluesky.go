@@ -0,0 +1,156 @@
+package publish
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"mvdan.cc/xurls/v2"
+)
+
+const (
+	// PlatformBluesky is the Platform of posts that come from Bluesky
+	PlatformBluesky = "bluesky"
+
+	blueskyMaxLength = 300
+)
+
+var blueskyURLRegex = xurls.Strict()
+
+// Bluesky publishes posts to a Bluesky account using the AT Protocol. Posts that come from Bluesky are reposted,
+// everything else is posted as a new post with a link to the original
+type Bluesky struct {
+	service    string
+	identifier string
+	password   string
+
+	client *http.Client
+
+	mu      sync.Mutex
+	session *blueskySession
+}
+
+type blueskySession struct {
+	AccessJwt string `json:"accessJwt"`
+	DID       string `json:"did"`
+}
+
+// NewBluesky returns a publisher for the given account. service is the URL of the PDS, e.g. https://bsky.social.
+// password should be an app password
+func NewBluesky(service, identifier, password string) *Bluesky {
+	return &Bluesky{
+		service:    strings.TrimSuffix(service, "/"),
+		identifier: identifier,
+		password:   password,
+		client:     newHTTPClient(),
+	}
+}
+
+func (b *Bluesky) Name() string {
+	return "bluesky (" + b.identifier + ")"
+}
+
+func (b *Bluesky) Publish(ctx context.Context, p Post) (err error) {
+	record := b.record(p)
+
+	for attempt := 0; attempt < 2; attempt++ {
+		var s *blueskySession
+		s, err = b.login(ctx)
+		if err != nil {
+			return
+		}
+
+		err = postJSON(ctx, b.client, b.Name(), b.service+"/xrpc/com.atproto.repo.createRecord", map[string]string{
+			"Authorization": "Bearer " + s.AccessJwt,
+		}, map[string]interface{}{
+			"repo":       s.DID,
+			"collection": record["$type"],
+			"record":     record,
+		}, nil)
+
+		// Access tokens expire after a few hours, then we log in again
+		var serr *StatusError
+		if errors.As(err, &serr) && (serr.StatusCode == http.StatusUnauthorized ||
+			(serr.StatusCode == http.StatusBadRequest && strings.Contains(serr.Body, "ExpiredToken"))) {
+			b.mu.Lock()
+			b.session = nil
+			b.mu.Unlock()
+			continue
+		}
+
+		return
+	}
+
+	return
+}
+
+// login returns the current session or creates a new one
+func (b *Bluesky) login(ctx context.Context) (s *blueskySession, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.session != nil {
+		return b.session, nil
+	}
+
+	s = new(blueskySession)
+	err = postJSON(ctx, b.client, b.Name(), b.service+"/xrpc/com.atproto.server.createSession", nil, map[string]string{
+		"identifier": b.identifier,
+		"password":   b.password,
+	}, s)
+	if err != nil {
+		return nil, err
+	}
+
+	b.session = s
+	return s, nil
+}
+
+// record returns the AT Protocol record for p
+func (b *Bluesky) record(p Post) map[string]interface{} {
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	if p.Kind == KindRepost && p.Platform == PlatformBluesky && p.ID != "" && p.CID != "" {
+		return map[string]interface{}{
+			"$type":     "app.bsky.feed.repost",
+			"createdAt": now,
+			"subject": map[string]string{
+				"uri": p.ID,
+				"cid": p.CID,
+			},
+		}
+	}
+
+	text := p.Format(blueskyMaxLength)
+
+	record := map[string]interface{}{
+		"$type":     "app.bsky.feed.post",
+		"text":      text,
+		"createdAt": now,
+	}
+
+	// Links are only clickable if they are marked with a facet
+	var facets []interface{}
+	for _, loc := range blueskyURLRegex.FindAllStringIndex(text, -1) {
+		facets = append(facets, map[string]interface{}{
+			"index": map[string]int{
+				"byteStart": loc[0],
+				"byteEnd":   loc[1],
+			},
+			"features": []interface{}{
+				map[string]string{
+					"$type": "app.bsky.richtext.facet#link",
+					"uri":   text[loc[0]:loc[1]],
+				},
+			},
+		})
+	}
+	if len(facets) > 0 {
+		record["facets"] = facets
+	}
+
+	return record
+}
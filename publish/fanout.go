@@ -0,0 +1,223 @@
+package publish
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Options configure how a Fanout handles failures of a backend
+type Options struct {
+	// QueueSize is the number of posts that can wait for a backend, posts are dropped if the queue is full
+	QueueSize int
+	// MaxAttempts is how often publishing a post is tried before giving up
+	MaxAttempts int
+	// Backoff is the time to wait before the first retry, it doubles on every further retry
+	Backoff time.Duration
+	// Timeout is the timeout of a single attempt
+	Timeout time.Duration
+
+	// After PauseAfter posts in a row failed, a backend is paused for PauseFor. Posts are dropped while it is paused
+	PauseAfter int
+	PauseFor   time.Duration
+}
+
+// DefaultOptions are used for all zero values of Options
+var DefaultOptions = Options{
+	QueueSize:   100,
+	MaxAttempts: 4,
+	Backoff:     5 * time.Second,
+	Timeout:     time.Minute,
+	PauseAfter:  5,
+	PauseFor:    30 * time.Minute,
+}
+
+// Stats are the stats of a single backend
+type Stats struct {
+	Published int       `json:"published"`
+	Failed    int       `json:"failed"`
+	Dropped   int       `json:"dropped"`
+	Retries   int       `json:"retries"`
+	Queued    int       `json:"queued"`
+	Paused    bool      `json:"paused"`
+	LastError string    `json:"last_error,omitempty"`
+	LastOK    time.Time `json:"last_ok,omitempty"`
+}
+
+// Fanout publishes posts to multiple backends. Every backend has its own queue and worker,
+// so a slow or failing backend does not hold up the others
+type Fanout struct {
+	backends []*backend
+	wg       sync.WaitGroup
+}
+
+type backend struct {
+	pub   Publisher
+	opts  Options
+	queue chan Post
+
+	mu                  sync.Mutex
+	stats               Stats
+	consecutiveFailures int
+	pausedUntil         time.Time
+}
+
+// NewFanout starts a worker for each publisher
+func NewFanout(opts Options, publishers ...Publisher) *Fanout {
+	opts = withDefaults(opts)
+
+	f := &Fanout{}
+	for _, p := range publishers {
+		b := &backend{
+			pub:   p,
+			opts:  opts,
+			queue: make(chan Post, opts.QueueSize),
+		}
+		f.backends = append(f.backends, b)
+
+		f.wg.Add(1)
+		go func() {
+			defer f.wg.Done()
+			b.run()
+		}()
+	}
+
+	return f
+}
+
+func withDefaults(opts Options) Options {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = DefaultOptions.QueueSize
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultOptions.MaxAttempts
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = DefaultOptions.Backoff
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultOptions.Timeout
+	}
+	if opts.PauseAfter <= 0 {
+		opts.PauseAfter = DefaultOptions.PauseAfter
+	}
+	if opts.PauseFor <= 0 {
+		opts.PauseFor = DefaultOptions.PauseFor
+	}
+	return opts
+}
+
+// Publish queues p for all backends. It never blocks
+func (f *Fanout) Publish(p Post) {
+	if p.Time.IsZero() {
+		p.Time = time.Now()
+	}
+
+	for _, b := range f.backends {
+		b.mu.Lock()
+		paused := time.Now().Before(b.pausedUntil)
+		b.mu.Unlock()
+
+		if paused {
+			b.drop("backend is paused")
+			continue
+		}
+
+		select {
+		case b.queue <- p:
+		default:
+			b.drop("queue is full")
+		}
+	}
+}
+
+// Close stops accepting posts and waits until all queued posts were handled
+func (f *Fanout) Close() {
+	for _, b := range f.backends {
+		close(b.queue)
+	}
+	f.wg.Wait()
+}
+
+// Stats returns the stats of all backends by name
+func (f *Fanout) Stats() map[string]Stats {
+	var out = make(map[string]Stats, len(f.backends))
+	for _, b := range f.backends {
+		b.mu.Lock()
+		s := b.stats
+		s.Queued = len(b.queue)
+		s.Paused = time.Now().Before(b.pausedUntil)
+		b.mu.Unlock()
+
+		out[b.pub.Name()] = s
+	}
+	return out
+}
+
+func (b *backend) drop(reason string) {
+	b.mu.Lock()
+	b.stats.Dropped++
+	b.mu.Unlock()
+
+	log.Printf("[Publish] Dropping post for %s: %s\n", b.pub.Name(), reason)
+}
+
+func (b *backend) run() {
+	for p := range b.queue {
+		b.mu.Lock()
+		paused := time.Now().Before(b.pausedUntil)
+		b.mu.Unlock()
+		if paused {
+			b.drop("backend is paused")
+			continue
+		}
+
+		err := b.publish(p)
+
+		b.mu.Lock()
+		if err == nil {
+			b.stats.Published++
+			b.stats.LastOK = time.Now()
+			b.consecutiveFailures = 0
+		} else {
+			b.stats.Failed++
+			b.stats.LastError = err.Error()
+			b.consecutiveFailures++
+			if b.consecutiveFailures >= b.opts.PauseAfter {
+				b.pausedUntil = time.Now().Add(b.opts.PauseFor)
+				b.consecutiveFailures = 0
+				log.Printf("[Publish] Pausing %s for %s after %d failures in a row\n", b.pub.Name(), b.opts.PauseFor, b.opts.PauseAfter)
+			}
+		}
+		b.mu.Unlock()
+
+		if err != nil {
+			log.Printf("[Publish] Publishing to %s failed: %s\n", b.pub.Name(), err.Error())
+		}
+	}
+}
+
+// publish tries to publish p, retrying temporary errors with exponential backoff
+func (b *backend) publish(p Post) (err error) {
+	backoff := b.opts.Backoff
+
+	for attempt := 1; attempt <= b.opts.MaxAttempts; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), b.opts.Timeout)
+		err = b.pub.Publish(ctx, p)
+		cancel()
+
+		if err == nil || !isTemporary(err) || attempt == b.opts.MaxAttempts {
+			return
+		}
+
+		b.mu.Lock()
+		b.stats.Retries++
+		b.mu.Unlock()
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+
+	return
+}
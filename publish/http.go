@@ -0,0 +1,82 @@
+package publish
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// StatusError is returned when a backend responds with an unexpected status code
+type StatusError struct {
+	Backend    string
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s responded with status %d: %s", e.Backend, e.StatusCode, e.Body)
+}
+
+// Temporary returns whether retrying the request might succeed
+func (e *StatusError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// isTemporary returns whether err is worth retrying. Errors that don't say otherwise,
+// e.g. network errors, are considered temporary
+func isTemporary(err error) bool {
+	var t interface{ Temporary() bool }
+	if errors.As(err, &t) {
+		return t.Temporary()
+	}
+	return true
+}
+
+func newHTTPClient() *http.Client {
+	return &http.Client{
+		Timeout: 30 * time.Second,
+	}
+}
+
+// postJSON sends body as JSON and decodes the response into out, if it is not nil
+func postJSON(ctx context.Context, client *http.Client, backend, url string, headers map[string]string, body, out interface{}) (err error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &StatusError{
+			Backend:    backend,
+			StatusCode: resp.StatusCode,
+			Body:       string(msg),
+		}
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(out)
+}
@@ -0,0 +1,76 @@
+package publish
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	// PlatformMastodon is the Platform of posts that come from Mastodon
+	PlatformMastodon = "mastodon"
+
+	mastodonMaxLength = 500
+)
+
+// Mastodon publishes posts to a Mastodon account. Posts that come from the same server are boosted,
+// as status IDs are only valid on the server they were loaded from.
+// Everything else is posted as a new status with a link to the original
+type Mastodon struct {
+	server      string
+	accessToken string
+	visibility  string
+
+	client *http.Client
+}
+
+// NewMastodon returns a publisher for the account the access token belongs to.
+// visibility is the visibility of new statuses, e.g. "public" or "unlisted"
+func NewMastodon(server, accessToken, visibility string) *Mastodon {
+	if visibility == "" {
+		visibility = "public"
+	}
+
+	return &Mastodon{
+		server:      strings.TrimSuffix(server, "/"),
+		accessToken: accessToken,
+		visibility:  visibility,
+		client:      newHTTPClient(),
+	}
+}
+
+func (m *Mastodon) Name() string {
+	return "mastodon (" + m.host() + ")"
+}
+
+func (m *Mastodon) host() string {
+	u, err := url.Parse(m.server)
+	if err != nil {
+		return m.server
+	}
+	return u.Host
+}
+
+func (m *Mastodon) Publish(ctx context.Context, p Post) error {
+	var headers = map[string]string{
+		"Authorization": "Bearer " + m.accessToken,
+	}
+
+	if p.Kind == KindRepost && p.Platform == PlatformMastodon && p.ID != "" && strings.EqualFold(p.Server, m.host()) {
+		return postJSON(ctx, m.client, m.Name(), m.server+"/api/v1/statuses/"+url.PathEscape(p.ID)+"/reblog", headers, struct{}{}, nil)
+	}
+
+	text := p.Format(mastodonMaxLength)
+
+	// If a request is retried after the status was already created, Mastodon returns the existing status
+	key := sha256.Sum256([]byte(string(p.Kind) + "\n" + p.URL + "\n" + text))
+	headers["Idempotency-Key"] = hex.EncodeToString(key[:])
+
+	return postJSON(ctx, m.client, m.Name(), m.server+"/api/v1/statuses", headers, map[string]string{
+		"status":     text,
+		"visibility": m.visibility,
+	}, nil)
+}
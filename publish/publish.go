@@ -0,0 +1,103 @@
+// Package publish shares what the bot posts on Twitter on other platforms, e.g. Mastodon, Bluesky or webhooks
+package publish
+
+import (
+	"context"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Kind is the kind of a post
+type Kind string
+
+const (
+	// KindRepost is a post by someone else that we retweeted or quoted
+	KindRepost Kind = "repost"
+	// KindStatus is a post written by the bot, e.g. about a YouTube live stream
+	KindStatus Kind = "status"
+)
+
+// Post is something that should be published
+type Post struct {
+	Kind Kind `json:"kind"`
+
+	// Text is the text of the post. For reposts, it is the text of the original post
+	Text string `json:"text"`
+	// URL links to the original post of a repost
+	URL string `json:"url,omitempty"`
+	// Author is the screen name of the author of a repost
+	Author string `json:"author,omitempty"`
+
+	// Platform and ID identify the original post if it is not from Twitter. Backends on the same platform
+	// can then share it natively, e.g. as a boost on Mastodon. CID is the content ID of Bluesky posts,
+	// Server is the host of the Mastodon server the ID belongs to
+	Platform string `json:"platform,omitempty"`
+	ID       string `json:"id,omitempty"`
+	CID      string `json:"cid,omitempty"`
+	Server   string `json:"server,omitempty"`
+
+	Time time.Time `json:"time"`
+}
+
+// Publisher is a backend posts are published to
+type Publisher interface {
+	// Name identifies the backend in logs and stats
+	Name() string
+
+	// Publish publishes a post. Errors that are not worth retrying should not be Temporary
+	Publish(ctx context.Context, p Post) error
+}
+
+// Format returns the text of the post, shortened so it has at most maxLen characters.
+// The URL of a repost is never shortened. The author of a repost is attributed in plain text, an
+// @mention would notify whatever account has the same name on the platform we publish to
+func (p *Post) Format(maxLen int) string {
+	if p.Kind != KindRepost {
+		return truncate(p.Text, maxLen)
+	}
+
+	var prefix string
+	if p.Author != "" {
+		prefix = "By " + p.Author + " on " + p.platformName() + ": "
+	}
+
+	var suffix string
+	if p.URL != "" && !strings.Contains(p.Text, p.URL) {
+		suffix = "\n\n" + p.URL
+	}
+
+	remaining := maxLen - utf8.RuneCountInString(prefix) - utf8.RuneCountInString(suffix)
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	return prefix + truncate(p.Text, remaining) + suffix
+}
+
+// platformName returns the name of the platform the original post is from
+func (p *Post) platformName() string {
+	switch p.Platform {
+	case "":
+		return "Twitter"
+	case PlatformMastodon:
+		return "Mastodon"
+	case PlatformBluesky:
+		return "Bluesky"
+	default:
+		return p.Platform
+	}
+}
+
+// truncate shortens s to at most maxLen characters, adding an ellipsis if it was shortened
+func truncate(s string, maxLen int) string {
+	if utf8.RuneCountInString(s) <= maxLen {
+		return s
+	}
+	if maxLen <= 0 {
+		return ""
+	}
+
+	runes := []rune(s)
+	return strings.TrimSpace(string(runes[:maxLen-1])) + "…"
+}
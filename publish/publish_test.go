@@ -0,0 +1,260 @@
+package publish
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPostFormat(t *testing.T) {
+	var repost = Post{
+		Kind:   KindRepost,
+		Text:   "Starship SN15 is rolling out to the launch pad right now, this is a really long text",
+		URL:    "https://twitter.com/user/status/1",
+		Author: "user",
+	}
+
+	if got := repost.Format(500); got != "By user on Twitter: "+repost.Text+"\n\nhttps://twitter.com/user/status/1" {
+		t.Errorf("unexpected repost text %q", got)
+	}
+
+	got := repost.Format(60)
+	if !strings.HasSuffix(got, "…\n\nhttps://twitter.com/user/status/1") || len([]rune(got)) > 60 {
+		t.Errorf("expected shortened text to keep the URL, but got %q", got)
+	}
+
+	repost.Platform = PlatformMastodon
+	if got := repost.Format(500); !strings.HasPrefix(got, "By user on Mastodon: ") {
+		t.Errorf("unexpected attribution of a Mastodon repost %q", got)
+	}
+
+	var status = Post{Kind: KindStatus, Text: "SpaceX is live on YouTube"}
+	if got := status.Format(500); got != status.Text {
+		t.Errorf("unexpected status text %q", got)
+	}
+}
+
+// recorder is a stand-in server that records requests
+type recorder struct {
+	mu       sync.Mutex
+	requests []recordedRequest
+}
+
+type recordedRequest struct {
+	Path   string
+	Header http.Header
+	Body   map[string]interface{}
+	Raw    []byte
+}
+
+func (rec *recorder) record(r *http.Request) recordedRequest {
+	raw, _ := io.ReadAll(r.Body)
+	req := recordedRequest{Path: r.URL.Path, Header: r.Header, Raw: raw}
+	_ = json.Unmarshal(raw, &req.Body)
+
+	rec.mu.Lock()
+	rec.requests = append(rec.requests, req)
+	rec.mu.Unlock()
+	return req
+}
+
+func TestMastodon(t *testing.T) {
+	var rec recorder
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := rec.record(r)
+		if req.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"id": "1"}`))
+	}))
+	defer srv.Close()
+
+	m := NewMastodon(srv.URL+"/", "token", "")
+
+	err := m.Publish(context.Background(), Post{Kind: KindRepost, Text: "Hello", URL: "https://twitter.com/a/status/1", Author: "a"})
+	if err != nil {
+		t.Fatalf("publishing link post: %s", err.Error())
+	}
+	err = m.Publish(context.Background(), Post{Kind: KindRepost, Platform: PlatformMastodon, ID: "123", Server: strings.TrimPrefix(srv.URL, "http://")})
+	if err != nil {
+		t.Fatalf("boosting: %s", err.Error())
+	}
+	// Status IDs from other servers can't be boosted
+	err = m.Publish(context.Background(), Post{Kind: KindRepost, Platform: PlatformMastodon, ID: "456", Server: "example.com", Text: "Other", URL: "https://example.com/@b/456", Author: "b@example.com"})
+	if err != nil {
+		t.Fatalf("publishing status from other server: %s", err.Error())
+	}
+
+	if len(rec.requests) != 3 {
+		t.Fatalf("expected 3 requests, got %d", len(rec.requests))
+	}
+
+	status := rec.requests[0]
+	if status.Path != "/api/v1/statuses" || status.Body["status"] != "By a on Twitter: Hello\n\nhttps://twitter.com/a/status/1" ||
+		status.Body["visibility"] != "public" || status.Header.Get("Idempotency-Key") == "" {
+		t.Errorf("unexpected status request %+v", status)
+	}
+	if rec.requests[1].Path != "/api/v1/statuses/123/reblog" {
+		t.Errorf("expected boost, but got request to %s", rec.requests[1].Path)
+	}
+	if rec.requests[2].Path != "/api/v1/statuses" {
+		t.Errorf("expected link post, but got request to %s", rec.requests[2].Path)
+	}
+}
+
+func TestBlueskySessionRefresh(t *testing.T) {
+	var (
+		rec      recorder
+		sessions int32
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := rec.record(r)
+
+		switch req.Path {
+		case "/xrpc/com.atproto.server.createSession":
+			n := atomic.AddInt32(&sessions, 1)
+			_ = json.NewEncoder(w).Encode(map[string]string{
+				"accessJwt": "jwt" + string(rune('0'+n)),
+				"did":       "did:plc:test",
+			})
+		case "/xrpc/com.atproto.repo.createRecord":
+			// The first token has expired
+			if req.Header.Get("Authorization") == "Bearer jwt1" {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"error": "ExpiredToken"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"uri": "at://x", "cid": "y"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	b := NewBluesky(srv.URL, "bot.bsky.social", "app-password")
+
+	err := b.Publish(context.Background(), Post{Kind: KindStatus, Text: "Live now: https://youtube.com/watch?v=abc"})
+	if err != nil {
+		t.Fatalf("publishing: %s", err.Error())
+	}
+	if sessions != 2 {
+		t.Errorf("expected to log in again after the token expired, but got %d sessions", sessions)
+	}
+
+	last := rec.requests[len(rec.requests)-1]
+	if last.Body["repo"] != "did:plc:test" || last.Body["collection"] != "app.bsky.feed.post" {
+		t.Errorf("unexpected record request %s", last.Raw)
+	}
+	record := last.Body["record"].(map[string]interface{})
+	facets, _ := record["facets"].([]interface{})
+	if len(facets) != 1 {
+		t.Errorf("expected link facet, got %s", last.Raw)
+	}
+
+	// Posts from Bluesky are reposted
+	err = b.Publish(context.Background(), Post{Kind: KindRepost, Platform: PlatformBluesky, ID: "at://did:plc:other/app.bsky.feed.post/1", CID: "cid"})
+	if err != nil {
+		t.Fatalf("reposting: %s", err.Error())
+	}
+	last = rec.requests[len(rec.requests)-1]
+	if last.Body["collection"] != "app.bsky.feed.repost" {
+		t.Errorf("expected repost record, got %s", last.Raw)
+	}
+}
+
+func TestWebhookSignature(t *testing.T) {
+	var rec recorder
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec.record(r)
+	}))
+	defer srv.Close()
+
+	err := NewWebhook(srv.URL, "secret").Publish(context.Background(), Post{Kind: KindStatus, Text: "Hi"})
+	if err != nil {
+		t.Fatalf("publishing: %s", err.Error())
+	}
+
+	req := rec.requests[0]
+	if req.Header.Get("X-Signature-256") != "sha256="+Sign("secret", req.Raw) {
+		t.Errorf("signature doesn't match body")
+	}
+	if req.Body["text"] != "Hi" || req.Body["kind"] != "status" {
+		t.Errorf("unexpected body %s", req.Raw)
+	}
+}
+
+// testPublisher returns the errors in errs one after another, then succeeds
+type testPublisher struct {
+	name string
+
+	mu    sync.Mutex
+	errs  []error
+	calls int
+}
+
+func (p *testPublisher) Name() string { return p.name }
+
+func (p *testPublisher) Publish(ctx context.Context, post Post) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.calls++
+	if len(p.errs) > 0 {
+		err := p.errs[0]
+		p.errs = p.errs[1:]
+		return err
+	}
+	return nil
+}
+
+func TestFanoutFailureHandling(t *testing.T) {
+	var (
+		ok        = &testPublisher{name: "ok"}
+		flaky     = &testPublisher{name: "flaky", errs: []error{&StatusError{StatusCode: 503}, errors.New("connection reset")}}
+		permanent = &testPublisher{name: "permanent", errs: []error{&StatusError{StatusCode: 422}}}
+		broken    = &testPublisher{name: "broken", errs: []error{&StatusError{StatusCode: 403}, &StatusError{StatusCode: 403}}}
+	)
+
+	f := NewFanout(Options{
+		MaxAttempts: 3,
+		Backoff:     time.Millisecond,
+		PauseAfter:  2,
+		PauseFor:    time.Hour,
+	}, ok, flaky, permanent, broken)
+
+	f.Publish(Post{Kind: KindStatus, Text: "first"})
+	f.Publish(Post{Kind: KindStatus, Text: "second"})
+
+	// Wait until broken has been paused, posts after that are dropped
+	for !f.Stats()["broken"].Paused {
+		time.Sleep(time.Millisecond)
+	}
+	f.Publish(Post{Kind: KindStatus, Text: "third"})
+	f.Close()
+
+	stats := f.Stats()
+
+	if s := stats["ok"]; s.Published != 3 || s.Failed != 0 {
+		t.Errorf("unexpected stats for ok backend: %+v", s)
+	}
+	// Temporary errors are retried
+	if s := stats["flaky"]; s.Published != 3 || s.Retries != 2 || flaky.calls != 5 {
+		t.Errorf("unexpected stats for flaky backend: %+v, %d calls", s, flaky.calls)
+	}
+	// Permanent errors are not retried
+	if s := stats["permanent"]; s.Published != 2 || s.Failed != 1 || s.Retries != 0 || permanent.calls != 3 {
+		t.Errorf("unexpected stats for permanent backend: %+v, %d calls", s, permanent.calls)
+	}
+	if s := stats["broken"]; s.Failed != 2 || s.Dropped != 1 || !s.Paused || broken.calls != 2 {
+		t.Errorf("unexpected stats for broken backend: %+v, %d calls", s, broken.calls)
+	}
+}
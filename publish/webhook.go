@@ -0,0 +1,79 @@
+package publish
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// Webhook sends every post as JSON to a URL. If a secret is set, the body is signed with HMAC-SHA256
+// and the signature is sent in the X-Signature-256 header as "sha256=<hex>"
+type Webhook struct {
+	url    string
+	secret string
+
+	client *http.Client
+}
+
+// NewWebhook returns a publisher that posts to the given URL
+func NewWebhook(url, secret string) *Webhook {
+	return &Webhook{
+		url:    url,
+		secret: secret,
+		client: newHTTPClient(),
+	}
+}
+
+func (w *Webhook) Name() string {
+	u, err := url.Parse(w.url)
+	if err != nil {
+		return "webhook"
+	}
+	return "webhook (" + u.Host + ")"
+}
+
+func (w *Webhook) Publish(ctx context.Context, p Post) (err error) {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(data))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.secret != "" {
+		req.Header.Set("X-Signature-256", "sha256="+Sign(w.secret, data))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &StatusError{
+			Backend:    w.Name(),
+			StatusCode: resp.StatusCode,
+			Body:       string(msg),
+		}
+	}
+
+	return nil
+}
+
+// Sign returns the hex-encoded HMAC-SHA256 of body, receivers of webhooks can use it to check the X-Signature-256 header
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
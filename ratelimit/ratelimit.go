@@ -0,0 +1,239 @@
+// Package ratelimit keeps track of the Twitter API rate limits and spreads the requests of polling jobs over them.
+// Limits are read from the x-rate-limit-* headers of all responses, so jobs don't have to pass them around
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+// Endpoint families used by polling jobs. Rate limits apply per endpoint, not per list or user
+const (
+	HomeTimeline = "statuses/home_timeline"
+	UserTimeline = "statuses/user_timeline"
+	ListStatuses = "lists/statuses"
+	SearchTweets = "search/tweets"
+
+	HomeTimelineV2 = "2/users/:id/timelines/reverse_chronological"
+	UserTimelineV2 = "2/users/:id/tweets"
+	ListStatusesV2 = "2/lists/:id/tweets"
+	SearchRecentV2 = "2/tweets/search/recent"
+)
+
+// Limiter stores the rate limit state of all endpoints we made requests to
+type Limiter struct {
+	mu        sync.Mutex
+	endpoints map[string]*endpoint
+
+	now func() time.Time
+}
+
+type endpoint struct {
+	limit     int
+	remaining int
+	reset     time.Time
+
+	// jobs is the number of jobs that poll this endpoint
+	jobs int
+}
+
+// Default is used by the Twitter clients and all jobs
+var Default = New()
+
+func New() *Limiter {
+	return &Limiter{
+		endpoints: make(map[string]*endpoint),
+		now:       time.Now,
+	}
+}
+
+func (l *Limiter) get(name string) *endpoint {
+	e, ok := l.endpoints[name]
+	if !ok {
+		e = &endpoint{remaining: -1}
+		l.endpoints[name] = e
+	}
+	return e
+}
+
+// Register announces that a job polls the given endpoint. The budget of an endpoint is shared between all its jobs
+func (l *Limiter) Register(name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.get(name).jobs++
+}
+
+// Unregister undoes Register when a job stops
+func (l *Limiter) Unregister(name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if e := l.get(name); e.jobs > 0 {
+		e.jobs--
+	}
+}
+
+// Update stores the limits from the response headers of a request to the given endpoint
+func (l *Limiter) Update(name string, h http.Header) {
+	limit, err1 := strconv.Atoi(h.Get("x-rate-limit-limit"))
+	remaining, err2 := strconv.Atoi(h.Get("x-rate-limit-remaining"))
+	reset, err3 := strconv.ParseInt(h.Get("x-rate-limit-reset"), 10, 64)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return
+	}
+
+	l.set(name, limit, remaining, time.Unix(reset, 0))
+}
+
+func (l *Limiter) set(name string, limit, remaining int, reset time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e := l.get(name)
+	e.limit, e.remaining, e.reset = limit, remaining, reset
+}
+
+// Sync loads the current limits of all timeline endpoints using the rate limit status endpoint of the v1.1 API
+func (l *Limiter) Sync(client *twitter.Client) error {
+	status, _, err := client.RateLimits.Status(&twitter.RateLimitParams{
+		Resources: []string{"statuses", "lists", "search"},
+	})
+	if err != nil {
+		return err
+	}
+	if status.Resources == nil {
+		return nil
+	}
+
+	for _, family := range []map[string]*twitter.RateLimitResource{
+		status.Resources.Statuses, status.Resources.Lists, status.Resources.Search,
+	} {
+		for path, r := range family {
+			if r == nil {
+				continue
+			}
+			l.set(strings.TrimPrefix(path, "/"), r.Limit, r.Remaining, time.Unix(int64(r.Reset), 0))
+		}
+	}
+
+	return nil
+}
+
+// Delay returns how long a job should wait before its next request to the given endpoint.
+// The remaining requests until the reset are split between all jobs of the endpoint, but jobs never poll
+// more often than minInterval. If the limit is exhausted, jobs wait until it resets
+func (l *Limiter) Delay(name string, minInterval time.Duration) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e := l.get(name)
+
+	var now = l.now()
+	if e.remaining < 0 || !e.reset.After(now) {
+		// We don't know anything or the window is over, so there's a new budget
+		return minInterval
+	}
+
+	var untilReset = e.reset.Sub(now)
+	if e.remaining == 0 {
+		return untilReset
+	}
+
+	var jobs = e.jobs
+	if jobs < 1 {
+		jobs = 1
+	}
+
+	// Every job gets the same share of the remaining requests
+	delay := untilReset * time.Duration(jobs) / time.Duration(e.remaining)
+	if delay < minInterval {
+		return minInterval
+	}
+	return delay
+}
+
+// Wait sleeps for Delay(name, minInterval) plus the given jitter. It returns false if ctx was cancelled before that
+func (l *Limiter) Wait(ctx context.Context, name string, minInterval, jitter time.Duration) bool {
+	t := time.NewTimer(l.Delay(name, minInterval) + jitter)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// Status describes the remaining budget of an endpoint
+type Status struct {
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	Reset     time.Time `json:"reset"`
+	Jobs      int       `json:"jobs"`
+}
+
+// Stats returns the status of all endpoints with known limits or registered jobs
+func (l *Limiter) Stats() map[string]Status {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var stats = make(map[string]Status, len(l.endpoints))
+	for name, e := range l.endpoints {
+		if e.remaining < 0 && e.jobs == 0 {
+			continue
+		}
+		stats[name] = Status{
+			Limit:     e.limit,
+			Remaining: e.remaining,
+			Reset:     e.reset,
+			Jobs:      e.jobs,
+		}
+	}
+	return stats
+}
+
+// EndpointName returns the endpoint family of an API URL path, e.g. "lists/statuses" for "/1.1/lists/statuses.json"
+// and "2/lists/:id/tweets" for "/2/lists/123/tweets"
+func EndpointName(path string) string {
+	path = strings.TrimPrefix(path, "/")
+	path = strings.TrimPrefix(path, "1.1/")
+	path = strings.TrimSuffix(path, ".json")
+
+	var parts = strings.Split(path, "/")
+	for i, p := range parts {
+		// The first part is "2" for the v2 API, which is not an ID
+		if _, err := strconv.ParseUint(p, 10, 64); err == nil && i > 0 {
+			parts[i] = ":id"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// Transport returns a RoundTripper that updates the limits from all responses of base
+func (l *Limiter) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{limiter: l, base: base}
+}
+
+type transport struct {
+	limiter *Limiter
+	base    http.RoundTripper
+}
+
+func (t *transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(r)
+	if err == nil {
+		t.limiter.Update(EndpointName(r.URL.Path), resp.Header)
+	}
+	return resp, err
+}
@@ -0,0 +1,97 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestEndpointName(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/1.1/lists/statuses.json", ListStatuses},
+		{"/1.1/statuses/home_timeline.json", HomeTimeline},
+		{"/1.1/statuses/retweet/1234.json", "statuses/retweet/:id"},
+		{"/2/lists/1234/tweets", ListStatusesV2},
+		{"/2/users/44196397/timelines/reverse_chronological", HomeTimelineV2},
+	}
+	for _, tt := range tests {
+		if got := EndpointName(tt.path); got != tt.want {
+			t.Errorf("EndpointName(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestLimiter_Delay(t *testing.T) {
+	var now = time.Unix(1650000000, 0)
+
+	l := New()
+	l.now = func() time.Time { return now }
+
+	if d := l.Delay(ListStatuses, time.Minute); d != time.Minute {
+		t.Errorf("expected min interval without known limits, got %s", d)
+	}
+
+	// 30 lists share 900 requests per 15 minutes, so every list can be requested once per 30 seconds
+	for i := 0; i < 30; i++ {
+		l.Register(ListStatuses)
+	}
+	l.set(ListStatuses, 900, 900, now.Add(15*time.Minute))
+	if d := l.Delay(ListStatuses, 10*time.Second); d != 30*time.Second {
+		t.Errorf("expected 30s between requests, got %s", d)
+	}
+	if d := l.Delay(ListStatuses, time.Minute); d != time.Minute {
+		t.Errorf("expected min interval if there is enough budget, got %s", d)
+	}
+
+	// Only 60 requests left for 10 minutes: every list gets 2
+	l.set(ListStatuses, 900, 60, now.Add(10*time.Minute))
+	if d := l.Delay(ListStatuses, time.Minute); d != 5*time.Minute {
+		t.Errorf("expected 5 minutes between requests, got %s", d)
+	}
+
+	l.set(ListStatuses, 900, 0, now.Add(3*time.Minute))
+	if d := l.Delay(ListStatuses, time.Minute); d != 3*time.Minute {
+		t.Errorf("expected to wait until reset, got %s", d)
+	}
+
+	now = now.Add(4 * time.Minute)
+	if d := l.Delay(ListStatuses, time.Minute); d != time.Minute {
+		t.Errorf("expected min interval after reset, got %s", d)
+	}
+
+	stats := l.Stats()
+	if len(stats) != 1 || stats[ListStatuses].Jobs != 30 || stats[ListStatuses].Remaining != 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestLimiter_Transport(t *testing.T) {
+	var reset = time.Now().Add(5 * time.Minute).Unix()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("x-rate-limit-limit", "15")
+		w.Header().Set("x-rate-limit-remaining", "3")
+		w.Header().Set("x-rate-limit-reset", strconv.FormatInt(reset, 10))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	l := New()
+	client := &http.Client{Transport: l.Transport(nil)}
+
+	resp, err := client.Get(srv.URL + "/1.1/statuses/home_timeline.json?count=200")
+	if err != nil {
+		t.Fatalf("request failed: %s", err.Error())
+	}
+	resp.Body.Close()
+
+	s, ok := l.Stats()[HomeTimeline]
+	if !ok || s.Limit != 15 || s.Remaining != 3 || s.Reset.Unix() != reset {
+		t.Errorf("expected limits from headers, got %+v", l.Stats())
+	}
+}
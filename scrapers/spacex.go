@@ -35,7 +35,7 @@ var ErrNoInfo = errors.New("no info")
 
 // SpaceXStarship returns info about the starship page (ship name & first mentioned date)
 func SpaceXStarship(websiteURL string, now time.Time) (s StarshipInfo, err error) {
-	resp, err := c.Get(websiteURL)
+	resp, err := util.DefaultFetcher.Get(websiteURL)
 	if err != nil {
 		return
 	}
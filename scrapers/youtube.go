@@ -14,12 +14,6 @@ import (
 	"github.com/xarantolus/spacex-hop-bot/util"
 )
 
-var (
-	c = http.Client{
-		Timeout: 30 * time.Second,
-	}
-)
-
 // This struct only contains minimal info, there is more but I don't care about other info we can get
 type LiveVideo struct {
 	VideoID string `json:"videoId"`
@@ -95,6 +89,31 @@ func (lv *LiveVideo) URL() string {
 
 var ErrNoVideo = errors.New("not live")
 
+const (
+	// How long the live status of a stream is cached by CachedYouTubeLive
+	liveStatusCacheTTL  = 5 * time.Minute
+	failedLiveStatusTTL = time.Minute
+)
+
+// fetchYouTubeLive is the lookup CachedYouTubeLive caches, tests replace it
+var fetchYouTubeLive = YouTubeLive
+
+// CachedYouTubeLive is like YouTubeLive, but results are cached for a few minutes. It is meant for checking
+// links in tweets, where the same stream is often linked many times
+func CachedYouTubeLive(liveURL string) (lv LiveVideo, err error) {
+	v, err := util.DefaultFetcher.Cached("youtube-live:"+liveURL, func() (interface{}, time.Duration, error) {
+		lv, err := fetchYouTubeLive(liveURL)
+		if err != nil && !errors.Is(err, ErrNoVideo) {
+			return lv, failedLiveStatusTTL, err
+		}
+		return lv, liveStatusCacheTTL, err
+	})
+
+	// If the lookup panicked, there is no value
+	lv, _ = v.(LiveVideo)
+	return lv, err
+}
+
 // YouTubeLive extracts a live stream from a channel live url. This kind of URL looks like the following:
 //
 //	https://www.youtube.com/channel/UCSUu1lih2RifWkKtDOJdsBA/live
@@ -116,7 +135,7 @@ func YouTubeLive(channelLiveURL string) (lv LiveVideo, err error) {
 	// Sometimes a redirect to that page causes the scraper to not work, so this is an attempt to go around that
 	req.Header.Set("Cookie", "CONSENT=YES+cb.20210328-17-p0.en+FX+419; PREF=tz=UTC")
 
-	resp, err := c.Do(req)
+	resp, err := util.DefaultFetcher.Do(req)
 	if err != nil {
 		return
 	}
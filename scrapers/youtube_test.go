@@ -1,6 +1,12 @@
 package scrapers
 
-import "testing"
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/xarantolus/spacex-hop-bot/util"
+)
 
 // Make sure URL generation works correctly
 func TestLiveVideo_URL(t *testing.T) {
@@ -23,3 +29,54 @@ func TestLiveVideo_URL(t *testing.T) {
 		})
 	}
 }
+
+func TestCachedYouTubeLivePanic(t *testing.T) {
+	const liveURL = "https://www.youtube.com/channel/panic-test/live"
+
+	var (
+		started = make(chan struct{})
+		release = make(chan struct{})
+		waiter  = make(chan error)
+	)
+
+	fetchYouTubeLive = func(string) (LiveVideo, error) {
+		close(started)
+		<-release
+		panic("oops")
+	}
+	defer func() { fetchYouTubeLive = YouTubeLive }()
+
+	go func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("expected the panic to reach the caller")
+			}
+		}()
+
+		_, _ = CachedYouTubeLive(liveURL)
+	}()
+	<-started
+
+	coalesced := util.DefaultFetcher.Stats().Coalesced
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				t.Errorf("waiting caller panicked: %v", r)
+				waiter <- nil
+			}
+		}()
+
+		_, err := CachedYouTubeLive(liveURL)
+		waiter <- err
+	}()
+
+	// Wait until the second caller waits for the first call
+	for util.DefaultFetcher.Stats().Coalesced == coalesced {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+
+	if err := <-waiter; !errors.Is(err, util.ErrCachedCallPanicked) {
+		t.Errorf("expected waiting caller to get ErrCachedCallPanicked, got %v", err)
+	}
+}
@@ -0,0 +1,147 @@
+package twitterv2
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+// Retweet retweets a tweet as the given user, which must be the authenticated user
+func (c *Client) Retweet(userID, tweetID string) error {
+	// https://developer.twitter.com/en/docs/twitter-api/tweets/retweets/api-reference/post-users-id-retweets
+	return c.post("/2/users/"+pathEscape(userID)+"/retweets", map[string]string{
+		"tweet_id": tweetID,
+	}, nil)
+}
+
+// UnRetweet undoes a retweet of the given tweet
+func (c *Client) UnRetweet(userID, tweetID string) error {
+	// https://developer.twitter.com/en/docs/twitter-api/tweets/retweets/api-reference/delete-users-id-retweets-tweet_id
+	return c.delete("/2/users/"+pathEscape(userID)+"/retweets/"+pathEscape(tweetID), nil)
+}
+
+// Like likes a tweet as the given user
+func (c *Client) Like(userID, tweetID string) error {
+	// https://developer.twitter.com/en/docs/twitter-api/tweets/likes/api-reference/post-users-id-likes
+	return c.post("/2/users/"+pathEscape(userID)+"/likes", map[string]string{
+		"tweet_id": tweetID,
+	}, nil)
+}
+
+// CreateTweet posts a tweet. If inReplyToID is not empty, the tweet is a reply to that tweet.
+// The returned tweet only contains the ID and text
+func (c *Client) CreateTweet(text, inReplyToID string) (tweet *twitter.Tweet, err error) {
+	var body = struct {
+		Text  string `json:"text"`
+		Reply *struct {
+			InReplyToTweetID string `json:"in_reply_to_tweet_id"`
+		} `json:"reply,omitempty"`
+	}{Text: text}
+	if inReplyToID != "" {
+		body.Reply = &struct {
+			InReplyToTweetID string `json:"in_reply_to_tweet_id"`
+		}{inReplyToID}
+	}
+
+	// https://developer.twitter.com/en/docs/twitter-api/tweets/manage-tweets/api-reference/post-tweets
+	var resp struct {
+		Data Tweet `json:"data"`
+	}
+	err = c.post("/2/tweets", body, &resp)
+	if err != nil {
+		return
+	}
+	if resp.Data.ID == "" {
+		return nil, errorf("created tweet has no ID")
+	}
+
+	t := ToTweet(resp.Data, Includes{})
+	return &t, nil
+}
+
+// OwnedLists returns the lists the user owns
+func (c *Client) OwnedLists(userID string) ([]List, error) {
+	// https://developer.twitter.com/en/docs/twitter-api/lists/list-lookup/api-reference/get-users-id-owned_lists
+	return c.lists("/2/users/" + pathEscape(userID) + "/owned_lists")
+}
+
+// FollowedLists returns the lists the user follows
+func (c *Client) FollowedLists(userID string) ([]List, error) {
+	// https://developer.twitter.com/en/docs/twitter-api/lists/list-follows/api-reference/get-users-id-followed_lists
+	return c.lists("/2/users/" + pathEscape(userID) + "/followed_lists")
+}
+
+func (c *Client) lists(path string) (lists []List, err error) {
+	var q = url.Values{
+		"list.fields": {"owner_id,private"},
+		"max_results": {"100"},
+	}
+
+	for {
+		var resp struct {
+			Data []List `json:"data"`
+			Meta Meta   `json:"meta"`
+		}
+		err = c.get(path, q, &resp)
+		if err != nil {
+			return
+		}
+		lists = append(lists, resp.Data...)
+
+		if resp.Meta.NextToken == "" {
+			return
+		}
+		q.Set("pagination_token", resp.Meta.NextToken)
+	}
+}
+
+// ListMembers returns all members of a list
+func (c *Client) ListMembers(listID string) (users []twitter.User, err error) {
+	var q = url.Values{
+		"user.fields": {userFields},
+		"max_results": {"100"},
+	}
+
+	for {
+		// https://developer.twitter.com/en/docs/twitter-api/lists/list-members/api-reference/get-lists-id-members
+		var resp struct {
+			Data []User `json:"data"`
+			Meta Meta   `json:"meta"`
+		}
+		err = c.get("/2/lists/"+pathEscape(listID)+"/members", q, &resp)
+		if err != nil {
+			return
+		}
+		for _, u := range resp.Data {
+			users = append(users, *convertUser(u))
+		}
+
+		if resp.Meta.NextToken == "" {
+			return
+		}
+		q.Set("pagination_token", resp.Meta.NextToken)
+	}
+}
+
+// AddListMember adds a user to a list
+func (c *Client) AddListMember(listID, userID string) error {
+	// https://developer.twitter.com/en/docs/twitter-api/lists/list-members/api-reference/post-lists-id-members
+	return c.post("/2/lists/"+pathEscape(listID)+"/members", map[string]string{
+		"user_id": userID,
+	}, nil)
+}
+
+// RemoveListMember removes a user from a list
+func (c *Client) RemoveListMember(listID, userID string) error {
+	// https://developer.twitter.com/en/docs/twitter-api/lists/list-members/api-reference/delete-lists-id-members-user_id
+	return c.delete("/2/lists/"+pathEscape(listID)+"/members/"+pathEscape(userID), nil)
+}
+
+// SetListPrivate changes whether a list is private
+func (c *Client) SetListPrivate(listID string, private bool) error {
+	// https://developer.twitter.com/en/docs/twitter-api/lists/manage-lists/api-reference/put-lists-id
+	return c.do(c.userClient, http.MethodPut, "/2/lists/"+pathEscape(listID), nil, map[string]bool{
+		"private": private,
+	}, nil)
+}
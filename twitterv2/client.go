@@ -0,0 +1,169 @@
+// Package twitterv2 implements the parts of the Twitter API v2 the bot needs.
+// Tweets are converted to the v1.1 model of the go-twitter library, so they can be processed like all other tweets
+package twitterv2
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// DefaultBaseURL is the URL all API paths are relative to
+const DefaultBaseURL = "https://api.twitter.com"
+
+// Client makes requests to the v2 API
+type Client struct {
+	// BaseURL can be changed for tests
+	BaseURL string
+
+	// userClient signs requests in the context of the bot user (OAuth 1.0a)
+	userClient *http.Client
+	// appClient authenticates as the app using a bearer token. The filtered stream is only available this way
+	appClient *http.Client
+}
+
+// NewClient returns a client that makes user requests using userClient, usually an OAuth 1.0a client, and app requests
+// using bearerToken. If bearerToken is empty, userClient is used for everything.
+// If wrapApp is not nil, it wraps the transport of app requests, so they can go through the same middleware as userClient
+func NewClient(userClient *http.Client, bearerToken string, wrapApp func(http.RoundTripper) http.RoundTripper) *Client {
+	var appClient = userClient
+	if bearerToken != "" {
+		var transport http.RoundTripper = &bearerTransport{token: bearerToken}
+		if wrapApp != nil {
+			transport = wrapApp(transport)
+		}
+
+		appClient = &http.Client{
+			Transport: transport,
+		}
+	}
+
+	return &Client{
+		BaseURL:    DefaultBaseURL,
+		userClient: userClient,
+		appClient:  appClient,
+	}
+}
+
+type bearerTransport struct {
+	token string
+	base  http.RoundTripper
+}
+
+func (b *bearerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	base := b.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	// RoundTrippers must not modify the request
+	r = r.Clone(r.Context())
+	r.Header.Set("Authorization", "Bearer "+b.token)
+
+	return base.RoundTrip(r)
+}
+
+// requestTimeout is the timeout for all requests except the stream
+const requestTimeout = 30 * time.Second
+
+// maxResponseSize limits how much of a response is read
+const maxResponseSize = 10 << 20
+
+// do sends a request to path, encodes in as JSON body if it is not nil and decodes the response into out
+func (c *Client) do(client *http.Client, method, path string, query url.Values, in, out interface{}) (err error) {
+	req, err := c.newRequest(method, path, query, in)
+	if err != nil {
+		return
+	}
+
+	var tc = *client
+	tc.Timeout = requestTimeout
+
+	resp, err := tc.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if err = checkResponse(resp); err != nil {
+		return
+	}
+
+	if out == nil {
+		return
+	}
+
+	return json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(out)
+}
+
+func (c *Client) newRequest(method, path string, query url.Values, in interface{}) (req *http.Request, err error) {
+	var u = c.BaseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var body io.Reader
+	if in != nil {
+		buf, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(buf)
+	}
+
+	req, err = http.NewRequest(method, u, body)
+	if err != nil {
+		return
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	return
+}
+
+// checkResponse returns an *Error for unsuccessful responses
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	var apiErr = Error{StatusCode: resp.StatusCode}
+	// Errors are usually problem objects, but we still want to return an error if they aren't
+	_ = json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&apiErr)
+	apiErr.StatusCode = resp.StatusCode
+	if apiErr.Title == "" {
+		apiErr.Title = http.StatusText(resp.StatusCode)
+	}
+	if sec, err := strconv.ParseInt(resp.Header.Get("x-rate-limit-reset"), 10, 64); err == nil && sec > 0 {
+		apiErr.Reset = time.Unix(sec, 0)
+	}
+
+	return &apiErr
+}
+
+func (c *Client) get(path string, query url.Values, out interface{}) error {
+	return c.do(c.userClient, http.MethodGet, path, query, nil, out)
+}
+
+func (c *Client) post(path string, in, out interface{}) error {
+	return c.do(c.userClient, http.MethodPost, path, nil, in, out)
+}
+
+func (c *Client) delete(path string, out interface{}) error {
+	return c.do(c.userClient, http.MethodDelete, path, nil, nil, out)
+}
+
+func pathEscape(s string) string {
+	return url.PathEscape(s)
+}
+
+// errorf is used for responses that are successful, but don't contain what we expected
+func errorf(format string, a ...interface{}) error {
+	return fmt.Errorf("twitter v2: "+format, a...)
+}
@@ -0,0 +1,215 @@
+package twitterv2
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+// maxReferenceDepth limits how deep referenced tweets are converted, e.g. the quoted tweet of a retweeted tweet
+const maxReferenceDepth = 2
+
+// ToTweet converts a v2 tweet to the tweet model used everywhere else in the bot.
+// Authors, media, places and referenced tweets are taken from includes
+func ToTweet(t Tweet, includes Includes) twitter.Tweet {
+	return convertTweet(t, &includes, 0)
+}
+
+func convertTweet(t Tweet, inc *Includes, depth int) twitter.Tweet {
+	text, entities := t.Text, t.Entities
+	if t.NoteTweet != nil && t.NoteTweet.Text != "" {
+		text, entities = t.NoteTweet.Text, t.NoteTweet.Entities
+	}
+
+	var tweet = twitter.Tweet{
+		ID:                 parseID(t.ID),
+		IDStr:              t.ID,
+		FullText:           text,
+		Lang:               t.Lang,
+		PossiblySensitive:  t.PossiblySensitive,
+		InReplyToUserID:    parseID(t.InReplyToUserID),
+		InReplyToUserIDStr: t.InReplyToUserID,
+	}
+
+	if created, err := time.Parse(time.RFC3339, t.CreatedAt); err == nil {
+		tweet.CreatedAt = created.UTC().Format(time.RubyDate)
+	}
+
+	if u := inc.user(t.AuthorID); u != nil {
+		tweet.User = convertUser(*u)
+	} else if t.AuthorID != "" {
+		tweet.User = &twitter.User{ID: parseID(t.AuthorID), IDStr: t.AuthorID}
+	}
+
+	tweet.Entities, tweet.ExtendedEntities = convertEntities(t, entities, inc)
+
+	if t.Geo != nil && t.Geo.PlaceID != "" {
+		tweet.Place = &twitter.Place{ID: t.Geo.PlaceID}
+		if p := inc.place(t.Geo.PlaceID); p != nil {
+			tweet.Place.FullName = p.FullName
+			tweet.Place.Name = p.Name
+			tweet.Place.CountryCode = p.CountryCode
+			tweet.Place.PlaceType = p.PlaceType
+		}
+	}
+
+	if u := inc.user(t.InReplyToUserID); u != nil {
+		tweet.InReplyToScreenName = u.Username
+	}
+
+	for _, ref := range t.ReferencedTweets {
+		var referenced *twitter.Tweet
+		if rt := inc.tweet(ref.ID); rt != nil && depth < maxReferenceDepth {
+			converted := convertTweet(*rt, inc, depth+1)
+			referenced = &converted
+		}
+
+		switch ref.Type {
+		case ReferenceRepliedTo:
+			tweet.InReplyToStatusID = parseID(ref.ID)
+			tweet.InReplyToStatusIDStr = ref.ID
+		case ReferenceQuoted:
+			tweet.QuotedStatusID = parseID(ref.ID)
+			tweet.QuotedStatusIDStr = ref.ID
+			tweet.QuotedStatus = referenced
+		case ReferenceRetweeted:
+			tweet.RetweetedStatus = referenced
+		}
+	}
+
+	return tweet
+}
+
+func convertUser(u User) *twitter.User {
+	return &twitter.User{
+		ID:          parseID(u.ID),
+		IDStr:       u.ID,
+		ScreenName:  u.Username,
+		Name:        u.Name,
+		Description: u.Description,
+		Protected:   u.Protected,
+		Verified:    u.Verified,
+
+		FollowersCount: u.PublicMetrics.FollowersCount,
+		FriendsCount:   u.PublicMetrics.FollowingCount,
+		StatusesCount:  u.PublicMetrics.TweetCount,
+	}
+}
+
+// convertEntities converts links, mentions, hashtags and attached media. Links to media are media entities, like in v1.1
+func convertEntities(t Tweet, e *Entities, inc *Includes) (entities *twitter.Entities, extended *twitter.ExtendedEntity) {
+	entities = &twitter.Entities{}
+
+	// All attached media share the same link
+	var mediaLink *URLEntity
+
+	if e != nil {
+		for i, u := range e.URLs {
+			if u.MediaKey != "" {
+				if mediaLink == nil {
+					mediaLink = &e.URLs[i]
+				}
+				continue
+			}
+			entities.Urls = append(entities.Urls, twitter.URLEntity{
+				Indices:     twitter.Indices{u.Start, u.End},
+				DisplayURL:  u.DisplayURL,
+				ExpandedURL: u.ExpandedURL,
+				URL:         u.URL,
+			})
+		}
+
+		for _, m := range e.Mentions {
+			entities.UserMentions = append(entities.UserMentions, twitter.MentionEntity{
+				Indices:    twitter.Indices{m.Start, m.End},
+				ID:         parseID(m.ID),
+				IDStr:      m.ID,
+				ScreenName: m.Username,
+			})
+		}
+
+		for _, h := range e.Hashtags {
+			entities.Hashtags = append(entities.Hashtags, twitter.HashtagEntity{
+				Indices: twitter.Indices{h.Start, h.End},
+				Text:    h.Tag,
+			})
+		}
+	}
+
+	if t.Attachments == nil || len(t.Attachments.MediaKeys) == 0 {
+		return
+	}
+
+	extended = &twitter.ExtendedEntity{}
+	for _, key := range t.Attachments.MediaKeys {
+		var me = twitter.MediaEntity{}
+		if mediaLink != nil {
+			me.URLEntity = twitter.URLEntity{
+				Indices:     twitter.Indices{mediaLink.Start, mediaLink.End},
+				DisplayURL:  mediaLink.DisplayURL,
+				ExpandedURL: mediaLink.ExpandedURL,
+				URL:         mediaLink.URL,
+			}
+		}
+		if m := inc.media(key); m != nil {
+			me.Type = m.Type
+			me.MediaURLHttps = m.URL
+			if me.MediaURLHttps == "" {
+				// Videos and GIFs only have a preview image
+				me.MediaURLHttps = m.PreviewImageURL
+			}
+			me.MediaURL = me.MediaURLHttps
+		}
+		extended.Media = append(extended.Media, me)
+	}
+
+	// v1.1 only has the first attachment in the entities
+	entities.Media = extended.Media[:1]
+
+	return
+}
+
+func (inc *Includes) user(id string) *User {
+	if id == "" {
+		return nil
+	}
+	for i := range inc.Users {
+		if inc.Users[i].ID == id {
+			return &inc.Users[i]
+		}
+	}
+	return nil
+}
+
+func (inc *Includes) tweet(id string) *Tweet {
+	for i := range inc.Tweets {
+		if inc.Tweets[i].ID == id {
+			return &inc.Tweets[i]
+		}
+	}
+	return nil
+}
+
+func (inc *Includes) media(key string) *Media {
+	for i := range inc.Media {
+		if inc.Media[i].MediaKey == key {
+			return &inc.Media[i]
+		}
+	}
+	return nil
+}
+
+func (inc *Includes) place(id string) *Place {
+	for i := range inc.Places {
+		if inc.Places[i].ID == id {
+			return &inc.Places[i]
+		}
+	}
+	return nil
+}
+
+func parseID(id string) int64 {
+	i, _ := strconv.ParseInt(id, 10, 64)
+	return i
+}
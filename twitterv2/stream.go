@@ -0,0 +1,155 @@
+package twitterv2
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+// Rule is a filtered stream rule.
+// See https://developer.twitter.com/en/docs/twitter-api/tweets/filtered-stream/integrate/build-a-rule
+type Rule struct {
+	ID    string `json:"id,omitempty"`
+	Value string `json:"value"`
+	Tag   string `json:"tag,omitempty"`
+}
+
+// Rules returns the rules of the filtered stream
+func (c *Client) Rules() (rules []Rule, err error) {
+	// https://developer.twitter.com/en/docs/twitter-api/tweets/filtered-stream/api-reference/get-tweets-search-stream-rules
+	var resp struct {
+		Data []Rule `json:"data"`
+	}
+	err = c.do(c.appClient, http.MethodGet, "/2/tweets/search/stream/rules", nil, nil, &resp)
+	return resp.Data, err
+}
+
+// SetRules changes the rules of the filtered stream to the given rules. Rules are compared by their value,
+// unchanged rules are kept
+func (c *Client) SetRules(rules []Rule) (err error) {
+	current, err := c.Rules()
+	if err != nil {
+		return
+	}
+
+	var wanted = make(map[string]bool)
+	for _, r := range rules {
+		wanted[r.Value] = true
+	}
+
+	var (
+		existing = make(map[string]bool)
+		remove   []string
+	)
+	for _, r := range current {
+		if wanted[r.Value] {
+			existing[r.Value] = true
+		} else {
+			remove = append(remove, r.ID)
+		}
+	}
+
+	var add []Rule
+	for _, r := range rules {
+		if !existing[r.Value] {
+			add = append(add, Rule{Value: r.Value, Tag: r.Tag})
+		}
+	}
+
+	// https://developer.twitter.com/en/docs/twitter-api/tweets/filtered-stream/api-reference/post-tweets-search-stream-rules
+	if len(remove) > 0 {
+		err = c.do(c.appClient, http.MethodPost, "/2/tweets/search/stream/rules", nil, map[string]interface{}{
+			"delete": map[string][]string{"ids": remove},
+		}, nil)
+		if err != nil {
+			return
+		}
+	}
+
+	if len(add) > 0 {
+		var resp struct {
+			Errors []Error `json:"errors"`
+		}
+		err = c.do(c.appClient, http.MethodPost, "/2/tweets/search/stream/rules", nil, map[string]interface{}{
+			"add": add,
+		}, &resp)
+		if err == nil && len(resp.Errors) > 0 {
+			err = &resp.Errors[0]
+		}
+	}
+
+	return
+}
+
+// StreamTweet is a tweet from the filtered stream
+type StreamTweet struct {
+	Tweet twitter.Tweet
+	// Tags contains the tags of all rules that matched this tweet
+	Tags []string
+}
+
+// Stream connects to the filtered stream and calls handle for every tweet until the stream ends or ctx is cancelled.
+// It always returns a non-nil error
+func (c *Client) Stream(ctx context.Context, handle func(StreamTweet)) (err error) {
+	req, err := c.newRequest(http.MethodGet, "/2/tweets/search/stream", tweetQuery(), nil)
+	if err != nil {
+		return
+	}
+	req = req.WithContext(ctx)
+
+	// https://developer.twitter.com/en/docs/twitter-api/tweets/filtered-stream/api-reference/get-tweets-search-stream
+	resp, err := c.appClient.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if err = checkResponse(resp); err != nil {
+		return
+	}
+
+	var scanner = bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64<<10), maxResponseSize)
+
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		// Empty lines are sent to keep the connection alive
+		if len(line) == 0 {
+			continue
+		}
+
+		var msg struct {
+			Data          *Tweet   `json:"data"`
+			Includes      Includes `json:"includes"`
+			MatchingRules []Rule   `json:"matching_rules"`
+			Errors        []Error  `json:"errors"`
+		}
+		if json.Unmarshal(line, &msg) != nil {
+			continue
+		}
+
+		if msg.Data == nil {
+			// Disconnect messages only contain errors
+			if len(msg.Errors) > 0 {
+				return &msg.Errors[0]
+			}
+			continue
+		}
+
+		var st = StreamTweet{Tweet: ToTweet(*msg.Data, msg.Includes)}
+		for _, r := range msg.MatchingRules {
+			st.Tags = append(st.Tags, r.Tag)
+		}
+		handle(st)
+	}
+
+	if err = scanner.Err(); err != nil {
+		return
+	}
+
+	return errorf("stream ended")
+}
@@ -0,0 +1,157 @@
+package twitterv2
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/dghubble/go-twitter/twitter"
+)
+
+// userFields are the fields that are requested for every user
+const userFields = "description,protected,public_metrics,verified"
+
+// tweetQuery returns the fields and expansions that are requested for every tweet,
+// so that a converted tweet contains everything the matcher looks at
+func tweetQuery() url.Values {
+	return url.Values{
+		"tweet.fields": {"attachments,author_id,conversation_id,created_at,entities,geo,in_reply_to_user_id,lang,note_tweet,possibly_sensitive,referenced_tweets"},
+		"expansions":   {"author_id,attachments.media_keys,geo.place_id,in_reply_to_user_id,entities.mentions.username,referenced_tweets.id,referenced_tweets.id.author_id,referenced_tweets.id.attachments.media_keys"},
+		"user.fields":  {userFields},
+		"media.fields": {"type,url,preview_image_url"},
+		"place.fields": {"full_name,name,country_code,place_type"},
+	}
+}
+
+// Lookup returns the tweets with the given IDs, at most 100 at once. Tweets that don't exist are left out
+func (c *Client) Lookup(ids ...string) (tweets []twitter.Tweet, err error) {
+	var q = tweetQuery()
+	q.Set("ids", strings.Join(ids, ","))
+
+	// https://developer.twitter.com/en/docs/twitter-api/tweets/lookup/api-reference/get-tweets
+	var resp tweetsResponse
+	err = c.get("/2/tweets", q, &resp)
+	if err != nil {
+		return
+	}
+
+	return resp.tweets(), nil
+}
+
+// LookupTweet returns the tweet with the given ID. If it doesn't exist, the returned *Error has the type ProblemNotFound
+func (c *Client) LookupTweet(id string) (tweet *twitter.Tweet, err error) {
+	var resp tweetsResponse
+	var q = tweetQuery()
+	q.Set("ids", id)
+
+	err = c.get("/2/tweets", q, &resp)
+	if err != nil {
+		return
+	}
+
+	tweets := resp.tweets()
+	if len(tweets) == 0 {
+		if len(resp.Errors) > 0 {
+			return nil, &resp.Errors[0]
+		}
+		return nil, errorf("tweet %s not found", id)
+	}
+
+	return &tweets[0], nil
+}
+
+// UserTimeline returns the newest tweets of a user that are newer than sinceID. An empty sinceID returns the newest tweets
+func (c *Client) UserTimeline(userID, sinceID string) ([]twitter.Tweet, error) {
+	// https://developer.twitter.com/en/docs/twitter-api/tweets/timelines/api-reference/get-users-id-tweets
+	return c.timeline("/2/users/"+pathEscape(userID)+"/tweets", nil, sinceID, true)
+}
+
+// HomeTimeline returns the newest tweets of accounts the user follows that are newer than sinceID
+func (c *Client) HomeTimeline(userID, sinceID string) ([]twitter.Tweet, error) {
+	// https://developer.twitter.com/en/docs/twitter-api/tweets/timelines/api-reference/get-users-id-reverse-chronological
+	return c.timeline("/2/users/"+pathEscape(userID)+"/timelines/reverse_chronological", nil, sinceID, true)
+}
+
+// ListTimeline returns the newest tweets of a list that are newer than sinceID
+func (c *Client) ListTimeline(listID, sinceID string) ([]twitter.Tweet, error) {
+	// This endpoint doesn't support since_id, so older tweets are filtered out afterwards.
+	// https://developer.twitter.com/en/docs/twitter-api/lists/list-tweets/api-reference/get-lists-id-tweets
+	return c.timeline("/2/lists/"+pathEscape(listID)+"/tweets", nil, sinceID, false)
+}
+
+// SearchRecent returns tweets from the last seven days that match query and are newer than sinceID
+func (c *Client) SearchRecent(query, sinceID string) ([]twitter.Tweet, error) {
+	// https://developer.twitter.com/en/docs/twitter-api/tweets/search/api-reference/get-tweets-search-recent
+	return c.timeline("/2/tweets/search/recent", url.Values{"query": {query}}, sinceID, true)
+}
+
+// timeline requests tweets from path with the given additional query parameters
+func (c *Client) timeline(path string, params url.Values, sinceID string, supportsSinceID bool) (tweets []twitter.Tweet, err error) {
+	var q = tweetQuery()
+	for k, v := range params {
+		q[k] = v
+	}
+	q.Set("max_results", "100")
+	if sinceID != "" && supportsSinceID {
+		q.Set("since_id", sinceID)
+	}
+
+	var resp tweetsResponse
+	err = c.get(path, q, &resp)
+	if err != nil {
+		return
+	}
+
+	var since = parseID(sinceID)
+	for _, t := range resp.tweets() {
+		if t.ID > since {
+			tweets = append(tweets, t)
+		}
+	}
+
+	// Sort tweets so the first tweet is the oldest one
+	sort.Slice(tweets, func(i, j int) bool {
+		return tweets[i].ID < tweets[j].ID
+	})
+
+	return
+}
+
+func (r *tweetsResponse) tweets() (tweets []twitter.Tweet) {
+	for _, t := range r.Data {
+		tweets = append(tweets, ToTweet(t, r.Includes))
+	}
+	return
+}
+
+// Me returns the authenticated user
+func (c *Client) Me() (*twitter.User, error) {
+	// https://developer.twitter.com/en/docs/twitter-api/users/lookup/api-reference/get-users-me
+	return c.user("/2/users/me")
+}
+
+// UserByUsername returns the user with the given username
+func (c *Client) UserByUsername(username string) (*twitter.User, error) {
+	// https://developer.twitter.com/en/docs/twitter-api/users/lookup/api-reference/get-users-by-username-username
+	return c.user("/2/users/by/username/" + pathEscape(username))
+}
+
+func (c *Client) user(path string) (user *twitter.User, err error) {
+	var resp struct {
+		Data   *User   `json:"data"`
+		Errors []Error `json:"errors"`
+	}
+	err = c.get(path, url.Values{"user.fields": {userFields}}, &resp)
+	if err != nil {
+		return
+	}
+
+	if resp.Data == nil {
+		if len(resp.Errors) > 0 {
+			return nil, &resp.Errors[0]
+		}
+		return nil, errorf("no user in response from %s", path)
+	}
+
+	return convertUser(*resp.Data), nil
+}
@@ -0,0 +1,412 @@
+package twitterv2
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type recordedRequest struct {
+	Method, Path string
+	Query        map[string][]string
+	Body         map[string]interface{}
+	Auth         string
+}
+
+// fixtureServer answers requests with the recorded responses in testdata. Routes map "METHOD /path" to a file name,
+// a route can also include a pagination token, e.g. "GET /2/lists/1/members?PAGE2"
+func fixtureServer(t *testing.T, routes map[string]string) (*Client, *[]recordedRequest) {
+	t.Helper()
+
+	var requests []recordedRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rec = recordedRequest{
+			Method: r.Method,
+			Path:   r.URL.Path,
+			Query:  r.URL.Query(),
+			Auth:   r.Header.Get("Authorization"),
+		}
+		if b, _ := io.ReadAll(r.Body); len(b) > 0 {
+			if err := json.Unmarshal(b, &rec.Body); err != nil {
+				t.Errorf("request body is not JSON: %s", err.Error())
+			}
+		}
+		requests = append(requests, rec)
+
+		var key = r.Method + " " + r.URL.Path
+		if token := r.URL.Query().Get("pagination_token"); token != "" {
+			key += "?" + token
+		}
+		file, ok := routes[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"title": "Not Found", "status": 404}`))
+			return
+		}
+
+		status := http.StatusOK
+		// Fixtures of error responses are named after their status code, e.g. "error_429.json"
+		if strings.HasPrefix(file, "error_") {
+			status, _ = strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(file, "error_"), ".json"))
+		}
+
+		data, err := os.ReadFile(filepath.Join("testdata", file))
+		if err != nil {
+			t.Fatalf("reading fixture: %s", err.Error())
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write(data)
+	}))
+	t.Cleanup(srv.Close)
+
+	c := NewClient(srv.Client(), "app-token", nil)
+	c.BaseURL = srv.URL
+
+	return c, &requests
+}
+
+func TestLookupTweet(t *testing.T) {
+	c, requests := fixtureServer(t, map[string]string{
+		"GET /2/tweets": "tweets_lookup.json",
+	})
+
+	tweet, err := c.LookupTweet("1650000000000000002")
+	if err != nil {
+		t.Fatalf("looking up tweet: %s", err.Error())
+	}
+
+	q := (*requests)[0].Query
+	if q["ids"][0] != "1650000000000000002" || !strings.Contains(q["expansions"][0], "attachments.media_keys") ||
+		!strings.Contains(q["expansions"][0], "geo.place_id") || !strings.Contains(q["expansions"][0], "referenced_tweets.id") ||
+		!strings.Contains(q["user.fields"][0], "public_metrics") {
+		t.Errorf("unexpected query %v", q)
+	}
+
+	if tweet.ID != 1650000000000000002 || tweet.IDStr != "1650000000000000002" || tweet.Lang != "en" {
+		t.Errorf("unexpected identity %d %q %q", tweet.ID, tweet.IDStr, tweet.Lang)
+	}
+	if created, err := tweet.CreatedAtTime(); err != nil || created.Year() != 2023 || created.Hour() != 13 {
+		t.Errorf("unexpected creation time %q: %v", tweet.CreatedAt, err)
+	}
+	if tweet.User == nil || tweet.User.ID != 80 || tweet.User.ScreenName != "someone" || tweet.User.Description != "Photographer at Starbase" ||
+		tweet.User.FollowersCount != 1520 {
+		t.Errorf("unexpected user %+v", tweet.User)
+	}
+
+	// Text handling must match v1.1 tweets: links are expanded or removed, media links are always removed
+	if got := tweet.TextWithURLs(); got != "@SpaceX S25 rolling out to the pad, more at https://www.spacex.com/vehicles/starship/ " {
+		t.Errorf("unexpected text with URLs %q", got)
+	}
+	if got := tweet.Text(); got != "@SpaceX S25 rolling out to the pad, more at  " {
+		t.Errorf("unexpected text %q", got)
+	}
+	if len(tweet.Entities.UserMentions) != 1 || tweet.Entities.UserMentions[0].ID != 34743251 {
+		t.Errorf("unexpected mentions %+v", tweet.Entities.UserMentions)
+	}
+
+	if tweet.ExtendedEntities == nil || len(tweet.ExtendedEntities.Media) != 2 || len(tweet.Entities.Media) != 1 {
+		t.Fatalf("expected two media entities, got %+v / %+v", tweet.ExtendedEntities, tweet.Entities.Media)
+	}
+	photo, video := tweet.ExtendedEntities.Media[0], tweet.ExtendedEntities.Media[1]
+	if photo.Type != "photo" || photo.MediaURLHttps != "https://pbs.twimg.com/media/1.jpg" || photo.URL != "https://t.co/media" {
+		t.Errorf("unexpected photo %+v", photo)
+	}
+	if video.Type != "video" || video.MediaURLHttps != "https://pbs.twimg.com/ext_tw_video_thumb/2.jpg" {
+		t.Errorf("unexpected video %+v", video)
+	}
+
+	if tweet.Place == nil || tweet.Place.ID != "124bed061b8e4e2f" || tweet.Place.FullName != "Starbase, TX" {
+		t.Errorf("unexpected place %+v", tweet.Place)
+	}
+
+	if tweet.InReplyToStatusID != 1650000000000000001 || tweet.InReplyToUserID != 34743251 || tweet.InReplyToScreenName != "SpaceX" {
+		t.Errorf("unexpected reply fields %d %d %q", tweet.InReplyToStatusID, tweet.InReplyToUserID, tweet.InReplyToScreenName)
+	}
+	if tweet.QuotedStatus == nil || tweet.QuotedStatusID != 1649999999999999999 ||
+		tweet.QuotedStatus.User.ScreenName != "SpaceX" || tweet.QuotedStatus.Text() != "Booster 7 and Ship 24 are stacked" {
+		t.Errorf("unexpected quoted tweet %+v", tweet.QuotedStatus)
+	}
+	if tweet.RetweetedStatus != nil {
+		t.Errorf("tweet should not be a retweet")
+	}
+}
+
+func TestConvertUser(t *testing.T) {
+	var u User
+	err := json.Unmarshal([]byte(`{"id": "80", "name": "Someone", "username": "someone", "verified": true,
+		"public_metrics": {"followers_count": 1520, "following_count": 310, "tweet_count": 8800, "listed_count": 12}}`), &u)
+	if err != nil {
+		t.Fatalf("decoding user: %s", err.Error())
+	}
+
+	got := convertUser(u)
+	if got.ID != 80 || got.ScreenName != "someone" || !got.Verified {
+		t.Errorf("unexpected user %+v", got)
+	}
+	if got.FollowersCount != 1520 || got.FriendsCount != 310 || got.StatusesCount != 8800 {
+		t.Errorf("unexpected counts: %d followers, %d friends, %d tweets", got.FollowersCount, got.FriendsCount, got.StatusesCount)
+	}
+}
+
+func TestLookupTweet_Missing(t *testing.T) {
+	c, _ := fixtureServer(t, map[string]string{
+		"GET /2/tweets": "tweets_lookup_missing.json",
+	})
+
+	_, err := c.LookupTweet("1")
+
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.Type != ProblemNotFound {
+		t.Errorf("expected not found error, got %v", err)
+	}
+
+	tweets, err := c.Lookup("1")
+	if err != nil || len(tweets) != 0 {
+		t.Errorf("expected missing tweets to be left out, got %v / %v", tweets, err)
+	}
+}
+
+func TestTimelines(t *testing.T) {
+	c, requests := fixtureServer(t, map[string]string{
+		"GET /2/users/44196397/tweets": "user_tweets.json",
+		"GET /2/lists/5/tweets":        "list_tweets.json",
+		"GET /2/tweets/search/recent":  "user_tweets.json",
+	})
+
+	tweets, err := c.UserTimeline("44196397", "1650000000000000010")
+	if err != nil {
+		t.Fatalf("loading user timeline: %s", err.Error())
+	}
+	if got := (*requests)[0].Query["since_id"]; len(got) != 1 || got[0] != "1650000000000000010" {
+		t.Errorf("expected since_id to be sent, got %v", got)
+	}
+	if len(tweets) != 2 || tweets[0].ID != 1650000000000000011 {
+		t.Fatalf("expected two tweets sorted from oldest to newest, got %d", len(tweets))
+	}
+
+	// Long tweets use the text and entities of the note tweet
+	if got := tweets[0].TextWithURLs(); !strings.Contains(got, "https://www.spacex.com/ that only") {
+		t.Errorf("unexpected note tweet text %q", got)
+	}
+
+	rt := tweets[1]
+	if rt.RetweetedStatus == nil || rt.RetweetedStatus.User.ScreenName != "SpaceX" || rt.RetweetedStatus.Text() != "Starship Flight Test soon" {
+		t.Errorf("unexpected retweeted status %+v", rt.RetweetedStatus)
+	}
+
+	// The list endpoint doesn't support since_id, so older tweets must be filtered
+	tweets, err = c.ListTimeline("5", "1650000000000000021")
+	if err != nil {
+		t.Fatalf("loading list timeline: %s", err.Error())
+	}
+	if _, ok := (*requests)[1].Query["since_id"]; ok {
+		t.Errorf("since_id should not be sent to the list endpoint")
+	}
+	if len(tweets) != 2 || tweets[0].Text() != "Second" || tweets[1].Text() != "Third" {
+		t.Errorf("unexpected list tweets %+v", tweets)
+	}
+
+	// Searches use the same format as timelines
+	tweets, err = c.SearchRecent("starship static fire", "1650000000000000010")
+	if err != nil {
+		t.Fatalf("searching tweets: %s", err.Error())
+	}
+	if got := url.Values((*requests)[2].Query); got.Get("query") != "starship static fire" || got.Get("since_id") != "1650000000000000010" || got.Get("expansions") == "" {
+		t.Errorf("unexpected search query %v", got)
+	}
+	if len(tweets) != 2 || tweets[0].ID != 1650000000000000011 {
+		t.Errorf("unexpected search results %+v", tweets)
+	}
+}
+
+func TestUsersAndLists(t *testing.T) {
+	c, requests := fixtureServer(t, map[string]string{
+		"GET /2/users/me":                              "users_me.json",
+		"GET /2/users/by/username/doesnotexist":        "users_by_username_missing.json",
+		"GET /2/users/1391052224582078464/owned_lists": "owned_lists.json",
+		"GET /2/lists/7/members":                       "list_members_page1.json",
+		"GET /2/lists/7/members?PAGE2":                 "list_members_page2.json",
+	})
+
+	me, err := c.Me()
+	if err != nil || me.ID != 1391052224582078464 || me.ScreenName != "wenhopbot" {
+		t.Errorf("unexpected user %+v / %v", me, err)
+	}
+
+	_, err = c.UserByUsername("doesnotexist")
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.Type != ProblemNotFound {
+		t.Errorf("expected not found error, got %v", err)
+	}
+
+	lists, err := c.OwnedLists(me.IDStr)
+	if err != nil || len(lists) != 1 || lists[0].Name != "Space People" {
+		t.Errorf("unexpected lists %+v / %v", lists, err)
+	}
+
+	members, err := c.ListMembers("7")
+	if err != nil {
+		t.Fatalf("loading list members: %s", err.Error())
+	}
+	if len(members) != 3 || members[2].ID != 3 || !members[2].Protected {
+		t.Errorf("expected members of both pages, got %+v", members)
+	}
+	if n := len(*requests); (*requests)[n-1].Query["pagination_token"][0] != "PAGE2" {
+		t.Errorf("expected second page to be requested")
+	}
+}
+
+func TestActions(t *testing.T) {
+	c, requests := fixtureServer(t, map[string]string{
+		"POST /2/users/10/retweets":      "retweet.json",
+		"DELETE /2/users/10/retweets/20": "unretweet.json",
+		"POST /2/users/10/likes":         "like.json",
+		"POST /2/tweets":                 "create_tweet.json",
+		"POST /2/lists/7/members":        "list_member.json",
+		"DELETE /2/lists/7/members/30":   "list_member.json",
+		"PUT /2/lists/7":                 "list_update.json",
+	})
+
+	for _, err := range []error{
+		c.Retweet("10", "20"),
+		c.UnRetweet("10", "20"),
+		c.Like("10", "20"),
+		c.AddListMember("7", "30"),
+		c.RemoveListMember("7", "30"),
+		c.SetListPrivate("7", true),
+	} {
+		if err != nil {
+			t.Errorf("unexpected error: %s", err.Error())
+		}
+	}
+
+	tweet, err := c.CreateTweet("SpaceX is live", "20")
+	if err != nil || tweet.ID != 1650000000000000099 || tweet.Text() != "SpaceX is live" {
+		t.Errorf("unexpected created tweet %+v / %v", tweet, err)
+	}
+
+	var reqs = *requests
+	if reqs[0].Body["tweet_id"] != "20" || reqs[2].Body["tweet_id"] != "20" || reqs[3].Body["user_id"] != "30" {
+		t.Errorf("unexpected request bodies %+v", reqs)
+	}
+	if reqs[5].Method != http.MethodPut || reqs[5].Body["private"] != true {
+		t.Errorf("unexpected list update %+v", reqs[5])
+	}
+	reply, _ := reqs[6].Body["reply"].(map[string]interface{})
+	if reqs[6].Body["text"] != "SpaceX is live" || reply["in_reply_to_tweet_id"] != "20" {
+		t.Errorf("unexpected tweet body %+v", reqs[6].Body)
+	}
+
+	// User actions must not use the app token
+	for _, r := range reqs {
+		if r.Auth != "" {
+			t.Errorf("%s %s was sent with app authentication", r.Method, r.Path)
+		}
+	}
+}
+
+func TestStream(t *testing.T) {
+	c, requests := fixtureServer(t, map[string]string{
+		"GET /2/tweets/search/stream/rules":  "stream_rules.json",
+		"POST /2/tweets/search/stream/rules": "stream_rules_add.json",
+		"GET /2/tweets/search/stream":        "stream.jsonl",
+	})
+
+	err := c.SetRules([]Rule{
+		{Value: "bounding_box:[-97.321014 25.838213 -96.942673 26.121535] lang:en", Tag: "location"},
+		{Value: "starship has:media"},
+	})
+	if err != nil {
+		t.Fatalf("setting rules: %s", err.Error())
+	}
+
+	var reqs = *requests
+	if len(reqs) != 3 {
+		t.Fatalf("expected rules to be loaded, one deleted and one added, got %d requests", len(reqs))
+	}
+	del, _ := reqs[1].Body["delete"].(map[string]interface{})
+	if ids, _ := del["ids"].([]interface{}); len(ids) != 1 || ids[0] != "2" {
+		t.Errorf("expected old rule to be deleted, got %+v", reqs[1].Body)
+	}
+	if add, _ := reqs[2].Body["add"].([]interface{}); len(add) != 1 || add[0].(map[string]interface{})["value"] != "starship has:media" {
+		t.Errorf("expected new rule to be added, got %+v", reqs[2].Body)
+	}
+
+	var tweets []StreamTweet
+	err = c.Stream(context.Background(), func(st StreamTweet) {
+		tweets = append(tweets, st)
+	})
+
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.Title != "operational-disconnect" {
+		t.Errorf("expected disconnect error, got %v", err)
+	}
+	if len(tweets) != 2 || tweets[0].Tweet.Place == nil || tweets[0].Tweet.Place.FullName != "Starbase, TX" ||
+		len(tweets[0].Tags) != 1 || tweets[0].Tags[0] != "location" || tweets[1].Tweet.User.ScreenName != "other" {
+		t.Errorf("unexpected stream tweets %+v", tweets)
+	}
+
+	// The stream and its rules are only available with app authentication
+	for _, r := range *requests {
+		if r.Auth != "Bearer app-token" {
+			t.Errorf("%s %s was sent without app authentication", r.Method, r.Path)
+		}
+	}
+}
+
+func TestErrorResponse(t *testing.T) {
+	c, _ := fixtureServer(t, map[string]string{
+		"GET /2/users/1/tweets": "error_429.json",
+	})
+
+	_, err := c.UserTimeline("1", "")
+
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusTooManyRequests || apiErr.Title != "Too Many Requests" {
+		t.Errorf("expected rate limit error, got %v", err)
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestAppTransportWrapped(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer app-token" {
+			t.Errorf("%s %s was sent without app authentication", r.Method, r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"meta": {"sent": "2023-04-20T13:33:00.000Z", "result_count": 0}}`))
+	}))
+	defer srv.Close()
+
+	// App requests must go through the same middleware as user requests, e.g. for rate limits
+	var wrapped int
+	c := NewClient(srv.Client(), "app-token", func(base http.RoundTripper) http.RoundTripper {
+		return roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			wrapped++
+			return base.RoundTrip(r)
+		})
+	})
+	c.BaseURL = srv.URL
+
+	if _, err := c.Rules(); err != nil {
+		t.Fatalf("getting rules: %s", err.Error())
+	}
+	if wrapped != 1 {
+		t.Errorf("expected app request to go through the wrapped transport once, got %d", wrapped)
+	}
+}
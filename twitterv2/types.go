@@ -0,0 +1,160 @@
+package twitterv2
+
+import (
+	"fmt"
+	"time"
+)
+
+// Tweet is a tweet as returned by the v2 API.
+// See https://developer.twitter.com/en/docs/twitter-api/data-dictionary/object-model/tweet
+type Tweet struct {
+	ID                string            `json:"id"`
+	Text              string            `json:"text"`
+	AuthorID          string            `json:"author_id"`
+	CreatedAt         string            `json:"created_at"`
+	ConversationID    string            `json:"conversation_id"`
+	InReplyToUserID   string            `json:"in_reply_to_user_id"`
+	Lang              string            `json:"lang"`
+	PossiblySensitive bool              `json:"possibly_sensitive"`
+	ReferencedTweets  []ReferencedTweet `json:"referenced_tweets"`
+	Entities          *Entities         `json:"entities"`
+
+	Attachments *struct {
+		MediaKeys []string `json:"media_keys"`
+	} `json:"attachments"`
+
+	Geo *struct {
+		PlaceID string `json:"place_id"`
+	} `json:"geo"`
+
+	// NoteTweet contains the full text of tweets longer than 280 characters
+	NoteTweet *struct {
+		Text     string    `json:"text"`
+		Entities *Entities `json:"entities"`
+	} `json:"note_tweet"`
+}
+
+// Types of referenced tweets
+const (
+	ReferenceRetweeted = "retweeted"
+	ReferenceQuoted    = "quoted"
+	ReferenceRepliedTo = "replied_to"
+)
+
+type ReferencedTweet struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+type Entities struct {
+	URLs     []URLEntity     `json:"urls"`
+	Mentions []MentionEntity `json:"mentions"`
+	Hashtags []HashtagEntity `json:"hashtags"`
+}
+
+type URLEntity struct {
+	Start       int    `json:"start"`
+	End         int    `json:"end"`
+	URL         string `json:"url"`
+	ExpandedURL string `json:"expanded_url"`
+	DisplayURL  string `json:"display_url"`
+	// MediaKey is set if the link points to attached media
+	MediaKey string `json:"media_key"`
+}
+
+type MentionEntity struct {
+	Start    int    `json:"start"`
+	End      int    `json:"end"`
+	Username string `json:"username"`
+	ID       string `json:"id"`
+}
+
+type HashtagEntity struct {
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	Tag   string `json:"tag"`
+}
+
+type User struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Username    string `json:"username"`
+	Description string `json:"description"`
+	Protected   bool   `json:"protected"`
+	Verified    bool   `json:"verified"`
+
+	PublicMetrics struct {
+		FollowersCount int `json:"followers_count"`
+		FollowingCount int `json:"following_count"`
+		TweetCount     int `json:"tweet_count"`
+	} `json:"public_metrics"`
+}
+
+type Media struct {
+	MediaKey        string `json:"media_key"`
+	Type            string `json:"type"`
+	URL             string `json:"url"`
+	PreviewImageURL string `json:"preview_image_url"`
+}
+
+type Place struct {
+	ID          string `json:"id"`
+	FullName    string `json:"full_name"`
+	Name        string `json:"name"`
+	CountryCode string `json:"country_code"`
+	PlaceType   string `json:"place_type"`
+}
+
+type List struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	OwnerID string `json:"owner_id"`
+	Private bool   `json:"private"`
+}
+
+// Includes contains the objects requested using expansions
+type Includes struct {
+	Users  []User  `json:"users"`
+	Tweets []Tweet `json:"tweets"`
+	Media  []Media `json:"media"`
+	Places []Place `json:"places"`
+}
+
+type Meta struct {
+	NewestID    string `json:"newest_id"`
+	OldestID    string `json:"oldest_id"`
+	ResultCount int    `json:"result_count"`
+	NextToken   string `json:"next_token"`
+}
+
+// Error is returned for failed requests and for problems reported in otherwise successful responses
+type Error struct {
+	StatusCode int    `json:"status"`
+	Title      string `json:"title"`
+	Detail     string `json:"detail"`
+	Type       string `json:"type"`
+
+	// Reset is when the rate limit resets, it is set for all responses with rate limit headers
+	Reset time.Time `json:"-"`
+}
+
+func (e *Error) Error() string {
+	if e.Detail != "" {
+		return fmt.Sprintf("twitter v2: %s (%d): %s", e.Title, e.StatusCode, e.Detail)
+	}
+	return fmt.Sprintf("twitter v2: %s (%d)", e.Title, e.StatusCode)
+}
+
+// ProblemNotFound is the type of problems for tweets, users etc. that don't exist
+const ProblemNotFound = "https://api.twitter.com/2/problems/resource-not-found"
+
+// ProblemNotAuthorized is the type of problems for tweets of protected users and users that blocked us
+const ProblemNotAuthorized = "https://api.twitter.com/2/problems/not-authorized-for-resource"
+
+// tweetsResponse is returned by all endpoints that return one or more tweets
+type tweetsResponse struct {
+	Data     []Tweet  `json:"data"`
+	Includes Includes `json:"includes"`
+	Meta     Meta     `json:"meta"`
+	Errors   []Error  `json:"errors"`
+}
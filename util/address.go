@@ -0,0 +1,70 @@
+package util
+
+import (
+	"net"
+)
+
+// blockedNetworks are address ranges that are not reachable on the public internet, see
+// https://www.iana.org/assignments/iana-ipv4-special-registry and https://www.iana.org/assignments/iana-ipv6-special-registry
+var blockedNetworks = mustParseCIDRs(
+	"0.0.0.0/8",       // "This network"
+	"10.0.0.0/8",      // Private
+	"100.64.0.0/10",   // Carrier-grade NAT
+	"127.0.0.0/8",     // Loopback
+	"169.254.0.0/16",  // Link-local, including cloud metadata services
+	"172.16.0.0/12",   // Private
+	"192.0.0.0/24",    // IETF protocol assignments
+	"192.0.2.0/24",    // Documentation
+	"192.168.0.0/16",  // Private
+	"198.18.0.0/15",   // Benchmarking
+	"198.51.100.0/24", // Documentation
+	"203.0.113.0/24",  // Documentation
+	"224.0.0.0/4",     // Multicast
+	"240.0.0.0/4",     // Reserved, including broadcast
+	"::/128",          // Unspecified
+	"::1/128",         // Loopback
+	"64:ff9b::/96",    // IPv4/IPv6 translation, could be used to reach IPv4 addresses from above
+	"100::/64",        // Discard
+	"2001:db8::/32",   // Documentation
+	"fc00::/7",        // Unique local
+	"fe80::/10",       // Link-local
+	"ff00::/8",        // Multicast
+)
+
+func mustParseCIDRs(cidrs ...string) (nets []*net.IPNet) {
+	for _, c := range cidrs {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			panic("parsing CIDR " + c + ": " + err.Error())
+		}
+		nets = append(nets, n)
+	}
+	return
+}
+
+// isPublicIP returns whether ip is a public unicast address
+func isPublicIP(ip net.IP) bool {
+	// IPv4-mapped IPv6 addresses like ::ffff:127.0.0.1 are checked as IPv4 addresses
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+
+	for _, n := range blockedNetworks {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isPublicAddress returns whether the "host:port" address contains a public IP address
+func isPublicAddress(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && isPublicIP(ip)
+}
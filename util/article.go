@@ -2,6 +2,7 @@ package util
 
 import (
 	"bufio"
+	"fmt"
 	"math/rand"
 	"net/http"
 	"time"
@@ -16,9 +17,51 @@ var (
 	urlRegex = xurls.Strict()
 )
 
+// How long canonical URLs are cached. Failed lookups are cached for a shorter time, the site might just be down
+const (
+	canonicalURLCacheTTL       = 6 * time.Hour
+	failedCanonicalURLCacheTTL = 10 * time.Minute
+)
+
 // FindCanonicalURL returns the canonical URL of an article if possible,
 // else the original input is returned
 func FindCanonicalURL(url string, secondTry bool) (out string) {
+	// On the second try refresh URLs are not followed, so the result can differ from the first try
+	var key = "canonical:" + url
+	if secondTry {
+		key = "canonical-second-try:" + url
+	}
+
+	v, err := DefaultFetcher.Cached(key, func() (interface{}, time.Duration, error) {
+		canonical, err := findCanonicalURL(url, secondTry)
+		if err != nil {
+			return canonical, failedCanonicalURLCacheTTL, nil
+		}
+		return canonical, canonicalURLCacheTTL, nil
+	})
+
+	out, ok := v.(string)
+	if err != nil || !ok {
+		return url
+	}
+	return out
+}
+
+func findCanonicalURL(url string, secondTry bool) (out string, err error) {
+	out, refreshURL, err := fetchCanonicalURL(url)
+
+	// The request for the refresh URL is made after the response body was closed, that way we don't need two
+	// request slots of the fetcher at the same time
+	if refreshURL != "" && refreshURL != url && !secondTry {
+		return FindCanonicalURL(refreshURL, true), nil
+	}
+
+	return
+}
+
+// fetchCanonicalURL returns the canonical URL of the page at url. If the page has no canonical URL but redirects
+// using a <meta http-equiv="refresh"> tag, refreshURL is the URL it redirects to
+func fetchCanonicalURL(url string) (out, refreshURL string, err error) {
 	// These must be declared within the method because chains are stateful (and thus we need a new one every time). See #2 for more
 	var (
 		noScriptEnter = replace.String("<noscript>", "")
@@ -29,32 +72,17 @@ func FindCanonicalURL(url string, secondTry bool) (out string) {
 
 	out = url
 
-	client := http.Client{
-		Timeout: 30 * time.Second,
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			// If we were redirected, we now get a different URL
-			out = req.URL.String()
-			return nil
-		},
-	}
-
-	req, err := http.NewRequest(http.MethodGet, url, nil)
-	if err != nil {
-		return
-	}
-
-	req.Header.Set("User-Agent", GetUserAgent())
-	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
-	req.Header.Set("Accept-Language", "en-US;q=0.7,en;q=0.3")
-
-	resp, err := client.Do(req)
+	resp, err := DefaultFetcher.Get(url)
 	if err != nil {
 		return
 	}
 	defer resp.Body.Close()
 
+	// If we were redirected, we now have a different URL
+	out = resp.Request.URL.String()
+
 	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusBadRequest {
-		return
+		return out, "", fmt.Errorf("unexpected status %s", resp.Status)
 	}
 
 	// t.co doesn't use real redirects. And the <meta http-equiv="refresh" tag is inside a <noscript>
@@ -70,15 +98,12 @@ func FindCanonicalURL(url string, secondTry bool) (out string) {
 
 	canon := doc.Find("[rel=canonical]").First()
 	if canon.Length() != 0 {
-		return canon.AttrOr("href", url)
+		return canon.AttrOr("href", url), "", nil
 	}
 
 	equiv := doc.Find("[http-equiv]").First()
 	if equiv.Length() != 0 {
-		u := urlRegex.FindString(equiv.AttrOr("content", ""))
-		if u != "" && u != url && !secondTry {
-			return FindCanonicalURL(u, true)
-		}
+		refreshURL = urlRegex.FindString(equiv.AttrOr("content", ""))
 	}
 
 	return
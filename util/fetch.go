@@ -0,0 +1,363 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+var (
+	// ErrResponseTooLarge is returned when reading a response body that is larger than the fetcher allows
+	ErrResponseTooLarge = errors.New("response body too large")
+	// ErrBlockedAddress is returned when a request would connect to a private, loopback or otherwise internal address
+	ErrBlockedAddress = errors.New("address is not allowed")
+	// ErrTooManyRedirects is returned when a request is redirected more often than the fetcher allows
+	ErrTooManyRedirects = errors.New("too many redirects")
+	// ErrContentType is returned when a response has a content type the fetcher doesn't accept
+	ErrContentType = errors.New("content type is not allowed")
+	// ErrCachedCallPanicked is returned to callers of Cached that waited for a call that panicked
+	ErrCachedCallPanicked = errors.New("cached call panicked")
+)
+
+// FetcherOptions configure a Fetcher. Zero values are replaced by defaults
+type FetcherOptions struct {
+	// MaxConcurrent is the maximum number of requests that run at the same time
+	MaxConcurrent int
+	// MaxBodySize is the maximum number of bytes that are read from a response body
+	MaxBodySize int64
+	// Timeout is the timeout for a whole request, including reading the body
+	Timeout time.Duration
+
+	// MaxRedirects is the maximum number of redirects that are followed
+	MaxRedirects int
+	// ContentTypes are the media types a response may have, e.g. "text/html". Responses without a
+	// Content-Type header are accepted
+	ContentTypes []string
+
+	// AllowPrivateNetworks allows requests to private and loopback addresses, which are blocked by default because
+	// URLs from tweets could otherwise make us access internal services. It should only be used in tests
+	AllowPrivateNetworks bool
+
+	// CacheSize is the maximum number of cached results
+	CacheSize int
+}
+
+const (
+	defaultMaxConcurrentFetches = 8
+	defaultMaxBodySize          = 5 << 20
+	defaultFetchTimeout         = 30 * time.Second
+	defaultFetchCacheSize       = 2000
+	defaultMaxRedirects         = 5
+)
+
+var defaultContentTypes = []string{"text/html", "application/xhtml+xml"}
+
+// Fetcher is the shared way of making requests to websites linked in tweets. It limits how many requests run at once
+// and how much of a response is read, and it caches results so the same URL is not requested over and over again.
+// It is safe for concurrent use
+type Fetcher struct {
+	// Accessed atomically, so they are at the start of the struct to be 64-bit aligned
+	requests, inFlight, coalesced, tooLarge int64
+
+	client       *http.Client
+	sem          chan struct{}
+	maxBodySize  int64
+	contentTypes map[string]bool
+
+	// allowAddress decides whether we may connect to a resolved address
+	allowAddress func(address string) bool
+
+	cache *LRUCache
+
+	flightsMu sync.Mutex
+	flights   map[string]*flight
+}
+
+// flight is a call to Cached that is currently running, other callers with the same key wait for its result
+type flight struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+type cachedResult struct {
+	value interface{}
+	err   error
+}
+
+// DefaultFetcher is used by FindCanonicalURL and the scrapers
+var DefaultFetcher = NewFetcher(FetcherOptions{})
+
+// NewFetcher returns a fetcher with the given options
+func NewFetcher(opts FetcherOptions) *Fetcher {
+	if opts.MaxConcurrent <= 0 {
+		opts.MaxConcurrent = defaultMaxConcurrentFetches
+	}
+	if opts.MaxBodySize <= 0 {
+		opts.MaxBodySize = defaultMaxBodySize
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultFetchTimeout
+	}
+	if opts.CacheSize <= 0 {
+		opts.CacheSize = defaultFetchCacheSize
+	}
+
+	if opts.MaxRedirects <= 0 {
+		opts.MaxRedirects = defaultMaxRedirects
+	}
+	if len(opts.ContentTypes) == 0 {
+		opts.ContentTypes = defaultContentTypes
+	}
+
+	f := &Fetcher{
+		sem:          make(chan struct{}, opts.MaxConcurrent),
+		maxBodySize:  opts.MaxBodySize,
+		contentTypes: make(map[string]bool),
+		allowAddress: isPublicAddress,
+		cache:        NewLRUCache(opts.CacheSize),
+		flights:      make(map[string]*flight),
+	}
+	if opts.AllowPrivateNetworks {
+		f.allowAddress = func(string) bool { return true }
+	}
+	for _, ct := range opts.ContentTypes {
+		f.contentTypes[strings.ToLower(ct)] = true
+	}
+
+	// Addresses are checked after DNS resolution, right before connecting. That way redirects and
+	// DNS entries that point to internal addresses are caught
+	dialer := &net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			if !f.allowAddress(address) {
+				return fmt.Errorf("connecting to %s: %w", address, ErrBlockedAddress)
+			}
+			return nil
+		},
+	}
+
+	f.client = &http.Client{
+		Timeout: opts.Timeout,
+		Transport: &http.Transport{
+			// No proxy, we would only check the address of the proxy
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          100,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > opts.MaxRedirects {
+				return fmt.Errorf("following redirect to %s: %w", req.URL.String(), ErrTooManyRedirects)
+			}
+			return checkScheme(req.URL)
+		},
+	}
+
+	return f
+}
+
+// Do sends the request once there's a free slot. The slot is held until the response body is closed,
+// reading more than the maximum body size returns ErrResponseTooLarge
+func (f *Fetcher) Do(req *http.Request) (resp *http.Response, err error) {
+	err = checkScheme(req.URL)
+	if err != nil {
+		return
+	}
+
+	select {
+	case f.sem <- struct{}{}:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+
+	atomic.AddInt64(&f.requests, 1)
+	atomic.AddInt64(&f.inFlight, 1)
+
+	var release = func() {
+		atomic.AddInt64(&f.inFlight, -1)
+		<-f.sem
+	}
+
+	resp, err = f.client.Do(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	if !f.allowedContentType(resp.Header.Get("Content-Type")) {
+		resp.Body.Close()
+		release()
+		return nil, fmt.Errorf("fetching %s: %w (%s)", resp.Request.URL.String(), ErrContentType, resp.Header.Get("Content-Type"))
+	}
+
+	if resp.ContentLength > f.maxBodySize {
+		resp.Body.Close()
+		release()
+		atomic.AddInt64(&f.tooLarge, 1)
+		return nil, fmt.Errorf("fetching %s: %w (%d bytes)", req.URL.String(), ErrResponseTooLarge, resp.ContentLength)
+	}
+
+	resp.Body = &limitedBody{
+		body:      resp.Body,
+		remaining: f.maxBodySize,
+		release:   release,
+		tooLarge:  &f.tooLarge,
+	}
+
+	return resp, nil
+}
+
+func (f *Fetcher) allowedContentType(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return f.contentTypes[strings.ToLower(mediaType)]
+}
+
+func checkScheme(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("fetching %s: unsupported scheme %q", u.String(), u.Scheme)
+	}
+	return nil
+}
+
+// Get is like Do with a GET request that has browser-like headers
+func (f *Fetcher) Get(url string) (resp *http.Response, err error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return
+	}
+
+	req.Header.Set("User-Agent", GetUserAgent())
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+	req.Header.Set("Accept-Language", "en-US;q=0.7,en;q=0.3")
+
+	return f.Do(req)
+}
+
+// Cached returns the cached result for key. If there is none, fn is called and its result is cached for the TTL it returns,
+// a TTL of zero means that the result is not cached. Concurrent calls with the same key share one call of fn
+func (f *Fetcher) Cached(key string, fn func() (value interface{}, ttl time.Duration, err error)) (interface{}, error) {
+	if v, ok := f.cache.Get(key); ok {
+		res := v.(cachedResult)
+		return res.value, res.err
+	}
+
+	f.flightsMu.Lock()
+	if fl, ok := f.flights[key]; ok {
+		f.flightsMu.Unlock()
+		atomic.AddInt64(&f.coalesced, 1)
+
+		<-fl.done
+		return fl.value, fl.err
+	}
+
+	fl := &flight{done: make(chan struct{})}
+	f.flights[key] = fl
+	f.flightsMu.Unlock()
+
+	// Even if fn panics, waiting callers must be released and later callers must not wait for this flight
+	var finished bool
+	defer func() {
+		if !finished {
+			fl.value, fl.err = nil, ErrCachedCallPanicked
+		}
+
+		f.flightsMu.Lock()
+		delete(f.flights, key)
+		f.flightsMu.Unlock()
+		close(fl.done)
+	}()
+
+	var ttl time.Duration
+	fl.value, ttl, fl.err = fn()
+	finished = true
+
+	if ttl > 0 {
+		f.cache.Add(key, cachedResult{value: fl.value, err: fl.err}, ttl)
+	}
+
+	return fl.value, fl.err
+}
+
+// FetcherStats contains metrics about requests and the cache of a Fetcher
+type FetcherStats struct {
+	Requests  int64 `json:"requests"`
+	InFlight  int64 `json:"in_flight"`
+	Coalesced int64 `json:"coalesced"`
+	TooLarge  int64 `json:"too_large"`
+
+	CacheSize   int    `json:"cache_size"`
+	CacheHits   uint64 `json:"cache_hits"`
+	CacheMisses uint64 `json:"cache_misses"`
+}
+
+// Stats returns metrics about this fetcher
+func (f *Fetcher) Stats() (s FetcherStats) {
+	s.Requests = atomic.LoadInt64(&f.requests)
+	s.InFlight = atomic.LoadInt64(&f.inFlight)
+	s.Coalesced = atomic.LoadInt64(&f.coalesced)
+	s.TooLarge = atomic.LoadInt64(&f.tooLarge)
+	s.CacheSize, s.CacheHits, s.CacheMisses = f.cache.Stats()
+	return
+}
+
+// limitedBody is a response body that returns ErrResponseTooLarge after reading too much and releases
+// the concurrency slot of its request when it is closed
+type limitedBody struct {
+	body      io.ReadCloser
+	remaining int64
+	release   func()
+	once      sync.Once
+	tooLarge  *int64
+	exceeded  bool
+}
+
+func (l *limitedBody) Read(p []byte) (n int, err error) {
+	if l.remaining <= 0 {
+		// Check whether there's more, the body might be exactly as large as the limit
+		var b [1]byte
+		n, err = l.body.Read(b[:])
+		if n > 0 || l.exceeded {
+			if !l.exceeded {
+				atomic.AddInt64(l.tooLarge, 1)
+				l.exceeded = true
+			}
+			return 0, ErrResponseTooLarge
+		}
+		return 0, err
+	}
+
+	if int64(len(p)) > l.remaining {
+		p = p[:l.remaining]
+	}
+	n, err = l.body.Read(p)
+	l.remaining -= int64(n)
+	return
+}
+
+func (l *limitedBody) Close() error {
+	err := l.body.Close()
+	l.once.Do(l.release)
+	return err
+}
@@ -0,0 +1,373 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLRUCache(t *testing.T) {
+	c := NewLRUCache(2)
+
+	c.Add("a", 1, time.Hour)
+	c.Add("b", 2, time.Hour)
+	// Using a makes b the least recently used entry
+	if v, ok := c.Get("a"); !ok || v.(int) != 1 {
+		t.Fatalf("expected a to be cached")
+	}
+	c.Add("c", 3, time.Hour)
+
+	if _, ok := c.Get("b"); ok {
+		t.Errorf("expected b to be evicted")
+	}
+	if _, ok := c.Get("c"); !ok {
+		t.Errorf("expected c to be cached")
+	}
+
+	// This evicts a, and is removed once we notice it has expired
+	c.Add("expired", 4, -time.Second)
+	if _, ok := c.Get("expired"); ok {
+		t.Errorf("expected expired entry not to be returned")
+	}
+
+	size, hits, misses := c.Stats()
+	if size != 1 || hits != 2 || misses != 2 {
+		t.Errorf("unexpected stats: size %d, hits %d, misses %d", size, hits, misses)
+	}
+}
+
+func TestFetcherCached(t *testing.T) {
+	f := NewFetcher(FetcherOptions{})
+
+	var (
+		calls   int32
+		release = make(chan struct{})
+		wg      sync.WaitGroup
+	)
+	var fn = func() (interface{}, time.Duration, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "result", time.Hour, nil
+	}
+
+	// Identical concurrent lookups share one call
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := f.Cached("key", fn)
+			if err != nil || v.(string) != "result" {
+				t.Errorf("unexpected result %v, %v", v, err)
+			}
+		}()
+	}
+
+	// Wait until all goroutines are waiting for the first call
+	for f.Stats().Coalesced < 4 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	// Now the result is cached
+	if v, _ := f.Cached("key", fn); v.(string) != "result" || atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("expected fn to be called once, but it was called %d times", calls)
+	}
+
+	// Errors are returned, and not cached with a TTL of zero
+	errFailed := errors.New("failed")
+	for i := 0; i < 2; i++ {
+		_, err := f.Cached("failing", func() (interface{}, time.Duration, error) {
+			atomic.AddInt32(&calls, 1)
+			return nil, 0, errFailed
+		})
+		if !errors.Is(err, errFailed) {
+			t.Errorf("expected error, got %v", err)
+		}
+	}
+	if atomic.LoadInt32(&calls) != 3 {
+		t.Errorf("expected uncached function to be called twice, but got %d calls in total", calls)
+	}
+
+	if s := f.Stats(); s.CacheHits != 1 || s.Coalesced != 4 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
+
+func TestFetcherCachedPanic(t *testing.T) {
+	f := NewFetcher(FetcherOptions{})
+
+	var (
+		started = make(chan struct{})
+		release = make(chan struct{})
+		waiter  = make(chan error)
+	)
+
+	go func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("expected the panic to reach the caller")
+			}
+		}()
+
+		_, _ = f.Cached("key", func() (interface{}, time.Duration, error) {
+			close(started)
+			<-release
+			panic("oops")
+		})
+	}()
+	<-started
+
+	go func() {
+		_, err := f.Cached("key", func() (interface{}, time.Duration, error) {
+			return "not called", time.Hour, nil
+		})
+		waiter <- err
+	}()
+
+	// Wait until the second caller waits for the first call
+	for f.Stats().Coalesced < 1 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+
+	if err := <-waiter; !errors.Is(err, ErrCachedCallPanicked) {
+		t.Errorf("expected waiting caller to get ErrCachedCallPanicked, got %v", err)
+	}
+
+	// Later callers make a new call instead of waiting forever
+	if v, err := f.Cached("key", func() (interface{}, time.Duration, error) {
+		return "result", time.Hour, nil
+	}); err != nil || v.(string) != "result" {
+		t.Errorf("unexpected result %v, %v", v, err)
+	}
+}
+
+func TestFetcherConcurrencyLimit(t *testing.T) {
+	var current, max int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&current, 1)
+		defer atomic.AddInt32(&current, -1)
+
+		for {
+			m := atomic.LoadInt32(&max)
+			if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+				break
+			}
+		}
+
+		time.Sleep(20 * time.Millisecond)
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	f := NewFetcher(FetcherOptions{MaxConcurrent: 2, AllowPrivateNetworks: true})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			resp, err := f.Get(srv.URL)
+			if err != nil {
+				t.Errorf("Get: %s", err.Error())
+				return
+			}
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+
+	if max > 2 {
+		t.Errorf("expected at most 2 concurrent requests, but got %d", max)
+	}
+	if s := f.Stats(); s.Requests != 6 || s.InFlight != 0 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
+
+func TestFetcherBodySize(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+
+		var body = strings.Repeat("a", 100)
+		if r.URL.Path == "/chunked" {
+			// Without Content-Length, the limit is only noticed while reading
+			w.(http.Flusher).Flush()
+		} else if r.URL.Path == "/exact" {
+			body = body[:10]
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	f := NewFetcher(FetcherOptions{MaxBodySize: 10, AllowPrivateNetworks: true})
+
+	if _, err := f.Get(srv.URL + "/large"); !errors.Is(err, ErrResponseTooLarge) {
+		t.Errorf("expected ErrResponseTooLarge for response with large Content-Length, got %v", err)
+	}
+
+	resp, err := f.Get(srv.URL + "/chunked")
+	if err != nil {
+		t.Fatalf("Get: %s", err.Error())
+	}
+	_, err = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !errors.Is(err, ErrResponseTooLarge) {
+		t.Errorf("expected ErrResponseTooLarge while reading, got %v", err)
+	}
+
+	resp, err = f.Get(srv.URL + "/exact")
+	if err != nil {
+		t.Fatalf("Get: %s", err.Error())
+	}
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil || len(data) != 10 {
+		t.Errorf("expected body with exactly the maximum size to be read, got %d bytes and error %v", len(data), err)
+	}
+
+	if s := f.Stats(); s.TooLarge != 2 || s.InFlight != 0 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
+
+func TestIsPublicIP(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"1.1.1.1", true},
+		{"140.82.121.4", true},
+		{"2606:4700:4700::1111", true},
+
+		{"127.0.0.1", false},
+		{"127.1.2.3", false},
+		{"0.0.0.0", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"172.31.255.255", false},
+		{"192.168.178.1", false},
+		{"100.64.0.1", false},
+		{"169.254.169.254", false},
+		{"224.0.0.1", false},
+		{"255.255.255.255", false},
+		{"::1", false},
+		{"::", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:169.254.169.254", false},
+		{"fd00:ec2::254", false},
+		{"fe80::1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			if got := isPublicIP(net.ParseIP(tt.ip)); got != tt.want {
+				t.Errorf("isPublicIP(%s) = %v, want %v", tt.ip, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetcherBlocksPrivateAddresses(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+	}))
+	defer srv.Close()
+
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+
+	f := NewFetcher(FetcherOptions{})
+	for _, u := range []string{
+		srv.URL,
+		"http://localhost:" + port,
+		"http://[::ffff:127.0.0.1]:" + port,
+		"http://169.254.169.254/latest/meta-data/",
+		"http://10.0.0.1/",
+	} {
+		_, err := f.Get(u)
+		if !errors.Is(err, ErrBlockedAddress) {
+			t.Errorf("expected request to %s to be blocked, got %v", u, err)
+		}
+	}
+
+	if _, err := f.Get("file:///etc/passwd"); err == nil {
+		t.Errorf("expected file URL to be rejected")
+	}
+
+	if n := atomic.LoadInt32(&requests); n != 0 {
+		t.Errorf("expected no request to reach the server, but got %d", n)
+	}
+}
+
+func TestFetcherRedirects(t *testing.T) {
+	var internalRequests int32
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&internalRequests, 1)
+	}))
+	defer internal.Close()
+
+	var public *httptest.Server
+	public = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/internal":
+			http.Redirect(w, r, internal.URL, http.StatusFound)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		case "/chain":
+			http.Redirect(w, r, "/page", http.StatusFound)
+		case "/file":
+			http.Redirect(w, r, "file:///etc/passwd", http.StatusFound)
+		case "/image":
+			w.Header().Set("Content-Type", "image/png")
+		default:
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprint(w, "<html></html>")
+		}
+	}))
+	defer public.Close()
+
+	f := NewFetcher(FetcherOptions{})
+	// Both servers run on localhost, so here only the internal one is treated as an internal address
+	f.allowAddress = func(address string) bool {
+		return address == public.Listener.Addr().String()
+	}
+
+	resp, err := f.Get(public.URL + "/chain")
+	if err != nil {
+		t.Fatalf("expected redirect within public server to work, got %s", err.Error())
+	}
+	resp.Body.Close()
+	if resp.Request.URL.Path != "/page" {
+		t.Errorf("expected to end up at /page, but got %s", resp.Request.URL.Path)
+	}
+
+	if _, err = f.Get(public.URL + "/internal"); !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("expected redirect to internal address to be blocked, got %v", err)
+	}
+	if n := atomic.LoadInt32(&internalRequests); n != 0 {
+		t.Errorf("expected no request to reach the internal server, but got %d", n)
+	}
+
+	if _, err = f.Get(public.URL + "/loop"); !errors.Is(err, ErrTooManyRedirects) {
+		t.Errorf("expected redirect loop to fail with ErrTooManyRedirects, got %v", err)
+	}
+	if _, err = f.Get(public.URL + "/file"); err == nil {
+		t.Errorf("expected redirect to file URL to fail")
+	}
+
+	if _, err = f.Get(public.URL + "/image"); !errors.Is(err, ErrContentType) {
+		t.Errorf("expected image to be rejected, got %v", err)
+	}
+}
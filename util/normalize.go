@@ -0,0 +1,177 @@
+package util
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	// hostPrefixes are subdomains that usually serve the same content as the main domain
+	hostPrefixes = []string{"www.", "m.", "mobile.", "amp."}
+
+	// trackingParameters are query parameters that don't change the content of a page
+	trackingParameters = map[string]bool{
+		"fbclid":     true,
+		"gclid":      true,
+		"dclid":      true,
+		"msclkid":    true,
+		"yclid":      true,
+		"igshid":     true,
+		"mc_cid":     true,
+		"mc_eid":     true,
+		"_ga":        true,
+		"ref_src":    true,
+		"ref_url":    true,
+		"cmpid":      true,
+		"ocid":       true,
+		"smid":       true,
+		"amp":        true,
+		"outputtype": true,
+	}
+	// trackingParameterPrefixes are prefixes of tracking parameters, e.g. utm_source, utm_medium etc.
+	trackingParameterPrefixes = []string{"utm_", "at_", "__twitter"}
+
+	// twitterShareParameters are added when sharing a tweet, e.g. "?s=20"
+	twitterShareParameters = map[string]bool{
+		"s": true,
+		"t": true,
+	}
+
+	youTubeIDRegex = regexp.MustCompile(`^[A-Za-z0-9_-]{11}$`)
+)
+
+// NormalizeURL returns a normalized version of an URL without making any requests. It can be used to detect that two URLs
+// point to the same page, e.g. because one of them has tracking parameters, is the mobile or AMP version or is a youtu.be link.
+// If the input is not an absolute http(s) URL, it is returned unchanged
+func NormalizeURL(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Host == "" {
+		return raw
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return raw
+	}
+
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		port = ""
+	}
+
+	// AMP cache URLs contain the original URL in their path, e.g.
+	// https://www-example-com.cdn.ampproject.org/c/s/www.example.com/article
+	if strings.HasSuffix(host, ".cdn.ampproject.org") {
+		if orig, ok := ampCacheOrigin(u.Path); ok {
+			u.Scheme, host, port, u.Path = "https", orig.host, "", orig.path
+		}
+	}
+
+	for _, prefix := range hostPrefixes {
+		// Only strip the prefix if there's still a domain left, e.g. "m.me" stays the same
+		if strings.HasPrefix(host, prefix) && strings.Contains(host[len(prefix):], ".") {
+			host = host[len(prefix):]
+			break
+		}
+	}
+
+	u.Host = host
+	if port != "" {
+		u.Host += ":" + port
+	}
+	u.User = nil
+	u.Fragment = ""
+	u.RawFragment = ""
+
+	if id, ok := youTubeVideoID(host, u); ok {
+		return "https://youtube.com/watch?v=" + id
+	}
+
+	u.Path = normalizePath(u.Path)
+	u.RawPath = ""
+
+	var query = u.Query()
+	for key := range query {
+		lk := strings.ToLower(key)
+		if trackingParameters[lk] || hasAnyPrefix(lk, trackingParameterPrefixes) ||
+			(host == "twitter.com" && twitterShareParameters[lk]) {
+			query.Del(key)
+		}
+	}
+	// Encode sorts parameters, that way their order doesn't matter
+	u.RawQuery = query.Encode()
+	u.ForceQuery = false
+
+	return u.String()
+}
+
+// normalizePath removes AMP path variants and trailing slashes
+func normalizePath(p string) string {
+	switch {
+	case strings.HasPrefix(p, "/amp/"):
+		p = p[len("/amp"):]
+	case strings.HasSuffix(p, "/amp") || strings.HasSuffix(p, "/amp/"):
+		p = strings.TrimSuffix(strings.TrimSuffix(p, "/"), "/amp")
+	case strings.HasSuffix(p, ".amp.html"):
+		p = strings.TrimSuffix(p, ".amp.html") + ".html"
+	case strings.HasSuffix(p, ".amp"):
+		p = strings.TrimSuffix(p, ".amp")
+	}
+
+	return strings.TrimRight(p, "/")
+}
+
+type hostPath struct {
+	host, path string
+}
+
+// ampCacheOrigin extracts the origin host and path from the path of an AMP cache URL
+func ampCacheOrigin(p string) (orig hostPath, ok bool) {
+	// Content is served from /c/, images from /i/ and viewer pages from /v/. The optional "s/" means https
+	parts := strings.SplitN(strings.TrimPrefix(p, "/"), "/", 3)
+	if len(parts) < 2 || (parts[0] != "c" && parts[0] != "v" && parts[0] != "i") {
+		return
+	}
+
+	rest := strings.Join(parts[1:], "/")
+	rest = strings.TrimPrefix(rest, "s/")
+
+	slash := strings.IndexByte(rest, '/')
+	if slash < 0 {
+		return hostPath{host: rest}, rest != ""
+	}
+	return hostPath{host: rest[:slash], path: rest[slash:]}, slash > 0
+}
+
+// youTubeVideoID returns the video ID of watch, youtu.be, shorts, live and embed links
+func youTubeVideoID(host string, u *url.URL) (id string, ok bool) {
+	var segments = strings.Split(strings.Trim(u.Path, "/"), "/")
+
+	switch host {
+	case "youtu.be":
+		id = segments[0]
+	case "youtube.com", "music.youtube.com", "youtube-nocookie.com":
+		switch {
+		case segments[0] == "watch":
+			id = u.Query().Get("v")
+		case len(segments) == 2 && (segments[0] == "shorts" || segments[0] == "live" ||
+			segments[0] == "embed" || segments[0] == "v"):
+			id = segments[1]
+		}
+	default:
+		return "", false
+	}
+
+	return id, youTubeIDRegex.MatchString(id)
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
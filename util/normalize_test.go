@@ -0,0 +1,61 @@
+package util
+
+import "testing"
+
+func TestNormalizeURL(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		// Not URLs we can normalize
+		{"Twitter dot com", "Twitter dot com"},
+		{"mailto:someone@example.com", "mailto:someone@example.com"},
+		{"/relative/path", "/relative/path"},
+
+		// Scheme and host casing, default ports, fragments and trailing slashes
+		{"HTTPS://Example.COM/Article", "https://example.com/Article"},
+		{"https://example.com:443/article/", "https://example.com/article"},
+		{"http://example.com:80/", "http://example.com"},
+		{"https://example.com:8080/a", "https://example.com:8080/a"},
+		{"https://example.com/article#comments", "https://example.com/article"},
+
+		// www, mobile and AMP variants
+		{"https://www.nasaspaceflight.com/2022/07/starship-update/", "https://nasaspaceflight.com/2022/07/starship-update"},
+		{"https://m.example.com/article", "https://example.com/article"},
+		{"https://mobile.twitter.com/SpaceX/status/1", "https://twitter.com/SpaceX/status/1"},
+		{"https://amp.example.com/article", "https://example.com/article"},
+		{"https://m.me/someone", "https://m.me/someone"},
+		{"https://example.com/article/amp/", "https://example.com/article"},
+		{"https://example.com/amp/article", "https://example.com/article"},
+		{"https://example.com/article.amp.html", "https://example.com/article.html"},
+		{"https://example.com/article?amp=1", "https://example.com/article"},
+		{"https://www-example-com.cdn.ampproject.org/c/s/www.example.com/article/amp", "https://example.com/article"},
+
+		// Tracking parameters
+		{"https://example.com/article?utm_source=twitter&utm_medium=social", "https://example.com/article"},
+		{"https://example.com/article?UTM_Campaign=x&id=5", "https://example.com/article?id=5"},
+		{"https://example.com/article?fbclid=abc&gclid=def&igshid=ghi", "https://example.com/article"},
+		{"https://example.com/search?q=starship&fbclid=abc", "https://example.com/search?q=starship"},
+		{"https://example.com/?b=2&a=1", "https://example.com?a=1&b=2"},
+		{"https://twitter.com/SpaceX/status/1?s=20&t=abcdef", "https://twitter.com/SpaceX/status/1"},
+		{"https://example.com/page?s=20", "https://example.com/page?s=20"},
+
+		// YouTube
+		{"https://www.youtube.com/watch?v=mhJRzQsLZGg", "https://youtube.com/watch?v=mhJRzQsLZGg"},
+		{"https://youtube.com/watch?feature=share&v=mhJRzQsLZGg&t=42", "https://youtube.com/watch?v=mhJRzQsLZGg"},
+		{"https://m.youtube.com/watch?v=mhJRzQsLZGg", "https://youtube.com/watch?v=mhJRzQsLZGg"},
+		{"https://youtu.be/mhJRzQsLZGg?si=abcdef", "https://youtube.com/watch?v=mhJRzQsLZGg"},
+		{"https://youtube.com/shorts/mhJRzQsLZGg?feature=share", "https://youtube.com/watch?v=mhJRzQsLZGg"},
+		{"https://www.youtube.com/live/mhJRzQsLZGg?feature=share", "https://youtube.com/watch?v=mhJRzQsLZGg"},
+		{"https://www.youtube-nocookie.com/embed/mhJRzQsLZGg", "https://youtube.com/watch?v=mhJRzQsLZGg"},
+		{"https://www.youtube.com/c/NASASpaceflight/live", "https://youtube.com/c/NASASpaceflight/live"},
+		{"https://www.youtube.com/watch?v=tooshort", "https://youtube.com/watch?v=tooshort"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			if got := NormalizeURL(tt.in); got != tt.want {
+				t.Errorf("NormalizeURL(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}